@@ -0,0 +1,481 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/ShowBaba/kagewallet/helpers"
+	log "github.com/ShowBaba/kagewallet/logging"
+	"github.com/ShowBaba/kagewallet/repositories"
+	"github.com/ShowBaba/kagewallet/services"
+	tgApi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// startConvert lists the crypto the user holds so they can pick what to
+// convert.
+func startConvert(chatID int64, user *database.User) error {
+	if err := accountService.Guard(user, "convert"); err != nil {
+		return sendAccountFrozen(chatID, user)
+	}
+	balances, err := conversionService.GetConvertibleBalances(user.ID)
+	if err != nil {
+		log.Error("error fetching convertible balances", zap.Error(err))
+		return sendErrorMessage(chatID)
+	}
+	if len(balances) == 0 {
+		return Telegram.SendUserMessage(TelegramMessage{
+			Text:      "😕 *You have no crypto to convert.*\n\n🔹 Deposits are sold for Naira while auto-convert is on. Use /auto\\_convert to keep the crypto you deposit.",
+			User:      chatID,
+			ParseMode: "Markdown",
+		})
+	}
+
+	if _, err := startSession(chatID, StateConvertFrom); err != nil {
+		log.Error("error starting session", zap.Error(err))
+		return sendErrorMessage(chatID)
+	}
+
+	var buttons [][]tgApi.InlineKeyboardButton
+	for _, balance := range balances {
+		buttons = append(buttons, []tgApi.InlineKeyboardButton{{
+			Text:         fmt.Sprintf("%s — %s", formatAssetName(balance.Symbol, balance.Standard), balance.Balance),
+			CallbackData: helpers.StrPtr("convert_from:" + balance.AssetID.String()),
+		}})
+	}
+	buttons = append(buttons, []tgApi.InlineKeyboardButton{{Text: "Cancel", CallbackData: helpers.StrPtr("cancel_convert")}})
+
+	text := "🔄 *Convert Crypto*\n\n🔹 Select the crypto you want to convert."
+	if fee := feeText(common.FeeOperationConvert, uuid.Nil, user, decimal.Decimal.String); fee != "" {
+		text += fmt.Sprintf("\n\n⚠️ *A conversion fee of %s applies.*", fee)
+	}
+	return Telegram.SendUserMessage(TelegramMessage{
+		Text:        text,
+		User:        chatID,
+		ParseMode:   "Markdown",
+		ReplyMarkup: tgApi.InlineKeyboardMarkup{InlineKeyboard: buttons},
+	})
+}
+
+// sendAutoConvertSetting shows whether the user's deposits are sold for Naira
+// or kept, with a button to switch.
+func sendAutoConvertSetting(chatID int64, user *database.User) error {
+	text, replyMarkup := autoConvertSetting(user)
+	return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chatID, ParseMode: "Markdown", ReplyMarkup: replyMarkup})
+}
+
+func autoConvertSetting(user *database.User) (string, tgApi.InlineKeyboardMarkup) {
+	var (
+		text   string
+		button tgApi.InlineKeyboardButton
+	)
+	if user.AutoConvert {
+		text = "🔄 *Auto-Convert: On*\n\n🔹 Crypto you deposit is sold for Naira at the current rate as soon as it arrives."
+		button = tgApi.InlineKeyboardButton{Text: "Keep my crypto", CallbackData: helpers.StrPtr("auto_convert:off")}
+	} else {
+		text = "🔄 *Auto-Convert: Off*\n\n🔹 Crypto you deposit is kept in your wallet. Use /convert to swap it or turn auto-convert on to sell it for Naira."
+		button = tgApi.InlineKeyboardButton{Text: "Sell deposits for Naira", CallbackData: helpers.StrPtr("auto_convert:on")}
+	}
+	return text, tgApi.InlineKeyboardMarkup{InlineKeyboard: [][]tgApi.InlineKeyboardButton{{button}}}
+}
+
+// handleConvertCallback handles the buttons of the /convert flow. It reports
+// false for callbacks that belong to something else.
+func handleConvertCallback(callbackQuery *tgApi.CallbackQuery) (bool, error) {
+	var (
+		data   = callbackQuery.Data
+		chatID = callbackQuery.Message.Chat.ID
+	)
+
+	switch {
+	case strings.HasPrefix(data, "convert_from:"):
+		session, err := loadSession(chatID)
+		if err != nil {
+			log.Error("error loading session", zap.Error(err))
+			return true, sendErrorMessage(chatID)
+		}
+		if !session.In(StateConvertFrom) {
+			return true, sendSessionExpired(callbackQuery)
+		}
+		fromAssetID, err := uuid.Parse(strings.TrimPrefix(data, "convert_from:"))
+		if err != nil {
+			return true, sendSessionExpired(callbackQuery)
+		}
+
+		targets, err := conversionService.GetTargetAssets(fromAssetID)
+		if err != nil {
+			log.Error("error fetching conversion targets", zap.Error(err))
+			return true, sendErrorMessage(chatID)
+		}
+		if len(targets) == 0 {
+			_ = session.Clear()
+			return true, Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
+				CallbackQueryID: callbackQuery.ID,
+				Text:            "There is nothing to convert this crypto to right now. Please try again later.",
+				ShowAlert:       true,
+			})
+		}
+
+		session.Data[sessionAssetID] = fromAssetID.String()
+		if err := session.Transition(StateConvertTo); err != nil {
+			log.Error("error updating session", zap.Error(err))
+			return true, sendErrorMessage(chatID)
+		}
+
+		var buttons [][]tgApi.InlineKeyboardButton
+		for _, target := range targets {
+			buttons = append(buttons, []tgApi.InlineKeyboardButton{{
+				Text:         formatAssetName(target.Symbol, target.Standard),
+				CallbackData: helpers.StrPtr("convert_to:" + target.AssetID.String()),
+			}})
+		}
+		buttons = append(buttons, []tgApi.InlineKeyboardButton{{Text: "Cancel", CallbackData: helpers.StrPtr("cancel_convert")}})
+		replyMarkup := tgApi.InlineKeyboardMarkup{InlineKeyboard: buttons}
+
+		err = Telegram.EditMessage(TelegramMessageEdit{
+			ChatID:      chatID,
+			MessageID:   callbackQuery.Message.MessageID,
+			NewText:     "🔄 *Convert Crypto*\n\n🔹 Select the crypto you want to receive.",
+			ReplyMarkup: &replyMarkup,
+			ParseMode:   "Markdown",
+		})
+		if err != nil {
+			log.Error("error editing message with conversion targets", zap.Error(err))
+		}
+		return true, Telegram.SendCallbackResponse(common.TelegramCallbackResponse{CallbackQueryID: callbackQuery.ID})
+	case strings.HasPrefix(data, "convert_to:"):
+		session, err := loadSession(chatID)
+		if err != nil {
+			log.Error("error loading session", zap.Error(err))
+			return true, sendErrorMessage(chatID)
+		}
+		if !session.In(StateConvertTo) {
+			return true, sendSessionExpired(callbackQuery)
+		}
+		toAssetID, err := uuid.Parse(strings.TrimPrefix(data, "convert_to:"))
+		if err != nil {
+			return true, sendSessionExpired(callbackQuery)
+		}
+		user, err := telegramRepo.FindUserByTelegramID(int(callbackQuery.From.ID))
+		if err != nil {
+			log.Error("error fetching user by telegram id", zap.Error(err))
+			return true, sendErrorMessage(chatID)
+		}
+		fromAssetID := uuid.MustParse(session.Data[sessionAssetID])
+		wallet, err := walletRepo.GetWalletByUserAndAsset(user.ID, fromAssetID)
+		if err != nil {
+			log.Error("error fetching wallet", zap.Error(err))
+			return true, sendErrorMessage(chatID)
+		}
+		fromAsset, err := assetRepo.FindAssetByID(fromAssetID.String())
+		if err != nil {
+			log.Error("error fetching asset data", zap.Error(err))
+			return true, sendErrorMessage(chatID)
+		}
+
+		session.Data[sessionToAssetID] = toAssetID.String()
+		if err := session.Transition(StateConvertAmount); err != nil {
+			log.Error("error updating session", zap.Error(err))
+			return true, sendErrorMessage(chatID)
+		}
+
+		err = Telegram.EditMessage(TelegramMessageEdit{
+			ChatID:    chatID,
+			MessageID: callbackQuery.Message.MessageID,
+			NewText: fmt.Sprintf("💰 *Your %s Balance:* `%s`\n\n🔹 Enter the amount of %s you want to convert.",
+				formatAssetName(fromAsset.Symbol, fromAsset.Standard), wallet.Balance, strings.ToUpper(fromAsset.Symbol)),
+			ParseMode: "Markdown",
+		})
+		if err != nil {
+			log.Error("error editing message with conversion amount prompt", zap.Error(err))
+		}
+		return true, Telegram.SendCallbackResponse(common.TelegramCallbackResponse{CallbackQueryID: callbackQuery.ID})
+	case data == "confirm_convert":
+		session, err := loadSession(chatID)
+		if err != nil {
+			log.Error("error loading session", zap.Error(err))
+			return true, sendErrorMessage(chatID)
+		}
+		if !session.In(StateConvertConfirm, StateConvertPassword) {
+			return true, sendSessionExpired(callbackQuery)
+		}
+		if err := session.Transition(StateConvertPassword); err != nil {
+			log.Error("error updating session", zap.Error(err))
+			return true, sendErrorMessage(chatID)
+		}
+		return true, Telegram.SendUserMessage(TelegramMessage{
+			Text:      "*🔒 To keep your account secure, please enter your password to continue:*\n\n*⏳ Your session will expire in 60 seconds if not completed.*",
+			User:      chatID,
+			ParseMode: "markdown",
+		})
+	case strings.HasPrefix(data, "auto_convert:"):
+		user, err := telegramRepo.FindUserByTelegramID(int(callbackQuery.From.ID))
+		if err != nil {
+			log.Error("error fetching user by telegram id", zap.Error(err))
+			return true, sendErrorMessage(chatID)
+		}
+		autoConvert := strings.TrimPrefix(data, "auto_convert:") == "on"
+		if err := userRepo.UpdateField(user.ID, "auto_convert", autoConvert); err != nil {
+			log.Error("error updating auto convert setting", zap.Error(err))
+			return true, sendErrorMessage(chatID)
+		}
+		user.AutoConvert = autoConvert
+
+		text, replyMarkup := autoConvertSetting(user)
+		err = Telegram.EditMessage(TelegramMessageEdit{
+			ChatID:      chatID,
+			MessageID:   callbackQuery.Message.MessageID,
+			NewText:     text,
+			ReplyMarkup: &replyMarkup,
+			ParseMode:   "Markdown",
+		})
+		if err != nil {
+			log.Error("error editing auto convert message", zap.Error(err))
+		}
+		return true, Telegram.SendCallbackResponse(common.TelegramCallbackResponse{CallbackQueryID: callbackQuery.ID, Text: "Saved ✅"})
+	case data == "cancel_convert":
+		session := &Session{ChatID: chatID}
+		if err := session.Clear(); err != nil {
+			log.Error("error clearing session", zap.Error(err))
+		}
+		return true, Telegram.SendUserMessage(TelegramMessage{
+			Text:      "***Conversion cancelled***",
+			User:      chatID,
+			ParseMode: "markdown",
+		})
+	}
+	return false, nil
+}
+
+// handleConvertAmount prices the amount the user typed and asks them to
+// confirm the preview.
+func handleConvertAmount(chatID int64, user *database.User, session *Session, text string) error {
+	var (
+		fromAssetID = uuid.MustParse(session.Data[sessionAssetID])
+		toAssetID   = uuid.MustParse(session.Data[sessionToAssetID])
+	)
+	fromAsset, err := assetRepo.FindAssetByID(fromAssetID.String())
+	if err != nil {
+		log.Error("error fetching asset data", zap.Error(err))
+		return sendErrorMessage(chatID)
+	}
+	amount, err := validateAssetAmount(text, fromAsset)
+	if err != nil {
+		return Telegram.SendUserMessage(TelegramMessage{Text: err.Error(), User: chatID})
+	}
+
+	wallet, err := walletRepo.GetWalletByUserAndAsset(user.ID, fromAssetID)
+	if err != nil {
+		log.Error("error fetching wallet", zap.Error(err))
+		return sendErrorMessage(chatID)
+	}
+	if amount.GreaterThan(wallet.Balance) {
+		return Telegram.SendUserMessage(TelegramMessage{
+			Text: fmt.Sprintf("🚨 *Insufficient Balance!* 🚨\n\n💰 *Your %s Balance:* `%s`\n\n🔹 Enter a smaller amount or send /cancel to stop.",
+				formatAssetName(fromAsset.Symbol, fromAsset.Standard), wallet.Balance),
+			User:      chatID,
+			ParseMode: "Markdown",
+		})
+	}
+
+	quote, err := conversionService.Quote(user, fromAssetID, toAssetID, amount)
+	if err != nil {
+		return sendConversionError(chatID, session, err)
+	}
+	return sendConversionPreview(chatID, session, quote)
+}
+
+// sendConversionPreview saves the quote in the session and shows it with
+// the confirm and cancel buttons.
+func sendConversionPreview(chatID int64, session *Session, quote *services.ConversionQuote) error {
+	session.Data[sessionAmount] = quote.Amount.String()
+	session.Data[sessionFromRateID] = quote.FromRateID.String()
+	session.Data[sessionToRateID] = quote.ToRateID.String()
+	session.Data[sessionFee] = quote.Fee.Amount.String()
+	if err := session.Transition(StateConvertConfirm); err != nil {
+		log.Error("error updating session", zap.Error(err))
+		return sendErrorMessage(chatID)
+	}
+
+	var (
+		from = strings.ToUpper(quote.FromAsset.Symbol)
+		to   = strings.ToUpper(quote.ToAsset.Symbol)
+		m    strings.Builder
+	)
+	m.WriteString("🔄 *Conversion Preview*\n")
+	m.WriteString("  ━━━━━━━━━━━━━━  \n")
+	m.WriteString(fmt.Sprintf("📤 *You Convert:* `%s %s`\n", quote.Amount, from))
+	m.WriteString(feeLine("Conversion fee", quote.Fee, func(fee decimal.Decimal) string {
+		return fmt.Sprintf("`%s %s`", fee, from)
+	}))
+	m.WriteString(fmt.Sprintf("💹 *Rate:* `1 %s = %s %s`\n", from, quote.Rate.Truncate(assetPlaces(quote.ToAsset)), to))
+	m.WriteString(fmt.Sprintf("📥 *You Receive:* `%s %s`\n", quote.Receive, to))
+	m.WriteString("  ━━━━━━━━━━━━━━  \n\n")
+	m.WriteString("🔹 Confirm to convert at this rate.")
+
+	buttons := [][]tgApi.InlineKeyboardButton{
+		{
+			{Text: "Confirm", CallbackData: helpers.StrPtr("confirm_convert")},
+		},
+		{
+			{Text: "Cancel", CallbackData: helpers.StrPtr("cancel_convert")},
+		},
+	}
+	return Telegram.SendUserMessage(TelegramMessage{
+		Text:        m.String(),
+		User:        chatID,
+		ParseMode:   "Markdown",
+		ReplyMarkup: tgApi.InlineKeyboardMarkup{InlineKeyboard: buttons},
+	})
+}
+
+// handleConvertPassword checks the user's password and carries out the
+// conversion they confirmed.
+func handleConvertPassword(chatID int64, user *database.User, session *Session, text string) error {
+	passwordMatch, err := authService.ConfirmPassword(user.ID.String(), text)
+	if err != nil {
+		log.Error("error validating password", zap.Error(err))
+		return sendErrorMessage(chatID)
+	}
+	if !passwordMatch {
+		if err := session.Transition(StateConvertConfirm); err != nil {
+			log.Error("error updating session", zap.Error(err))
+			return sendErrorMessage(chatID)
+		}
+		buttons := [][]tgApi.InlineKeyboardButton{
+			{
+				{Text: "Retry", CallbackData: helpers.StrPtr("confirm_convert")},
+			},
+			{
+				{Text: "Cancel", CallbackData: helpers.StrPtr("cancel_convert")},
+			},
+		}
+		return Telegram.SendUserMessage(TelegramMessage{
+			Text:        "🚫 *Invalid Password!* 🚫\n\n🔹 Please double-check and try again.",
+			User:        chatID,
+			ParseMode:   "Markdown",
+			ReplyMarkup: tgApi.InlineKeyboardMarkup{InlineKeyboard: buttons},
+		})
+	}
+
+	preview, err := sessionConversionPreview(session)
+	if err != nil {
+		log.Error("error reading conversion from session", zap.Error(err))
+		return sendErrorMessage(chatID)
+	}
+	// Clear the session before converting so a second reply cannot convert
+	// the same funds again.
+	if err := session.Clear(); err != nil {
+		log.Error("error clearing session", zap.Error(err))
+		return sendErrorMessage(chatID)
+	}
+	if err := accountService.Guard(user, "convert"); err != nil {
+		return sendAccountFrozen(chatID, user)
+	}
+
+	transaction, quote, err := conversionService.Convert(user, preview)
+	if err != nil {
+		return sendConversionError(chatID, session, err)
+	}
+
+	var (
+		from = strings.ToUpper(quote.FromAsset.Symbol)
+		to   = strings.ToUpper(quote.ToAsset.Symbol)
+		m    strings.Builder
+	)
+	m.WriteString("✅ *Conversion Successful!* ✅\n")
+	m.WriteString("  ━━━━━━━━━━━━━━  \n")
+	m.WriteString(fmt.Sprintf("🆔 *Reference:* `%s`\n", transaction.Reference))
+	m.WriteString(fmt.Sprintf("📤 *Converted:* `%s %s`\n", quote.Amount, from))
+	m.WriteString(fmt.Sprintf("💸 *Fee:* `%s %s`\n", quote.Fee.Amount, from))
+	m.WriteString(fmt.Sprintf("💹 *Rate:* `1 %s = %s %s`\n", from, quote.Rate.Truncate(assetPlaces(quote.ToAsset)), to))
+	m.WriteString(fmt.Sprintf("📥 *Received:* `%s %s`\n", quote.Receive, to))
+	m.WriteString(fmt.Sprintf("📅 *Date:* %s\n", transaction.CreatedAt.Format("02 Jan 2006, 03:04 PM")))
+	m.WriteString("  ━━━━━━━━━━━━━━  \n\n")
+	m.WriteString("🔍 Use /balance to check your updated balances.")
+	return Telegram.SendUserMessage(TelegramMessage{Text: m.String(), User: chatID, ParseMode: "Markdown"})
+}
+
+func sendConversionError(chatID int64, session *Session, err error) error {
+	var text string
+	switch {
+	case errors.Is(err, services.ErrConversionRateChanged):
+		text = "⏳ *Rates or fees have changed.*\n\n🔹 Nothing was converted. Send /convert to see the new price."
+	case errors.Is(err, services.ErrConversionTooSmall):
+		return Telegram.SendUserMessage(TelegramMessage{
+			Text:      "🚫 *Amount too small.*\n\n🔹 After the fee it converts to nothing. Enter a larger amount or send /cancel to stop.",
+			User:      chatID,
+			ParseMode: "Markdown",
+		})
+	case errors.Is(err, services.ErrConversionUnavailable), errors.Is(err, services.ErrConversionSameAsset):
+		text = "⏸️ *This conversion is not available right now.*\n\n🔹 Please try again in a few minutes."
+	case errors.Is(err, repositories.ErrInsufficientBalance):
+		text = "🚫 *Insufficient Balance!* 🚫\n\n🔹 Your balance no longer covers this conversion. Use /balance to check it."
+	default:
+		log.Error("error converting", zap.Error(err))
+		_ = session.Clear()
+		return sendErrorMessage(chatID)
+	}
+	if err := session.Clear(); err != nil {
+		log.Error("error clearing session", zap.Error(err))
+	}
+	return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chatID, ParseMode: "Markdown"})
+}
+
+func sessionConversionPreview(session *Session) (services.ConversionPreview, error) {
+	var (
+		preview services.ConversionPreview
+		err     error
+	)
+	if preview.FromAssetID, err = uuid.Parse(session.Data[sessionAssetID]); err != nil {
+		return preview, err
+	}
+	if preview.ToAssetID, err = uuid.Parse(session.Data[sessionToAssetID]); err != nil {
+		return preview, err
+	}
+	if preview.Amount, err = decimal.NewFromString(session.Data[sessionAmount]); err != nil {
+		return preview, err
+	}
+	if preview.FromRateID, err = uuid.Parse(session.Data[sessionFromRateID]); err != nil {
+		return preview, err
+	}
+	if preview.ToRateID, err = uuid.Parse(session.Data[sessionToRateID]); err != nil {
+		return preview, err
+	}
+	if preview.Fee, err = decimal.NewFromString(session.Data[sessionFee]); err != nil {
+		return preview, err
+	}
+	return preview, nil
+}
+
+// validateAssetAmount reads a crypto amount with no more decimal places than
+// the asset has.
+func validateAssetAmount(input string, asset *database.Asset) (decimal.Decimal, error) {
+	input = strings.ReplaceAll(strings.TrimSpace(input), ",", "")
+	if input == "" {
+		return decimal.Zero, errors.New("input cannot be empty")
+	}
+	amount, err := decimal.NewFromString(input)
+	if err != nil {
+		return decimal.Zero, errors.New("invalid amount, please enter a valid number")
+	}
+	if !amount.IsPositive() {
+		return decimal.Zero, errors.New("amount must be greater than zero")
+	}
+	if places := assetPlaces(asset); !amount.Equal(amount.Truncate(places)) {
+		return decimal.Zero, fmt.Errorf("amount cannot have more than %d decimal places", places)
+	}
+	return amount, nil
+}
+
+func assetPlaces(asset *database.Asset) int32 {
+	if asset.Decimals > 0 {
+		return int32(asset.Decimals)
+	}
+	return 8
+}
@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/ShowBaba/kagewallet/helpers"
+	log "github.com/ShowBaba/kagewallet/logging"
+	"github.com/ShowBaba/kagewallet/services"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func nairaAmount(amount decimal.Decimal) string {
+	return "₦" + helpers.CommaDecimal(amount)
+}
+
+func assetAmount(symbol string) func(decimal.Decimal) string {
+	return func(amount decimal.Decimal) string {
+		return fmt.Sprintf("%s %s", amount, strings.ToUpper(symbol))
+	}
+}
+
+// feeText describes the fee a user of the tier pays for an operation on the
+// asset, such as "₦100" or "0.5% (min ₦50)". It is empty when the operation
+// is free or its schedule cannot be read.
+func feeText(operation string, assetID uuid.UUID, user *database.User, format func(decimal.Decimal) string) string {
+	schedule, err := feeService.ActiveSchedule(operation, assetID, user.Tier)
+	if err != nil {
+		log.Error("error fetching fee schedule", zap.String("operation", operation), zap.Error(err))
+		return ""
+	}
+	return describeFeeSchedule(schedule, format)
+}
+
+func describeFeeSchedule(schedule *database.FeeSchedule, format func(decimal.Decimal) string) string {
+	if schedule == nil {
+		return ""
+	}
+	var text string
+	if schedule.Kind == common.FeeKindTiered {
+		var bands []string
+		for i, band := range schedule.Bands {
+			rate := describeRate(band.Flat, band.RateBPS, format)
+			switch {
+			case !band.UpTo.IsZero():
+				bands = append(bands, fmt.Sprintf("%s up to %s", rate, format(band.UpTo)))
+			case i > 0:
+				bands = append(bands, fmt.Sprintf("%s above %s", rate, format(schedule.Bands[i-1].UpTo)))
+			default:
+				bands = append(bands, rate)
+			}
+		}
+		text = strings.Join(bands, ", ")
+	} else {
+		text = describeRate(schedule.Flat, schedule.RateBPS, format)
+	}
+
+	var caps []string
+	if schedule.MinFee.IsPositive() {
+		caps = append(caps, "min "+format(schedule.MinFee))
+	}
+	if schedule.MaxFee.IsPositive() {
+		caps = append(caps, "max "+format(schedule.MaxFee))
+	}
+	if len(caps) > 0 {
+		text += fmt.Sprintf(" (%s)", strings.Join(caps, ", "))
+	}
+	if text == "" || text == format(decimal.Zero) {
+		return ""
+	}
+	return text
+}
+
+func describeRate(flat decimal.Decimal, bps int64, format func(decimal.Decimal) string) string {
+	percent := decimal.New(bps, -2).String() + "%"
+	switch {
+	case bps == 0:
+		return format(flat)
+	case flat.IsZero():
+		return percent
+	}
+	return fmt.Sprintf("%s + %s", format(flat), percent)
+}
+
+// feeLine is one line of a fee breakdown, naming how the fee was worked out
+// when it was not flat.
+func feeLine(name string, fee services.Fee, format func(decimal.Decimal) string) string {
+	if label := fee.Label(); label != "" {
+		name = fmt.Sprintf("%s (%s)", name, label)
+	}
+	return fmt.Sprintf("🧾 *%s:* %s\n", name, format(fee.Amount))
+}
@@ -0,0 +1,299 @@
+package bot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/redis/go-redis/v9"
+)
+
+// State names a step of a conversation. A chat is in exactly one state at a
+// time; free text and buttons are only acted on when they belong to it.
+type State string
+
+const (
+	StateIdle                  State = "idle"
+	StatePasswordSetup         State = "password_setup"
+	StateEmailSetup            State = "email_setup"
+	StateResetPasswordCode     State = "reset_password_code"
+	StateResetPasswordNew      State = "reset_password_new"
+	StateUnlockPassword        State = "unlock_password"
+	StateUnlockCode            State = "unlock_code"
+	StateSellAsset             State = "sell_asset"
+	StateSellConfirm           State = "sell_confirm"
+	StateWithdrawAmount        State = "withdraw_amount"
+	StateWithdrawBank          State = "withdraw_bank"
+	StateWithdrawBankSearch    State = "withdraw_bank_search"
+	StateWithdrawAccountNumber State = "withdraw_account_number"
+	StateWithdrawConfirm       State = "withdraw_confirm"
+	StateWithdrawPassword      State = "withdraw_password"
+
+	StateBeneficiaryBank          State = "beneficiary_bank"
+	StateBeneficiaryBankSearch    State = "beneficiary_bank_search"
+	StateBeneficiaryAccountNumber State = "beneficiary_account_number"
+	StateBeneficiaryNickname      State = "beneficiary_nickname"
+	StateBeneficiaryPassword      State = "beneficiary_password"
+
+	StateConvertFrom     State = "convert_from"
+	StateConvertTo       State = "convert_to"
+	StateConvertAmount   State = "convert_amount"
+	StateConvertConfirm  State = "convert_confirm"
+	StateConvertPassword State = "convert_password"
+)
+
+// Keys of Session.Data.
+const (
+	sessionAssetID       = "asset_id"
+	sessionAmount        = "amount"
+	sessionBankCode      = "bank_code"
+	sessionAccountNumber = "account_number"
+	// sessionWithdrawAll marks a withdrawal of the whole balance. The
+	// balance the user saw is kept in sessionAmount and the withdrawal is
+	// refused if it changes before they confirm.
+	sessionWithdrawAll = "withdraw_all"
+	sessionNickname    = "nickname"
+	// A conversion keeps the rates and fee it was previewed at so it can be
+	// refused if they change before the user confirms.
+	sessionToAssetID  = "to_asset_id"
+	sessionFromRateID = "from_rate_id"
+	sessionToRateID   = "to_rate_id"
+	sessionFee        = "fee"
+)
+
+// sessionGrace keeps an expired session around long enough to tell the user
+// it timed out instead of silently ignoring their reply.
+const sessionGrace = time.Hour
+
+var ErrInvalidTransition = errors.New("invalid session transition")
+
+type stateConfig struct {
+	// Timeout is how long the chat may stay in the state without a reply.
+	Timeout time.Duration
+	// Next lists the states reachable from this one. Going back to idle is
+	// always allowed.
+	Next []State
+	// Prompt is sent when the user types text in a state that waits for a
+	// button.
+	Prompt string
+}
+
+var states = map[State]stateConfig{
+	StateIdle: {
+		Next: []State{StatePasswordSetup, StateResetPasswordCode, StateUnlockPassword, StateSellAsset, StateWithdrawAmount, StateWithdrawBank, StateBeneficiaryBank, StateConvertFrom},
+	},
+	StatePasswordSetup: {
+		Timeout: 10 * time.Minute,
+		Next:    []State{StateEmailSetup},
+	},
+	StateEmailSetup: {
+		Timeout: 10 * time.Minute,
+	},
+	StateResetPasswordCode: {
+		Timeout: 10 * time.Minute,
+		Next:    []State{StateResetPasswordNew},
+	},
+	StateResetPasswordNew: {
+		Timeout: 10 * time.Minute,
+	},
+	StateUnlockPassword: {
+		Timeout: 2 * time.Minute,
+		Next:    []State{StateUnlockCode},
+	},
+	StateUnlockCode: {
+		Timeout: 10 * time.Minute,
+	},
+	StateSellAsset: {
+		Timeout: 5 * time.Minute,
+		Next:    []State{StateSellConfirm},
+		Prompt:  "Please select a crypto from the list above, or send /cancel to stop.",
+	},
+	StateSellConfirm: {
+		Timeout: 5 * time.Minute,
+		Prompt:  "Please confirm or cancel your selection above, or send /cancel to stop.",
+	},
+	StateWithdrawAmount: {
+		Timeout: 5 * time.Minute,
+		Next:    []State{StateWithdrawBank},
+	},
+	StateWithdrawBank: {
+		Timeout: 5 * time.Minute,
+		Next:    []State{StateWithdrawBankSearch, StateWithdrawAccountNumber, StateWithdrawConfirm},
+		Prompt:  "Please select your bank from the list above or tap *Search Bank 🔍*.",
+	},
+	StateWithdrawBankSearch: {
+		Timeout: 5 * time.Minute,
+		Next:    []State{StateWithdrawBank, StateWithdrawAccountNumber, StateWithdrawConfirm},
+	},
+	StateWithdrawAccountNumber: {
+		Timeout: 5 * time.Minute,
+		Next:    []State{StateWithdrawConfirm},
+	},
+	StateWithdrawConfirm: {
+		Timeout: 5 * time.Minute,
+		Next:    []State{StateWithdrawPassword},
+		Prompt:  "Please confirm or cancel the withdrawal above.",
+	},
+	StateWithdrawPassword: {
+		Timeout: 60 * time.Second,
+		Next:    []State{StateWithdrawConfirm},
+	},
+	StateBeneficiaryBank: {
+		Timeout: 5 * time.Minute,
+		Next:    []State{StateBeneficiaryBankSearch, StateBeneficiaryAccountNumber},
+		Prompt:  "Please select the bank of the account from the list above or tap *Search Bank 🔍*.",
+	},
+	StateBeneficiaryBankSearch: {
+		Timeout: 5 * time.Minute,
+		Next:    []State{StateBeneficiaryBank, StateBeneficiaryAccountNumber},
+	},
+	StateBeneficiaryAccountNumber: {
+		Timeout: 5 * time.Minute,
+		Next:    []State{StateBeneficiaryNickname},
+	},
+	StateBeneficiaryNickname: {
+		Timeout: 5 * time.Minute,
+		Next:    []State{StateBeneficiaryPassword},
+	},
+	StateBeneficiaryPassword: {
+		Timeout: 60 * time.Second,
+	},
+	StateConvertFrom: {
+		Timeout: 5 * time.Minute,
+		Next:    []State{StateConvertTo},
+		Prompt:  "Please select the crypto to convert from the list above, or send /cancel to stop.",
+	},
+	StateConvertTo: {
+		Timeout: 5 * time.Minute,
+		Next:    []State{StateConvertAmount},
+		Prompt:  "Please select the crypto to receive from the list above, or send /cancel to stop.",
+	},
+	StateConvertAmount: {
+		Timeout: 5 * time.Minute,
+		Next:    []State{StateConvertConfirm},
+	},
+	StateConvertConfirm: {
+		Timeout: 2 * time.Minute,
+		Next:    []State{StateConvertPassword},
+		Prompt:  "Please confirm or cancel the conversion above.",
+	},
+	StateConvertPassword: {
+		Timeout: 60 * time.Second,
+		Next:    []State{StateConvertConfirm},
+	},
+}
+
+// Session is the conversation state of one chat, stored in Redis as a single
+// JSON document.
+type Session struct {
+	ChatID    int64             `json:"chat_id"`
+	State     State             `json:"state"`
+	Data      map[string]string `json:"data"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+var chatLocks sync.Map
+
+// lockChat serialises updates of one chat so two of them never read and
+// write its session at the same time.
+func lockChat(chatID int64) func() {
+	value, _ := chatLocks.LoadOrStore(chatID, &sync.Mutex{})
+	lock := value.(*sync.Mutex)
+	lock.Lock()
+	return lock.Unlock
+}
+
+func loadSession(chatID int64) (*Session, error) {
+	raw, err := database.GetRedisKey(fmt.Sprintf(common.RedisSessionKey, chatID))
+	if errors.Is(err, redis.Nil) {
+		return &Session{ChatID: chatID, State: StateIdle, Data: map[string]string{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching session: %v", err)
+	}
+
+	var session Session
+	if err := json.Unmarshal([]byte(raw), &session); err != nil {
+		return nil, fmt.Errorf("error decoding session: %v", err)
+	}
+	if session.Data == nil {
+		session.Data = map[string]string{}
+	}
+	return &session, nil
+}
+
+// startSession begins a new flow, abandoning whatever the chat was doing.
+func startSession(chatID int64, state State) (*Session, error) {
+	session := &Session{ChatID: chatID, State: StateIdle, Data: map[string]string{}}
+	if err := session.Transition(state); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Expired reports whether the user left the current state unanswered for
+// longer than its timeout.
+func (s *Session) Expired() bool {
+	return s.State != StateIdle && time.Now().After(s.ExpiresAt)
+}
+
+// In reports whether the session is in one of the given states and has not
+// timed out.
+func (s *Session) In(candidates ...State) bool {
+	for _, state := range candidates {
+		if s.State == state && !s.Expired() {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition moves the session to the given state and saves it, restarting
+// the state's timeout. Moving to idle clears the session.
+func (s *Session) Transition(to State) error {
+	if to == StateIdle {
+		return s.Clear()
+	}
+	if to != s.State && !canTransition(s.State, to) {
+		return fmt.Errorf("%w from %s to %s", ErrInvalidTransition, s.State, to)
+	}
+
+	s.State = to
+	s.ExpiresAt = time.Now().Add(states[to].Timeout)
+	return s.save()
+}
+
+func (s *Session) Clear() error {
+	s.State = StateIdle
+	s.Data = map[string]string{}
+	s.ExpiresAt = time.Time{}
+	if err := database.DeleteRedisKey(fmt.Sprintf(common.RedisSessionKey, s.ChatID)); err != nil {
+		return fmt.Errorf("error clearing session: %v", err)
+	}
+	return nil
+}
+
+func (s *Session) save() error {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("error encoding session: %v", err)
+	}
+	ttl := time.Until(s.ExpiresAt) + sessionGrace
+	if err := database.SetRedisKey(fmt.Sprintf(common.RedisSessionKey, s.ChatID), string(raw), ttl); err != nil {
+		return fmt.Errorf("error saving session: %v", err)
+	}
+	return nil
+}
+
+func canTransition(from, to State) bool {
+	for _, next := range states[from].Next {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
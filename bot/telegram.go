@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
 	"io"
 	"net/http"
 	"regexp"
@@ -20,9 +21,9 @@ import (
 	"github.com/ShowBaba/kagewallet/repositories"
 	"github.com/ShowBaba/kagewallet/services"
 	"github.com/ShowBaba/kagewallet/tmpl"
-	"github.com/dustin/go-humanize"
 	tgApi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
@@ -46,13 +47,23 @@ const (
 	CommandTransaction        = "/transaction"
 	CommandTransactionHistory = "/transaction_history"
 	CommandWithdraw           = "/withdraw"
+	CommandWithdrawAll        = "/withdraw_all"
+	CommandBeneficiaries      = "/beneficiaries"
+	CommandLimits             = "/limits"
+	CommandConvert            = "/convert"
+	CommandAutoConvert        = "/auto_convert"
+	CommandCancel             = "/cancel"
+	CommandLockAccount        = "/lock_account"
+	CommandUnlockAccount      = "/unlock_account"
 )
 
 const (
-	fetchTransactionLimit = 2
+	fetchTransactionLimit = 5
 	fetchBankLimit        = 10
 )
 
+const sessionExpiredText = "⌛ *Your session has expired.* Please start again."
+
 var (
 	mu                 = &sync.RWMutex{}
 	userRepo           *repositories.UserRepository
@@ -69,8 +80,11 @@ var (
 	withdrawalRepo     *repositories.WithdrawalRepository
 	walletService      *services.WalletService
 	transactionService *services.TransactionService
-	monnifyService     *services.MonnifyService
 	withdrawalService  *services.WithdrawalService
+	accountService     *services.AccountService
+	beneficiaryService *services.BeneficiaryService
+	conversionService  *services.ConversionService
+	feeService         *services.FeeService
 	ctx, _             = context.WithCancel(context.Background())
 )
 
@@ -105,10 +119,18 @@ func NewTelegramBot(TOKEN string, db *gorm.DB) (*TelegramBot, error) {
 		{Command: CommandSetPassword, Description: "Set a new password for your account"},
 		{Command: CommandResetPassword, Description: "Reset your password if forgotten"},
 		{Command: CommandRefresh, Description: "Refresh your session and update data"},
+		{Command: CommandCancel, Description: "Cancel the operation in progress"},
 		{Command: CommandRate, Description: "Get the current exchange rate"},
 		{Command: CommandBalance, Description: "Check your balance for a specific asset"},
 		{Command: CommandTransactions, Description: "View your complete transaction history"},
 		{Command: CommandWithdraw, Description: "Withdraw funds to your bank account"},
+		{Command: CommandWithdrawAll, Description: "Withdraw your whole Naira balance in one step"},
+		{Command: CommandBeneficiaries, Description: "Manage the bank accounts you withdraw to"},
+		{Command: CommandLimits, Description: "See how much you can still withdraw"},
+		{Command: CommandConvert, Description: "Convert one crypto to another"},
+		{Command: CommandAutoConvert, Description: "Choose whether deposits are sold for Naira or kept"},
+		{Command: CommandLockAccount, Description: "Lock your account if you suspect unauthorized access"},
+		{Command: CommandUnlockAccount, Description: "Unlock your account with your password and an email code"},
 	}
 
 	setCommandsConfig := tgApi.NewSetMyCommands(commands...)
@@ -123,18 +145,33 @@ func NewTelegramBot(TOKEN string, db *gorm.DB) (*TelegramBot, error) {
 	telegramRepo = repositories.NewTelegramRepository(db)
 	telegramCmdLogRepo = repositories.NewTelegramCommandLogRepository(db)
 	rateRepo = repositories.NewRateRepository(db)
-	rateService = services.NewRateService(rateRepo)
+	rateService = services.NewRateService(rateRepo, repositories.NewQuoteRepository(db))
 	assetRepo = repositories.NewAssetRepository(db)
 	addressRepo = repositories.NewAddressRepository(db)
-	addressService = services.NewAddressService(userRepo, addressRepo, assetRepo)
-	authService = services.NewAuthService(userRepo)
+	addressService = services.NewAddressService(userRepo, addressRepo, assetRepo, services.NewCustodyServiceFromEnv(repositories.NewNetworkRepository(db)))
+	mailer, err := services.NewMailerFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	authService = services.NewAuthService(userRepo, mailer)
+	accountService = services.NewAccountService(userRepo, repositories.NewSecurityEventRepository(db))
 	walletRepo = repositories.NewWalletRepository(db)
-	walletService = services.NewWalletService(walletRepo, assetRepo)
+	walletService = services.NewWalletService(walletRepo, assetRepo, rateService)
 	transactionRepo = repositories.NewTransactionRepository(db)
 	withdrawalRepo = repositories.NewWithdrawalRepository(db)
-	transactionService = services.NewTransactionService(userRepo, transactionRepo)
-	monnifyService = services.NewMonnifyService()
-	withdrawalService = services.NewWithdrawalService(monnifyService, withdrawalRepo, walletRepo)
+	payouts, err := services.NewPayoutRouterFromEnv(services.NewMonnifyService())
+	if err != nil {
+		return nil, err
+	}
+	beneficiaryRepo := repositories.NewBeneficiaryRepository(db)
+	feeRepo := repositories.NewFeeRepository(db)
+	feeService = services.NewFeeService(feeRepo)
+	withdrawalService = services.NewWithdrawalService(payouts, withdrawalRepo, walletRepo, transactionRepo, userRepo, beneficiaryRepo, feeService, accountService)
+	beneficiaryService = services.NewBeneficiaryService(beneficiaryRepo, withdrawalService)
+	conversionRepo := repositories.NewConversionRepository(db)
+	conversionService = services.NewConversionService(rateService, assetRepo, walletRepo, conversionRepo, feeService)
+	transactionService = services.NewTransactionService(userRepo, transactionRepo, assetRepo, repositories.NewNetworkRepository(db),
+		rateRepo, withdrawalRepo, conversionRepo, feeRepo)
 	return &tBot, err
 }
 
@@ -160,9 +197,19 @@ func (tb *TelegramBot) SendUserMessage(message TelegramMessage) error {
 		mode = message.ParseMode
 	}
 
-	msg := tgApi.NewMessage(message.User, message.Text)
-	msg.ParseMode = mode
-	msg.ReplyMarkup = message.ReplyMarkup
+	var msg tgApi.Chattable
+	if message.File.Bytes != nil {
+		document := tgApi.NewDocument(message.User, message.File)
+		document.Caption = message.Text
+		document.ParseMode = mode
+		document.ReplyMarkup = message.ReplyMarkup
+		msg = document
+	} else {
+		text := tgApi.NewMessage(message.User, message.Text)
+		text.ParseMode = mode
+		text.ReplyMarkup = message.ReplyMarkup
+		msg = text
+	}
 
 	if _, err := tb.Api.Send(msg); err != nil {
 		log.Error("error sending message", zap.Error(err))
@@ -261,6 +308,9 @@ func handleUpdate(update tgApi.Update) {
 
 	switch {
 	case update.Message != nil:
+		unlock := lockChat(update.Message.Chat.ID)
+		defer unlock()
+
 		err := handleMessage(update.Message)
 		if err != nil {
 			err = sendErrorMessage(update.Message.Chat.ID)
@@ -270,9 +320,12 @@ func handleUpdate(update tgApi.Update) {
 			}
 		}
 	case update.CallbackQuery != nil:
+		unlock := lockChat(update.CallbackQuery.Message.Chat.ID)
+		defer unlock()
+
 		err := handleCallback(update.CallbackQuery)
 		if err != nil {
-			err = sendErrorMessage(update.Message.Chat.ID)
+			err = sendErrorMessage(update.CallbackQuery.Message.Chat.ID)
 			if err != nil {
 				log.Error("error sending error message", zap.Error(err))
 			}
@@ -309,6 +362,9 @@ func handleMessage(message *tgApi.Message) error {
 				log.Error("error fetching user by telegram id", zap.Error(err))
 				return sendErrorMessage(message.Chat.ID)
 			}
+			if err := accountService.Guard(user, "set_password"); err != nil {
+				return sendAccountFrozen(chat.ID, user)
+			}
 			hasSetPassword, err := userRepo.HasSetPassword(user.ID)
 			if err != nil {
 				log.Error("error checking if user has set password", zap.Error(err))
@@ -319,17 +375,100 @@ func handleMessage(message *tgApi.Message) error {
 				return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
 			}
 
-			err = database.SetRedisKey(fmt.Sprintf(common.RedisPasswordSetupKey, chat.ID), "true", 0)
+			if _, err := startSession(chat.ID, StatePasswordSetup); err != nil {
+				log.Error("error starting session", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+
+			text, _ := helpers.FormatHTML(nil, tmpl.PasswordPrompt)
+			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+		case CommandResetPassword:
+			user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
+			if err != nil {
+				log.Error("error fetching user by telegram id", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+			if err := accountService.Guard(user, "reset_password"); err != nil {
+				return sendAccountFrozen(chat.ID, user)
+			}
+			if user.PasswordHash == "" {
+				text := "You have not set a password yet. Use /set_password to create one."
+				return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+			}
+
+			email, err := authService.RequestPasswordReset(user.ID.String())
 			if err != nil {
-				log.Error("error setting redis key", zap.Error(err))
+				switch {
+				case errors.Is(err, services.ErrNoEmailOnFile):
+					text := "📭 <b>No email on file.</b>\n\nWe can only reset your password through a verified email address. Please contact support."
+					return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+				case errors.Is(err, services.ErrTooManyCodeRequests):
+					text := "⏳ <b>Too many reset requests.</b>\n\nPlease wait an hour before asking for another code."
+					return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+				}
+				log.Error("error requesting password reset", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
 
+			if _, err := startSession(chat.ID, StateResetPasswordCode); err != nil {
+				log.Error("error starting session", zap.Error(err))
+				return sendErrorMessage(chat.ID)
 			}
 
-			text, _ := helpers.FormatHTML(nil, tmpl.PasswordPrompt)
+			text := fmt.Sprintf("📧 We sent a 6-digit code to <b>%s</b>.\n\n"+
+				"Enter it here to continue. The code expires in %s. Send /cancel to stop.",
+				html.EscapeString(maskEmail(email)), services.OneTimeCodeTTL().Round(time.Minute))
 			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
-		case CommandRefresh:
-			err := database.DeleteRedisKeysByPattern(common.GenerateRedisDeleteKeyPattern(chat.ID))
+		case CommandLockAccount:
+			user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
+			if err != nil {
+				log.Error("error fetching user by telegram id", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+			err = accountService.Freeze(user.ID, "locked by the user from telegram", common.FrozenByUser)
+			if errors.Is(err, services.ErrAccountFrozen) {
+				return sendAccountFrozen(chat.ID, user)
+			}
+			if err != nil {
+				log.Error("error locking account", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+			if err := (&Session{ChatID: chat.ID}).Clear(); err != nil {
+				log.Error("error clearing session", zap.Error(err))
+			}
+
+			text := "🔒 <b>Your account is locked.</b>\n\n" +
+				"Withdrawals, new deposit addresses and password changes are blocked until you unlock it. " +
+				"Deposits to your existing addresses are still credited.\n\n" +
+				"Use /unlock_account when you are ready. You will need your password and a code sent to your email."
+			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+		case CommandUnlockAccount:
+			user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
 			if err != nil {
+				log.Error("error fetching user by telegram id", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+			if !user.IsFrozen {
+				return Telegram.SendUserMessage(TelegramMessage{Text: "Your account is not locked.", User: chat.ID})
+			}
+			if user.FrozenBy != common.FrozenByUser {
+				return sendAccountFrozen(chat.ID, user)
+			}
+			if user.PasswordHash == "" {
+				text := "Your account has no password, so it cannot be unlocked here. Please contact support."
+				return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+			}
+
+			if _, err := startSession(chat.ID, StateUnlockPassword); err != nil {
+				log.Error("error starting session", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+
+			text := "🔐 <b>Unlock your account</b>\n\nPlease enter your password. Send /cancel to stop."
+			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+		case CommandRefresh:
+			session := &Session{ChatID: chat.ID}
+			if err := session.Clear(); err != nil {
 				log.Error("error refreshing chat state", zap.Error(err))
 				text, _ := helpers.FormatHTML(nil, tmpl.RefreshChatFailed)
 				return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
@@ -337,28 +476,63 @@ func handleMessage(message *tgApi.Message) error {
 
 			text, _ := helpers.FormatHTML(nil, tmpl.RefreshChatSuccess)
 			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+		case CommandCancel:
+			session, err := loadSession(chat.ID)
+			if err != nil {
+				log.Error("error loading session", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+			if session.State == StateIdle {
+				return Telegram.SendUserMessage(TelegramMessage{Text: "There is nothing to cancel.", User: chat.ID})
+			}
+			if err := session.Clear(); err != nil {
+				log.Error("error clearing session", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+			return Telegram.SendUserMessage(TelegramMessage{Text: "✖️ Operation cancelled. You can start any operation again.", User: chat.ID})
 		case CommandRate, CommandRates:
-			rate, err := rateService.GetCurrentRate()
+			rates, err := rateService.GetActiveAssetRates(common.RateSideSell)
 			if err != nil {
 				log.Error("error fetching rates", zap.Error(err))
 				return sendErrorMessage(message.Chat.ID)
 			}
 
-			text := fmt.Sprintf(
-				"📈 *Current Exchange Rate* 📉\n\n"+
-					"💵 *1 USD = %v* Naira\n\n"+
-					"⏳ *Last Updated:* %s\n\n"+
-					"🔄 Rates may fluctuate. Stay updated!",
-				rate.Rate,
-				time.Now().Format("02 Jan 2006, 03:04 PM"),
+			if len(rates) == 0 {
+				text := "No exchange rates are available at the moment. Please check back later."
+				return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+			}
+
+			var (
+				m           strings.Builder
+				lastUpdated time.Time
 			)
+			m.WriteString("📈 *Current Exchange Rates* 📉\n\n")
+			for _, rate := range rates {
+				m.WriteString(fmt.Sprintf("💵 *1 %s = %s* Naira\n", formatAssetName(rate.Symbol, rate.Standard), rate.Rate.StringFixed(2)))
+				if rate.CreatedAt.After(lastUpdated) {
+					lastUpdated = rate.CreatedAt
+				}
+			}
+			m.WriteString(fmt.Sprintf(
+				"\n⏳ *Last Updated:* %s\n\n"+
+					"🔄 Rates may fluctuate. Stay updated!",
+				lastUpdated.Format("02 Jan 2006, 03:04 PM"),
+			))
 
-			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID, ParseMode: "markdown"})
+			return Telegram.SendUserMessage(TelegramMessage{Text: m.String(), User: chat.ID, ParseMode: "markdown"})
 		case CommandGenerate, CommandGenerateAddress, CommandSell:
 			if err := Telegram.SendLoader(chat.ID); err != nil {
 				log.Error("error sending loader", zap.Error(err))
 				return sendErrorMessage(chat.ID)
 			}
+			user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
+			if err != nil {
+				log.Error("error fetching user by telegram id", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+			if err := accountService.Guard(user, "generate_address"); err != nil {
+				return sendAccountFrozen(chat.ID, user)
+			}
 			assets, err := assetRepo.GetActiveAssets()
 			if err != nil {
 				log.Error("error fetching active assets", zap.Error(err))
@@ -371,6 +545,18 @@ func handleMessage(message *tgApi.Message) error {
 				return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
 			}
 
+			sellable := assets[:0]
+			for _, asset := range assets {
+				if halted, _ := rateService.IsSellHalted(asset.ID.String()); !halted {
+					sellable = append(sellable, asset)
+				}
+			}
+			if len(sellable) == 0 {
+				text := "⏸ Sells are paused while we refresh our rates. Please try again in a few minutes."
+				return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+			}
+			assets = sellable
+
 			buttons := make([][]tgApi.InlineKeyboardButton, len(assets))
 			for i, asset := range assets {
 				buttons[i] = []tgApi.InlineKeyboardButton{
@@ -387,6 +573,11 @@ func handleMessage(message *tgApi.Message) error {
 				}
 			}
 
+			if _, err := startSession(chat.ID, StateSellAsset); err != nil {
+				log.Error("error starting session", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+
 			replyMarkup := tgApi.InlineKeyboardMarkup{InlineKeyboard: buttons}
 			text := "Please select a crypto to sell:"
 			return Telegram.SendUserMessage(TelegramMessage{
@@ -402,7 +593,7 @@ func handleMessage(message *tgApi.Message) error {
 				log.Error("error fetching user by telegram id", zap.Error(err))
 				return sendErrorMessage(message.Chat.ID)
 			}
-			wallet, err := walletService.GetUserWalletsData(user.ID.String())
+			balances, err := walletService.GetBalances(user.ID)
 			if err != nil {
 				log.Error("failed to fetch user wallets", zap.Error(err))
 				text := "Sorry, we couldn't retrieve your wallet balances at this time. Please try again later."
@@ -411,18 +602,34 @@ func handleMessage(message *tgApi.Message) error {
 			var m strings.Builder
 			m.WriteString("💰 *Your Wallet Balance:* 💼\n\n")
 
-			if wallet == nil {
+			if len(balances) == 0 {
 				m.WriteString("🔴 *₦0.00* – No funds available.")
 			} else {
-				m.WriteString(fmt.Sprintf(
-					"🟢 *₦%s* – Available Balance",
-					humanize.Commaf(wallet.Balance),
-				))
+				total := decimal.Zero
+				for _, balance := range balances {
+					if balance.AssetID.String() == common.NairaAssetID {
+						m.WriteString(fmt.Sprintf("🟢 *₦%s* – Naira\n", helpers.CommaDecimal(balance.Balance)))
+					} else if balance.Priced {
+						m.WriteString(fmt.Sprintf("🟢 *%s %s* – ≈ ₦%s\n", balance.Balance, formatAssetName(balance.Symbol, balance.Standard),
+							helpers.CommaDecimal(balance.NairaValue)))
+					} else {
+						m.WriteString(fmt.Sprintf("🟢 *%s %s* – no rate available\n", balance.Balance, formatAssetName(balance.Symbol, balance.Standard)))
+					}
+					total = total.Add(balance.NairaValue)
+				}
+				if len(balances) > 1 {
+					m.WriteString(fmt.Sprintf("\n💵 *Total:* ≈ ₦%s", helpers.CommaDecimal(total)))
+				}
 			}
 
-			m.WriteString("\n\n📌 Use /sell to sell crypto or /withdraw to cash out.")
+			if user.AutoConvert {
+				m.WriteString("\n\n🔄 Crypto you deposit is sold for Naira. Use /auto\\_convert to keep it instead.")
+			} else {
+				m.WriteString("\n\n🔄 Crypto you deposit is kept in your wallet. Use /auto\\_convert to sell it for Naira instead.")
+			}
+			m.WriteString("\n\n📌 Use /sell to sell crypto, /convert to swap crypto or /withdraw to cash out.")
 
-			footer, err := getFooter()
+			footer, err := getFooter("")
 			if err != nil {
 				log.Error("error getting footer", zap.Error(err))
 				return sendErrorMessage(message.Chat.ID)
@@ -442,87 +649,17 @@ func handleMessage(message *tgApi.Message) error {
 				return sendErrorMessage(message.Chat.ID)
 			}
 
-			var (
-				page   = 1
-				offset = (page - 1) * fetchTransactionLimit
-			)
-
-			transactions, err := transactionService.FetchUserTransactions(user.ID.String(), fetchTransactionLimit, offset)
+			text, replyMarkup, err := transactionHistory(user, 1)
 			if err != nil {
 				log.Error("failed to fetch user transactions", zap.Error(err))
 				return sendErrorMessage(message.Chat.ID)
 			}
-
-			totalTransactions, err := transactionService.FetchUserTransactionCount(user.ID.String())
-			if err != nil {
-				log.Error("failed to count user transactions", zap.Error(err))
-				return sendErrorMessage(message.Chat.ID)
-			}
-
-			var m strings.Builder
-			totalPages := (totalTransactions + fetchTransactionLimit - 1) / fetchTransactionLimit
-			m.WriteString(fmt.Sprintf("*Your Transaction History (Page %d of %d):*\n\n", page, totalPages))
-
-			for _, tx := range transactions {
-				nairaBalance := humanize.Commaf(tx.Amount * tx.Rate)
-				usdBalance := humanize.Commaf(tx.AmountUSD)
-				m.WriteString(fmt.Sprintf(
-					"📜 *Transaction Details*\n"+
-						"  ━━━━━━━━━━━━━━  \n"+
-						"🔖 *Type:* %s\n"+
-						"🆔 *Transaction Reference:* `%s`\n"+
-						"💰 *Currency:* %s\n"+
-						"📊 *Amount:* `%.2f %s`\n"+
-						"🔵 *Status:* %s\n"+
-						"🔂 *Confirmations:* %d\n"+
-						"📅 *Date:* %s\n"+
-						"💹 *Rate:* `₦%.2f`\n"+
-						"💵 *Amount in Naira:* `₦%s`\n"+
-						"💲 *Amount in USD:* `$%s`\n"+
-						"  ━━━━━━━━━━━━━━  \n",
-					tx.Type,
-					tx.Reference,
-					func() string {
-						s := fmt.Sprintf("%s", strings.ToUpper(tx.AssetSymbol))
-						if tx.AssetStandard != "" {
-							s = fmt.Sprintf("%s (%s)", strings.ToUpper(tx.AssetSymbol), strings.ToUpper(tx.AssetStandard))
-						}
-						return s
-					}(),
-					tx.Amount,
-					tx.AssetSymbol,
-					tx.Status,
-					tx.Confirmations,
-					tx.CreatedAt.Format("02 Jan 2006, 03:04 PM"),
-					tx.Rate,
-					nairaBalance,
-					usdBalance,
-				))
-				m.WriteString("\n")
-			}
-
-			var buttons [][]tgApi.InlineKeyboardButton
-
-			if totalTransactions > page*fetchTransactionLimit {
-				nextPageButton := tgApi.NewInlineKeyboardButtonData(
-					fmt.Sprintf("Next Page (%d)", page+1),
-					fmt.Sprintf("transactions_page:%d", page+1),
-				)
-				buttons = append(buttons, []tgApi.InlineKeyboardButton{nextPageButton})
-				replyMarkup := tgApi.InlineKeyboardMarkup{InlineKeyboard: buttons}
-				return Telegram.SendUserMessage(TelegramMessage{
-					Text:        m.String(),
-					User:        chat.ID,
-					ReplyMarkup: replyMarkup,
-					ParseMode:   "markdown",
-				})
-			} else {
-				return Telegram.SendUserMessage(TelegramMessage{
-					Text:      m.String(),
-					User:      chat.ID,
-					ParseMode: "markdown",
-				})
-			}
+			return Telegram.SendUserMessage(TelegramMessage{
+				Text:        text,
+				User:        chat.ID,
+				ReplyMarkup: replyMarkup,
+				ParseMode:   "Markdown",
+			})
 		case CommandWithdraw:
 			if err := Telegram.SendLoader(chat.ID); err != nil {
 				log.Error("error sending loader", zap.Error(err))
@@ -533,6 +670,12 @@ func handleMessage(message *tgApi.Message) error {
 				log.Error("error fetching user by telegram id", zap.Error(err))
 				return sendErrorMessage(message.Chat.ID)
 			}
+			if err := accountService.Guard(user, "withdraw"); err != nil {
+				return sendAccountFrozen(chat.ID, user)
+			}
+			if until, paused := services.WithdrawalCooldownUntil(user); paused {
+				return sendWithdrawalCooldown(chat.ID, until)
+			}
 			wallet, err := walletService.GetUserWalletsData(user.ID.String())
 			if err != nil {
 				log.Error("failed to fetch user wallets", zap.Error(err))
@@ -542,17 +685,17 @@ func handleMessage(message *tgApi.Message) error {
 			var m strings.Builder
 			m.WriteString("💰 *Your Wallet Balance:* \n\n")
 
-			if wallet == nil || wallet.Balance == 0 {
+			if wallet == nil || wallet.Balance.IsZero() {
 				m.WriteString("🚨 *₦0.00*\n\n")
 				m.WriteString("😕 Oops! You don’t have enough balance to withdraw.\n\n")
 				return Telegram.SendUserMessage(TelegramMessage{Text: m.String(), User: chat.ID, ParseMode: "Markdown"})
 			}
 
-			m.WriteString(fmt.Sprintf("💵 *₦%s*\n\n", humanize.Commaf(wallet.Balance)))
+			m.WriteString(fmt.Sprintf("💵 *₦%s*\n\n", helpers.CommaDecimal(wallet.Balance)))
 
-			err = database.SetRedisKey(fmt.Sprintf(common.RedisWithdrawSetupKey, chat.ID), "true", 0)
-			if err != nil {
-				log.Error("error setting redis key", zap.Error(err))
+			if _, err := startSession(chat.ID, StateWithdrawAmount); err != nil {
+				log.Error("error starting session", zap.Error(err))
+				return sendErrorMessage(chat.ID)
 			}
 
 			buttons := [][]tgApi.InlineKeyboardButton{
@@ -564,115 +707,361 @@ func handleMessage(message *tgApi.Message) error {
 			replyMarkup := tgApi.InlineKeyboardMarkup{InlineKeyboard: buttons}
 
 			m.WriteString("🔹 Click the *Withdraw All* button or enter an amount to withdraw.\n\n")
-			m.WriteString(fmt.Sprintf("⚠️ *A withdrawal fee of ₦%v applies.*", common.WithdrawalFee))
+			if fee := feeText(common.FeeOperationWithdrawal, uuid.MustParse(common.NairaAssetID), user, nairaAmount); fee != "" {
+				m.WriteString(fmt.Sprintf("⚠️ *A withdrawal fee of %s applies.*", fee))
+			}
 
 			return Telegram.SendUserMessage(TelegramMessage{Text: m.String(), User: chat.ID,
 				ReplyMarkup: replyMarkup, ParseMode: "Markdown"})
+		case CommandLimits:
+			user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
+			if err != nil {
+				log.Error("error fetching user by telegram id", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+			status, err := withdrawalService.GetWithdrawalLimitStatus(user)
+			if err != nil {
+				log.Error("error fetching withdrawal limits", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+			return Telegram.SendUserMessage(TelegramMessage{Text: formatWithdrawalLimits(status), User: chat.ID, ParseMode: "Markdown"})
+		case CommandBeneficiaries:
+			user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
+			if err != nil {
+				log.Error("error fetching user by telegram id", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+			return sendBeneficiaries(chat.ID, user)
+		case CommandConvert:
+			if err := Telegram.SendLoader(chat.ID); err != nil {
+				log.Error("error sending loader", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+			user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
+			if err != nil {
+				log.Error("error fetching user by telegram id", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+			return startConvert(chat.ID, user)
+		case CommandAutoConvert:
+			user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
+			if err != nil {
+				log.Error("error fetching user by telegram id", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+			return sendAutoConvertSetting(chat.ID, user)
+		case CommandWithdrawAll:
+			if err := Telegram.SendLoader(chat.ID); err != nil {
+				log.Error("error sending loader", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+			user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
+			if err != nil {
+				log.Error("error fetching user by telegram id", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+			if err := accountService.Guard(user, "withdraw"); err != nil {
+				return sendAccountFrozen(chat.ID, user)
+			}
+			if until, paused := services.WithdrawalCooldownUntil(user); paused {
+				return sendWithdrawalCooldown(chat.ID, until)
+			}
+			balance, fee, ok, err := withdrawalService.WithdrawableBalance(user.ID.String())
+			if err != nil {
+				log.Error("failed to fetch user wallets", zap.Error(err))
+				text := "Sorry, we couldn't retrieve your wallet balances at this time. Please try again later."
+				return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+			}
+			if !ok {
+				return sendBalanceBelowFee(chat.ID, balance, fee)
+			}
+
+			session, err := startSession(chat.ID, StateWithdrawBank)
+			if err != nil {
+				log.Error("error starting session", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+			session.Data[sessionAmount] = balance.String()
+			session.Data[sessionWithdrawAll] = "true"
+			if err := session.Transition(StateWithdrawBank); err != nil {
+				log.Error("error updating session", zap.Error(err))
+				return sendErrorMessage(chat.ID)
+			}
+			if err := sendWithdrawAllSummary(chat.ID, user, balance); err != nil {
+				return err
+			}
+			return getPayoutAccounts(chat.ID, user)
 		default:
 			text, _ := helpers.FormatHTML(nil, tmpl.Commands)
 			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
 		}
 	}
 
-	if state, _ := database.GetRedisKey(fmt.Sprintf(common.RedisPasswordSetupKey, chat.ID)); state == "true" {
-		if len(text) < 8 {
-			text, _ := helpers.FormatHTML(nil, tmpl.PasswordTooShort)
-			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+	session, err := loadSession(chat.ID)
+	if err != nil {
+		log.Error("error loading session", zap.Error(err))
+		return sendErrorMessage(chat.ID)
+	}
+	if session.Expired() {
+		if err := session.Clear(); err != nil {
+			log.Error("error clearing session", zap.Error(err))
 		}
+		return Telegram.SendUserMessage(TelegramMessage{Text: sessionExpiredText, User: chat.ID, ParseMode: "markdown"})
+	}
 
+	switch session.State {
+	case StateConvertAmount, StateConvertPassword:
 		user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
 		if err != nil {
 			log.Error("error fetching user by telegram id", zap.Error(err))
-			return sendErrorMessage(message.Chat.ID)
+			return sendErrorMessage(chat.ID)
 		}
-
-		err = authService.SetPassword(common.SetPasswordInput{
-			UserID:   user.ID.String(),
-			Password: text,
-		})
+		if session.State == StateConvertAmount {
+			return handleConvertAmount(chat.ID, user, session, text)
+		}
+		return handleConvertPassword(chat.ID, user, session, text)
+	case StateUnlockPassword:
+		user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
 		if err != nil {
-			log.Error("error setting user password", zap.Error(err))
-			text, _ := helpers.FormatHTML(nil, tmpl.PasswordSetFailed)
-			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+			log.Error("error fetching user by telegram id", zap.Error(err))
+			return sendErrorMessage(chat.ID)
 		}
 
-		err = database.DeleteRedisKey(fmt.Sprintf(common.RedisPasswordSetupKey, chat.ID))
+		passwordMatch, err := authService.ConfirmPassword(user.ID.String(), text)
 		if err != nil {
-			log.Error("error deleting redis key", zap.Error(err))
+			log.Error("error validating password", zap.Error(err))
+			return sendErrorMessage(chat.ID)
+		}
+		if !passwordMatch {
+			accountService.RecordEvent(user.ID, common.SecurityEventUnlockFailed, "password", "incorrect password", common.FrozenByUser)
+			if err := session.Clear(); err != nil {
+				log.Error("error clearing session", zap.Error(err))
+			}
+			text := "🚫 <b>Incorrect password.</b>\n\nUse /unlock_account to try again."
+			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
 		}
 
-		text, _ := helpers.FormatHTML(nil, tmpl.PasswordSetSuccess)
-		err = Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+		email, err := authService.RequestUnlockCode(user.ID.String())
 		if err != nil {
-			log.Error("error sending success message", zap.Error(err))
-			return sendErrorMessage(message.Chat.ID)
+			if err := session.Clear(); err != nil {
+				log.Error("error clearing session", zap.Error(err))
+			}
+			switch {
+			case errors.Is(err, services.ErrNoEmailOnFile):
+				text := "📭 <b>No email on file.</b>\n\nWe need to send a code to your email to unlock your account. Please contact support."
+				return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+			case errors.Is(err, services.ErrTooManyCodeRequests):
+				text := "⏳ <b>Too many code requests.</b>\n\nPlease wait an hour before asking for another code."
+				return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+			}
+			log.Error("error requesting unlock code", zap.Error(err))
+			return sendErrorMessage(chat.ID)
 		}
 
-		err = database.SetRedisKey(fmt.Sprintf(common.RedisEmailSetupKey, chat.ID), "true", 0)
-		if err != nil {
-			log.Error("error setting redis key", zap.Error(err))
+		if err := session.Transition(StateUnlockCode); err != nil {
+			log.Error("error updating session", zap.Error(err))
+			return sendErrorMessage(chat.ID)
 		}
 
-		text, _ = helpers.FormatHTML(nil, tmpl.EmailPrompt)
+		text := fmt.Sprintf("📧 We sent a 6-digit code to <b>%s</b>.\n\n"+
+			"Enter it here to unlock your account. The code expires in %s.",
+			html.EscapeString(maskEmail(email)), services.OneTimeCodeTTL().Round(time.Minute))
 		return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
-	}
+	case StateUnlockCode:
+		user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
+		if err != nil {
+			log.Error("error fetching user by telegram id", zap.Error(err))
+			return sendErrorMessage(chat.ID)
+		}
 
-	if state, _ := database.GetRedisKey(fmt.Sprintf(common.RedisEmailSetupKey, chat.ID)); state == "true" {
-		if !helpers.IsValidEmail(text) {
-			text, _ := helpers.FormatHTML(nil, tmpl.InvalidEmail)
+		err = authService.VerifyUnlockCode(user.ID.String(), text)
+		switch {
+		case errors.Is(err, services.ErrInvalidCode):
+			accountService.RecordEvent(user.ID, common.SecurityEventUnlockFailed, "code", "incorrect code", common.FrozenByUser)
+			text := "❌ That code is not correct. Please check your email and try again."
+			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+		case errors.Is(err, services.ErrTooManyCodeAttempts), errors.Is(err, services.ErrCodeExpired):
+			accountService.RecordEvent(user.ID, common.SecurityEventUnlockFailed, "code", err.Error(), common.FrozenByUser)
+			if err := session.Clear(); err != nil {
+				log.Error("error clearing session", zap.Error(err))
+			}
+			text := "🚫 This code is no longer valid. Use /unlock_account to start again."
 			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+		case err != nil:
+			log.Error("error verifying unlock code", zap.Error(err))
+			return sendErrorMessage(chat.ID)
+		}
+
+		if err := session.Clear(); err != nil {
+			log.Error("error clearing session", zap.Error(err))
+		}
+		if user.IsFrozen && user.FrozenBy != common.FrozenByUser {
+			return sendAccountFrozen(chat.ID, user)
+		}
+		err = accountService.Unfreeze(user.ID, "unlocked by the user with password and email code", common.FrozenByUser)
+		if err != nil && !errors.Is(err, services.ErrAccountNotFrozen) {
+			log.Error("error unlocking account", zap.Error(err))
+			return sendErrorMessage(chat.ID)
 		}
 
+		text := "🔓 <b>Your account is unlocked.</b>\n\nAll features are available again."
+		return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+	case StateResetPasswordCode:
 		user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
 		if err != nil {
 			log.Error("error fetching user by telegram id", zap.Error(err))
 			return sendErrorMessage(chat.ID)
 		}
 
-		err = userRepo.UpdateField(user.ID, "email", text)
-		if err != nil {
-			log.Error("error updating user email", zap.Error(err))
-			text, _ := helpers.FormatHTML(nil, tmpl.EmailUpdateFailed)
+		err = authService.VerifyPasswordResetCode(user.ID.String(), text)
+		switch {
+		case errors.Is(err, services.ErrInvalidCode):
+			text := "❌ That code is not correct. Please check your email and try again."
 			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+		case errors.Is(err, services.ErrTooManyCodeAttempts), errors.Is(err, services.ErrCodeExpired):
+			if err := session.Clear(); err != nil {
+				log.Error("error clearing session", zap.Error(err))
+			}
+			text := "🚫 This code is no longer valid. Use /reset_password to get a new one."
+			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+		case err != nil:
+			log.Error("error verifying password reset code", zap.Error(err))
+			return sendErrorMessage(chat.ID)
 		}
 
-		err = database.DeleteRedisKey(fmt.Sprintf(common.RedisEmailSetupKey, chat.ID))
-		if err != nil {
-			log.Error("error deleting redis key", zap.Error(err))
+		if err := session.Transition(StateResetPasswordNew); err != nil {
+			log.Error("error updating session", zap.Error(err))
+			return sendErrorMessage(chat.ID)
 		}
 
-		text, _ := helpers.FormatHTML(nil, tmpl.EmailUpdateSuccess)
+		text, _ := helpers.FormatHTML(nil, tmpl.PasswordPrompt)
 		return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
-	}
-
-	if state, _ := database.GetRedisKey(fmt.Sprintf(common.RedisWithdrawSetupKey, chat.ID)); state == "true" {
-		if err := Telegram.SendLoader(chat.ID); err != nil {
-			log.Error("error sending loader", zap.Error(err))
-			return sendErrorMessage(chat.ID)
-		}
-		amount, err := validateAmount(text)
-		if err != nil {
-			log.Error("error getting withdrawal amount", zap.Error(err))
-			return Telegram.SendUserMessage(TelegramMessage{Text: err.Error(), User: chat.ID})
+	case StateResetPasswordNew:
+		if len(strings.TrimSpace(text)) < 8 {
+			text, _ := helpers.FormatHTML(nil, tmpl.PasswordTooShort)
+			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
 		}
+
 		user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
 		if err != nil {
 			log.Error("error fetching user by telegram id", zap.Error(err))
-			return sendErrorMessage(message.Chat.ID)
+			return sendErrorMessage(chat.ID)
 		}
-		wallet, err := walletService.GetUserWalletsData(user.ID.String())
-		if err != nil {
-			log.Error("failed to fetch user wallets", zap.Error(err))
+
+		err = authService.ResetPassword(user.ID.String(), text)
+		if err := session.Clear(); err != nil {
+			log.Error("error clearing session", zap.Error(err))
+		}
+		if errors.Is(err, services.ErrAccountFrozen) {
+			return sendAccountFrozen(chat.ID, user)
+		}
+		if errors.Is(err, services.ErrResetNotVerified) {
+			text := "⌛ Your reset session has expired. Use /reset_password to start again."
+			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+		}
+		if err != nil {
+			log.Error("error resetting password", zap.Error(err))
+			text, _ := helpers.FormatHTML(nil, tmpl.PasswordSetFailed)
+			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+		}
+
+		text := fmt.Sprintf("✅ <b>Your password has been reset.</b>\n\n"+
+			"As a precaution, withdrawals are paused until %s.",
+			time.Now().Add(services.PasswordResetCooldown()).Format("02 Jan 2006, 03:04 PM"))
+		return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+	case StatePasswordSetup:
+		if len(text) < 8 {
+			text, _ := helpers.FormatHTML(nil, tmpl.PasswordTooShort)
+			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+		}
+
+		user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
+		if err != nil {
+			log.Error("error fetching user by telegram id", zap.Error(err))
+			return sendErrorMessage(message.Chat.ID)
+		}
+
+		err = authService.SetPassword(common.SetPasswordInput{
+			UserID:   user.ID.String(),
+			Password: text,
+		})
+		if errors.Is(err, services.ErrAccountFrozen) {
+			_ = session.Clear()
+			return sendAccountFrozen(chat.ID, user)
+		}
+		if err != nil {
+			log.Error("error setting user password", zap.Error(err))
+			text, _ := helpers.FormatHTML(nil, tmpl.PasswordSetFailed)
+			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+		}
+
+		text, _ := helpers.FormatHTML(nil, tmpl.PasswordSetSuccess)
+		err = Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+		if err != nil {
+			log.Error("error sending success message", zap.Error(err))
+			return sendErrorMessage(message.Chat.ID)
+		}
+
+		if err := session.Transition(StateEmailSetup); err != nil {
+			log.Error("error updating session", zap.Error(err))
+			return sendErrorMessage(chat.ID)
+		}
+
+		text, _ = helpers.FormatHTML(nil, tmpl.EmailPrompt)
+		return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+	case StateEmailSetup:
+		if !helpers.IsValidEmail(text) {
+			text, _ := helpers.FormatHTML(nil, tmpl.InvalidEmail)
+			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+		}
+
+		user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
+		if err != nil {
+			log.Error("error fetching user by telegram id", zap.Error(err))
+			return sendErrorMessage(chat.ID)
+		}
+
+		err = userRepo.UpdateField(user.ID, "email", text)
+		if err != nil {
+			log.Error("error updating user email", zap.Error(err))
+			text, _ := helpers.FormatHTML(nil, tmpl.EmailUpdateFailed)
+			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+		}
+
+		if err := session.Clear(); err != nil {
+			log.Error("error clearing session", zap.Error(err))
+		}
+
+		text, _ := helpers.FormatHTML(nil, tmpl.EmailUpdateSuccess)
+		return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+	case StateWithdrawAmount:
+		if err := Telegram.SendLoader(chat.ID); err != nil {
+			log.Error("error sending loader", zap.Error(err))
+			return sendErrorMessage(chat.ID)
+		}
+		amount, err := validateAmount(text)
+		if err != nil {
+			log.Error("error getting withdrawal amount", zap.Error(err))
+			return Telegram.SendUserMessage(TelegramMessage{Text: err.Error(), User: chat.ID})
+		}
+		user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
+		if err != nil {
+			log.Error("error fetching user by telegram id", zap.Error(err))
+			return sendErrorMessage(message.Chat.ID)
+		}
+		wallet, err := walletService.GetUserWalletsData(user.ID.String())
+		if err != nil {
+			log.Error("failed to fetch user wallets", zap.Error(err))
 			text := "Sorry, we couldn't retrieve your wallet balances at this time. Please try again later."
 			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
 		}
-		if amount > wallet.Balance {
+		if amount.GreaterThan(wallet.Balance) {
 			var m strings.Builder
 			m.WriteString("🚨 *Insufficient Balance!* 🚨\n\n")
 			m.WriteString("💰 *Your Wallet Balance:*\n")
 			m.WriteString(fmt.Sprintf(
 				"💵 *₦%s*\n\n",
-				humanize.Commaf(wallet.Balance),
+				helpers.CommaDecimal(wallet.Balance),
 			))
 
 			buttons := [][]tgApi.InlineKeyboardButton{
@@ -684,24 +1073,21 @@ func handleMessage(message *tgApi.Message) error {
 			replyMarkup := tgApi.InlineKeyboardMarkup{InlineKeyboard: buttons}
 
 			m.WriteString("🔹 Click the *Withdraw All* button or enter an amount to withdraw.\n\n")
-			m.WriteString(fmt.Sprintf("⚠️ *A withdrawal fee of ₦%v applies.*", common.WithdrawalFee))
+			if fee := feeText(common.FeeOperationWithdrawal, uuid.MustParse(common.NairaAssetID), user, nairaAmount); fee != "" {
+				m.WriteString(fmt.Sprintf("⚠️ *A withdrawal fee of %s applies.*", fee))
+			}
 
 			return Telegram.SendUserMessage(TelegramMessage{Text: m.String(), User: chat.ID,
 				ReplyMarkup: replyMarkup, ParseMode: "Markdown"})
 		} else {
-			err := database.DeleteRedisKey(fmt.Sprintf(common.RedisWithdrawSetupKey, chat.ID))
-			if err != nil {
-				log.Error("error deleting redis key", zap.Error(err))
-			}
-			err = database.SetRedisKey(fmt.Sprintf(common.RedisWithdrawalAmountSetupKey, chat.ID), fmt.Sprintf(`%v`, amount), 0)
-			if err != nil {
-				log.Error("error setting redis key", zap.Error(err))
+			session.Data[sessionAmount] = amount.String()
+			if err := session.Transition(StateWithdrawBank); err != nil {
+				log.Error("error updating session", zap.Error(err))
+				return sendErrorMessage(chat.ID)
 			}
-			return getBanks(chat.ID)
+			return getPayoutAccounts(chat.ID, user)
 		}
-	}
-
-	if state, _ := database.GetRedisKey(fmt.Sprintf(common.RedisSearchBankKey, chat.ID)); state == "true" {
+	case StateWithdrawBankSearch, StateBeneficiaryBankSearch:
 		if err := Telegram.SendLoader(chat.ID); err != nil {
 			log.Error("error sending loader", zap.Error(err))
 			return sendErrorMessage(chat.ID)
@@ -737,47 +1123,69 @@ func handleMessage(message *tgApi.Message) error {
 			},
 		})
 		replyMarkup := tgApi.InlineKeyboardMarkup{InlineKeyboard: buttons}
-		err = database.DeleteRedisKey(fmt.Sprintf(common.RedisSearchBankKey, chat.ID))
-		if err != nil {
-			log.Error("error deleting redis key", zap.Error(err))
+		bankState, _, _ := bankStates(session)
+		if err := session.Transition(bankState); err != nil {
+			log.Error("error updating session", zap.Error(err))
+			return sendErrorMessage(chat.ID)
 		}
 		return Telegram.SendUserMessage(TelegramMessage{
 			Text:        "Please select your bank:",
 			User:        chat.ID,
 			ReplyMarkup: replyMarkup,
 		})
-	}
-
-	if state, _ := database.GetRedisKey(fmt.Sprintf(common.RedisSetBankAccountNumberKey, chat.ID)); state == "true" {
+	case StateWithdrawAccountNumber:
 		if err := Telegram.SendLoader(chat.ID); err != nil {
 			log.Error("error sending loader", zap.Error(err))
 			return sendErrorMessage(chat.ID)
 		}
 
-		redisKey := fmt.Sprintf(common.RedisSelectedBankKey, chat.ID)
-		bankCode, err := database.GetRedisKey(redisKey)
+		bankCode := session.Data[sessionBankCode]
+		withdrawalAmt, err := decimal.NewFromString(session.Data[sessionAmount])
 		if err != nil {
 			log.Error("error validating account", zap.Error(err))
 			return sendErrorMessage(chat.ID)
 		}
-		amountRedisKey := fmt.Sprintf(common.RedisWithdrawalAmountSetupKey, chat.ID)
-		withdrawalAmtStr, err := database.GetRedisKey(amountRedisKey)
+		accountNumber := strings.TrimSpace(text)
+
+		bankData, err := withdrawalService.GetBankByCode(bankCode)
+		if err != nil {
+			log.Error("error fetching banks", zap.Error(err))
+			return Telegram.SendUserMessage(TelegramMessage{
+				Text: "Failed to load banks. Please try again.",
+				User: chat.ID,
+			})
+		}
+		if isValid, errMsg := isValidAccountNumber(accountNumber); !isValid {
+			text := fmt.Sprintf("***%s\n\nEnter your %s account number***", errMsg, bankData.Name)
+			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+		}
+		accountDetails, err := withdrawalService.ValidateBankAccount(accountNumber, bankCode)
 		if err != nil {
-			log.Error("error validating account", zap.Error(err))
-			return sendErrorMessage(chat.ID)
+			log.Error("error fetching banks", zap.Error(err))
+			return Telegram.SendUserMessage(TelegramMessage{
+				Text: "Failed to load banks. Please try again.",
+				User: chat.ID,
+			})
 		}
-		withdrawalAmt, err := strconv.ParseFloat(withdrawalAmtStr, 64)
+		user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
 		if err != nil {
-			log.Error("error validating account", zap.Error(err))
+			log.Error("error fetching user by telegram id", zap.Error(err))
 			return sendErrorMessage(chat.ID)
 		}
-		accountNumber := text
-		err = database.SetRedisKey(fmt.Sprintf(common.RedisBankAccountNumberKey, chat.ID), accountNumber, 0)
-		if err != nil {
-			log.Error("error setting redis key", zap.Error(err))
-
+		session.Data[sessionAccountNumber] = accountNumber
+		if err := session.Transition(StateWithdrawConfirm); err != nil {
+			log.Error("error updating session", zap.Error(err))
+			return sendErrorMessage(chat.ID)
+		}
+		return sendWithdrawalDetails(chat.ID, user, withdrawalAmt, accountDetails, bankData.Name)
+	case StateBeneficiaryAccountNumber:
+		if err := Telegram.SendLoader(chat.ID); err != nil {
+			log.Error("error sending loader", zap.Error(err))
+			return sendErrorMessage(chat.ID)
 		}
 
+		bankCode := session.Data[sessionBankCode]
+		accountNumber := strings.TrimSpace(text)
 		bankData, err := withdrawalService.GetBankByCode(bankCode)
 		if err != nil {
 			log.Error("error fetching banks", zap.Error(err))
@@ -787,49 +1195,92 @@ func handleMessage(message *tgApi.Message) error {
 			})
 		}
 		if isValid, errMsg := isValidAccountNumber(accountNumber); !isValid {
-			text := fmt.Sprintf("***%s\n\nEnter your %s account number***", errMsg, bankData.Name)
+			text := fmt.Sprintf("***%s\n\nEnter the %s account number***", errMsg, bankData.Name)
 			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
 		}
 		accountDetails, err := withdrawalService.ValidateBankAccount(accountNumber, bankCode)
 		if err != nil {
-			log.Error("error fetching banks", zap.Error(err))
+			log.Error("error validating account", zap.Error(err))
 			return Telegram.SendUserMessage(TelegramMessage{
-				Text: "Failed to load banks. Please try again.",
+				Text: "We couldn't verify this account. Please check the number and try again.",
 				User: chat.ID,
 			})
 		}
-		_ = database.DeleteRedisKey(fmt.Sprintf(common.RedisSetBankAccountNumberKey, chat.ID))
-		var m strings.Builder
-		m.WriteString(fmt.Sprintf(
-			"📜 *Withdrawal Details*\n"+
-				"  ━━━━━━━━━━━━━━  \n"+
-				"🔖 *Amount:* %v\n"+
-				"🔖 *Account Name:* %s\n"+
-				"🆔 *Account Number:* `%s`\n"+
-				"💰 *Bank:* %s\n"+
-				"  ━━━━━━━━━━━━━━  \n",
-			fmt.Sprintf(
-				"**₦%s**",
-				humanize.Commaf(withdrawalAmt),
-			),
-			accountDetails.AccountNumber,
-			accountDetails.AccountName,
-			bankData.Name,
-		))
-		buttons := [][]tgApi.InlineKeyboardButton{
-			{
-				{Text: "Confirm", CallbackData: helpers.StrPtr("confirm_withdrawal")},
-			},
-			{
-				{Text: "Cancel", CallbackData: helpers.StrPtr("cancel_withdrawal")},
-			},
+		session.Data[sessionAccountNumber] = accountNumber
+		if err := session.Transition(StateBeneficiaryNickname); err != nil {
+			log.Error("error updating session", zap.Error(err))
+			return sendErrorMessage(chat.ID)
+		}
+		text := fmt.Sprintf("🏦 <b>%s</b>\n%s, %s\n\nSend a nickname for this account, up to %d characters.",
+			html.EscapeString(accountDetails.AccountName), html.EscapeString(bankData.Name), accountNumber, services.MaxBeneficiaryNicknameLength)
+		return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+	case StateBeneficiaryNickname:
+		nickname := strings.TrimSpace(text)
+		if nickname == "" || len([]rune(nickname)) > services.MaxBeneficiaryNicknameLength {
+			text := fmt.Sprintf("Please send a nickname of up to %d characters.", services.MaxBeneficiaryNicknameLength)
+			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+		}
+		session.Data[sessionNickname] = nickname
+		if err := session.Transition(StateBeneficiaryPassword); err != nil {
+			log.Error("error updating session", zap.Error(err))
+			return sendErrorMessage(chat.ID)
+		}
+		return Telegram.SendUserMessage(TelegramMessage{
+			Text:      "*🔒 Please enter your password to save this account:*\n\n*⏳ Your session will expire in 60 seconds if not completed.*",
+			User:      chat.ID,
+			ParseMode: "markdown",
+		})
+	case StateBeneficiaryPassword:
+		user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
+		if err != nil {
+			log.Error("error fetching user by telegram id", zap.Error(err))
+			return sendErrorMessage(chat.ID)
+		}
+		var (
+			bankCode      = session.Data[sessionBankCode]
+			accountNumber = session.Data[sessionAccountNumber]
+			nickname      = session.Data[sessionNickname]
+		)
+		if err := session.Clear(); err != nil {
+			log.Error("error clearing session", zap.Error(err))
+			return sendErrorMessage(chat.ID)
 		}
-		replyMarkup := tgApi.InlineKeyboardMarkup{InlineKeyboard: buttons}
 
-		return Telegram.SendUserMessage(TelegramMessage{Text: m.String(), User: chat.ID, ParseMode: "markdown", ReplyMarkup: &replyMarkup})
-	}
+		passwordMatch, err := authService.ConfirmPassword(user.ID.String(), text)
+		if err != nil {
+			log.Error("error validating password", zap.Error(err))
+			return sendErrorMessage(chat.ID)
+		}
+		if !passwordMatch {
+			return Telegram.SendUserMessage(TelegramMessage{
+				Text:      "🚫 *Invalid Password!* 🚫\n\n🔹 The account was not saved. Use /beneficiaries to try again.",
+				User:      chat.ID,
+				ParseMode: "Markdown",
+			})
+		}
+		if err := accountService.Guard(user, "add_beneficiary"); err != nil {
+			return sendAccountFrozen(chat.ID, user)
+		}
+
+		beneficiary, err := beneficiaryService.AddBeneficiary(user.ID, bankCode, accountNumber, nickname)
+		switch {
+		case errors.Is(err, services.ErrBeneficiaryExists):
+			return Telegram.SendUserMessage(TelegramMessage{Text: "This account is already saved. Use /beneficiaries to see it.", User: chat.ID})
+		case errors.Is(err, services.ErrBeneficiaryLimit):
+			text := fmt.Sprintf("You can save up to %d accounts. Remove one with /beneficiaries first.", services.MaxBeneficiaries)
+			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+		case err != nil:
+			log.Error("error adding beneficiary", zap.Error(err))
+			return sendErrorMessage(chat.ID)
+		}
 
-	if state, _ := database.GetRedisKey(fmt.Sprintf(common.RedisConfirmWithdrawalPasswordKey, chat.ID)); state == "true" {
+		text := fmt.Sprintf("✅ <b>%s</b> has been saved.", html.EscapeString(beneficiary.Nickname))
+		if time.Now().Before(beneficiary.ActiveFrom) {
+			text += fmt.Sprintf("\n\n⏳ For your security, you can withdraw to it from %s.",
+				beneficiary.ActiveFrom.Format("02 Jan 2006, 03:04 PM"))
+		}
+		return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chat.ID})
+	case StateWithdrawPassword:
 		user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
 		if err != nil {
 			log.Error("error fetching user by telegram id", zap.Error(err))
@@ -843,6 +1294,10 @@ func handleMessage(message *tgApi.Message) error {
 			return sendErrorMessage(message.Chat.ID)
 		}
 		if !passwordMatch {
+			if err := session.Transition(StateWithdrawConfirm); err != nil {
+				log.Error("error updating session", zap.Error(err))
+				return sendErrorMessage(chatId)
+			}
 			buttons := [][]tgApi.InlineKeyboardButton{
 				{
 					{Text: "Retry", CallbackData: helpers.StrPtr("confirm_withdrawal")},
@@ -861,41 +1316,84 @@ func handleMessage(message *tgApi.Message) error {
 			})
 		}
 
-		redisKey := fmt.Sprintf(common.RedisSelectedBankKey, chatId)
-		bankCode, err := database.GetRedisKey(redisKey)
+		var (
+			bankCode      = session.Data[sessionBankCode]
+			accountNumber = session.Data[sessionAccountNumber]
+		)
+		withdrawAll := session.Data[sessionWithdrawAll] == "true"
+		withdrawalAmt, err := decimal.NewFromString(session.Data[sessionAmount])
 		if err != nil {
-			log.Error("error fetching from redis", zap.Error(err))
+			log.Error("error reading withdrawal amount from session", zap.Error(err))
 			return sendErrorMessage(chatId)
 		}
-		amountRedisKey := fmt.Sprintf(common.RedisWithdrawalAmountSetupKey, chatId)
-		withdrawalAmtStr, err := database.GetRedisKey(amountRedisKey)
-		if err != nil {
-			log.Error("error fetching from redis", zap.Error(err))
+		// Clear the session before submitting so a second reply cannot
+		// start the same withdrawal again.
+		if err := session.Clear(); err != nil {
+			log.Error("error clearing session", zap.Error(err))
 			return sendErrorMessage(chatId)
 		}
-		withdrawalAmt, err := strconv.ParseFloat(withdrawalAmtStr, 64)
-		if err != nil {
-			log.Error("error fetching from redis", zap.Error(err))
-			return sendErrorMessage(chatId)
+		if err := accountService.Guard(user, "withdraw"); err != nil {
+			return sendAccountFrozen(chatId, user)
 		}
-		redisKey = fmt.Sprintf(common.RedisBankAccountNumberKey, chatId)
-		accountNumber, err := database.GetRedisKey(redisKey)
-		if err != nil {
-			log.Error("error fetching from redis", zap.Error(err))
-			return sendErrorMessage(chatId)
+		var withdrawal *database.Withdrawal
+		if withdrawAll {
+			withdrawal, err = withdrawalService.WithdrawAll(accountNumber, bankCode, user.ID.String(), withdrawalAmt)
+		} else {
+			withdrawal, err = withdrawalService.InitiateTransfer(accountNumber, bankCode, user.ID.String(), withdrawalAmt)
 		}
-		if err := withdrawalService.InitiateTransfer(accountNumber, bankCode, user.ID.String(), withdrawalAmt); err != nil {
+		if err != nil {
 			log.Error("error initiating withdrawal", zap.Error(err))
+			if errors.Is(err, services.ErrBalanceBelowFee) {
+				balance, fee, _, _ := withdrawalService.WithdrawableBalance(user.ID.String())
+				return sendBalanceBelowFee(chatId, balance, fee)
+			}
+			if errors.Is(err, services.ErrBalanceChanged) {
+				return confirmWithdrawAllAgain(chatId, user, bankCode, accountNumber)
+			}
+			if errors.Is(err, services.ErrBeneficiaryCoolingOff) {
+				return Telegram.SendUserMessage(TelegramMessage{
+					Text:      "⏳ *This account was saved recently.*\n\n🔹 For your security it cannot receive withdrawals yet. Use /beneficiaries to see when it can.",
+					User:      chatId,
+					ParseMode: "Markdown",
+				})
+			}
+			if errors.Is(err, services.ErrAccountFrozen) {
+				return sendAccountFrozen(chatId, user)
+			}
+			if errors.Is(err, services.ErrWithdrawalCooldown) {
+				if until, paused := services.WithdrawalCooldownUntil(user); paused {
+					return sendWithdrawalCooldown(chatId, until)
+				}
+			}
+			if errors.Is(err, repositories.ErrInsufficientBalance) {
+				return Telegram.SendUserMessage(TelegramMessage{
+					Text:      "🚫 *Insufficient Balance!* 🚫\n\n🔹 Your balance no longer covers this withdrawal and its fee. Use /balance to check it.",
+					User:      chatId,
+					ParseMode: "Markdown",
+				})
+			}
 			return sendErrorMessage(message.Chat.ID)
 		}
+		if withdrawal.Status == common.WithdrawalStatusAwaitingApproval {
+			return Telegram.SendUserMessage(TelegramMessage{
+				Text: fmt.Sprintf("🕵️ *Withdrawal Under Review*\n\n💵 Your withdrawal of *₦%s* needs a review by our team because %s. "+
+					"The funds are set aside in the meantime.\n\n📩 We'll notify you once it has been reviewed. Use /limits to see your limits.",
+					helpers.CommaDecimal(withdrawal.Amount), reviewReasonText(withdrawal.ReviewReason)),
+				User:      chatId,
+				ParseMode: "Markdown",
+			})
+		}
 		return Telegram.SendUserMessage(TelegramMessage{
-			Text:      "✅ *Withdrawal in Progress!* ✅\n\n📩 We'll notify you shortly once the transaction is processed.",
+			Text: fmt.Sprintf("✅ *Withdrawal in Progress!* ✅\n\n💵 *₦%s* is on its way to your bank.\n\n📩 We'll notify you shortly once the transaction is processed.",
+				helpers.CommaDecimal(withdrawal.Amount)),
 			User:      chatId,
 			ParseMode: "Markdown",
 		})
-
 	}
 
+	if prompt := states[session.State].Prompt; prompt != "" {
+		return Telegram.SendUserMessage(TelegramMessage{Text: prompt, User: chat.ID, ParseMode: "markdown"})
+	}
 	return nil
 }
 
@@ -905,6 +1403,13 @@ func handleCallback(callbackQuery *tgApi.CallbackQuery) error {
 		telegramId = callbackQuery.From.ID
 	)
 
+	if handled, err := handleConvertCallback(callbackQuery); handled {
+		return err
+	}
+	if handled, err := handleTransactionCallback(callbackQuery); handled {
+		return err
+	}
+
 	if strings.HasPrefix(data, "generate_address:") {
 		if err := Telegram.SendLoader(callbackQuery.Message.Chat.ID); err != nil {
 			log.Error("error sending loader", zap.Error(err))
@@ -912,16 +1417,13 @@ func handleCallback(callbackQuery *tgApi.CallbackQuery) error {
 		}
 		assetID := strings.TrimPrefix(data, "generate_address:")
 
-		redisKey := fmt.Sprintf(common.RedisAssetSelectionKey, callbackQuery.From.ID)
-		err := database.SetRedisKey(redisKey, assetID, 300)
+		session, err := loadSession(callbackQuery.Message.Chat.ID)
 		if err != nil {
-			log.Error("error setting redis key for asset selection", zap.Error(err))
-			text := "Failed to process your selection. Please try again."
-			return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
-				CallbackQueryID: callbackQuery.ID,
-				Text:            text,
-				ShowAlert:       true,
-			})
+			log.Error("error loading session", zap.Error(err))
+			return sendErrorMessage(callbackQuery.Message.Chat.ID)
+		}
+		if !session.In(StateSellAsset, StateSellConfirm) {
+			return sendSessionExpired(callbackQuery)
 		}
 
 		asset, err := assetRepo.FindAssetByID(assetID)
@@ -965,10 +1467,14 @@ func handleCallback(callbackQuery *tgApi.CallbackQuery) error {
 			log.Error("error editing message for confirm/cancel", zap.Error(err))
 		}
 
-		err = database.SetRedisKey(fmt.Sprintf(common.RedisAssetSelectionKey, callbackQuery.Message.Chat.ID), assetID, 0)
-		if err != nil {
-			log.Error("error setting redis key", zap.Error(err))
-
+		session.Data[sessionAssetID] = assetID
+		if err := session.Transition(StateSellConfirm); err != nil {
+			log.Error("error updating session", zap.Error(err))
+			return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
+				CallbackQueryID: callbackQuery.ID,
+				Text:            "Failed to process your selection. Please try again.",
+				ShowAlert:       true,
+			})
 		}
 		return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
 			CallbackQueryID: callbackQuery.ID,
@@ -980,11 +1486,24 @@ func handleCallback(callbackQuery *tgApi.CallbackQuery) error {
 			log.Error("error sending loader", zap.Error(err))
 			return sendErrorMessage(callbackQuery.Message.Chat.ID)
 		}
-		redisKey := fmt.Sprintf(common.RedisAssetSelectionKey, callbackQuery.From.ID)
-		assetID, err := database.GetRedisKey(redisKey)
-		if err != nil || assetID == "" {
-			log.Error("error fetching selected asset from redis", zap.Error(err))
-			text := fmt.Sprintf("No asset selected or session expired. Please send command again.")
+		session, err := loadSession(callbackQuery.Message.Chat.ID)
+		if err != nil {
+			log.Error("error loading session", zap.Error(err))
+			return sendErrorMessage(callbackQuery.Message.Chat.ID)
+		}
+		assetID := session.Data[sessionAssetID]
+		if !session.In(StateSellConfirm) || assetID == "" {
+			text := "No asset selected or session expired. Please send command again."
+			return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
+				CallbackQueryID: callbackQuery.ID,
+				Text:            text,
+				ShowAlert:       true,
+			})
+		}
+
+		if halted, _ := rateService.IsSellHalted(assetID); halted {
+			_ = session.Clear()
+			text := "Sells of this asset are paused while we refresh our rates. Please try again in a few minutes."
 			return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
 				CallbackQueryID: callbackQuery.ID,
 				Text:            text,
@@ -1013,6 +1532,13 @@ func handleCallback(callbackQuery *tgApi.CallbackQuery) error {
 				ShowAlert:       true,
 			})
 		}
+		if err := accountService.Guard(user, "generate_address"); err != nil {
+			_ = session.Clear()
+			if err := Telegram.SendCallbackResponse(common.TelegramCallbackResponse{CallbackQueryID: callbackQuery.ID}); err != nil {
+				log.Error("error answering callback", zap.Error(err))
+			}
+			return sendAccountFrozen(callbackQuery.Message.Chat.ID, user)
+		}
 
 		addressData, err := addressService.GetUserAddress(user, assetID)
 		if err != nil {
@@ -1025,7 +1551,9 @@ func handleCallback(callbackQuery *tgApi.CallbackQuery) error {
 			})
 		}
 
-		_ = database.DeleteRedisKey(redisKey)
+		if err := session.Clear(); err != nil {
+			log.Error("error clearing session", zap.Error(err))
+		}
 
 		err = Telegram.SendUserMessage(TelegramMessage{
 			User:      callbackQuery.Message.Chat.ID,
@@ -1057,24 +1585,21 @@ func handleCallback(callbackQuery *tgApi.CallbackQuery) error {
 			sb.WriteString("• All coins received are converted to Naira using the displayed rate and credited to your Naira wallet.\n")
 			sb.WriteString("• Any Naira balance is available for instant withdrawal to your bank account.\n")
 			sb.WriteString(fmt.Sprintf("• *Only send %s (%s)* to this address.\n", strings.ToUpper(asset.Symbol), strings.ToUpper(asset.Standard)))
+			if asset.MinDeposit.IsPositive() {
+				sb.WriteString(fmt.Sprintf("• Deposits below *%v %s* are not credited.\n", asset.MinDeposit, strings.ToUpper(asset.Symbol)))
+			}
+			if fee := feeText(common.FeeOperationDeposit, asset.ID, user, assetAmount(asset.Symbol)); fee != "" {
+				sb.WriteString(fmt.Sprintf("• A deposit fee of *%s* is deducted from each deposit.\n", fee))
+			}
+			if fee := feeText(common.FeeOperationSell, asset.ID, user, nairaAmount); fee != "" && user.AutoConvert {
+				sb.WriteString(fmt.Sprintf("• A sell fee of *%s* is deducted from the Naira credited.\n", fee))
+			}
 
 			sb.WriteString("\n")
 			return sb.String()
 		}()
 
 		m.WriteString(text)
-
-		footer, err := getFooter()
-		if err != nil {
-			log.Error("error getting user address", zap.Error(err))
-			text := "Failed to process your selection. Please try again."
-			return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
-				CallbackQueryID: callbackQuery.ID,
-				Text:            text,
-				ShowAlert:       true,
-			})
-		}
-		m.WriteString(footer)
 		// err = Telegram.EditMessage(TelegramMessageEdit{
 		// 	ChatID:    callbackQuery.Message.Chat.ID,
 		// 	MessageID: callbackQuery.Message.MessageID,
@@ -1093,40 +1618,40 @@ func handleCallback(callbackQuery *tgApi.CallbackQuery) error {
 			Text:      m.String(),
 			ParseMode: "markdown",
 		})
-	}
-
-	if data == "cancel_generate" {
-		redisKey := fmt.Sprintf(common.RedisAssetSelectionKey, callbackQuery.From.ID)
-		_ = database.DeleteRedisKey(redisKey)
+		if err != nil {
+			log.Error("error sending address instructions", zap.Error(err))
+		}
 
-		text := "Address generation canceled. You can use /generate to start again."
-		err := Telegram.EditMessage(TelegramMessageEdit{
-			ChatID:    callbackQuery.Message.Chat.ID,
-			MessageID: callbackQuery.Message.MessageID,
-			NewText:   text,
-		})
+		quote, err := rateService.IssueQuote(user.ID, asset.ID, addressData.Address)
 		if err != nil {
-			log.Error("error editing message for cancellation", zap.Error(err))
+			log.Error("error issuing quote", zap.Error(err))
+			footer, err := getFooter(assetID)
+			if err != nil {
+				return sendErrorMessage(callbackQuery.Message.Chat.ID)
+			}
+			return Telegram.SendUserMessage(TelegramMessage{
+				User:      callbackQuery.Message.Chat.ID,
+				Text:      strings.TrimSpace(footer),
+				ParseMode: "markdown",
+			})
 		}
 
-		return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
-			CallbackQueryID: callbackQuery.ID,
+		quoteText, quoteMarkup := formatQuote(quote, asset)
+		return Telegram.SendUserMessage(TelegramMessage{
+			User:        callbackQuery.Message.Chat.ID,
+			Text:        quoteText,
+			ParseMode:   "markdown",
+			ReplyMarkup: quoteMarkup,
 		})
 	}
 
-	if strings.HasPrefix(data, "transactions_page:") {
-		if err := Telegram.SendLoader(callbackQuery.Message.Chat.ID); err != nil {
-			log.Error("error sending loader", zap.Error(err))
-			return sendErrorMessage(callbackQuery.Message.Chat.ID)
-		}
-
-		pageStr := strings.TrimPrefix(data, "transactions_page:")
-		page, err := strconv.Atoi(pageStr)
-		if err != nil || page < 1 {
-			log.Error("invalid page number in callback", zap.Error(err))
+	if strings.HasPrefix(data, "quote_status:") || strings.HasPrefix(data, "quote_renew:") {
+		action, quoteIDStr, _ := strings.Cut(data, ":")
+		quoteID, err := uuid.Parse(quoteIDStr)
+		if err != nil {
 			return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
 				CallbackQueryID: callbackQuery.ID,
-				Text:            "Invalid page number.",
+				Text:            "Invalid quote.",
 			})
 		}
 
@@ -1135,108 +1660,81 @@ func handleCallback(callbackQuery *tgApi.CallbackQuery) error {
 			log.Error("error fetching user by telegram id", zap.Error(err))
 			return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
 				CallbackQueryID: callbackQuery.ID,
-				Text:            "Failed to process your selection. Please try again.",
+				Text:            "Failed to fetch your quote. Please try again.",
 				ShowAlert:       true,
 			})
 		}
 
-		offset := (page - 1) * fetchTransactionLimit
-
-		transactions, err := transactionService.FetchUserTransactions(user.ID.String(), fetchTransactionLimit, offset)
-		if err != nil {
-			log.Error("failed to fetch user transactions", zap.Error(err))
+		quote, err := rateService.GetQuote(quoteID)
+		if err != nil || quote.UserID != user.ID {
+			log.Error("error fetching quote", zap.Error(err))
 			return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
 				CallbackQueryID: callbackQuery.ID,
-				Text:            "Failed to fetch transactions. Please try again later.",
+				Text:            "Failed to fetch your quote. Please try again.",
+				ShowAlert:       true,
 			})
 		}
 
-		totalTransactions, err := transactionService.FetchUserTransactionCount(user.ID.String())
+		asset, err := assetRepo.FindAssetByID(quote.AssetID.String())
 		if err != nil {
-			log.Error("failed to count user transactions", zap.Error(err))
+			log.Error("error fetching asset data", zap.Error(err))
 			return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
 				CallbackQueryID: callbackQuery.ID,
-				Text:            "Failed to fetch transactions. Please try again later.",
+				Text:            "Failed to fetch your quote. Please try again.",
+				ShowAlert:       true,
 			})
 		}
 
-		var message strings.Builder
-		totalPages := (totalTransactions + fetchTransactionLimit - 1) / fetchTransactionLimit
-		message.WriteString(fmt.Sprintf("*Your Transaction History (Page %d of %d):*\n\n", page, totalPages))
-		for _, tx := range transactions {
-			nairaBalance := humanize.Commaf(tx.Amount * tx.Rate)
-			usdBalance := humanize.Commaf(tx.AmountUSD)
-			message.WriteString(fmt.Sprintf(
-				"📜 *Transaction Details*\n"+
-					"  ━━━━━━━━━━━━━━  \n"+
-					"🔖 *Type:* %s\n"+
-					"🆔 *Transaction Reference:* `%s`\n"+
-					"💰 *Currency:* %s\n"+
-					"📊 *Amount:* `%.2f %s`\n"+
-					"🔵 *Status:* %s\n"+
-					"🔂 *Confirmations:* %d\n"+
-					"📅 *Date:* %s\n"+
-					"💹 *Rate:* `₦%.2f`\n"+
-					"💵 *Amount in Naira:* `₦%s`\n"+
-					"💲 *Amount in USD:* `$%s`\n"+
-					"  ━━━━━━━━━━━━━━  \n",
-				tx.Type,
-				tx.Reference,
-				func() string {
-					s := fmt.Sprintf("%s", strings.ToUpper(tx.AssetSymbol))
-					if tx.AssetStandard != "" {
-						s = fmt.Sprintf("%s (%s)", strings.ToUpper(tx.AssetSymbol), strings.ToUpper(tx.AssetStandard))
-					}
-					return s
-				}(),
-				tx.Amount,
-				tx.AssetSymbol,
-				tx.Status,
-				tx.Confirmations,
-				tx.CreatedAt.Format("02 Jan 2006, 03:04 PM"),
-				tx.Rate,
-				nairaBalance,
-				usdBalance,
-			))
-			message.WriteString("\n")
-		}
-
-		var buttons [][]tgApi.InlineKeyboardButton
-
-		if page > 1 {
-			prevPageButton := tgApi.NewInlineKeyboardButtonData(
-				fmt.Sprintf("⬅️ Previous Page (%d)", page-1),
-				fmt.Sprintf("transactions_page:%d", page-1),
-			)
-			buttons = append(buttons, []tgApi.InlineKeyboardButton{prevPageButton})
-		}
-
-		if totalTransactions > page*fetchTransactionLimit {
-			nextPageButton := tgApi.NewInlineKeyboardButtonData(
-				fmt.Sprintf("Next Page (%d) ➡️", page+1),
-				fmt.Sprintf("transactions_page:%d", page+1),
-			)
-			if page > 1 {
-				buttons[len(buttons)-1] = append(buttons[len(buttons)-1], nextPageButton)
-			} else {
-				buttons = append(buttons, []tgApi.InlineKeyboardButton{nextPageButton})
+		if action == "quote_renew" {
+			if halted, _ := rateService.IsSellHalted(quote.AssetID.String()); halted {
+				return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
+					CallbackQueryID: callbackQuery.ID,
+					Text:            "Sells of this asset are paused while we refresh our rates. Please try again in a few minutes.",
+					ShowAlert:       true,
+				})
+			}
+			quote, err = rateService.IssueQuote(quote.UserID, quote.AssetID, quote.Address)
+			if err != nil {
+				log.Error("error issuing quote", zap.Error(err))
+				return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
+					CallbackQueryID: callbackQuery.ID,
+					Text:            "Failed to lock a new rate. Please try again.",
+					ShowAlert:       true,
+				})
 			}
 		}
 
-		replyMarkup := tgApi.NewInlineKeyboardMarkup(buttons...)
+		quoteText, quoteMarkup := formatQuote(quote, asset)
 		err = Telegram.EditMessage(TelegramMessageEdit{
 			ChatID:      callbackQuery.Message.Chat.ID,
 			MessageID:   callbackQuery.Message.MessageID,
-			NewText:     message.String(),
-			ParseMode:   "Markdown",
-			ReplyMarkup: &replyMarkup,
+			NewText:     quoteText,
+			ReplyMarkup: &quoteMarkup,
+			ParseMode:   "markdown",
 		})
 		if err != nil {
-			log.Error("error editing transactions message", zap.Error(err))
-			return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
-				CallbackQueryID: callbackQuery.ID,
-				Text:            "Failed to update the message.",
-			})
+			log.Error("error editing quote message", zap.Error(err))
+		}
+
+		return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
+			CallbackQueryID: callbackQuery.ID,
+		})
+	}
+
+	if data == "cancel_generate" {
+		session := &Session{ChatID: callbackQuery.Message.Chat.ID}
+		if err := session.Clear(); err != nil {
+			log.Error("error clearing session", zap.Error(err))
+		}
+
+		text := "Address generation canceled. You can use /generate to start again."
+		err := Telegram.EditMessage(TelegramMessageEdit{
+			ChatID:    callbackQuery.Message.Chat.ID,
+			MessageID: callbackQuery.Message.MessageID,
+			NewText:   text,
+		})
+		if err != nil {
+			log.Error("error editing message for cancellation", zap.Error(err))
 		}
 
 		return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
@@ -1246,9 +1744,13 @@ func handleCallback(callbackQuery *tgApi.CallbackQuery) error {
 
 	if data == "withdraw_all" {
 		chatId := callbackQuery.Message.Chat.ID
-		err := database.DeleteRedisKey(fmt.Sprintf(common.RedisWithdrawSetupKey, chatId))
+		session, err := loadSession(chatId)
 		if err != nil {
-			log.Error("error deleting redis key", zap.Error(err))
+			log.Error("error loading session", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		if !session.In(StateWithdrawAmount) {
+			return sendSessionExpired(callbackQuery)
 		}
 		user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
 		if err != nil {
@@ -1261,11 +1763,70 @@ func handleCallback(callbackQuery *tgApi.CallbackQuery) error {
 			text := "Sorry, we couldn't retrieve your wallet balances at this time. Please try again later."
 			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chatId})
 		}
-		err = database.SetRedisKey(fmt.Sprintf(common.RedisWithdrawalAmountSetupKey, chatId), fmt.Sprintf(`%v`, wallet.Balance), 0)
+		fee, err := withdrawalService.WithdrawalFee(user, wallet.Balance)
 		if err != nil {
-			log.Error("error setting redis key", zap.Error(err))
+			log.Error("error pricing withdrawal fee", zap.Error(err))
+			return sendErrorMessage(chatId)
 		}
-		return getBanks(chatId)
+		if wallet.Balance.LessThanOrEqual(fee.Amount) {
+			return sendBalanceBelowFee(chatId, wallet.Balance, fee)
+		}
+		session.Data[sessionAmount] = wallet.Balance.String()
+		session.Data[sessionWithdrawAll] = "true"
+		if err := session.Transition(StateWithdrawBank); err != nil {
+			log.Error("error updating session", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		if err := sendWithdrawAllSummary(chatId, user, wallet.Balance); err != nil {
+			return err
+		}
+		return getPayoutAccounts(chatId, user)
+	}
+
+	if data == "use_last_account" {
+		chatId := callbackQuery.Message.Chat.ID
+		session, err := loadSession(chatId)
+		if err != nil {
+			log.Error("error loading session", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		if !session.In(StateWithdrawBank, StateWithdrawBankSearch) {
+			return sendSessionExpired(callbackQuery)
+		}
+		if err := Telegram.SendLoader(chatId); err != nil {
+			log.Error("error sending loader", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
+		if err != nil {
+			log.Error("error fetching user by telegram id", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		last, err := withdrawalRepo.GetLastCompletedWithdrawal(user.ID)
+		if err != nil || last == nil {
+			log.Error("error fetching last withdrawal account", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		withdrawalAmt, err := decimal.NewFromString(session.Data[sessionAmount])
+		if err != nil {
+			log.Error("error reading withdrawal amount from session", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		accountDetails, err := withdrawalService.ValidateBankAccount(last.AccountNumber, last.BankCode)
+		if err != nil {
+			log.Error("error validating saved account", zap.Error(err))
+			return Telegram.SendUserMessage(TelegramMessage{
+				Text: "We couldn't verify your last account. Please select your bank from the list above.",
+				User: chatId,
+			})
+		}
+		session.Data[sessionBankCode] = last.BankCode
+		session.Data[sessionAccountNumber] = last.AccountNumber
+		if err := session.Transition(StateWithdrawConfirm); err != nil {
+			log.Error("error updating session", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		return sendWithdrawalDetails(chatId, user, withdrawalAmt, accountDetails, last.BankName)
 	}
 
 	if strings.HasPrefix(data, "banks_page:") {
@@ -1390,6 +1951,16 @@ func handleCallback(callbackQuery *tgApi.CallbackQuery) error {
 		bankCode := strings.TrimPrefix(data, "select_bank:")
 		chatId := callbackQuery.Message.Chat.ID
 
+		session, err := loadSession(chatId)
+		if err != nil {
+			log.Error("error loading session", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		_, _, accountNumberState := bankStates(session)
+		if accountNumberState == "" {
+			return sendSessionExpired(callbackQuery)
+		}
+
 		bankData, err := withdrawalService.GetBankByCode(bankCode)
 		if err != nil {
 			log.Error("error fetching banks", zap.Error(err))
@@ -1401,8 +1972,8 @@ func handleCallback(callbackQuery *tgApi.CallbackQuery) error {
 				})
 		}
 
-		err = database.SetRedisKey(fmt.Sprintf(common.RedisSelectedBankKey, chatId), bankCode, 0)
-		if err != nil {
+		session.Data[sessionBankCode] = bankCode
+		if err := session.Transition(accountNumberState); err != nil {
 			log.Error("error saving selected bank", zap.Error(err))
 			return Telegram.SendCallbackResponse(
 				common.TelegramCallbackResponse{
@@ -1412,11 +1983,6 @@ func handleCallback(callbackQuery *tgApi.CallbackQuery) error {
 				})
 		}
 
-		err = database.SetRedisKey(fmt.Sprintf(common.RedisSetBankAccountNumberKey, chatId), "true", 0)
-		if err != nil {
-			log.Error("error setting redis key", zap.Error(err))
-		}
-
 		text := fmt.Sprintf("***Enter your %s account number***", bankData.Name)
 
 		err = Telegram.SendUserMessage(TelegramMessage{
@@ -1434,9 +2000,18 @@ func handleCallback(callbackQuery *tgApi.CallbackQuery) error {
 
 	if data == "search_bank" {
 		chatId := callbackQuery.Message.Chat.ID
-		err := database.SetRedisKey(fmt.Sprintf(common.RedisSearchBankKey, chatId), "true", 0)
+		session, err := loadSession(chatId)
 		if err != nil {
-			log.Error("error setting redis key", zap.Error(err))
+			log.Error("error loading session", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		bankState, searchState, _ := bankStates(session)
+		if !session.In(bankState, searchState) {
+			return sendSessionExpired(callbackQuery)
+		}
+		if err := session.Transition(searchState); err != nil {
+			log.Error("error updating session", zap.Error(err))
+			return sendErrorMessage(chatId)
 		}
 
 		return Telegram.SendUserMessage(TelegramMessage{
@@ -1446,11 +2021,147 @@ func handleCallback(callbackQuery *tgApi.CallbackQuery) error {
 		})
 	}
 
+	if strings.HasPrefix(data, "pay_beneficiary:") {
+		chatId := callbackQuery.Message.Chat.ID
+		session, err := loadSession(chatId)
+		if err != nil {
+			log.Error("error loading session", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		if !session.In(StateWithdrawBank, StateWithdrawBankSearch) {
+			return sendSessionExpired(callbackQuery)
+		}
+		beneficiaryID, err := uuid.Parse(strings.TrimPrefix(data, "pay_beneficiary:"))
+		if err != nil {
+			return sendSessionExpired(callbackQuery)
+		}
+		user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
+		if err != nil {
+			log.Error("error fetching user by telegram id", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		beneficiary, err := beneficiaryService.GetPayableBeneficiary(user.ID, beneficiaryID)
+		switch {
+		case errors.Is(err, services.ErrBeneficiaryCoolingOff):
+			return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
+				CallbackQueryID: callbackQuery.ID,
+				Text:            fmt.Sprintf("For your security, %s can receive withdrawals from %s.", beneficiary.Nickname, beneficiary.ActiveFrom.Format("02 Jan 2006, 03:04 PM")),
+				ShowAlert:       true,
+			})
+		case errors.Is(err, services.ErrBeneficiaryNotFound):
+			return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
+				CallbackQueryID: callbackQuery.ID,
+				Text:            "This account is no longer saved. Please choose another.",
+				ShowAlert:       true,
+			})
+		case err != nil:
+			log.Error("error fetching beneficiary", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		withdrawalAmt, err := decimal.NewFromString(session.Data[sessionAmount])
+		if err != nil {
+			log.Error("error reading withdrawal amount from session", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		session.Data[sessionBankCode] = beneficiary.BankCode
+		session.Data[sessionAccountNumber] = beneficiary.AccountNumber
+		if err := session.Transition(StateWithdrawConfirm); err != nil {
+			log.Error("error updating session", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		account := &services.AccountDetails{
+			AccountNumber: beneficiary.AccountNumber,
+			AccountName:   beneficiary.AccountName,
+			BankCode:      beneficiary.BankCode,
+		}
+		return sendWithdrawalDetails(chatId, user, withdrawalAmt, account, beneficiary.BankName)
+	}
+
+	if data == "other_bank" {
+		chatId := callbackQuery.Message.Chat.ID
+		session, err := loadSession(chatId)
+		if err != nil {
+			log.Error("error loading session", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		if !session.In(StateWithdrawBank, StateWithdrawBankSearch) {
+			return sendSessionExpired(callbackQuery)
+		}
+		return getBanks(chatId)
+	}
+
+	if data == "add_beneficiary" {
+		chatId := callbackQuery.Message.Chat.ID
+		user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
+		if err != nil {
+			log.Error("error fetching user by telegram id", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		if err := accountService.Guard(user, "add_beneficiary"); err != nil {
+			return sendAccountFrozen(chatId, user)
+		}
+		if user.PasswordHash == "" {
+			text := "You have not set a password yet. Use /set_password to create one."
+			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chatId})
+		}
+		count, err := beneficiaryService.CountBeneficiaries(user.ID)
+		if err != nil {
+			log.Error("error counting beneficiaries", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		if count >= services.MaxBeneficiaries {
+			text := fmt.Sprintf("You can save up to %d accounts. Remove one first.", services.MaxBeneficiaries)
+			return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chatId})
+		}
+		if _, err := startSession(chatId, StateBeneficiaryBank); err != nil {
+			log.Error("error starting session", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		return getBanks(chatId)
+	}
+
+	if strings.HasPrefix(data, "remove_beneficiary:") {
+		chatId := callbackQuery.Message.Chat.ID
+		beneficiaryID, err := uuid.Parse(strings.TrimPrefix(data, "remove_beneficiary:"))
+		if err != nil {
+			return sendSessionExpired(callbackQuery)
+		}
+		user, err := telegramRepo.FindUserByTelegramID(int(telegramId))
+		if err != nil {
+			log.Error("error fetching user by telegram id", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		beneficiary, err := beneficiaryService.RemoveBeneficiary(user.ID, beneficiaryID)
+		if errors.Is(err, services.ErrBeneficiaryNotFound) {
+			return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
+				CallbackQueryID: callbackQuery.ID,
+				Text:            "This account was already removed.",
+			})
+		}
+		if err != nil {
+			log.Error("error removing beneficiary", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		text := fmt.Sprintf("🗑 <b>%s</b> has been removed.", html.EscapeString(beneficiary.Nickname))
+		if err := Telegram.SendUserMessage(TelegramMessage{Text: text, User: chatId}); err != nil {
+			return err
+		}
+		return sendBeneficiaries(chatId, user)
+	}
+
 	if data == "confirm_withdrawal" {
 		chatId := callbackQuery.Message.Chat.ID
-		err := database.SetRedisKey(fmt.Sprintf(common.RedisConfirmWithdrawalPasswordKey, chatId), "true", 0)
+		session, err := loadSession(chatId)
 		if err != nil {
-			log.Error("error setting redis key", zap.Error(err))
+			log.Error("error loading session", zap.Error(err))
+			return sendErrorMessage(chatId)
+		}
+		if !session.In(StateWithdrawConfirm, StateWithdrawPassword) {
+			return sendSessionExpired(callbackQuery)
+		}
+		if err := session.Transition(StateWithdrawPassword); err != nil {
+			log.Error("error updating session", zap.Error(err))
+			return sendErrorMessage(chatId)
 		}
 
 		return Telegram.SendUserMessage(TelegramMessage{
@@ -1462,6 +2173,10 @@ func handleCallback(callbackQuery *tgApi.CallbackQuery) error {
 	}
 
 	if data == "cancel_withdrawal" {
+		session := &Session{ChatID: callbackQuery.Message.Chat.ID}
+		if err := session.Clear(); err != nil {
+			log.Error("error clearing session", zap.Error(err))
+		}
 		return Telegram.SendUserMessage(TelegramMessage{
 			Text:      "***Withdrawal process terminated***",
 			User:      callbackQuery.Message.Chat.ID,
@@ -1472,19 +2187,374 @@ func handleCallback(callbackQuery *tgApi.CallbackQuery) error {
 	return nil
 }
 
-func getFooter() (string, error) {
-	rate, err := rateService.GetCurrentRate()
+func sendAccountFrozen(chatID int64, user *database.User) error {
+	text := "🔒 <b>Your account is locked.</b>\n\nUse /unlock_account to unlock it."
+	if user.FrozenBy != common.FrozenByUser {
+		text = "🔒 <b>Your account has been frozen by our team.</b>\n\nPlease contact support for help."
+	}
+	return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chatID})
+}
+
+// bankStates returns the bank, bank search and account number states of the
+// flow the session is in, so withdrawals and new beneficiaries share the bank
+// picker. All three are empty when the session is in neither flow.
+func bankStates(session *Session) (bank, search, accountNumber State) {
+	switch {
+	case session.In(StateWithdrawBank, StateWithdrawBankSearch, StateWithdrawAccountNumber):
+		return StateWithdrawBank, StateWithdrawBankSearch, StateWithdrawAccountNumber
+	case session.In(StateBeneficiaryBank, StateBeneficiaryBankSearch, StateBeneficiaryAccountNumber):
+		return StateBeneficiaryBank, StateBeneficiaryBankSearch, StateBeneficiaryAccountNumber
+	}
+	return "", "", ""
+}
+
+func sendBeneficiaries(chatID int64, user *database.User) error {
+	beneficiaries, err := beneficiaryService.ListBeneficiaries(user.ID)
+	if err != nil {
+		log.Error("error fetching beneficiaries", zap.Error(err))
+		return sendErrorMessage(chatID)
+	}
+
+	var (
+		m       strings.Builder
+		buttons [][]tgApi.InlineKeyboardButton
+	)
+	m.WriteString("🏦 <b>Saved Accounts</b>\n\n")
+	if len(beneficiaries) == 0 {
+		m.WriteString("You have no saved accounts yet. Saved accounts can be picked when you withdraw.")
+	}
+	for _, beneficiary := range beneficiaries {
+		m.WriteString(fmt.Sprintf("• <b>%s</b>: %s, %s %s",
+			html.EscapeString(beneficiary.Nickname),
+			html.EscapeString(beneficiary.AccountName),
+			html.EscapeString(beneficiary.BankName),
+			maskAccountNumber(beneficiary.AccountNumber),
+		))
+		if time.Now().Before(beneficiary.ActiveFrom) {
+			m.WriteString(fmt.Sprintf(" (⏳ from %s)", beneficiary.ActiveFrom.Format("02 Jan 2006, 03:04 PM")))
+		}
+		m.WriteString("\n")
+		buttons = append(buttons, []tgApi.InlineKeyboardButton{
+			{
+				Text:         fmt.Sprintf("🗑 Remove %s", beneficiary.Nickname),
+				CallbackData: helpers.StrPtr(fmt.Sprintf("remove_beneficiary:%s", beneficiary.ID)),
+			},
+		})
+	}
+	if len(beneficiaries) < services.MaxBeneficiaries {
+		buttons = append(buttons, []tgApi.InlineKeyboardButton{
+			{Text: "➕ Add Account", CallbackData: helpers.StrPtr("add_beneficiary")},
+		})
+	}
+
+	replyMarkup := tgApi.InlineKeyboardMarkup{InlineKeyboard: buttons}
+	return Telegram.SendUserMessage(TelegramMessage{Text: m.String(), User: chatID, ReplyMarkup: replyMarkup})
+}
+
+// getPayoutAccounts lets the user pick a saved account for a withdrawal and
+// falls back to the bank list when they have none.
+func getPayoutAccounts(chatID int64, user *database.User) error {
+	beneficiaries, err := beneficiaryService.ListBeneficiaries(user.ID)
+	if err != nil {
+		log.Error("error fetching beneficiaries", zap.Error(err))
+	}
+	if len(beneficiaries) == 0 {
+		return getBanks(chatID)
+	}
+
+	var buttons [][]tgApi.InlineKeyboardButton
+	for _, beneficiary := range beneficiaries {
+		label := fmt.Sprintf("%s · %s %s", beneficiary.Nickname, beneficiary.BankName, maskAccountNumber(beneficiary.AccountNumber))
+		if time.Now().Before(beneficiary.ActiveFrom) {
+			label = "⏳ " + label
+		}
+		buttons = append(buttons, []tgApi.InlineKeyboardButton{
+			{
+				Text:         label,
+				CallbackData: helpers.StrPtr(fmt.Sprintf("pay_beneficiary:%s", beneficiary.ID)),
+			},
+		})
+	}
+	buttons = append(buttons, []tgApi.InlineKeyboardButton{
+		{Text: "🏦 Another Account", CallbackData: helpers.StrPtr("other_bank")},
+	})
+	replyMarkup := tgApi.InlineKeyboardMarkup{InlineKeyboard: buttons}
+	return Telegram.SendUserMessage(TelegramMessage{
+		Text:        "Please choose where to send the money:",
+		User:        chatID,
+		ReplyMarkup: replyMarkup,
+	})
+}
+
+func formatWithdrawalLimits(status *services.WithdrawalLimitStatus) string {
+	formatAmount := func(amount decimal.Decimal, limited bool) string {
+		if !limited {
+			return "No limit"
+		}
+		return "₦" + helpers.CommaDecimal(amount)
+	}
+
+	var m strings.Builder
+	m.WriteString("📊 *Your Withdrawal Limits*\n\n")
+	m.WriteString(fmt.Sprintf("🏷 *Tier:* %s\n\n", status.Tier))
+	m.WriteString(fmt.Sprintf("🔹 *Per withdrawal:* %s\n", formatAmount(status.Limit.PerTransaction, status.Limit.PerTransaction.IsPositive())))
+	daily, limited := status.DailyRemaining()
+	m.WriteString(fmt.Sprintf("🔹 *Left today:* %s", formatAmount(daily, limited)))
+	if limited {
+		m.WriteString(fmt.Sprintf(" of %s", formatAmount(status.Limit.Daily, true)))
+	}
+	weekly, limited := status.WeeklyRemaining()
+	m.WriteString(fmt.Sprintf("\n🔹 *Left this week:* %s", formatAmount(weekly, limited)))
+	if limited {
+		m.WriteString(fmt.Sprintf(" of %s", formatAmount(status.Limit.Weekly, true)))
+	}
+	if hourly, limited := status.HourlyRemaining(); limited {
+		m.WriteString(fmt.Sprintf("\n🔹 *Withdrawals left this hour:* %d of %d", hourly, status.Limit.HourlyCount))
+	}
+	m.WriteString("\n\nLimits include the withdrawal fee. Today and this week mean the last 24 hours and 7 days. " +
+		"A withdrawal over a limit is reviewed by our team before it is sent.")
+	return m.String()
+}
+
+func reviewReasonText(reason string) string {
+	switch reason {
+	case common.WithdrawalReviewPerTransaction:
+		return "it is over your per-withdrawal limit"
+	case common.WithdrawalReviewDaily:
+		return "it goes over your daily limit"
+	case common.WithdrawalReviewWeekly:
+		return "it goes over your weekly limit"
+	case common.WithdrawalReviewHourlyCount:
+		return "you reached your number of withdrawals for the hour"
+	case common.WithdrawalReviewThreshold:
+		return "it is a large withdrawal"
+	case common.WithdrawalReviewNewPayee:
+		return "it is your first withdrawal to this account"
+	default:
+		return "of your withdrawal limits"
+	}
+}
+
+func sendBalanceBelowFee(chatID int64, balance decimal.Decimal, fee services.Fee) error {
+	text := fmt.Sprintf("😕 *Nothing to withdraw.*\n\n💰 Your balance is *₦%s*, which doesn't cover the *₦%s* withdrawal fee.",
+		helpers.CommaDecimal(balance), helpers.CommaDecimal(fee.Amount))
+	return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chatID, ParseMode: "Markdown"})
+}
+
+// confirmWithdrawAllAgain shows the new balance after it changed under a
+// full withdrawal and asks the user to confirm it for the same account.
+func confirmWithdrawAllAgain(chatID int64, user *database.User, bankCode, accountNumber string) error {
+	balance, fee, ok, err := withdrawalService.WithdrawableBalance(user.ID.String())
+	if err != nil {
+		log.Error("failed to fetch user wallets", zap.Error(err))
+		return sendErrorMessage(chatID)
+	}
+	if !ok {
+		return sendBalanceBelowFee(chatID, balance, fee)
+	}
+	accountDetails, err := withdrawalService.ValidateBankAccount(accountNumber, bankCode)
+	if err != nil {
+		log.Error("error validating withdrawal account", zap.Error(err))
+		return sendErrorMessage(chatID)
+	}
+	bank, err := withdrawalService.GetBankByCode(bankCode)
+	if err != nil {
+		log.Error("error fetching bank", zap.Error(err))
+		return sendErrorMessage(chatID)
+	}
+	session, err := startSession(chatID, StateWithdrawBank)
+	if err != nil {
+		log.Error("error starting session", zap.Error(err))
+		return sendErrorMessage(chatID)
+	}
+	session.Data[sessionAmount] = balance.String()
+	session.Data[sessionWithdrawAll] = "true"
+	session.Data[sessionBankCode] = bankCode
+	session.Data[sessionAccountNumber] = accountNumber
+	if err := session.Transition(StateWithdrawConfirm); err != nil {
+		log.Error("error updating session", zap.Error(err))
+		return sendErrorMessage(chatID)
+	}
+	err = Telegram.SendUserMessage(TelegramMessage{
+		Text:      "⚠️ *Your balance has changed.*\n\n🔹 Nothing was sent. Please check the new amount below and confirm again.",
+		User:      chatID,
+		ParseMode: "Markdown",
+	})
+	if err != nil {
+		return err
+	}
+	return sendWithdrawalDetails(chatID, user, balance, accountDetails, bank.Name)
+}
+
+// sendWithdrawAllSummary shows what a full withdrawal pays out and, when the
+// user has been paid before, offers the account of their last withdrawal.
+func sendWithdrawAllSummary(chatID int64, user *database.User, balance decimal.Decimal) error {
+	fee, err := withdrawalService.WithdrawalFee(user, balance)
+	if err != nil {
+		log.Error("error pricing withdrawal fee", zap.Error(err))
+		return sendErrorMessage(chatID)
+	}
+	var m strings.Builder
+	m.WriteString("💸 *Withdraw All*\n\n")
+	m.WriteString(fmt.Sprintf("💰 *Balance:* ₦%s\n", helpers.CommaDecimal(balance)))
+	m.WriteString(feeLine("Withdrawal fee", fee, nairaAmount))
+	m.WriteString(fmt.Sprintf("💵 *You receive:* ₦%s\n", helpers.CommaDecimal(balance.Sub(fee.Amount))))
+
+	message := TelegramMessage{User: chatID, ParseMode: "Markdown"}
+	last, err := withdrawalRepo.GetLastCompletedWithdrawal(user.ID)
+	if err != nil {
+		log.Error("error fetching last withdrawal account", zap.Error(err))
+	}
+	if last != nil {
+		m.WriteString(fmt.Sprintf("\n🔹 Send it to your last account, *%s* (%s), or choose an account below.", maskAccountNumber(last.AccountNumber), last.BankName))
+		message.ReplyMarkup = tgApi.InlineKeyboardMarkup{InlineKeyboard: [][]tgApi.InlineKeyboardButton{
+			{
+				{Text: "🏦 Use Last Account", CallbackData: helpers.StrPtr("use_last_account")},
+			},
+		}}
+	}
+	message.Text = m.String()
+	return Telegram.SendUserMessage(message)
+}
+
+func sendWithdrawalDetails(chatID int64, user *database.User, amount decimal.Decimal, account *services.AccountDetails, bankName string) error {
+	fee, err := withdrawalService.WithdrawalFee(user, amount)
+	if err != nil {
+		log.Error("error pricing withdrawal fee", zap.Error(err))
+		return sendErrorMessage(chatID)
+	}
+	var m strings.Builder
+	m.WriteString("📜 *Withdrawal Details*\n")
+	m.WriteString("  ━━━━━━━━━━━━━━  \n")
+	m.WriteString(fmt.Sprintf("🔖 *Amount:* ₦%s\n", helpers.CommaDecimal(amount)))
+	m.WriteString(feeLine("Withdrawal fee", fee, nairaAmount))
+	m.WriteString(fmt.Sprintf(
+		"💵 *You receive:* ₦%s\n"+
+			"🔖 *Account Name:* %s\n"+
+			"🆔 *Account Number:* `%s`\n"+
+			"💰 *Bank:* %s\n"+
+			"  ━━━━━━━━━━━━━━  \n",
+		helpers.CommaDecimal(amount.Sub(fee.Amount)),
+		account.AccountName,
+		account.AccountNumber,
+		bankName,
+	))
+	buttons := [][]tgApi.InlineKeyboardButton{
+		{
+			{Text: "Confirm", CallbackData: helpers.StrPtr("confirm_withdrawal")},
+		},
+		{
+			{Text: "Cancel", CallbackData: helpers.StrPtr("cancel_withdrawal")},
+		},
+	}
+	replyMarkup := tgApi.InlineKeyboardMarkup{InlineKeyboard: buttons}
+
+	return Telegram.SendUserMessage(TelegramMessage{Text: m.String(), User: chatID, ParseMode: "markdown", ReplyMarkup: &replyMarkup})
+}
+
+func maskAccountNumber(accountNumber string) string {
+	if len(accountNumber) <= 4 {
+		return accountNumber
+	}
+	return strings.Repeat("•", len(accountNumber)-4) + accountNumber[len(accountNumber)-4:]
+}
+
+func sendWithdrawalCooldown(chatID int64, until time.Time) error {
+	text := fmt.Sprintf("⏸ *Withdrawals are paused.*\n\nYour password was reset recently, so withdrawals reopen on %s.",
+		until.Format("02 Jan 2006, 03:04 PM"))
+	return Telegram.SendUserMessage(TelegramMessage{Text: text, User: chatID, ParseMode: "Markdown"})
+}
+
+// maskEmail hides most of the local part of an address, e.g. j***@mail.com.
+func maskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return email
+	}
+	return local[:1] + "***@" + domain
+}
+
+// sendSessionExpired answers a button that belongs to a flow the chat is no
+// longer in, either because it timed out or another flow replaced it.
+func sendSessionExpired(callbackQuery *tgApi.CallbackQuery) error {
+	return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
+		CallbackQueryID: callbackQuery.ID,
+		Text:            "This session has expired. Please send the command again.",
+		ShowAlert:       true,
+	})
+}
+
+func getFooter(assetID string) (string, error) {
+	if assetID != "" {
+		rate, err := rateService.GetCurrentRate(uuid.MustParse(assetID), common.RateSideSell)
+		if err != nil {
+			log.Error("Error fetching exchange rate", zap.Error(err))
+			return "", err
+		}
+
+		rateText := "\n\n💱 *Current Exchange Rate:* \n"
+		rateText += fmt.Sprintf("📊 *₦%s / $*\n", rate.Rate.StringFixed(2))
+
+		return rateText, nil
+	}
+
+	rates, err := rateService.GetActiveAssetRates(common.RateSideSell)
 	if err != nil {
-		log.Error("Error fetching exchange rate", zap.Error(err))
+		log.Error("Error fetching exchange rates", zap.Error(err))
 		return "", err
 	}
 
-	rateText := "\n\n💱 *Current Exchange Rate:* \n"
-	rateText += fmt.Sprintf("📊 *₦%.2f / $*\n", rate.Rate)
+	rateText := "\n\n💱 *Current Exchange Rates:* \n"
+	for _, rate := range rates {
+		rateText += fmt.Sprintf("📊 *%s: ₦%s*\n", formatAssetName(rate.Symbol, rate.Standard), rate.Rate.StringFixed(2))
+	}
 
 	return rateText, nil
 }
 
+// formatQuote renders a locked rate with the time left on it. Telegram does not
+// update messages on its own, so the countdown is refreshed from a button.
+func formatQuote(quote *database.Quote, asset *database.Asset) (string, tgApi.InlineKeyboardMarkup) {
+	var (
+		sb         strings.Builder
+		remaining  = time.Until(quote.ExpiresAt)
+		afterwards = "the live rate"
+	)
+	if services.QuoteExpiryPolicy() == common.QuoteExpiryPolicyLowerOf {
+		afterwards = "the lower of this rate and the live rate"
+	}
+
+	sb.WriteString(fmt.Sprintf("🔒 *Locked Rate:* ₦%s per %s\n", quote.Rate.StringFixed(2), formatAssetName(asset.Symbol, asset.Standard)))
+	if remaining > 0 && quote.Status == common.QuoteStatusActive {
+		sb.WriteString(fmt.Sprintf("⏳ *Valid for:* %s (until %s)\n\n", formatCountdown(remaining), quote.ExpiresAt.Format("03:04 PM")))
+		sb.WriteString(fmt.Sprintf("Deposits that land within this window are paid at the locked rate. After it expires you get %s.", afterwards))
+		return sb.String(), tgApi.InlineKeyboardMarkup{InlineKeyboard: [][]tgApi.InlineKeyboardButton{
+			{{Text: "🔄 Refresh", CallbackData: helpers.StrPtr(fmt.Sprintf("quote_status:%s", quote.ID))}},
+		}}
+	}
+
+	sb.WriteString("⌛ *This quote has expired.*\n\n")
+	sb.WriteString(fmt.Sprintf("Deposits to this address are now paid at %s. Lock a new rate before sending.", afterwards))
+	return sb.String(), tgApi.InlineKeyboardMarkup{InlineKeyboard: [][]tgApi.InlineKeyboardButton{
+		{{Text: "🔒 Lock New Rate", CallbackData: helpers.StrPtr(fmt.Sprintf("quote_renew:%s", quote.ID))}},
+	}}
+}
+
+func formatCountdown(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d >= time.Hour {
+		return fmt.Sprintf("%dh %02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dm %02ds", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+func formatAssetName(symbol, standard string) string {
+	if standard == "" {
+		return strings.ToUpper(symbol)
+	}
+	return fmt.Sprintf("%s (%s)", strings.ToUpper(symbol), strings.ToUpper(standard))
+}
+
 func getBanks(chatID int64) error {
 	page := 1
 
@@ -1612,21 +2682,20 @@ func SendTelegramUserMessage(chatId int64, message string) error {
 	return Telegram.SendUserMessage(TelegramMessage{Text: message, User: chatId, ParseMode: "markdown"})
 }
 
-func validateAmount(input string) (float64, error) {
+func validateAmount(input string) (decimal.Decimal, error) {
 	input = strings.TrimSpace(input)
 	if input == "" {
-		return 0, errors.New("input cannot be empty")
+		return decimal.Zero, errors.New("input cannot be empty")
 	}
-	amount, err := strconv.ParseFloat(input, 64)
+	amount, err := decimal.NewFromString(input)
 	if err != nil {
-		return 0, errors.New("invalid amount, please enter a valid number")
+		return decimal.Zero, errors.New("invalid amount, please enter a valid number")
 	}
-	if amount <= 0 {
-		return 0, errors.New("amount must be greater than zero")
+	if !amount.IsPositive() {
+		return decimal.Zero, errors.New("amount must be greater than zero")
 	}
-	parts := strings.Split(input, ".")
-	if len(parts) == 2 && len(parts[1]) > 2 {
-		return 0, errors.New("amount cannot have more than 2 decimal places")
+	if !amount.Equal(amount.Truncate(2)) {
+		return decimal.Zero, errors.New("amount cannot have more than 2 decimal places")
 	}
 	return amount, nil
 }
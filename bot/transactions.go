@@ -0,0 +1,356 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/ShowBaba/kagewallet/helpers"
+	log "github.com/ShowBaba/kagewallet/logging"
+	"github.com/ShowBaba/kagewallet/services"
+	tgApi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const transactionDateFormat = "02 Jan 2006, 03:04 PM"
+
+// transactionHistory lists one page of the user's transactions with a button
+// to open each of them and buttons to move between pages.
+func transactionHistory(user *database.User, page int) (string, tgApi.InlineKeyboardMarkup, error) {
+	transactions, err := transactionService.FetchUserTransactions(user.ID.String(), fetchTransactionLimit, (page-1)*fetchTransactionLimit)
+	if err != nil {
+		return "", tgApi.InlineKeyboardMarkup{}, fmt.Errorf("error fetching transactions: %v", err)
+	}
+	total, err := transactionService.FetchUserTransactionCount(user.ID.String())
+	if err != nil {
+		return "", tgApi.InlineKeyboardMarkup{}, fmt.Errorf("error counting transactions: %v", err)
+	}
+
+	totalPages := (total + fetchTransactionLimit - 1) / fetchTransactionLimit
+	if totalPages == 0 {
+		return "📭 *You have no transactions yet.*", tgApi.InlineKeyboardMarkup{InlineKeyboard: [][]tgApi.InlineKeyboardButton{}}, nil
+	}
+
+	var (
+		m       strings.Builder
+		buttons [][]tgApi.InlineKeyboardButton
+	)
+	m.WriteString(fmt.Sprintf("*Your Transaction History (Page %d of %d):*\n\n", page, totalPages))
+	for i, tx := range transactions {
+		number := (page-1)*fetchTransactionLimit + i + 1
+		m.WriteString(fmt.Sprintf("*%d.* %s %s · `%s %s`\n      %s · %s\n\n",
+			number,
+			transactionIcon(tx.Type),
+			transactionTypeLabel(tx.Type),
+			tx.Amount,
+			strings.ToUpper(tx.AssetSymbol),
+			statusLabel(tx.Status),
+			tx.CreatedAt.Format(transactionDateFormat),
+		))
+		buttons = append(buttons, []tgApi.InlineKeyboardButton{
+			tgApi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("%d. %s %s %s", number, transactionTypeLabel(tx.Type), tx.Amount, strings.ToUpper(tx.AssetSymbol)),
+				fmt.Sprintf("transaction:%s:%d", tx.TransactionID, page),
+			),
+		})
+	}
+	m.WriteString("🔹 Tap a transaction to see its details.")
+
+	var pager []tgApi.InlineKeyboardButton
+	if page > 1 {
+		pager = append(pager, tgApi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("⬅️ Previous Page (%d)", page-1),
+			fmt.Sprintf("transactions_page:%d", page-1),
+		))
+	}
+	if total > page*fetchTransactionLimit {
+		pager = append(pager, tgApi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("Next Page (%d) ➡️", page+1),
+			fmt.Sprintf("transactions_page:%d", page+1),
+		))
+	}
+	if len(pager) > 0 {
+		buttons = append(buttons, pager)
+	}
+	return m.String(), tgApi.InlineKeyboardMarkup{InlineKeyboard: buttons}, nil
+}
+
+// handleTransactionCallback pages through the history, opens a transaction
+// and sends its receipt. It reports whether the callback was one of these.
+func handleTransactionCallback(callbackQuery *tgApi.CallbackQuery) (bool, error) {
+	var (
+		data   = callbackQuery.Data
+		chatID = callbackQuery.Message.Chat.ID
+	)
+	if !strings.HasPrefix(data, "transactions_page:") && !strings.HasPrefix(data, "transaction:") &&
+		!strings.HasPrefix(data, "transaction_receipt:") {
+		return false, nil
+	}
+
+	if err := Telegram.SendLoader(chatID); err != nil {
+		log.Error("error sending loader", zap.Error(err))
+		return true, sendErrorMessage(chatID)
+	}
+	user, err := telegramRepo.FindUserByTelegramID(int(callbackQuery.From.ID))
+	if err != nil {
+		log.Error("error fetching user by telegram id", zap.Error(err))
+		return true, Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
+			CallbackQueryID: callbackQuery.ID,
+			Text:            "Failed to process your selection. Please try again.",
+			ShowAlert:       true,
+		})
+	}
+
+	switch {
+	case strings.HasPrefix(data, "transactions_page:"):
+		page, err := strconv.Atoi(strings.TrimPrefix(data, "transactions_page:"))
+		if err != nil || page < 1 {
+			log.Error("invalid page number in callback", zap.Error(err))
+			return true, Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
+				CallbackQueryID: callbackQuery.ID,
+				Text:            "Invalid page number.",
+			})
+		}
+		text, replyMarkup, err := transactionHistory(user, page)
+		if err != nil {
+			log.Error("failed to fetch user transactions", zap.Error(err))
+			return true, Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
+				CallbackQueryID: callbackQuery.ID,
+				Text:            "Failed to fetch transactions. Please try again later.",
+			})
+		}
+		return true, editTransactionMessage(callbackQuery, text, replyMarkup)
+
+	case strings.HasPrefix(data, "transaction:"):
+		parts := strings.Split(strings.TrimPrefix(data, "transaction:"), ":")
+		transactionID, err := uuid.Parse(parts[0])
+		if err != nil {
+			return true, sendTransactionNotFound(callbackQuery)
+		}
+		page := 1
+		if len(parts) > 1 {
+			if p, err := strconv.Atoi(parts[1]); err == nil && p > 0 {
+				page = p
+			}
+		}
+		detail, err := transactionService.GetTransactionDetail(user.ID, transactionID)
+		if err != nil {
+			if errors.Is(err, services.ErrTransactionNotFound) {
+				return true, sendTransactionNotFound(callbackQuery)
+			}
+			log.Error("error fetching transaction detail", zap.Error(err))
+			return true, sendErrorMessage(chatID)
+		}
+
+		buttons := [][]tgApi.InlineKeyboardButton{
+			{tgApi.NewInlineKeyboardButtonData("🧾 Share Receipt", "transaction_receipt:"+transactionID.String())},
+		}
+		if detail.ExplorerURL != "" {
+			buttons = append(buttons, []tgApi.InlineKeyboardButton{
+				tgApi.NewInlineKeyboardButtonURL("🔗 View on Explorer", detail.ExplorerURL),
+			})
+		}
+		buttons = append(buttons, []tgApi.InlineKeyboardButton{
+			tgApi.NewInlineKeyboardButtonData("⬅️ Back to History", fmt.Sprintf("transactions_page:%d", page)),
+		})
+		return true, editTransactionMessage(callbackQuery, formatTransactionDetail(detail), tgApi.InlineKeyboardMarkup{InlineKeyboard: buttons})
+
+	default:
+		transactionID, err := uuid.Parse(strings.TrimPrefix(data, "transaction_receipt:"))
+		if err != nil {
+			return true, sendTransactionNotFound(callbackQuery)
+		}
+		detail, err := transactionService.GetTransactionDetail(user.ID, transactionID)
+		if err != nil {
+			if errors.Is(err, services.ErrTransactionNotFound) {
+				return true, sendTransactionNotFound(callbackQuery)
+			}
+			log.Error("error fetching transaction detail", zap.Error(err))
+			return true, sendErrorMessage(chatID)
+		}
+		if err := Telegram.SendCallbackResponse(common.TelegramCallbackResponse{CallbackQueryID: callbackQuery.ID}); err != nil {
+			log.Error("error answering callback", zap.Error(err))
+		}
+		return true, Telegram.SendUserMessage(TelegramMessage{
+			Text: fmt.Sprintf("🧾 Receipt for transaction `%s`", detail.Transaction.Reference),
+			User: chatID,
+			File: tgApi.FileBytes{
+				Name:  fmt.Sprintf("receipt-%s.pdf", detail.Transaction.Reference),
+				Bytes: services.RenderReceiptPDF(transactionReceipt(detail)),
+			},
+			ParseMode: "Markdown",
+		})
+	}
+}
+
+func editTransactionMessage(callbackQuery *tgApi.CallbackQuery, text string, replyMarkup tgApi.InlineKeyboardMarkup) error {
+	err := Telegram.EditMessage(TelegramMessageEdit{
+		ChatID:      callbackQuery.Message.Chat.ID,
+		MessageID:   callbackQuery.Message.MessageID,
+		NewText:     text,
+		ParseMode:   "Markdown",
+		ReplyMarkup: &replyMarkup,
+	})
+	if err != nil {
+		log.Error("error editing transactions message", zap.Error(err))
+		return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
+			CallbackQueryID: callbackQuery.ID,
+			Text:            "Failed to update the message.",
+		})
+	}
+	return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{CallbackQueryID: callbackQuery.ID})
+}
+
+func sendTransactionNotFound(callbackQuery *tgApi.CallbackQuery) error {
+	return Telegram.SendCallbackResponse(common.TelegramCallbackResponse{
+		CallbackQueryID: callbackQuery.ID,
+		Text:            "This transaction could not be found.",
+		ShowAlert:       true,
+	})
+}
+
+// transactionRows are the facts about a transaction shared by its detail
+// view and its receipt.
+func transactionRows(detail *services.TransactionDetail) []services.ReceiptRow {
+	var (
+		tx     = detail.Transaction
+		symbol = strings.ToUpper(detail.Asset.Symbol)
+		rows   = []services.ReceiptRow{
+			{Label: "Type", Value: transactionTypeLabel(tx.Type)},
+			{Label: "Reference", Value: tx.Reference},
+			{Label: "Currency", Value: formatAssetName(detail.Asset.Symbol, detail.Asset.Standard)},
+		}
+	)
+	if tx.AssetID.String() == common.NairaAssetID {
+		rows = append(rows, services.ReceiptRow{Label: "Amount", Value: nairaAmount(tx.Amount)})
+	} else {
+		rows = append(rows, services.ReceiptRow{Label: "Amount", Value: fmt.Sprintf("%s %s", tx.Amount, symbol)})
+	}
+
+	switch {
+	case detail.Conversion != nil:
+		to := strings.ToUpper(detail.ToAsset.Symbol)
+		rows = append(rows,
+			services.ReceiptRow{Label: "Rate", Value: fmt.Sprintf("1 %s = %s %s", symbol, detail.Conversion.Rate.Truncate(assetPlaces(detail.ToAsset)), to)},
+			services.ReceiptRow{Label: "Received", Value: fmt.Sprintf("%s %s", detail.Conversion.ToAmount, to)},
+		)
+	case detail.Rate.IsPositive():
+		rows = append(rows,
+			services.ReceiptRow{Label: "Rate", Value: "₦" + helpers.CommaFixed(detail.Rate, 2)},
+			services.ReceiptRow{Label: "Amount in Naira", Value: "₦" + helpers.CommaFixed(tx.Amount.Mul(detail.Rate), 2)},
+		)
+	}
+
+	for _, fee := range detail.Fees {
+		value := fmt.Sprintf("%s %s", fee.Amount, strings.ToUpper(fee.Symbol))
+		if fee.AssetID.String() == common.NairaAssetID {
+			value = nairaAmount(fee.Amount)
+		}
+		rows = append(rows, services.ReceiptRow{Label: feeOperationLabel(fee.Operation), Value: value})
+	}
+
+	if w := detail.Withdrawal; w != nil {
+		rows = append(rows,
+			services.ReceiptRow{Label: "Bank", Value: w.BankName},
+			services.ReceiptRow{Label: "Account Number", Value: maskAccountNumber(w.AccountNumber)},
+		)
+	}
+	if tx.AmountUSD.IsPositive() {
+		rows = append(rows, services.ReceiptRow{Label: "Amount in USD", Value: "$" + helpers.CommaFixed(tx.AmountUSD, 2)})
+	}
+	if detail.Network != nil && detail.Withdrawal == nil && detail.Conversion == nil {
+		rows = append(rows, services.ReceiptRow{Label: "Network", Value: detail.Network.Name})
+		if tx.Hash != "" {
+			rows = append(rows, services.ReceiptRow{Label: "Hash", Value: tx.Hash})
+		}
+		if tx.Confirmations > 0 {
+			rows = append(rows, services.ReceiptRow{Label: "Confirmations", Value: strconv.FormatInt(tx.Confirmations, 10)})
+		}
+	}
+	rows = append(rows,
+		services.ReceiptRow{Label: "Status", Value: statusLabel(tx.Status)},
+		services.ReceiptRow{Label: "Date", Value: tx.CreatedAt.Format(transactionDateFormat)},
+	)
+	return rows
+}
+
+func formatTransactionDetail(detail *services.TransactionDetail) string {
+	var m strings.Builder
+	m.WriteString(fmt.Sprintf("%s *Transaction Details*\n", transactionIcon(detail.Transaction.Type)))
+	m.WriteString("  ━━━━━━━━━━━━━━  \n")
+	for _, row := range transactionRows(detail) {
+		m.WriteString(fmt.Sprintf("*%s:* `%s`\n", row.Label, row.Value))
+	}
+	m.WriteString("  ━━━━━━━━━━━━━━  \n")
+
+	if len(detail.Events) > 0 {
+		m.WriteString("\n🕒 *Timeline*\n")
+		for _, event := range detail.Events {
+			m.WriteString(fmt.Sprintf("• %s — %s\n", statusLabel(event.Status), event.CreatedAt.Format(transactionDateFormat)))
+		}
+	}
+	return m.String()
+}
+
+// transactionReceipt is the detail view as a receipt, with the timeline as
+// its last rows.
+func transactionReceipt(detail *services.TransactionDetail) services.Receipt {
+	receipt := services.Receipt{
+		Title:  "KageWallet Transaction Receipt",
+		Rows:   transactionRows(detail),
+		Footer: "Generated by KageWallet. Keep this receipt for your records.",
+	}
+	for i, event := range detail.Events {
+		label := ""
+		if i == 0 {
+			label = "Timeline"
+		}
+		receipt.Rows = append(receipt.Rows, services.ReceiptRow{
+			Label: label,
+			Value: fmt.Sprintf("%s - %s", statusLabel(event.Status), event.CreatedAt.Format(transactionDateFormat)),
+		})
+	}
+	return receipt
+}
+
+func transactionTypeLabel(transactionType string) string {
+	switch transactionType {
+	case common.TransactionTypeConvert:
+		return "Conversion"
+	case "":
+		return "Transaction"
+	}
+	return strings.ToUpper(transactionType[:1]) + transactionType[1:]
+}
+
+func transactionIcon(transactionType string) string {
+	switch transactionType {
+	case "withdrawal":
+		return "📤"
+	case common.TransactionTypeConvert:
+		return "🔄"
+	}
+	return "📥"
+}
+
+func statusLabel(status string) string {
+	status = strings.ReplaceAll(status, "_", " ")
+	if status == "" {
+		return status
+	}
+	return strings.ToUpper(status[:1]) + status[1:]
+}
+
+func feeOperationLabel(operation string) string {
+	switch operation {
+	case common.FeeOperationConvert:
+		return "Conversion Fee"
+	case "":
+		return "Fee"
+	}
+	return strings.ToUpper(operation[:1]) + operation[1:] + " Fee"
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/ShowBaba/kagewallet/migrations"
+	"github.com/ShowBaba/kagewallet/repositories"
+)
+
+func runCommand(args []string) error {
+	switch args[0] {
+	case "ledger":
+		return runLedgerCommand(args[1:])
+	case "migrate":
+		return runMigrateCommand(args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+func runLedgerCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: ledger open|rebuild|check")
+	}
+
+	ledgerRepo := repositories.NewLedgerRepository(db)
+
+	switch args[0] {
+	case "open":
+		count, err := ledgerRepo.OpenWalletBalances()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("opened %d wallet(s) from their stored balance\n", count)
+	case "rebuild":
+		count, err := ledgerRepo.RebuildWalletBalances()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("rebuilt %d wallet balance(s) from the ledger\n", count)
+	case "check":
+		journals, err := ledgerRepo.GetUnbalancedJournals()
+		if err != nil {
+			return err
+		}
+		for _, journal := range journals {
+			fmt.Printf("unbalanced journal %s asset %s total %s\n", journal.JournalID, journal.AssetID, journal.Total)
+		}
+
+		drifts, err := ledgerRepo.GetWalletsOutOfSync()
+		if err != nil {
+			return err
+		}
+		for _, drift := range drifts {
+			fmt.Printf("wallet %s (user %s) balance %s ledger %s\n", drift.WalletID, drift.UserID, drift.Balance, drift.LedgerBalance)
+		}
+
+		if len(journals) > 0 || len(drifts) > 0 {
+			return fmt.Errorf("ledger check failed: %d unbalanced journal(s), %d wallet(s) out of sync", len(journals), len(drifts))
+		}
+		fmt.Println("ledger check passed")
+	default:
+		return fmt.Errorf("unknown ledger command %q", args[0])
+	}
+
+	return nil
+}
+
+func runMigrateCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up|down [steps]|status")
+	}
+
+	switch args[0] {
+	case "up":
+		ran, err := migrations.Up(db)
+		for _, migration := range ran {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(ran) == 0 {
+			fmt.Println("schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		reverted, err := migrations.Down(db, steps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+	case "status":
+		statuses, err := migrations.GetStatus(db)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, state)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+
+	return nil
+}
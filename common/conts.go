@@ -1,26 +1,155 @@
 package common
 
-import "fmt"
-
-const (
-	RedisPasswordSetupKey             = "passwordSetup:%d"
-	RedisWithdrawSetupKey             = "withdrawSetup:%d"
-	RedisWithdrawalAmountSetupKey     = "withdrawalAmountSetup:%d"
-	RedisSelectedBankKey              = "selectedBank:%d"
-	RedisBankAccountNumberKey         = "bankAccountNumber:%d"
-	RedisSetBankAccountNumberKey      = "setBankAccountNumber:%d"
-	RedisConfirmWithdrawalPasswordKey = "confirmWithdrawalPassword:%d"
-	RedisEmailSetupKey                = "emailSetup:%d"
-	RedisSearchBankKey                = "searchBank:%d"
-	RedisAssetSelectionKey            = "assetSelection:%d"
-	RedisActiveChatsKey               = "activeChats"
-	RedisNotificationKey              = "notifications"
-	RedisNotificationChannelKey       = "notificationChannel"
-	RedisMonnifyToken                 = "monnifyToken"
-	NairaAssetID                      = "0f0a0c3c-9a0a-4ec4-9be0-3ddea69327b3"
-	WithdrawalFee                     = 100
-)
-
-var GenerateRedisDeleteKeyPattern = func(chatId int64) string {
-	return fmt.Sprintf("passwordSetup:%d|emailSetup:%d", chatId, chatId)
-}
+const (
+	RedisSessionKey               = "session:%d"
+	RedisOTPCodeKey               = "otp:%s:%s"
+	RedisOTPAttemptsKey           = "otpAttempts:%s:%s"
+	RedisOTPRequestsKey           = "otpRequests:%s:%s"
+	RedisPasswordResetVerifiedKey = "passwordResetVerified:%s"
+	RedisActiveChatsKey           = "activeChats"
+	RedisNotificationKey          = "notifications"
+	RedisNotificationChannelKey   = "notificationChannel"
+	RedisMonnifyToken             = "monnifyToken"
+	RedisSellHaltedKey            = "sellHalted"
+	NairaAssetID                  = "0f0a0c3c-9a0a-4ec4-9be0-3ddea69327b3"
+)
+
+// NairaPlaces is the precision of Naira amounts: whole kobo.
+const NairaPlaces int32 = 2
+
+// Rate sides are named from the user's point of view: the sell rate is what a
+// user receives in Naira for one unit of crypto they sell to us.
+const (
+	RateSideBuy  = "buy"
+	RateSideSell = "sell"
+
+	RateSourceAdmin = "Admin"
+)
+
+// A quote locks the sell rate for deposits to one address until it expires.
+// After that the expiry policy decides between the live rate and the lower of
+// the live and quoted rates.
+const (
+	QuoteStatusActive  = "active"
+	QuoteStatusExpired = "expired"
+
+	QuoteExpiryPolicyCurrent = "current"
+	QuoteExpiryPolicyLowerOf = "lower_of"
+)
+
+const (
+	WebhookProviderBlockradar = "blockradar"
+	WebhookProviderMonnify    = "monnify"
+	WebhookProviderPaystack   = "paystack"
+
+	WebhookEventStatusReceived   = "received"
+	WebhookEventStatusProcessing = "processing"
+	WebhookEventStatusProcessed  = "processed"
+	WebhookEventStatusFailed     = "failed"
+	WebhookEventStatusDead       = "dead"
+)
+
+// Payout providers report transfer outcomes in their own words; they are
+// mapped onto these statuses.
+const (
+	PayoutProviderMonnify  = "monnify"
+	PayoutProviderPaystack = "paystack"
+
+	PayoutStatusPending  = "pending"
+	PayoutStatusSuccess  = "success"
+	PayoutStatusFailed   = "failed"
+	PayoutStatusReversed = "reversed"
+)
+
+// One-time codes are emailed to confirm sensitive account changes. Each
+// purpose keeps its own code, attempt counter and request limit.
+const (
+	OTPPurposePasswordReset = "password_reset"
+	OTPPurposeUnlock        = "unlock"
+)
+
+const (
+	SecurityEventAccountFrozen   = "account_frozen"
+	SecurityEventAccountUnfrozen = "account_unfrozen"
+	SecurityEventActionBlocked   = "action_blocked"
+	SecurityEventUnlockFailed    = "unlock_failed"
+
+	FrozenByUser  = "user"
+	FrozenByAdmin = "admin"
+)
+
+// Every user is in a tier, and the tier's row in withdrawal_limit decides how
+// much they can withdraw. A withdrawal over a limit is held and waits for
+// approval with the review reason of the first limit it breaks.
+const (
+	UserTierBasic    = "basic"
+	UserTierVerified = "verified"
+
+	WithdrawalStatusAwaitingApproval = "awaiting_approval"
+
+	WithdrawalReviewPerTransaction = "per_transaction_limit"
+	WithdrawalReviewDaily          = "daily_limit"
+	WithdrawalReviewWeekly         = "weekly_limit"
+	WithdrawalReviewHourlyCount    = "hourly_count_limit"
+	WithdrawalReviewThreshold      = "approval_threshold"
+	WithdrawalReviewNewPayee       = "new_payee"
+
+	WithdrawalDecisionApprove = "approve"
+	WithdrawalDecisionReject  = "reject"
+)
+
+// A deposit waits as confirming until its network's required confirmations
+// are reached. One below the asset's minimum is kept as below_minimum and is
+// never credited.
+const (
+	DepositStatusConfirming   = "confirming"
+	DepositStatusBelowMinimum = "below_minimum"
+)
+
+const (
+	DepositOutcomeCreated = "created"
+	DepositOutcomeUpdated = "updated"
+	DepositOutcomeSkipped = "skipped"
+)
+
+const (
+	LedgerSideDebit  = "debit"
+	LedgerSideCredit = "credit"
+
+	LedgerAccountUserWallet      = "user_wallet"
+	LedgerAccountFees            = "fees"
+	LedgerAccountMonnifyFloat    = "monnify_float"
+	LedgerAccountPaystackFloat   = "paystack_float"
+	LedgerAccountBlockradarFloat = "blockradar_float"
+	LedgerAccountOpeningBalance  = "opening_balance"
+	// LedgerAccountWithdrawalHold holds funds reserved for an in-flight
+	// withdrawal. Its entries carry the wallet id but are not part of the
+	// spendable balance.
+	LedgerAccountWithdrawalHold = "withdrawal_hold"
+	// LedgerAccountConversion is our position in each asset from user
+	// conversions: it takes in the source asset and pays out the target.
+	LedgerAccountConversion = "conversion"
+)
+
+const TransactionTypeConvert = "convert"
+
+// A transaction's timeline records its statuses, plus the approval decisions
+// on a withdrawal that was held for review.
+const (
+	TransactionEventApproved = "approved"
+	TransactionEventRejected = "rejected"
+)
+
+// Fees are charged per operation in the asset the operation charges: the
+// deposited asset for deposit, Naira for sell and withdrawal, and the source
+// asset for convert.
+const (
+	FeeOperationDeposit    = "deposit"
+	FeeOperationSell       = "sell"
+	FeeOperationWithdrawal = "withdrawal"
+	FeeOperationConvert    = "convert"
+
+	FeeKindFlat       = "flat"
+	FeeKindPercentage = "percentage"
+	FeeKindTiered     = "tiered"
+)
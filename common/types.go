@@ -4,6 +4,7 @@ import (
 	"time"
 
 	tgApi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/shopspring/decimal"
 )
 
 type SetPasswordInput struct {
@@ -12,12 +13,49 @@ type SetPasswordInput struct {
 }
 
 type CreateAssetInput struct {
-	Name         string `json:"name"`
-	Symbol       string `json:"symbol"`
-	Standard     string `json:"standard"`
-	Instructions string `json:"instructions"`
-	IsActive     bool   `json:"is_active"`
-	LogoURL      string `json:"logo_url"`
+	Name          string          `json:"name"`
+	Symbol        string          `json:"symbol"`
+	Standard      string          `json:"standard"`
+	Instructions  string          `json:"instructions"`
+	IsActive      bool            `json:"is_active"`
+	LogoURL       string          `json:"logo_url"`
+	NetworkID     string          `json:"network_id"`
+	TokenContract string          `json:"token_contract"`
+	Decimals      int             `json:"decimals"`
+	MinDeposit    decimal.Decimal `json:"min_deposit"`
+}
+
+type CreateNetworkInput struct {
+	Code                  string `json:"code"`
+	Name                  string `json:"name"`
+	ChainID               int64  `json:"chain_id"`
+	Provider              string `json:"provider"`
+	TokenStandard         string `json:"token_standard"`
+	WalletIDEnv           string `json:"wallet_id_env"`
+	APIKeyEnv             string `json:"api_key_env"`
+	RequiredConfirmations int64  `json:"required_confirmations"`
+	IsActive              bool   `json:"is_active"`
+	ExplorerURL           string `json:"explorer_url"`
+}
+
+// FeeScheduleInput is a new version of the fee schedule for its operation,
+// asset and tier. An empty asset_id or tier applies to all of them.
+type FeeScheduleInput struct {
+	Operation string          `json:"operation"`
+	AssetID   string          `json:"asset_id"`
+	Tier      string          `json:"tier"`
+	Kind      string          `json:"kind"`
+	Flat      decimal.Decimal `json:"flat"`
+	RateBPS   int64           `json:"rate_bps"`
+	MinFee    decimal.Decimal `json:"min_fee"`
+	MaxFee    decimal.Decimal `json:"max_fee"`
+	Bands     []FeeBandInput  `json:"bands"`
+}
+
+type FeeBandInput struct {
+	UpTo    decimal.Decimal `json:"up_to"`
+	Flat    decimal.Decimal `json:"flat"`
+	RateBPS int64           `json:"rate_bps"`
 }
 
 type GenerateAddressResponse struct {
@@ -119,6 +157,19 @@ type BlockradarEvent struct {
 	} `json:"data"`
 }
 
+type Deposit struct {
+	Reference        string
+	Hash             string
+	RecipientAddress string
+	Amount           string
+	AmountPaid       string
+	Currency         string
+	Status           string
+	Type             string
+	Confirmations    int
+	CreatedAt        time.Time
+}
+
 type Notification struct {
 	Payload   interface{} `json:"payload"`
 	Subject   string      `json:"subject"`
@@ -154,3 +205,15 @@ type MonnifyEvent struct {
 		Status                   string `json:"status"`
 	} `json:"eventData"`
 }
+
+type PaystackEvent struct {
+	Event string `json:"event"`
+	Data  struct {
+		Amount       int64  `json:"amount"`
+		Currency     string `json:"currency"`
+		Reference    string `json:"reference"`
+		Status       string `json:"status"`
+		TransferCode string `json:"transfer_code"`
+		Reason       string `json:"reason"`
+	} `json:"data"`
+}
@@ -24,6 +24,21 @@ func DeleteRedisKey(key string) error {
 	return RedisClient.Del(ctx, key).Err()
 }
 
+// Incr bumps a counter and starts its expiry on the first increment, which
+// makes it a fixed-window rate limiter.
+func Incr(key string, expiration time.Duration) (int64, error) {
+	count, err := RedisClient.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		if err := RedisClient.Expire(ctx, key, expiration).Err(); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
 func DeleteRedisKeysByPattern(pattern string) error {
 	keys, err := RedisClient.Keys(ctx, pattern).Result()
 	if err != nil {
@@ -55,6 +70,10 @@ func HGet(key, childKey string) (string, error) {
 	return value, nil
 }
 
+func HDel(key string, childKeys ...string) error {
+	return RedisClient.HDel(ctx, key, childKeys...).Err()
+}
+
 func RedisPublish(channel, key string) error {
 	return RedisClient.Publish(ctx, channel, key).Err()
 }
@@ -9,11 +9,20 @@ import (
 )
 
 type User struct {
-	ID           uuid.UUID
-	PasswordHash string
-	Email        string
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	ID              uuid.UUID
+	PasswordHash    string
+	Email           string
+	PasswordResetAt *time.Time
+	IsFrozen        bool
+	FrozenAt        *time.Time
+	FrozenReason    string
+	FrozenBy        string
+	Tier            string `gorm:"default:basic"`
+	// AutoConvert sells deposits for Naira as they arrive. When it is off
+	// the crypto is kept in the user's wallet for that asset.
+	AutoConvert bool `gorm:"default:true"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
@@ -57,12 +66,26 @@ func (t *TelegramCommandLog) BeforeCreate(tx *gorm.DB) (err error) {
 
 type Rate struct {
 	ID        uuid.UUID
-	Rate      float64
+	AssetID   uuid.UUID
+	Side      string
+	Rate      decimal.Decimal
 	Source    string
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
+type AssetRate struct {
+	AssetID   uuid.UUID       `json:"asset_id"`
+	RateID    uuid.UUID       `json:"rate_id"`
+	Symbol    string          `json:"symbol"`
+	Name      string          `json:"name"`
+	Standard  string          `json:"standard"`
+	Side      string          `json:"side"`
+	Rate      decimal.Decimal `json:"rate"`
+	Source    string          `json:"source"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
 func (e *Rate) BeforeCreate(tx *gorm.DB) (err error) {
 	e.CreatedAt = time.Now().Local()
 	e.UpdatedAt = time.Now().Local()
@@ -70,16 +93,69 @@ func (e *Rate) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+type Quote struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	AssetID   uuid.UUID
+	Address   string
+	RateID    uuid.UUID
+	Rate      decimal.Decimal
+	Status    string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (q *Quote) BeforeCreate(tx *gorm.DB) (err error) {
+	q.CreatedAt = time.Now().Local()
+	q.UpdatedAt = time.Now().Local()
+	q.ID = uuid.New()
+	return
+}
+
+// Asset is a token users can deposit. MinDeposit is in units of the asset:
+// smaller deposits are recorded but not credited. Deposit fees come from the
+// fee schedules.
 type Asset struct {
-	ID           uuid.UUID
-	Symbol       string
-	Name         string
-	LogoURL      string
-	Standard     string
-	Instructions string
-	IsActive     bool
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	ID            uuid.UUID
+	Symbol        string
+	Name          string
+	LogoURL       string
+	Standard      string
+	Instructions  string
+	IsActive      bool
+	NetworkID     *uuid.UUID
+	TokenContract string
+	Decimals      int
+	MinDeposit    decimal.Decimal
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// Network is a chain wallet at a custody provider. The wallet id and API key
+// are read from the env vars it names, so secrets stay out of the database.
+// Deposits are not credited before they have RequiredConfirmations.
+type Network struct {
+	ID                    uuid.UUID `json:"id"`
+	Code                  string    `json:"code"`
+	Name                  string    `json:"name"`
+	ChainID               int64     `json:"chain_id"`
+	Provider              string    `json:"provider"`
+	TokenStandard         string    `json:"token_standard"`
+	WalletIDEnv           string    `json:"wallet_id_env"`
+	APIKeyEnv             string    `json:"api_key_env"`
+	RequiredConfirmations int64     `json:"required_confirmations"`
+	IsActive              bool      `json:"is_active"`
+	ExplorerURL           string    `json:"explorer_url"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+func (n *Network) BeforeCreate(tx *gorm.DB) (err error) {
+	n.CreatedAt = time.Now().Local()
+	n.UpdatedAt = time.Now().Local()
+	n.ID = uuid.New()
+	return
 }
 
 func (a *Asset) BeforeCreate(tx *gorm.DB) (err error) {
@@ -112,7 +188,7 @@ type Transaction struct {
 	AssetID         uuid.UUID
 	Type            string
 	Amount          decimal.Decimal
-	AmountUSD       float64
+	AmountUSD       decimal.Decimal
 	Status          string
 	Reference       string
 	Hash            string
@@ -122,6 +198,9 @@ type Transaction struct {
 	RateID          uuid.UUID
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
+	// Fees are recorded with the transaction by the repository that
+	// creates or completes it.
+	Fees []TransactionFee `gorm:"-"`
 }
 
 func (a *Transaction) BeforeCreate(tx *gorm.DB) (err error) {
@@ -131,9 +210,12 @@ func (a *Transaction) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+// Wallet is a user's balance of one asset. Each user has at most one wallet
+// per asset.
 type Wallet struct {
 	ID        uuid.UUID
 	UserID    uuid.UUID
+	AssetID   uuid.UUID
 	Balance   decimal.Decimal
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -155,7 +237,9 @@ type Withdrawal struct {
 	UserID        uuid.UUID
 	Status        string
 	Amount        decimal.Decimal
-	Fee           int
+	Fee           decimal.Decimal
+	ReviewReason  string
+	Provider      string
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 }
@@ -167,30 +251,278 @@ func (w *Wallet) Withdrawal(tx *gorm.DB) (err error) {
 	return
 }
 
-type WalletWithDetails struct {
+// TransactionEvent is one status a transaction moved to and when.
+type TransactionEvent struct {
+	ID            uuid.UUID `json:"id"`
+	TransactionID uuid.UUID `json:"transaction_id"`
+	Status        string    `json:"status"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (e *TransactionEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	e.CreatedAt = time.Now().Local()
+	e.ID = uuid.New()
+	return
+}
+
+// Conversion records a swap of one crypto asset for another. Rate is the
+// cross rate in units of the target asset per unit of the source asset, and
+// the fee is charged in the source asset before converting.
+type Conversion struct {
+	ID            uuid.UUID
+	TransactionID uuid.UUID
+	UserID        uuid.UUID
+	FromAssetID   uuid.UUID
+	ToAssetID     uuid.UUID
+	FromAmount    decimal.Decimal
+	Fee           decimal.Decimal
+	ToAmount      decimal.Decimal
+	Rate          decimal.Decimal
+	FromRateID    uuid.UUID
+	ToRateID      uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (c *Conversion) BeforeCreate(tx *gorm.DB) (err error) {
+	c.CreatedAt = time.Now().Local()
+	c.UpdatedAt = time.Now().Local()
+	c.ID = uuid.New()
+	return
+}
+
+// FeeSchedule prices one operation, optionally for a single asset or tier.
+// A fee is Flat plus RateBPS basis points of the amount, or for a tiered
+// schedule the same from the first band that covers the amount, then kept
+// between MinFee and MaxFee (0 means no cap). Amounts are in the asset the
+// operation charges.
+type FeeSchedule struct {
+	ID           uuid.UUID       `json:"id"`
+	Operation    string          `json:"operation"`
+	AssetID      *uuid.UUID      `json:"asset_id"`
+	Tier         string          `json:"tier"`
+	Version      int             `json:"version"`
+	Kind         string          `json:"kind"`
+	Flat         decimal.Decimal `json:"flat"`
+	RateBPS      int64           `json:"rate_bps"`
+	MinFee       decimal.Decimal `json:"min_fee"`
+	MaxFee       decimal.Decimal `json:"max_fee"`
+	CreatedBy    string          `json:"created_by"`
+	SupersededAt *time.Time      `json:"superseded_at"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	Bands        []FeeBand       `json:"bands,omitempty" gorm:"-"`
+}
+
+func (f *FeeSchedule) BeforeCreate(tx *gorm.DB) (err error) {
+	f.CreatedAt = time.Now().Local()
+	f.UpdatedAt = time.Now().Local()
+	f.ID = uuid.New()
+	return
+}
+
+// FeeBand is one band of a tiered fee schedule. An UpTo of zero has no upper
+// bound.
+type FeeBand struct {
+	ID            uuid.UUID       `json:"-"`
+	FeeScheduleID uuid.UUID       `json:"-"`
+	UpTo          decimal.Decimal `json:"up_to"`
+	Flat          decimal.Decimal `json:"flat"`
+	RateBPS       int64           `json:"rate_bps"`
+}
+
+func (b *FeeBand) BeforeCreate(tx *gorm.DB) (err error) {
+	b.ID = uuid.New()
+	return
+}
+
+// TransactionFee is a fee charged on a transaction and the schedule version
+// it was charged under.
+type TransactionFee struct {
+	ID            uuid.UUID       `json:"id"`
+	TransactionID uuid.UUID       `json:"transaction_id"`
+	FeeScheduleID uuid.UUID       `json:"fee_schedule_id"`
+	Operation     string          `json:"operation"`
+	AssetID       uuid.UUID       `json:"asset_id"`
+	Amount        decimal.Decimal `json:"amount"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+func (t *TransactionFee) BeforeCreate(tx *gorm.DB) (err error) {
+	t.CreatedAt = time.Now().Local()
+	t.ID = uuid.New()
+	return
+}
+
+type LedgerEntry struct {
+	ID            uuid.UUID
+	JournalID     uuid.UUID
+	TransactionID uuid.UUID
+	WalletID      uuid.UUID
+	AssetID       uuid.UUID
+	Account       string
+	Side          string
+	Amount        decimal.Decimal
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (l *LedgerEntry) BeforeCreate(tx *gorm.DB) (err error) {
+	l.CreatedAt = time.Now().Local()
+	l.UpdatedAt = time.Now().Local()
+	l.ID = uuid.New()
+	return
+}
+
+type WebhookEvent struct {
+	ID            uuid.UUID  `json:"id"`
+	Provider      string     `json:"provider"`
+	EventID       string     `json:"event_id"`
+	EventType     string     `json:"event_type"`
+	Payload       string     `json:"payload"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	ProcessedAt   *time.Time `json:"processed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (e *WebhookEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	e.CreatedAt = time.Now().Local()
+	e.UpdatedAt = time.Now().Local()
+	e.ID = uuid.New()
+	return
+}
+
+type SecurityEvent struct {
 	ID        uuid.UUID `json:"id"`
 	UserID    uuid.UUID `json:"user_id"`
-	Balance   float64   `json:"balance"`
-	Status    string    `json:"status"`
-	UserName  string    `json:"user_name"`
-	UserEmail string    `json:"user_email"`
+	Type      string    `json:"type"`
+	Action    string    `json:"action"`
+	Detail    string    `json:"detail"`
+	Actor     string    `json:"actor"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-type TransactionWithAsset struct {
-	TransactionID string    `json:"transaction_id"`
-	Reference     string    `json:"reference"`
-	UserID        string    `json:"user_id"`
-	AssetID       string    `json:"asset_id"`
-	Type          string    `json:"type"`
-	Amount        float64   `json:"amount"`
-	AmountUSD     float64   `json:"amount_usd"`
-	Confirmations int64     `json:"confirmations"`
-	Status        string    `json:"status"`
+func (e *SecurityEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	e.CreatedAt = time.Now().Local()
+	e.UpdatedAt = time.Now().Local()
+	e.ID = uuid.New()
+	return
+}
+
+// WithdrawalLimit caps the Naira a user of a tier can withdraw. Amounts
+// include the fee and a zero value means no limit. Withdrawals over a limit
+// wait for approval instead of going to Monnify.
+type WithdrawalLimit struct {
+	Tier           string          `json:"tier" gorm:"primaryKey"`
+	PerTransaction decimal.Decimal `json:"per_transaction"`
+	Daily          decimal.Decimal `json:"daily"`
+	Weekly         decimal.Decimal `json:"weekly"`
+	HourlyCount    int             `json:"hourly_count"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+// WithdrawalReview is one admin's decision on a withdrawal awaiting approval.
+// An admin can review a withdrawal once.
+type WithdrawalReview struct {
+	ID           uuid.UUID `json:"id"`
+	WithdrawalID uuid.UUID `json:"withdrawal_id"`
+	Admin        string    `json:"admin"`
+	Decision     string    `json:"decision"`
+	Reason       string    `json:"reason"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (r *WithdrawalReview) BeforeCreate(tx *gorm.DB) (err error) {
+	r.CreatedAt = time.Now().Local()
+	r.UpdatedAt = time.Now().Local()
+	r.ID = uuid.New()
+	return
+}
+
+// Beneficiary is a bank account a user saved for withdrawals. It can only be
+// paid from ActiveFrom, which gives the owner time to notice an account added
+// by someone else.
+type Beneficiary struct {
+	ID            uuid.UUID `json:"id"`
+	UserID        uuid.UUID `json:"user_id"`
+	BankCode      string    `json:"bank_code"`
+	BankName      string    `json:"bank_name"`
+	AccountNumber string    `json:"account_number"`
+	AccountName   string    `json:"account_name"`
+	Nickname      string    `json:"nickname"`
+	ActiveFrom    time.Time `json:"active_from"`
 	CreatedAt     time.Time `json:"created_at"`
-	AssetName     string    `json:"asset_name"`
-	AssetSymbol   string    `json:"asset_symbol"`
-	AssetStandard string    `json:"asset_standard"`
-	Rate          float64   `json:"rate"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (b *Beneficiary) BeforeCreate(tx *gorm.DB) (err error) {
+	b.CreatedAt = time.Now().Local()
+	b.UpdatedAt = time.Now().Local()
+	b.ID = uuid.New()
+	return
+}
+
+type UnbalancedJournal struct {
+	JournalID uuid.UUID       `json:"journal_id"`
+	AssetID   uuid.UUID       `json:"asset_id"`
+	Total     decimal.Decimal `json:"total"`
+}
+
+// WithdrawalUsage is what a user withdrew, fees included, within a window.
+type WithdrawalUsage struct {
+	Total decimal.Decimal `json:"total"`
+	Count int64           `json:"count"`
+}
+
+type WalletLedgerDrift struct {
+	WalletID      uuid.UUID       `json:"wallet_id"`
+	UserID        uuid.UUID       `json:"user_id"`
+	Balance       decimal.Decimal `json:"balance"`
+	LedgerBalance decimal.Decimal `json:"ledger_balance"`
+}
+
+type WalletWithDetails struct {
+	ID        uuid.UUID       `json:"id"`
+	UserID    uuid.UUID       `json:"user_id"`
+	Balance   decimal.Decimal `json:"balance"`
+	Status    string          `json:"status"`
+	UserName  string          `json:"user_name"`
+	UserEmail string          `json:"user_email"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// AssetBalance is one of a user's wallets with the asset it holds.
+type AssetBalance struct {
+	WalletID uuid.UUID       `json:"wallet_id"`
+	AssetID  uuid.UUID       `json:"asset_id"`
+	Symbol   string          `json:"symbol"`
+	Name     string          `json:"name"`
+	Standard string          `json:"standard"`
+	Decimals int             `json:"decimals"`
+	Balance  decimal.Decimal `json:"balance"`
+}
+
+type TransactionWithAsset struct {
+	TransactionID string          `json:"transaction_id"`
+	Reference     string          `json:"reference"`
+	UserID        string          `json:"user_id"`
+	AssetID       string          `json:"asset_id"`
+	Type          string          `json:"type"`
+	Amount        decimal.Decimal `json:"amount"`
+	AmountUSD     decimal.Decimal `json:"amount_usd"`
+	Confirmations int64           `json:"confirmations"`
+	Status        string          `json:"status"`
+	CreatedAt     time.Time       `json:"created_at"`
+	AssetName     string          `json:"asset_name"`
+	AssetSymbol   string          `json:"asset_symbol"`
+	AssetStandard string          `json:"asset_standard"`
+	Rate          decimal.Decimal `json:"rate"`
 }
@@ -18,7 +18,6 @@ require (
 )
 
 require (
-	github.com/ShowBaba/blockradar-go v0.0.0-20250319105034-2a20bc4ca237 // indirect
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
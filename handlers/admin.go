@@ -2,14 +2,22 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/ShowBaba/kagewallet/helpers"
+	"github.com/ShowBaba/kagewallet/repositories"
 	"github.com/ShowBaba/kagewallet/services"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
 )
 
 type AdminHandler struct {
@@ -31,6 +39,16 @@ func (a *AdminHandler) CreateAsset() http.HandlerFunc {
 			http.Error(w, "Invalid request payload", http.StatusBadRequest)
 			return
 		}
+		if input.NetworkID != "" {
+			if _, err := uuid.Parse(input.NetworkID); err != nil {
+				http.Error(w, "Invalid network ID", http.StatusBadRequest)
+				return
+			}
+		}
+		if input.MinDeposit.IsNegative() {
+			http.Error(w, "min_deposit cannot be negative", http.StatusBadRequest)
+			return
+		}
 
 		if exists, err := a.AdminService.AssetExists(input.Name, input.Symbol, input.Standard); err != nil {
 			http.Error(w, fmt.Sprintf("Error checking asset existence: %v", err), http.StatusInternalServerError)
@@ -82,7 +100,9 @@ func (a *AdminHandler) CreateRate() http.HandlerFunc {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Content-Type", "application/json")
 		var input struct {
-			Rate float64 `json:"rate"`
+			AssetID string          `json:"asset_id"`
+			Side    string          `json:"side"`
+			Rate    decimal.Decimal `json:"rate"`
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
@@ -90,7 +110,24 @@ func (a *AdminHandler) CreateRate() http.HandlerFunc {
 			return
 		}
 
-		if err := a.AdminService.CreateRate(input.Rate, "Admin"); err != nil {
+		assetID, err := uuid.Parse(input.AssetID)
+		if err != nil {
+			http.Error(w, "Invalid asset ID", http.StatusBadRequest)
+			return
+		}
+		if input.Side == "" {
+			input.Side = common.RateSideSell
+		}
+		if input.Side != common.RateSideSell && input.Side != common.RateSideBuy {
+			http.Error(w, "Invalid side, expected buy or sell", http.StatusBadRequest)
+			return
+		}
+		if !input.Rate.IsPositive() {
+			http.Error(w, "Rate must be greater than zero", http.StatusBadRequest)
+			return
+		}
+
+		if err := a.AdminService.CreateRate(assetID, input.Side, input.Rate, common.RateSourceAdmin); err != nil {
 			http.Error(w, fmt.Sprintf("Failed to create exchange rate: %v", err), http.StatusInternalServerError)
 			return
 		}
@@ -154,3 +191,464 @@ func (a *AdminHandler) GetAssets() http.HandlerFunc {
 		}
 	}
 }
+
+func (a *AdminHandler) CreateNetwork() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json")
+		var input common.CreateNetworkInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		if input.Code == "" || input.Provider == "" || input.RequiredConfirmations < 0 {
+			http.Error(w, "code and provider are required and required_confirmations cannot be negative", http.StatusBadRequest)
+			return
+		}
+
+		network, err := a.AdminService.CreateNetwork(input)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to create network: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(network)
+	}
+}
+
+func (a *AdminHandler) UpdateNetwork() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json")
+		networkID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid network ID", http.StatusBadRequest)
+			return
+		}
+
+		var updates map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		delete(updates, "id")
+
+		if err := a.AdminService.UpdateNetwork(networkID, updates); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to update network: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Network updated successfully"})
+	}
+}
+
+func (a *AdminHandler) ListNetworks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json")
+
+		networks, err := a.AdminService.ListNetworks()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to fetch networks: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(networks)
+	}
+}
+
+func (a *AdminHandler) GetNetworkBalances() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json")
+		networkID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid network ID", http.StatusBadRequest)
+			return
+		}
+
+		balances, err := a.AdminService.GetNetworkBalances(networkID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				http.Error(w, "Network not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, fmt.Sprintf("Failed to fetch balances: %v", err), http.StatusBadGateway)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(balances)
+	}
+}
+
+func (a *AdminHandler) ListWebhookEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json")
+
+		query := r.URL.Query()
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit <= 0 || limit > 100 {
+			limit = 20
+		}
+		offset, err := strconv.Atoi(query.Get("offset"))
+		if err != nil || offset < 0 {
+			offset = 0
+		}
+
+		events, err := a.AdminService.ListWebhookEvents(query.Get("provider"), query.Get("status"), limit, offset)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to fetch webhook events: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(events)
+	}
+}
+
+func (a *AdminHandler) GetWebhookEvent() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json")
+		eventID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid event ID", http.StatusBadRequest)
+			return
+		}
+
+		event, err := a.AdminService.GetWebhookEvent(eventID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				http.Error(w, "Webhook event not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, fmt.Sprintf("Failed to fetch webhook event: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(event)
+	}
+}
+
+func (a *AdminHandler) ReplayWebhookEvent() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json")
+		eventID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid event ID", http.StatusBadRequest)
+			return
+		}
+
+		event, err := a.AdminService.ReplayWebhookEvent(eventID)
+		if err != nil {
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				http.Error(w, "Webhook event not found", http.StatusNotFound)
+			case errors.Is(err, repositories.ErrWebhookEventBusy):
+				http.Error(w, "Webhook event is being processed", http.StatusConflict)
+			default:
+				http.Error(w, fmt.Sprintf("Failed to replay webhook event: %v", err), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(event)
+	}
+}
+
+func (a *AdminHandler) FreezeUser() http.HandlerFunc {
+	return a.setUserFrozen(true)
+}
+
+func (a *AdminHandler) UnfreezeUser() http.HandlerFunc {
+	return a.setUserFrozen(false)
+}
+
+func (a *AdminHandler) setUserFrozen(freeze bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json")
+		userID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		var input struct {
+			Reason string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(input.Reason) == "" {
+			http.Error(w, "A reason is required", http.StatusBadRequest)
+			return
+		}
+
+		var user *database.User
+		if freeze {
+			user, err = a.AdminService.FreezeUser(userID, input.Reason)
+		} else {
+			user, err = a.AdminService.UnfreezeUser(userID, input.Reason)
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				http.Error(w, "User not found", http.StatusNotFound)
+			case errors.Is(err, services.ErrAccountFrozen):
+				http.Error(w, "User is already frozen", http.StatusConflict)
+			case errors.Is(err, services.ErrAccountNotFrozen):
+				http.Error(w, "User is not frozen", http.StatusConflict)
+			default:
+				http.Error(w, fmt.Sprintf("Failed to update user: %v", err), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":            user.ID,
+			"is_frozen":     user.IsFrozen,
+			"frozen_at":     user.FrozenAt,
+			"frozen_reason": user.FrozenReason,
+			"frozen_by":     user.FrozenBy,
+		})
+	}
+}
+
+func (a *AdminHandler) ListSecurityEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json")
+
+		query := r.URL.Query()
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit <= 0 || limit > 100 {
+			limit = 20
+		}
+		offset, err := strconv.Atoi(query.Get("offset"))
+		if err != nil || offset < 0 {
+			offset = 0
+		}
+		var userID uuid.UUID
+		if value := query.Get("user_id"); value != "" {
+			userID, err = uuid.Parse(value)
+			if err != nil {
+				http.Error(w, "Invalid user ID", http.StatusBadRequest)
+				return
+			}
+		}
+
+		events, err := a.AdminService.ListSecurityEvents(userID, query.Get("type"), limit, offset)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to fetch security events: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(events)
+	}
+}
+
+func (a *AdminHandler) ListWithdrawals() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json")
+
+		query := r.URL.Query()
+		limit, err := strconv.Atoi(query.Get("limit"))
+		if err != nil || limit <= 0 || limit > 100 {
+			limit = 20
+		}
+		offset, err := strconv.Atoi(query.Get("offset"))
+		if err != nil || offset < 0 {
+			offset = 0
+		}
+		status := query.Get("status")
+		if status == "" {
+			status = common.WithdrawalStatusAwaitingApproval
+		}
+
+		withdrawals, err := a.AdminService.ListWithdrawals(status, limit, offset)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to fetch withdrawals: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(withdrawals)
+	}
+}
+
+func (a *AdminHandler) GetWithdrawal() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json")
+		withdrawalID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid withdrawal ID", http.StatusBadRequest)
+			return
+		}
+
+		withdrawal, err := a.AdminService.GetWithdrawal(withdrawalID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				http.Error(w, "Withdrawal not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, fmt.Sprintf("Failed to fetch withdrawal: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(withdrawal)
+	}
+}
+
+func (a *AdminHandler) ApproveWithdrawal() http.HandlerFunc {
+	return a.reviewWithdrawal(true)
+}
+
+func (a *AdminHandler) RejectWithdrawal() http.HandlerFunc {
+	return a.reviewWithdrawal(false)
+}
+
+// reviewWithdrawal records the calling admin's decision on a withdrawal
+// awaiting approval. A reason is required to reject.
+func (a *AdminHandler) reviewWithdrawal(approve bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json")
+		withdrawalID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid withdrawal ID", http.StatusBadRequest)
+			return
+		}
+
+		var input struct {
+			Reason string `json:"reason"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		if !approve && strings.TrimSpace(input.Reason) == "" {
+			http.Error(w, "A reason is required", http.StatusBadRequest)
+			return
+		}
+
+		var (
+			withdrawal *services.WithdrawalWithReviews
+			missing    int
+		)
+		admin := helpers.AdminName(r)
+		if approve {
+			withdrawal, missing, err = a.AdminService.ApproveWithdrawal(withdrawalID, admin, input.Reason)
+		} else {
+			withdrawal, err = a.AdminService.RejectWithdrawal(withdrawalID, admin, input.Reason)
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				http.Error(w, "Withdrawal not found", http.StatusNotFound)
+			case errors.Is(err, services.ErrWithdrawalNotAwaitingApproval):
+				http.Error(w, "Withdrawal is not awaiting approval", http.StatusConflict)
+			case errors.Is(err, services.ErrWithdrawalAlreadyReviewed):
+				http.Error(w, "You have already reviewed this withdrawal", http.StatusConflict)
+			case errors.Is(err, services.ErrTransferRejected):
+				http.Error(w, fmt.Sprintf("No payout provider accepted the transfer, the funds were returned to the user: %v", err), http.StatusBadGateway)
+			default:
+				http.Error(w, fmt.Sprintf("Failed to review withdrawal: %v", err), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"withdrawal":         withdrawal,
+			"approvals_required": services.WithdrawalApprovalsRequired(),
+			"approvals_missing":  missing,
+		})
+	}
+}
+
+func (a *AdminHandler) ListFeeSchedules() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json")
+
+		query := r.URL.Query()
+		history, _ := strconv.ParseBool(query.Get("history"))
+
+		schedules, err := a.AdminService.ListFeeSchedules(query.Get("operation"), history)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to fetch fee schedules: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(schedules)
+	}
+}
+
+func (a *AdminHandler) CreateFeeSchedule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json")
+		var input common.FeeScheduleInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		schedule, err := a.AdminService.CreateFeeSchedule(input, helpers.AdminName(r))
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrInvalidFeeSchedule):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				http.Error(w, "Asset not found", http.StatusNotFound)
+			default:
+				http.Error(w, fmt.Sprintf("Failed to create fee schedule: %v", err), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(schedule)
+	}
+}
+
+func (a *AdminHandler) RetireFeeSchedule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Content-Type", "application/json")
+		scheduleID, err := uuid.Parse(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid fee schedule ID", http.StatusBadRequest)
+			return
+		}
+
+		retired, err := a.AdminService.RetireFeeSchedule(scheduleID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to retire fee schedule: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if !retired {
+			http.Error(w, "Fee schedule not found or already superseded", http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Fee schedule retired successfully"})
+	}
+}
@@ -1,32 +1,46 @@
 package handlers
 
 import (
-	"crypto/hmac"
-	"crypto/sha512"
-	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strings"
 
-	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/helpers"
 	log "github.com/ShowBaba/kagewallet/logging"
 	"github.com/ShowBaba/kagewallet/services"
 	"go.uber.org/zap"
 )
 
 type WebhookHandler struct {
-	WebhookService *services.WebhookService
+	InboxService   *services.WebhookInboxService
+	Payouts        *services.PayoutRouter
+	CustodyService *services.CustodyService
 }
 
-func NewWebhookHandler(webhookService *services.WebhookService) *WebhookHandler {
+func NewWebhookHandler(inboxService *services.WebhookInboxService, payouts *services.PayoutRouter,
+	custodyService *services.CustodyService) *WebhookHandler {
 	return &WebhookHandler{
-		webhookService,
+		inboxService,
+		payouts,
+		custodyService,
 	}
 }
 
-func (wb *WebhookHandler) BlockradarWebhook() http.HandlerFunc {
+func logRejectedWebhook(r *http.Request, provider, reason string) {
+	log.Warn("security event: rejected webhook",
+		zap.String("provider", provider),
+		zap.String("reason", reason),
+		zap.String("remote_ip", helpers.ClientIP(r)),
+		zap.String("path", r.URL.Path),
+	)
+}
+
+// CustodyWebhook receives deposit webhooks for one custody provider. The
+// signature is checked against the key of the network the payload is for, and
+// nothing else in the payload is used before it is verified.
+func (wb *WebhookHandler) CustodyWebhook(providerName string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Content-Type", "application/json")
@@ -38,50 +52,34 @@ func (wb *WebhookHandler) BlockradarWebhook() http.HandlerFunc {
 		}
 		defer r.Body.Close()
 
-		fmt.Println("body; ", string(body))
-
-		var input common.BlockradarEvent
-		if err := json.Unmarshal(body, &input); err != nil {
-			log.Error("Failed to parse request body", zap.Error(err))
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
-			// w.WriteHeader(http.StatusOK)
-			// fmt.Fprintln(w, "OK")
+		provider, err := wb.CustodyService.Provider(providerName)
+		if err != nil {
+			http.Error(w, "Unknown provider", http.StatusNotFound)
 			return
 		}
 
-		signature := r.Header.Get("x-blockradar-signature")
-		if signature == "" {
-			http.Error(w, "Missing signature header", http.StatusUnauthorized)
+		valid, err := wb.CustodyService.VerifyWebhook(providerName, body, r.Header)
+		if err != nil {
+			log.Error("error verifying custody webhook", zap.String("provider", providerName), zap.Error(err))
+			http.Error(w, "Failed to verify webhook", http.StatusInternalServerError)
 			return
 		}
-
-		var apiKey string
-
-		fmt.Println("signature; ", signature)
-
-		switch input.Data.Blockchain.TokenStandard {
-		case "ERC20":
-			apiKey = os.Getenv("BLOCKRADAR_ETH_API_KEY")
-		case "TRC20":
-			apiKey = os.Getenv("BLOCKRADAR_TRON_API_KEY")
-		case "BEP20":
-			apiKey = os.Getenv("BLOCKRADAR_BNB_API_KEY")
+		if !valid {
+			logRejectedWebhook(r, providerName, "invalid signature")
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
 		}
 
-		hash := hmac.New(sha512.New, []byte(apiKey))
-		hash.Write(body)
-		computedSignature := hex.EncodeToString(hash.Sum(nil))
-
-		if computedSignature != signature {
-			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		event, err := provider.ParseWebhook(body)
+		if err != nil {
+			log.Error("Failed to parse request body", zap.Error(err))
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
 			return
 		}
 
-		if err := wb.WebhookService.BlockradarWebhook(input); err != nil {
-			log.Error("error processing blockradar webhook", zap.Error(err))
-			http.Error(w, "Failed to process webhook", http.StatusInternalServerError)
-			// w.WriteHeader(http.StatusOK)
-			// fmt.Fprintln(w, "OK")
+		if _, err := wb.InboxService.Receive(providerName, event.ID, event.Type, body); err != nil {
+			log.Error("error storing custody webhook", zap.String("provider", providerName), zap.Error(err))
+			http.Error(w, "Failed to store webhook", http.StatusInternalServerError)
 			return
 		}
 
@@ -90,7 +88,9 @@ func (wb *WebhookHandler) BlockradarWebhook() http.HandlerFunc {
 	}
 }
 
-func (wb *WebhookHandler) MonnifyWebhook() http.HandlerFunc {
+// PayoutWebhook receives transfer webhooks for one payout provider. Calls can
+// be limited to the provider's addresses with <PROVIDER>_WEBHOOK_IPS.
+func (wb *WebhookHandler) PayoutWebhook(providerName string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Content-Type", "application/json")
@@ -102,22 +102,33 @@ func (wb *WebhookHandler) MonnifyWebhook() http.HandlerFunc {
 		}
 		defer r.Body.Close()
 
-		fmt.Println("body; ", string(body))
+		if !helpers.IPAllowed(helpers.ClientIP(r), os.Getenv(strings.ToUpper(providerName)+"_WEBHOOK_IPS")) {
+			logRejectedWebhook(r, providerName, "source ip not allowed")
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
 
-		var input common.MonnifyEvent
-		if err := json.Unmarshal(body, &input); err != nil {
+		provider, err := wb.Payouts.Provider(providerName)
+		if err != nil {
+			http.Error(w, "Unknown provider", http.StatusNotFound)
+			return
+		}
+		if !provider.VerifyWebhook(body, r.Header) {
+			logRejectedWebhook(r, providerName, "invalid signature")
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		event, err := provider.ParseWebhook(body)
+		if err != nil {
 			log.Error("Failed to parse request body", zap.Error(err))
 			http.Error(w, "Invalid request payload", http.StatusBadRequest)
-			// w.WriteHeader(http.StatusOK)
-			// fmt.Fprintln(w, "OK")
 			return
 		}
 
-		if err := wb.WebhookService.MonnifyWebhook(input); err != nil {
-			log.Error("error processing monnify webhook", zap.Error(err))
-			http.Error(w, "Failed to process webhook", http.StatusInternalServerError)
-			// w.WriteHeader(http.StatusOK)
-			// fmt.Fprintln(w, "OK")
+		if _, err := wb.InboxService.Receive(providerName, event.Reference, event.Type, body); err != nil {
+			log.Error("error storing payout webhook", zap.String("provider", providerName), zap.Error(err))
+			http.Error(w, "Failed to store webhook", http.StatusInternalServerError)
 			return
 		}
 
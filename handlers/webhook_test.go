@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/ShowBaba/kagewallet/migrations"
+	"github.com/ShowBaba/kagewallet/repositories"
+	"github.com/ShowBaba/kagewallet/services"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	monnifySecret  = "monnify-secret"
+	paystackSecret = "paystack-secret"
+)
+
+// testDB connects to TEST_DATABASE_URL and migrates it. Tests that need
+// Postgres are skipped when it is not set.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		NamingStrategy:         schema.NamingStrategy{SingularTable: true},
+		SkipDefaultTransaction: true,
+		Logger:                 logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("error connecting to test database: %v", err)
+	}
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatalf("error migrating test database: %v", err)
+	}
+	return db
+}
+
+func sign(body, secret string) string {
+	mac := hmac.New(sha512.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// testWebhookHandler builds a handler with both payout providers. The inbox
+// is only set when db is, so tests without a database must be rejected
+// before anything is stored.
+func testWebhookHandler(db *gorm.DB) *WebhookHandler {
+	monnify := services.NewMonnifyServiceWithConfig(services.MonnifyConfig{SecretKey: monnifySecret}, http.DefaultClient)
+	paystack := services.NewPaystackServiceWithConfig(services.PaystackConfig{SecretKey: paystackSecret}, http.DefaultClient)
+	providers := []services.PayoutProvider{monnify, paystack}
+	payouts := services.NewPayoutRouter(providers, providers, nil, nil, 3, time.Minute, time.Hour)
+
+	var inbox *services.WebhookInboxService
+	var networkRepo *repositories.NetworkRepository
+	if db != nil {
+		inbox = services.NewWebhookInboxService(repositories.NewWebhookEventRepository(db), nil)
+		networkRepo = repositories.NewNetworkRepository(db)
+	}
+	custody := services.NewCustodyService(networkRepo, services.NewBlockradarServiceWithConfig("", http.DefaultClient))
+	return NewWebhookHandler(inbox, payouts, custody)
+}
+
+func post(handler http.HandlerFunc, remoteAddr, body string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/webhook", strings.NewReader(body))
+	r.RemoteAddr = remoteAddr
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func payoutBody(provider, reference string) string {
+	if provider == common.PayoutProviderMonnify {
+		return fmt.Sprintf(`{"eventType":"SUCCESSFUL_DISBURSEMENT","eventData":{"reference":%q,"amount":5000,"status":"SUCCESS"}}`, reference)
+	}
+	return fmt.Sprintf(`{"event":"transfer.success","data":{"reference":%q,"amount":500000,"status":"success"}}`, reference)
+}
+
+var payoutProviders = []struct {
+	name   string
+	header string
+	secret string
+}{
+	{common.PayoutProviderMonnify, "monnify-signature", monnifySecret},
+	{common.PayoutProviderPaystack, "x-paystack-signature", paystackSecret},
+}
+
+func TestPayoutWebhookRejectsBadSignatures(t *testing.T) {
+	handler := testWebhookHandler(nil)
+	for _, provider := range payoutProviders {
+		body := payoutBody(provider.name, "ref-1")
+		tests := []struct {
+			name   string
+			body   string
+			header http.Header
+		}{
+			{"missing", body, http.Header{}},
+			{"tampered body", strings.Replace(body, "5000", "9000", 1), http.Header{provider.header: {sign(body, provider.secret)}}},
+			{"wrong key", body, http.Header{provider.header: {sign(body, "other-secret")}}},
+			{"not hex", body, http.Header{provider.header: {"signature"}}},
+		}
+		for _, tt := range tests {
+			t.Run(provider.name+" "+tt.name, func(t *testing.T) {
+				w := post(handler.PayoutWebhook(provider.name), "35.242.133.146:443", tt.body, tt.header)
+				if w.Code != http.StatusUnauthorized {
+					t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+				}
+			})
+		}
+	}
+}
+
+func TestPayoutWebhookIPAllowList(t *testing.T) {
+	handler := testWebhookHandler(nil)
+	tests := []struct {
+		name       string
+		trust      string
+		remoteAddr string
+		forwarded  string
+		want       int
+	}{
+		// Signatures are bad throughout, so 401 means the caller got past the
+		// allow-list.
+		{"listed address", "false", "35.242.133.146:443", "", http.StatusUnauthorized},
+		{"address in range", "false", "10.1.2.3:443", "", http.StatusUnauthorized},
+		{"unlisted address", "false", "203.0.113.7:443", "", http.StatusForbidden},
+		{"forwarded header ignored when untrusted", "false", "203.0.113.7:443", "35.242.133.146", http.StatusForbidden},
+		{"forwarded header used when trusted", "true", "10.9.9.9:443", "203.0.113.7, 35.242.133.146", http.StatusUnauthorized},
+		{"unlisted forwarded address when trusted", "true", "35.242.133.146:443", "203.0.113.7", http.StatusForbidden},
+		// The proxy appends the real caller, so a listed address the client
+		// put in front of it does not get through.
+		{"spoofed forwarded address when trusted", "true", "10.9.9.9:443", "35.242.133.146, 203.0.113.7", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MONNIFY_WEBHOOK_IPS", "35.242.133.146, 10.0.0.0/8")
+			t.Setenv("TRUST_PROXY_HEADERS", tt.trust)
+			header := http.Header{}
+			if tt.forwarded != "" {
+				header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			w := post(handler.PayoutWebhook(common.PayoutProviderMonnify), tt.remoteAddr, payoutBody(common.PayoutProviderMonnify, "ref-1"), header)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestPayoutWebhookUnknownProvider(t *testing.T) {
+	w := post(testWebhookHandler(nil).PayoutWebhook("flutterwave"), "35.242.133.146:443", "{}", http.Header{})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestPayoutWebhookStoresSignedEvent(t *testing.T) {
+	db := testDB(t)
+	handler := testWebhookHandler(db)
+	for _, provider := range payoutProviders {
+		t.Run(provider.name, func(t *testing.T) {
+			reference := uuid.NewString()
+			body := payoutBody(provider.name, reference)
+			w := post(handler.PayoutWebhook(provider.name), "35.242.133.146:443", body, http.Header{provider.header: {sign(body, provider.secret)}})
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+			}
+			var count int64
+			db.Model(&database.WebhookEvent{}).Where("provider = ? AND event_id = ?", provider.name, reference).Count(&count)
+			if count != 1 {
+				t.Errorf("stored %d events, want 1", count)
+			}
+		})
+	}
+}
+
+func TestCustodyWebhookRejectsWithoutDatabase(t *testing.T) {
+	handler := testWebhookHandler(nil)
+	w := post(handler.CustodyWebhook("fireblocks"), "35.242.133.146:443", "{}", http.Header{})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown provider: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	// A body that does not parse names no network, so there is no key to
+	// check it against.
+	w = post(handler.CustodyWebhook(common.WebhookProviderBlockradar), "35.242.133.146:443", "not json",
+		http.Header{"X-Blockradar-Signature": {sign("not json", "key")}})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unparseable body: status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+// custodyNetwork creates an active Blockradar network whose API key is set to
+// key, and an asset on it.
+func custodyNetwork(t *testing.T, db *gorm.DB, key string) (*database.Network, *database.Asset) {
+	t.Helper()
+	code := "TEST_" + strings.ToUpper(strings.ReplaceAll(uuid.NewString()[:8], "-", ""))
+	network := &database.Network{
+		Code:      code,
+		Name:      code,
+		ChainID:   time.Now().UnixNano(),
+		Provider:  common.WebhookProviderBlockradar,
+		APIKeyEnv: code + "_API_KEY",
+		IsActive:  true,
+	}
+	if err := db.Create(network).Error; err != nil {
+		t.Fatalf("error creating network: %v", err)
+	}
+	t.Setenv(network.APIKeyEnv, key)
+	asset := &database.Asset{Symbol: code, Name: code, NetworkID: &network.ID}
+	if err := db.Create(asset).Error; err != nil {
+		t.Fatalf("error creating asset: %v", err)
+	}
+	return network, asset
+}
+
+func custodyBody(asset *database.Asset, chainID int64) string {
+	return fmt.Sprintf(`{"event":"deposit.success","data":{"id":%q,"amount":"10","chainId":%d,"address":{"address":"0xabc","metadata":{"asset_id":%q}}}}`,
+		uuid.NewString(), chainID, asset.ID)
+}
+
+func TestCustodyWebhookChecksThePayloadsNetwork(t *testing.T) {
+	db := testDB(t)
+	handler := testWebhookHandler(db)
+	network, asset := custodyNetwork(t, db, "network-key")
+	other, _ := custodyNetwork(t, db, "other-network-key")
+	body := custodyBody(asset, network.ChainID)
+
+	tests := []struct {
+		name   string
+		body   string
+		header http.Header
+		want   int
+	}{
+		{"valid", body, http.Header{"X-Blockradar-Signature": {sign(body, "network-key")}}, http.StatusOK},
+		{"missing", body, http.Header{}, http.StatusUnauthorized},
+		{"tampered body", strings.Replace(body, `"10"`, `"1000"`, 1), http.Header{"X-Blockradar-Signature": {sign(body, "network-key")}}, http.StatusUnauthorized},
+		// Another network of the same provider cannot vouch for this one.
+		{"another network's key", body, http.Header{"X-Blockradar-Signature": {sign(body, "other-network-key")}}, http.StatusUnauthorized},
+		{"unknown asset", custodyBody(&database.Asset{ID: uuid.New()}, other.ChainID), http.Header{}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := post(handler.CustodyWebhook(common.WebhookProviderBlockradar), "35.242.133.146:443", tt.body, tt.header)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
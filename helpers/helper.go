@@ -5,10 +5,13 @@ import (
 	"encoding/hex"
 	"fmt"
 	"math/rand"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/badoux/checkmail"
+	"github.com/shopspring/decimal"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -65,6 +68,63 @@ func IsValidEmail(email string) bool {
 	return true
 }
 
+func DurationFromEnv(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+func IntFromEnv(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
+
+func StringFromEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// CommaDecimal formats an amount with thousands separators, like
+// humanize.Commaf but without going through float64.
+func CommaDecimal(amount decimal.Decimal) string {
+	return commaNumber(amount.String())
+}
+
+// CommaFixed is CommaDecimal with the amount rounded to a fixed number of
+// decimal places.
+func CommaFixed(amount decimal.Decimal, places int32) string {
+	return commaNumber(amount.StringFixed(places))
+}
+
+func commaNumber(text string) string {
+	sign := ""
+	if strings.HasPrefix(text, "-") {
+		sign, text = "-", text[1:]
+	}
+	integer, fraction := text, ""
+	if i := strings.IndexByte(text, '.'); i >= 0 {
+		integer, fraction = text[:i], text[i:]
+	}
+
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	b.WriteString(fraction)
+	return b.String()
+}
+
 func StrPtr(value string) *string {
 	return &value
 }
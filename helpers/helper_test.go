@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestCommaFixed(t *testing.T) {
+	tests := []struct {
+		amount string
+		places int32
+		want   string
+	}{
+		{"0", 2, "0.00"},
+		{"999.995", 2, "1,000.00"},
+		{"1234567.891", 2, "1,234,567.89"},
+		{"-1234.5", 2, "-1,234.50"},
+		{"100000", 0, "100,000"},
+		{"0.000123456789", 8, "0.00012346"},
+	}
+	for _, tt := range tests {
+		if got := CommaFixed(decimal.RequireFromString(tt.amount), tt.places); got != tt.want {
+			t.Errorf("CommaFixed(%s, %d) = %q, want %q", tt.amount, tt.places, got, tt.want)
+		}
+	}
+}
+
+// TestKoboAmountsSumExactly adds thousands of Naira amounts the way a
+// template would and checks the total matches the same sum in whole kobo,
+// which float64 cannot guarantee.
+func TestKoboAmountsSumExactly(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	var (
+		total decimal.Decimal
+		kobo  int64
+	)
+	for i := 0; i < 5000; i++ {
+		k := r.Int63n(100_000_000_00)
+		kobo += k
+		total = total.Add(toDecimal(fmt.Sprintf("%d.%02d", k/100, k%100)))
+	}
+	if want := decimal.New(kobo, -2); !total.Equal(want) {
+		t.Fatalf("total = %s, want %s", total, want)
+	}
+	want := CommaDecimal(decimal.NewFromInt(kobo/100)) + fmt.Sprintf(".%02d", kobo%100)
+	if got := CommaFixed(total, 2); got != want {
+		t.Errorf("CommaFixed(total) = %q, want %q", got, want)
+	}
+}
@@ -4,15 +4,14 @@ import (
 	"bytes"
 	"fmt"
 	"log"
-	"math"
 	"path/filepath"
 	"reflect"
-	"strconv"
 	"strings"
 	"text/template"
 	"time"
 
 	"github.com/dustin/go-humanize"
+	"github.com/shopspring/decimal"
 )
 
 var funcMap = template.FuncMap{
@@ -36,43 +35,60 @@ var funcMap = template.FuncMap{
 
 func add(a, b int) int { return a + b }
 
-func minus(a, b float64) float64 { return a - b }
+func minus(a, b interface{}) decimal.Decimal { return toDecimal(a).Sub(toDecimal(b)) }
 
-func divide(a, b float64) string {
-	d := a / b
-	formatted := fmt.Sprintf("%.2f", d)
-
-	parts := strings.Split(formatted, ".")
-
-	val, _ := strconv.ParseInt(parts[0], 10, 64)
-	return strings.Replace(humanize.Comma(val)+"."+parts[1], "-", "", 1)
+func divide(a, b interface{}) string {
+	divisor := toDecimal(b)
+	if divisor.IsZero() {
+		return "0.00"
+	}
+	return CommaFixed(toDecimal(a).Div(divisor).Abs(), 2)
 }
 
-func multiply(a, b float64) string {
-	d := a * b
-	formatted := fmt.Sprintf("%.2f", d)
-	parts := strings.Split(formatted, ".")
-
-	val, _ := strconv.ParseInt(parts[0], 10, 64)
-	return strings.Replace(humanize.Comma(val)+"."+parts[1], "-", "", 1)
+func multiply(a, b interface{}) string {
+	return CommaFixed(toDecimal(a).Mul(toDecimal(b)).Abs(), 2)
 }
+
 func addComma(val interface{}) string {
-	switch val.(type) {
+	switch v := val.(type) {
 	case int:
-		return humanize.Comma(int64(val.(int)))
-	case float64:
-		return humanize.Commaf(val.(float64))
+		return humanize.Comma(int64(v))
+	case int64:
+		return humanize.Comma(v)
 	default:
-		return humanize.Comma(val.(int64))
-
+		return CommaDecimal(toDecimal(val))
 	}
 }
 
-func greater(a, b float64) bool {
-	if a > b {
-		return true
-	} else {
-		return false
+func greater(a, b interface{}) bool {
+	return toDecimal(a).GreaterThan(toDecimal(b))
+}
+
+// toDecimal reads a number handed to a template, which may come from JSON
+// as a float64 or from our own structs as a decimal.
+func toDecimal(val interface{}) decimal.Decimal {
+	switch v := val.(type) {
+	case decimal.Decimal:
+		return v
+	case *decimal.Decimal:
+		if v == nil {
+			return decimal.Zero
+		}
+		return *v
+	case int:
+		return decimal.NewFromInt(int64(v))
+	case int64:
+		return decimal.NewFromInt(v)
+	case float64:
+		return decimal.NewFromFloat(v)
+	case string:
+		d, err := decimal.NewFromString(v)
+		if err != nil {
+			return decimal.Zero
+		}
+		return d
+	default:
+		return decimal.Zero
 	}
 }
 
@@ -169,10 +185,11 @@ func timeTo(endDateStr string) string {
 	return convertTime(seconds)
 }
 
-func calcPercent(val float64, percent float64) string {
-	equiv := val - ((val * percent) / 100)
+func calcPercent(val, percent interface{}) string {
+	amount := toDecimal(val)
+	equiv := amount.Sub(amount.Mul(toDecimal(percent)).Div(decimal.NewFromInt(100)))
 
-	return addComma(math.Round(equiv))
+	return CommaDecimal(equiv.Round(0))
 }
 
 func FormatLink(s string) string { return strings.ReplaceAll(s, " ", "-") }
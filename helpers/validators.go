@@ -1,11 +1,21 @@
 package helpers
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"net"
 	"net/http"
 	"os"
 	"strings"
 )
 
+type adminContextKey struct{}
+
+// ValidateAdminToken accepts ADMIN_TOKEN, which acts as the admin "admin", or
+// one of the personal tokens in ADMIN_TOKENS ("name:token,name:token"). The
+// admin's name is available to the handler through AdminName.
 func ValidateAdminToken(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -21,9 +31,89 @@ func ValidateAdminToken(next http.HandlerFunc) http.HandlerFunc {
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
-		if parts[1] != os.Getenv("ADMIN_TOKEN") {
+		name, ok := adminForToken(parts[1])
+		if !ok {
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), adminContextKey{}, name)))
+	}
+}
+
+// AdminName returns the admin that authenticated the request.
+func AdminName(r *http.Request) string {
+	name, _ := r.Context().Value(adminContextKey{}).(string)
+	return name
+}
+
+func adminForToken(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	for _, entry := range strings.Split(os.Getenv("ADMIN_TOKENS"), ",") {
+		name, value, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if found && name != "" && hmac.Equal([]byte(value), []byte(token)) {
+			return name, true
+		}
+	}
+	if adminToken := os.Getenv("ADMIN_TOKEN"); adminToken != "" && hmac.Equal([]byte(adminToken), []byte(token)) {
+		return "admin", true
+	}
+	return "", false
+}
+
+// ValidHMACSHA512 reports whether signature is the hex HMAC-SHA512 of body
+// under secret, comparing in constant time.
+func ValidHMACSHA512(body []byte, secret, signature string) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+	mac := hmac.New(sha512.New, []byte(secret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(signature)))
+}
+
+// ClientIP returns the caller's address. X-Forwarded-For is only trusted when
+// TRUST_PROXY_HEADERS is set, and then only its right-most entry, which is
+// the one our proxy appends; anything to its left came from the client.
+func ClientIP(r *http.Request) string {
+	if os.Getenv("TRUST_PROXY_HEADERS") == "true" {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// IPAllowed checks ip against a comma separated list of addresses and CIDR
+// ranges. An empty list allows every address.
+func IPAllowed(ip, allowList string) bool {
+	if strings.TrimSpace(allowList) == "" {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, entry := range strings.Split(allowList, ",") {
+		entry = strings.TrimSpace(entry)
+		if strings.Contains(entry, "/") {
+			if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(parsed) {
+				return true
+			}
+			continue
+		}
+		if allowed := net.ParseIP(entry); allowed != nil && allowed.Equal(parsed) {
+			return true
 		}
-		next(w, r)
 	}
+	return false
 }
@@ -0,0 +1,110 @@
+package helpers
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Signed fixtures in the shape each provider sends. The signatures were made
+// outside Go so the test does not check the code against itself.
+const (
+	monnifyBody      = `{"eventType":"SUCCESSFUL_DISBURSEMENT","eventData":{"reference":"ref-1","amount":5000,"status":"SUCCESS"}}`
+	monnifySecret    = "monnify-secret"
+	monnifySignature = "d36e183bd5898bb0cde188967103535d6d92e9067fcf47dbc5617094ec5f8cf7037e84cb22cb1493a34a247eb371b99c3bd820fa72fddccc1c9ed33f05fb7997"
+
+	blockradarBody      = `{"event":"deposit.success","data":{"id":"evt-1","amount":"10","chainId":1,"address":{"address":"0xabc","metadata":{"asset_id":"a1"}}}}`
+	blockradarKey       = "blockradar-key"
+	blockradarSignature = "e726477681cb62493439ebea4ab2ae2768701a6ae8db8cdd2add0abe46b58cacf3e88894d663cc0fa7a07436842128bf7663051afc10cbe49fccdad9269f2b5a"
+)
+
+func TestValidHMACSHA512(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		secret    string
+		signature string
+		want      bool
+	}{
+		{"monnify valid", monnifyBody, monnifySecret, monnifySignature, true},
+		{"monnify upper case", monnifyBody, monnifySecret, strings.ToUpper(monnifySignature), true},
+		{"monnify tampered body", strings.Replace(monnifyBody, "5000", "50000", 1), monnifySecret, monnifySignature, false},
+		{"monnify wrong secret", monnifyBody, "other-secret", monnifySignature, false},
+		{"monnify missing signature", monnifyBody, monnifySecret, "", false},
+		{"monnify missing secret", monnifyBody, "", monnifySignature, false},
+		{"blockradar valid", blockradarBody, blockradarKey, blockradarSignature, true},
+		{"blockradar tampered body", strings.Replace(blockradarBody, `"10"`, `"1000"`, 1), blockradarKey, blockradarSignature, false},
+		{"blockradar tampered signature", blockradarBody, blockradarKey, "0" + blockradarSignature[1:], false},
+		{"blockradar truncated signature", blockradarBody, blockradarKey, blockradarSignature[:64], false},
+		{"blockradar missing signature", blockradarBody, blockradarKey, "", false},
+		{"signed with another provider's key", blockradarBody, monnifySecret, blockradarSignature, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidHMACSHA512([]byte(tt.body), tt.secret, tt.signature); got != tt.want {
+				t.Errorf("ValidHMACSHA512() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPAllowed(t *testing.T) {
+	tests := []struct {
+		name      string
+		ip        string
+		allowList string
+		want      bool
+	}{
+		{"empty list allows all", "203.0.113.7", "", true},
+		{"blank list allows all", "203.0.113.7", "  ", true},
+		{"listed address", "35.242.133.146", "35.242.133.146", true},
+		{"one of several", "35.242.133.146", "52.31.139.75, 35.242.133.146", true},
+		{"unlisted address", "203.0.113.7", "35.242.133.146", false},
+		{"inside range", "10.1.2.3", "10.0.0.0/8", true},
+		{"outside range", "11.1.2.3", "10.0.0.0/8", false},
+		{"address and range", "192.168.1.20", "35.242.133.146,192.168.1.0/24", true},
+		{"ipv6 in range", "2001:db8::1", "2001:db8::/32", true},
+		{"invalid entries are skipped", "10.1.2.3", "not-an-ip,10.0.0.0/33,10.1.2.3", true},
+		{"invalid address", "not-an-ip", "10.0.0.0/8", false},
+		{"empty address", "", "10.0.0.0/8", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IPAllowed(tt.ip, tt.allowList); got != tt.want {
+				t.Errorf("IPAllowed(%q, %q) = %v, want %v", tt.ip, tt.allowList, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		trust      string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"untrusted ignores forwarded", "false", "10.0.0.1:4321", "35.242.133.146", "10.0.0.1"},
+		{"unset ignores forwarded", "", "10.0.0.1:4321", "35.242.133.146", "10.0.0.1"},
+		{"trusted uses forwarded", "true", "10.0.0.1:4321", "35.242.133.146", "35.242.133.146"},
+		{"trusted uses right-most forwarded", "true", "10.0.0.1:4321", " 203.0.113.7 , 35.242.133.146 ", "35.242.133.146"},
+		{"trusted ignores spoofed left entries", "true", "10.0.0.1:4321", "35.242.133.146, 203.0.113.7", "203.0.113.7"},
+		{"trusted with empty right-most entry", "true", "10.0.0.1:4321", "35.242.133.146, ", "10.0.0.1"},
+		{"trusted without forwarded", "true", "10.0.0.1:4321", "", "10.0.0.1"},
+		{"remote address without port", "false", "10.0.0.1", "", "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUST_PROXY_HEADERS", tt.trust)
+			r := httptest.NewRequest("POST", "/webhook", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := ClientIP(r); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,24 +1,58 @@
 package jobs
 
 import (
+	"time"
+
+	"github.com/ShowBaba/kagewallet/helpers"
 	log "github.com/ShowBaba/kagewallet/logging"
 	"github.com/ShowBaba/kagewallet/repositories"
+	"github.com/ShowBaba/kagewallet/services"
 )
 
 type Job struct {
-	AddressRepo *repositories.AddressRepository
-	UserRepo    *repositories.UserRepository
+	AddressRepo       *repositories.AddressRepository
+	UserRepo          *repositories.UserRepository
+	WithdrawalRepo    *repositories.WithdrawalRepository
+	WebhookService    *services.WebhookService
+	WithdrawalService *services.WithdrawalService
+	RateAggregator    *services.RateAggregator
+	InboxService      *services.WebhookInboxService
 }
 
-func NewJob(addressRepo *repositories.AddressRepository, userRepo *repositories.UserRepository) *Job {
+func NewJob(addressRepo *repositories.AddressRepository, userRepo *repositories.UserRepository,
+	withdrawalRepo *repositories.WithdrawalRepository, webhookService *services.WebhookService,
+	withdrawalService *services.WithdrawalService, rateAggregator *services.RateAggregator,
+	inboxService *services.WebhookInboxService) *Job {
 	return &Job{
 		addressRepo,
 		userRepo,
+		withdrawalRepo,
+		webhookService,
+		withdrawalService,
+		rateAggregator,
+		inboxService,
 	}
 }
 
 func (j *Job) Start() {
 	log.Info("Starting job...")
 	go ListenForNotifications()
+	go schedule(helpers.DurationFromEnv("WEBHOOK_WORKER_INTERVAL", 5*time.Second), j.ProcessWebhookEvents)
+	go schedule(helpers.DurationFromEnv("BLOCKRADAR_RECONCILE_INTERVAL", 10*time.Minute), func() {
+		j.ReconcileCustodyDeposits()
+	})
+	go schedule(helpers.DurationFromEnv("MONNIFY_RECONCILE_INTERVAL", 15*time.Minute), j.ReconcilePendingWithdrawals)
+	if j.RateAggregator != nil {
+		go schedule(helpers.DurationFromEnv("RATE_REFRESH_INTERVAL", 5*time.Minute), j.RefreshRates)
+	}
 	select {}
 }
+
+func schedule(interval time.Duration, task func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		task()
+		<-ticker.C
+	}
+}
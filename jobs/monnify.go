@@ -1,24 +1,29 @@
 package jobs
 
-// func ReconcilePendingWithdrawals() {
-// 	pendingWithdrawals, err := w.WithdrawalRepo.GetPendingWithdrawals()
-// 	if err != nil {
-// 		log.Error("Failed to fetch pending withdrawals", zap.Error(err))
-// 		return
-// 	}
-//
-// 	for _, withdrawal := range pendingWithdrawals {
-// 		status, err := w.MonnifyService.GetWithdrawalStatus(withdrawal.TransactionID)
-// 		if err != nil {
-// 			log.Error("Failed to fetch withdrawal status", zap.Error(err))
-// 			continue
-// 		}
-//
-// 		if status != "pending" {
-// 			err := w.WithdrawalRepo.UpdateWithdrawalStatus(withdrawal.ID, status)
-// 			if err != nil {
-// 				log.Error("Failed to update withdrawal status", zap.Error(err))
-// 			}
-// 		}
-// 	}
-// }
+import (
+	"time"
+
+	"github.com/ShowBaba/kagewallet/helpers"
+	log "github.com/ShowBaba/kagewallet/logging"
+	"go.uber.org/zap"
+)
+
+// ReconcilePendingWithdrawals settles withdrawals whose provider webhook never
+// arrived. Recent withdrawals are left alone to give the webhook a head start.
+func (j *Job) ReconcilePendingWithdrawals() {
+	olderThan := time.Now().Add(-helpers.DurationFromEnv("MONNIFY_RECONCILE_MIN_AGE", 10*time.Minute))
+	pendingWithdrawals, err := j.WithdrawalRepo.GetPendingWithdrawals(olderThan)
+	if err != nil {
+		log.Error("Failed to fetch pending withdrawals", zap.Error(err))
+		return
+	}
+
+	for _, withdrawal := range pendingWithdrawals {
+		status, err := j.WithdrawalService.ReconcileWithdrawal(withdrawal)
+		if err != nil {
+			log.Error("Failed to reconcile withdrawal", zap.String("withdrawal_id", withdrawal.ID.String()), zap.Error(err))
+			continue
+		}
+		log.Info("reconciled withdrawal", zap.String("withdrawal_id", withdrawal.ID.String()), zap.String("status", status))
+	}
+}
@@ -0,0 +1,26 @@
+package jobs
+
+import (
+	log "github.com/ShowBaba/kagewallet/logging"
+	"go.uber.org/zap"
+)
+
+// RefreshRates pulls fresh quotes from the configured rate providers and
+// publishes new buy and sell rates for every active asset.
+func (j *Job) RefreshRates() {
+	results, err := j.RateAggregator.Refresh()
+	if err != nil {
+		log.Error("Failed to refresh rates", zap.Error(err))
+	}
+
+	for _, result := range results {
+		if result.Halted {
+			continue
+		}
+		log.Info("refreshed rate",
+			zap.String("asset", result.Key),
+			zap.String("mid", result.Mid.String()),
+			zap.String("source", result.Source),
+		)
+	}
+}
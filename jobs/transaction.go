@@ -1,206 +1,128 @@
 package jobs
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"os"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/ShowBaba/kagewallet/helpers"
 	log "github.com/ShowBaba/kagewallet/logging"
 	"go.uber.org/zap"
 )
 
-func (j *Job) fetchBlockradarTransactions() error {
-	var (
-		totalWorkers    = 200
-		wRequestCh      = make(chan workerRequest)
-		errorCh         = make(chan error, totalWorkers)
-		wg              = &sync.WaitGroup{}
-		transactionChan = make(chan BlockradarTransaction)
-		wallets         = []string{
-			os.Getenv("BLOCKRADER_ETH_WALLET_ID"),
-		}
-	)
+const defaultReconcileLookback = 72 * time.Hour
 
-	for i := 0; i < totalWorkers; i++ {
-		worker := &worker{
-			id:        i,
-			waitGroup: wg,
-			RequestCh: wRequestCh,
-			errorCh:   errorCh,
-		}
-		go worker.run()
-		go func(workerID int, errorCh chan error) {
-			for err := range errorCh {
-				log.Error(fmt.Sprintf("Error from worker: %v", workerID), zap.Error(err))
-			}
-		}(i, errorCh)
-	}
+type ReconciliationReport struct {
+	Wallet  string
+	Pages   int
+	Created []string
+	Updated []string
+	Skipped []string
+	Failed  []string
+}
 
-	go func() {
-		defer wg.Done()
-		wg.Add(1)
-		for tnx := range transactionChan {
-			addressData, err := j.AddressRepo.GetAddressByColumn("address", tnx.RecipientAddress)
-			if err != nil {
-				errorCh <- err
-				continue
-			}
-			if addressData != nil {
-				wRequestCh <- workerRequest{
-					BlockradarTransaction: tnx,
-				}
-			}
-		}
-	}()
+// ReconcileCustodyDeposits walks the recent transactions of every configured
+// network and pushes deposits we missed, or still hold as pending, through the
+// same path as the webhook.
+func (j *Job) ReconcileCustodyDeposits() []ReconciliationReport {
+	custody := j.WebhookService.CustodyService
+	networks, err := custody.ActiveNetworks()
+	if err != nil {
+		log.Error("error fetching networks", zap.Error(err))
+		return nil
+	}
 
-	for _, wallet := range wallets {
+	var (
+		wg      = &sync.WaitGroup{}
+		reports = make([]ReconciliationReport, len(networks))
+		since   = time.Now().Add(-helpers.DurationFromEnv("BLOCKRADAR_RECONCILE_LOOKBACK", defaultReconcileLookback))
+	)
+	for i, network := range networks {
 		wg.Add(1)
-		go func(wallet string) {
+		go func(i int, network database.Network) {
 			defer wg.Done()
-			for {
-				var (
-					page  = 1
-					count int
-				)
-
-				url := fmt.Sprintf("https://api.blockradar.co/v1/wallets/%s/transactions?page=%d", wallet, page)
-
-				req, err := http.NewRequest("GET", url, nil)
-				if err != nil {
-					errorCh <- fmt.Errorf("failed to create HTTP request: %v", err)
-					continue
-				}
-
-				req.Header.Set("Content-Type", "application/json")
-				req.Header.Set("x-api-key", os.Getenv("BLOCKRADAR_ETH_API_KEY"))
-
-				client := &http.Client{}
-				resp, err := client.Do(req)
-				if err != nil {
-					errorCh <- fmt.Errorf("failed to send HTTP request: %v", err)
-					continue
-				}
-				// defer resp.Body.Close()
-
-				respBody, err := ioutil.ReadAll(resp.Body)
-				if err != nil {
-					errorCh <- fmt.Errorf("failed to read response body: %v", err)
-					continue
-				}
-				var response FetchTransactionResponse
-				if err := json.Unmarshal(respBody, &response); err != nil {
-					errorCh <- fmt.Errorf("failed to unmarshal response: %v", err)
-					continue
-				}
-
-				for _, transaction := range response.Data {
-					transactionChan <- transaction
-				}
-
-				log.Info("processed ", zap.Int("", count))
+			reports[i] = j.reconcileNetwork(network, since)
+		}(i, network)
+	}
+	wg.Wait()
 
-				if len(response.Data) < 1 {
-					break
-				}
-				page += 1
-				count += len(response.Data)
-			}
-		}(wallet)
+	for _, report := range reports {
+		log.Info("custody reconciliation report",
+			zap.String("wallet", report.Wallet),
+			zap.Int("pages", report.Pages),
+			zap.Strings("created", report.Created),
+			zap.Strings("updated", report.Updated),
+			zap.Int("skipped", len(report.Skipped)),
+			zap.Strings("failed", report.Failed),
+		)
 	}
 
-	wg.Wait()
-	return nil
+	return reports
 }
 
-type workerRequest struct {
-	BlockradarTransaction BlockradarTransaction
-}
+func (j *Job) reconcileNetwork(network database.Network, since time.Time) ReconciliationReport {
+	report := ReconciliationReport{Wallet: network.Code}
+	provider, err := j.WebhookService.CustodyService.Provider(network.Provider)
+	if err != nil {
+		log.Error("error reconciling network", zap.String("network", network.Code), zap.Error(err))
+		report.Failed = append(report.Failed, network.Provider)
+		return report
+	}
 
-type worker struct {
-	id        int
-	waitGroup *sync.WaitGroup
-	RequestCh chan workerRequest
-	errorCh   chan error
-}
+	for page := 1; ; page++ {
+		deposits, more, err := provider.ListTransactions(&network, page)
+		if err != nil {
+			log.Error("error fetching custody transactions", zap.String("network", network.Code), zap.Int("page", page), zap.Error(err))
+			report.Failed = append(report.Failed, fmt.Sprintf("page %d", page))
+			return report
+		}
+		report.Pages = page
+
+		reachedLookback := false
+		for _, deposit := range deposits {
+			if !deposit.CreatedAt.IsZero() && deposit.CreatedAt.Before(since) {
+				reachedLookback = true
+				continue
+			}
+			switch j.reconcileDeposit(deposit) {
+			case common.DepositOutcomeCreated:
+				report.Created = append(report.Created, deposit.Hash)
+			case common.DepositOutcomeUpdated:
+				report.Updated = append(report.Updated, deposit.Hash)
+			case common.DepositOutcomeSkipped:
+				report.Skipped = append(report.Skipped, deposit.Hash)
+			default:
+				report.Failed = append(report.Failed, deposit.Hash)
+			}
+		}
 
-func (w *worker) run() {
-	log.Info(fmt.Sprintf(`starting worker; %v`, w.id))
-	w.waitGroup.Add(1)
-	defer w.waitGroup.Done()
-	for task := range w.RequestCh {
-		address := task.BlockradarTransaction.RecipientAddress
-		fmt.Println(address)
-		// TODO: process transaction is it doesn't exist (or status is pending), avoid race conditions with the webhook
+		if reachedLookback || !more {
+			return report
+		}
 	}
 }
 
-type FetchTransactionResponse struct {
-	Message    string                  `json:"message"`
-	StatusCode int                     `json:"statusCode"`
-	Data       []BlockradarTransaction `json:"data"`
-	Analytics  struct {
-		DepositsSumIn24Hours       int64
-		WithdrawsSumIn24Hours      int64
-		TransactionsSumIn24Hours   int64
-		DepositsCountIn24Hours     int64
-		WithdrawsCountIn24Hours    int64
-		TransactionsCountIn24Hours int64
-		TotalDepositsCount         int64
-		TotalWithdrawsCount        int64
-		TotalTransactionsCount     int64
-	} `json:"analytics"`
+func (j *Job) reconcileDeposit(deposit common.Deposit) string {
+	if !strings.EqualFold(deposit.Type, "DEPOSIT") {
+		return common.DepositOutcomeSkipped
+	}
 
-	Meta struct {
-		TotalItems   int64
-		ItemCount    int64
-		ItemsPerPage int64
-		TotalPages   int64
-		CurrentPage  int64
-	} `json:"meta"`
-}
+	addresses, err := j.AddressRepo.GetAddressByColumn("address", deposit.RecipientAddress)
+	if err != nil {
+		log.Error("error fetching address", zap.String("hash", deposit.Hash), zap.Error(err))
+		return ""
+	}
+	if len(addresses) == 0 {
+		return common.DepositOutcomeSkipped
+	}
 
-type BlockradarTransaction struct {
-	ID               string  `json:"id"`
-	Reference        string  `json:"reference"`
-	SenderAddress    string  `json:"senderAddress"`
-	RecipientAddress string  `json:"recipientAddress"`
-	Amount           string  `json:"amount"`
-	AmountPaid       string  `json:"amountPaid"`
-	Fee              *string `json:"fee"`
-	Currency         string  `json:"currency"`
-	BlockNumber      int     `json:"blockNumber"`
-	BlockHash        string  `json:"blockHash"`
-	Hash             string  `json:"hash"`
-	Confirmations    int     `json:"confirmations"`
-	Confirmed        bool    `json:"confirmed"`
-	GasPrice         string  `json:"gasPrice"`
-	GasUsed          string  `json:"gasUsed"`
-	GasFee           string  `json:"gasFee"`
-	Status           string  `json:"status"`
-	Type             string  `json:"type"`
-	Note             *string `json:"note"`
-	AmlScreening     struct {
-		Provider string `json:"provider"`
-		Status   string `json:"status"`
-		Message  string `json:"message"`
-	} `json:"amlScreening"`
-	AssetSwept                 bool   `json:"assetSwept"`
-	AssetSweptAt               string `json:"assetSweptAt"`
-	AssetSweptGasFee           string `json:"assetSweptGasFee"`
-	AssetSweptHash             string `json:"assetSweptHash"`
-	AssetSweptSenderAddress    string `json:"assetSweptSenderAddress"`
-	AssetSweptRecipientAddress string `json:"assetSweptRecipientAddress"`
-	AssetSweptAmount           string `json:"assetSweptAmount"`
-	Reason                     string `json:"reason"`
-	Network                    string `json:"network"`
-	ChainID                    int    `json:"chainId"`
-	Metadata                   interface{}
-	CreatedAt                  string `json:"createdAt"`
-	UpdatedAt                  string `json:"updatedAt"`
-	Beneficiary                *string
-	PaymentLink                *string
-	Customer                   *string
+	outcome, err := j.WebhookService.ProcessDeposit(addresses[0].UserID.String(), addresses[0].AssetID.String(), deposit)
+	if err != nil {
+		log.Error("error reconciling deposit", zap.String("hash", deposit.Hash), zap.Error(err))
+		return ""
+	}
+	return outcome
 }
@@ -0,0 +1,21 @@
+package jobs
+
+import (
+	log "github.com/ShowBaba/kagewallet/logging"
+	"go.uber.org/zap"
+)
+
+const webhookBatchSize = 50
+
+// ProcessWebhookEvents drains the webhook inbox until no due events remain.
+func (j *Job) ProcessWebhookEvents() {
+	for {
+		processed, failed := j.InboxService.ProcessPending(webhookBatchSize)
+		if processed+failed > 0 {
+			log.Info("processed webhook events", zap.Int("processed", processed), zap.Int("failed", failed))
+		}
+		if processed+failed < webhookBatchSize {
+			return
+		}
+	}
+}
@@ -11,8 +11,10 @@ import (
 	"github.com/ShowBaba/kagewallet/database"
 	"github.com/ShowBaba/kagewallet/jobs"
 	log "github.com/ShowBaba/kagewallet/logging"
+	"github.com/ShowBaba/kagewallet/migrations"
 	"github.com/ShowBaba/kagewallet/repositories"
 	"github.com/ShowBaba/kagewallet/routes"
+	"github.com/ShowBaba/kagewallet/services"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
@@ -60,11 +62,6 @@ func init() {
 	if err != nil {
 		log.Fatal("error connecting to redis", zap.Error(err))
 	}
-
-	tBot, err = bot.NewTelegramBot(os.Getenv("TELEGRAM_TOKEN"), db)
-	if err != nil {
-		log.Fatal("error initializing telegram bot ", zap.Error(err))
-	}
 }
 
 func main() {
@@ -72,6 +69,28 @@ func main() {
 		log.InitializeLogger(zapcore.InfoLevel)
 	}
 
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if os.Getenv("MIGRATE_ON_STARTUP") == "true" {
+		ran, err := migrations.Up(db)
+		if err != nil {
+			log.Fatal("error running migrations", zap.Error(err))
+		}
+		log.Info("migrations applied", zap.Int("count", len(ran)))
+	}
+
+	var err error
+	tBot, err = bot.NewTelegramBot(os.Getenv("TELEGRAM_TOKEN"), db)
+	if err != nil {
+		log.Fatal("error initializing telegram bot ", zap.Error(err))
+	}
+
 	/*
 		f, err := os.Create("cpu.prof")
 		if err != nil {
@@ -124,11 +143,31 @@ func main() {
 
 	go func() {
 		var (
-			addressRepo = repositories.NewAddressRepository(db)
-			userRepo    = repositories.NewUserRepository(db)
-			jobService  = jobs.NewJob(addressRepo, userRepo)
+			addressRepo     = repositories.NewAddressRepository(db)
+			userRepo        = repositories.NewUserRepository(db)
+			transactionRepo = repositories.NewTransactionRepository(db)
+			walletRepo      = repositories.NewWalletRepository(db)
+			assetRepo       = repositories.NewAssetRepository(db)
+			withdrawalRepo  = repositories.NewWithdrawalRepository(db)
+			rateRepo        = repositories.NewRateRepository(db)
+			rateService     = services.NewRateService(rateRepo, repositories.NewQuoteRepository(db))
+			feeService      = services.NewFeeService(repositories.NewFeeRepository(db))
+			accountService  = services.NewAccountService(userRepo, repositories.NewSecurityEventRepository(db))
 		)
-		jobService.Start()
+		payouts, err := services.NewPayoutRouterFromEnv(services.NewMonnifyService())
+		if err != nil {
+			log.Fatal("error configuring payout providers", zap.Error(err))
+		}
+		var (
+			withdrawalService = services.NewWithdrawalService(payouts, withdrawalRepo, walletRepo, transactionRepo, userRepo, repositories.NewBeneficiaryRepository(db), feeService, accountService)
+			webhookService    = services.NewWebhookService(addressRepo, userRepo, transactionRepo, walletRepo, assetRepo, withdrawalRepo, rateService, withdrawalService, services.NewCustodyServiceFromEnv(repositories.NewNetworkRepository(db)), feeService)
+		)
+		rateAggregator, err := services.NewRateAggregatorFromEnv(rateRepo, assetRepo)
+		if err != nil {
+			log.Fatal("error configuring rate providers", zap.Error(err))
+		}
+		inboxService := services.NewWebhookInboxService(repositories.NewWebhookEventRepository(db), webhookService)
+		jobs.NewJob(addressRepo, userRepo, withdrawalRepo, webhookService, withdrawalService, rateAggregator, inboxService).Start()
 	}()
 
 	if env == "dev" {
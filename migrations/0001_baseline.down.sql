@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS withdrawal;
+DROP TABLE IF EXISTS wallet;
+DROP TABLE IF EXISTS transaction;
+DROP TABLE IF EXISTS address;
+DROP TABLE IF EXISTS rate;
+DROP TABLE IF EXISTS asset;
+DROP TABLE IF EXISTS telegram_command_log;
+DROP TABLE IF EXISTS telegram;
+DROP TABLE IF EXISTS "user";
@@ -0,0 +1,126 @@
+-- Baseline schema for the tables that existed before migrations were tracked.
+-- Everything is IF NOT EXISTS so the migration also adopts a database that was
+-- created by hand.
+
+CREATE TABLE IF NOT EXISTS "user" (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    password_hash TEXT NOT NULL DEFAULT '',
+    email         TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS telegram (
+    id          UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    username    TEXT NOT NULL DEFAULT '',
+    telegram_id BIGINT NOT NULL,
+    user_id     UUID NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS telegram_telegram_id_key ON telegram (telegram_id);
+CREATE UNIQUE INDEX IF NOT EXISTS telegram_user_id_key ON telegram (user_id);
+
+CREATE TABLE IF NOT EXISTS telegram_command_log (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    command_name TEXT NOT NULL,
+    user_id      UUID NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+    usage_time   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS telegram_command_log_user_id_idx ON telegram_command_log (user_id);
+
+CREATE TABLE IF NOT EXISTS asset (
+    id           UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    symbol       TEXT NOT NULL,
+    name         TEXT NOT NULL,
+    logo_url     TEXT NOT NULL DEFAULT '',
+    standard     TEXT NOT NULL DEFAULT '',
+    instructions TEXT NOT NULL DEFAULT '',
+    is_active    BOOLEAN NOT NULL DEFAULT false,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS asset_name_symbol_standard_key ON asset (name, symbol, standard);
+
+-- The Naira asset backs every wallet balance and ledger entry.
+INSERT INTO asset (id, symbol, name, is_active)
+VALUES ('0f0a0c3c-9a0a-4ec4-9be0-3ddea69327b3', 'NGN', 'Naira', false)
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS rate (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    rate       DOUBLE PRECISION NOT NULL,
+    source     TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS address (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    address    TEXT NOT NULL,
+    asset_id   UUID NOT NULL REFERENCES asset (id),
+    user_id    UUID NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+    is_active  BOOLEAN DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS address_address_asset_id_key ON address (address, asset_id);
+CREATE INDEX IF NOT EXISTS address_address_idx ON address (address);
+CREATE INDEX IF NOT EXISTS address_user_id_asset_id_idx ON address (user_id, asset_id);
+
+CREATE TABLE IF NOT EXISTS transaction (
+    id               UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id          UUID NOT NULL REFERENCES "user" (id),
+    asset_id         UUID NOT NULL REFERENCES asset (id),
+    type             TEXT NOT NULL,
+    amount           NUMERIC NOT NULL DEFAULT 0,
+    amount_usd       DOUBLE PRECISION NOT NULL DEFAULT 0,
+    status           TEXT NOT NULL,
+    reference        TEXT NOT NULL,
+    hash             TEXT NOT NULL DEFAULT '',
+    source_reference TEXT NOT NULL DEFAULT '',
+    source           TEXT NOT NULL DEFAULT '',
+    confirmations    BIGINT NOT NULL DEFAULT 0,
+    rate_id          UUID,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS transaction_reference_key ON transaction (reference);
+CREATE UNIQUE INDEX IF NOT EXISTS transaction_hash_key ON transaction (hash) WHERE hash <> '';
+CREATE INDEX IF NOT EXISTS transaction_source_reference_idx ON transaction (source_reference);
+CREATE INDEX IF NOT EXISTS transaction_user_id_created_at_idx ON transaction (user_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS wallet (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    UUID NOT NULL REFERENCES "user" (id),
+    balance    NUMERIC NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS wallet_user_id_key ON wallet (user_id);
+
+CREATE TABLE IF NOT EXISTS withdrawal (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    transaction_id UUID NOT NULL REFERENCES transaction (id),
+    account_number TEXT NOT NULL,
+    bank_name      TEXT NOT NULL DEFAULT '',
+    bank_code      TEXT NOT NULL,
+    user_id        UUID NOT NULL REFERENCES "user" (id),
+    status         TEXT NOT NULL,
+    amount         NUMERIC NOT NULL,
+    fee            INTEGER NOT NULL DEFAULT 0,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS withdrawal_transaction_id_key ON withdrawal (transaction_id);
+CREATE INDEX IF NOT EXISTS withdrawal_user_id_idx ON withdrawal (user_id);
+CREATE INDEX IF NOT EXISTS withdrawal_status_created_at_idx ON withdrawal (status, created_at);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS ledger_entry (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    journal_id     UUID NOT NULL,
+    transaction_id UUID NOT NULL,
+    wallet_id      UUID NOT NULL,
+    asset_id       UUID NOT NULL REFERENCES asset (id),
+    account        TEXT NOT NULL,
+    side           TEXT NOT NULL CHECK (side IN ('debit', 'credit')),
+    amount         NUMERIC NOT NULL CHECK (amount > 0),
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS ledger_entry_journal_id_idx ON ledger_entry (journal_id);
+CREATE INDEX IF NOT EXISTS ledger_entry_transaction_id_idx ON ledger_entry (transaction_id);
+CREATE INDEX IF NOT EXISTS ledger_entry_wallet_id_account_idx ON ledger_entry (wallet_id, account);
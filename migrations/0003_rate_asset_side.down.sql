@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS rate_asset_id_side_created_at_idx;
+ALTER TABLE rate DROP COLUMN IF EXISTS side;
+ALTER TABLE rate DROP COLUMN IF EXISTS asset_id;
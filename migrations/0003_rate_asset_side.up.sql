@@ -0,0 +1,4 @@
+ALTER TABLE rate ADD COLUMN IF NOT EXISTS asset_id UUID REFERENCES asset (id);
+ALTER TABLE rate ADD COLUMN IF NOT EXISTS side TEXT NOT NULL DEFAULT 'sell';
+
+CREATE INDEX IF NOT EXISTS rate_asset_id_side_created_at_idx ON rate (asset_id, side, created_at DESC);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS quote (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    UUID NOT NULL REFERENCES "user" (id),
+    asset_id   UUID NOT NULL REFERENCES asset (id),
+    address    TEXT NOT NULL,
+    rate_id    UUID NOT NULL REFERENCES rate (id),
+    rate       DOUBLE PRECISION NOT NULL,
+    status     TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS quote_address_asset_id_created_at_idx ON quote (address, asset_id, created_at DESC);
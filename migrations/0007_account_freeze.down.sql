@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS security_event;
+
+ALTER TABLE "user"
+    DROP COLUMN IF EXISTS frozen_by,
+    DROP COLUMN IF EXISTS frozen_reason,
+    DROP COLUMN IF EXISTS frozen_at,
+    DROP COLUMN IF EXISTS is_frozen;
@@ -0,0 +1,19 @@
+ALTER TABLE "user"
+    ADD COLUMN IF NOT EXISTS is_frozen     BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS frozen_at     TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS frozen_reason TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS frozen_by     TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS security_event (
+    id         UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    UUID NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+    type       TEXT NOT NULL,
+    action     TEXT NOT NULL DEFAULT '',
+    detail     TEXT NOT NULL DEFAULT '',
+    actor      TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS security_event_user_id_created_at_idx ON security_event (user_id, created_at);
+CREATE INDEX IF NOT EXISTS security_event_type_created_at_idx ON security_event (type, created_at);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS beneficiary (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id        UUID NOT NULL REFERENCES "user" (id) ON DELETE CASCADE,
+    bank_code      TEXT NOT NULL,
+    bank_name      TEXT NOT NULL DEFAULT '',
+    account_number TEXT NOT NULL,
+    account_name   TEXT NOT NULL DEFAULT '',
+    nickname       TEXT NOT NULL DEFAULT '',
+    active_from    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS beneficiary_user_id_bank_code_account_number_key
+    ON beneficiary (user_id, bank_code, account_number);
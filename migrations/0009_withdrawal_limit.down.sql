@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS withdrawal_user_id_created_at_idx;
+
+DROP TABLE IF EXISTS withdrawal_limit;
+
+ALTER TABLE withdrawal
+    DROP COLUMN IF EXISTS review_reason;
+
+ALTER TABLE "user"
+    DROP COLUMN IF EXISTS tier;
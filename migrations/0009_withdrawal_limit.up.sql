@@ -0,0 +1,22 @@
+ALTER TABLE "user"
+    ADD COLUMN IF NOT EXISTS tier TEXT NOT NULL DEFAULT 'basic';
+
+ALTER TABLE withdrawal
+    ADD COLUMN IF NOT EXISTS review_reason TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS withdrawal_limit (
+    tier            TEXT PRIMARY KEY,
+    per_transaction NUMERIC NOT NULL DEFAULT 0,
+    daily           NUMERIC NOT NULL DEFAULT 0,
+    weekly          NUMERIC NOT NULL DEFAULT 0,
+    hourly_count    INTEGER NOT NULL DEFAULT 0,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+INSERT INTO withdrawal_limit (tier, per_transaction, daily, weekly, hourly_count)
+VALUES ('basic', 200000, 500000, 2000000, 3),
+       ('verified', 1000000, 3000000, 10000000, 5)
+ON CONFLICT (tier) DO NOTHING;
+
+CREATE INDEX IF NOT EXISTS withdrawal_user_id_created_at_idx ON withdrawal (user_id, created_at);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS withdrawal_status_updated_at_idx;
+
+DROP TABLE IF EXISTS withdrawal_review;
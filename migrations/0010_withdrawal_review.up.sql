@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS withdrawal_review (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    withdrawal_id UUID NOT NULL REFERENCES withdrawal (id) ON DELETE CASCADE,
+    admin         TEXT NOT NULL,
+    decision      TEXT NOT NULL,
+    reason        TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS withdrawal_review_withdrawal_id_admin_key ON withdrawal_review (withdrawal_id, admin);
+
+CREATE INDEX IF NOT EXISTS withdrawal_status_updated_at_idx ON withdrawal (status, updated_at);
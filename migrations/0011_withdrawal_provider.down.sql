@@ -0,0 +1,2 @@
+ALTER TABLE withdrawal
+    DROP COLUMN IF EXISTS provider;
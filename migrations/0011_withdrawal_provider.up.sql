@@ -0,0 +1,7 @@
+-- Every withdrawal made before payout providers were routed went through
+-- Monnify.
+ALTER TABLE withdrawal
+    ADD COLUMN IF NOT EXISTS provider TEXT NOT NULL DEFAULT 'monnify';
+
+ALTER TABLE withdrawal
+    ALTER COLUMN provider SET DEFAULT '';
@@ -0,0 +1,4 @@
+ALTER TABLE asset
+    DROP COLUMN IF EXISTS network_id;
+
+DROP TABLE IF EXISTS network;
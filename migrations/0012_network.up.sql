@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS network (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    code           TEXT NOT NULL UNIQUE,
+    name           TEXT NOT NULL DEFAULT '',
+    provider       TEXT NOT NULL,
+    token_standard TEXT NOT NULL DEFAULT '',
+    wallet_id_env  TEXT NOT NULL DEFAULT '',
+    api_key_env    TEXT NOT NULL DEFAULT '',
+    is_active      BOOLEAN NOT NULL DEFAULT true,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- The wallets that used to be hard-coded, keeping their existing env vars.
+INSERT INTO network (code, name, provider, token_standard, wallet_id_env, api_key_env)
+VALUES ('ETH', 'Ethereum', 'blockradar', 'ERC20', 'BLOCKRADER_ETH_WALLET_ID', 'BLOCKRADAR_ETH_API_KEY'),
+       ('TRON', 'Tron', 'blockradar', 'TRC20', 'BLOCKRADER_TRON_WALLET_ID', 'BLOCKRADAR_TRON_API_KEY'),
+       ('BNB', 'BNB Smart Chain', 'blockradar', 'BEP20', 'BLOCKRADER_BNB_WALLET_ID', 'BLOCKRADAR_BNB_API_KEY')
+ON CONFLICT (code) DO NOTHING;
+
+ALTER TABLE asset
+    ADD COLUMN IF NOT EXISTS network_id UUID REFERENCES network (id);
+
+UPDATE asset
+SET network_id = network.id
+FROM network
+WHERE asset.network_id IS NULL
+  AND asset.standard = network.token_standard;
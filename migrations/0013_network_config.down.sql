@@ -0,0 +1,9 @@
+ALTER TABLE asset
+    DROP COLUMN IF EXISTS deposit_fee,
+    DROP COLUMN IF EXISTS min_deposit,
+    DROP COLUMN IF EXISTS decimals,
+    DROP COLUMN IF EXISTS token_contract;
+
+ALTER TABLE network
+    DROP COLUMN IF EXISTS required_confirmations,
+    DROP COLUMN IF EXISTS chain_id;
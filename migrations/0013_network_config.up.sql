@@ -0,0 +1,15 @@
+ALTER TABLE network
+    ADD COLUMN IF NOT EXISTS chain_id               BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS required_confirmations INTEGER NOT NULL DEFAULT 0;
+
+UPDATE network SET chain_id = 1, required_confirmations = 12 WHERE code = 'ETH';
+UPDATE network SET chain_id = 728126428, required_confirmations = 19 WHERE code = 'TRON';
+UPDATE network SET chain_id = 56, required_confirmations = 15 WHERE code = 'BNB';
+
+-- Token settings live on the asset, since one network carries many tokens.
+-- min_deposit and deposit_fee are in units of the asset.
+ALTER TABLE asset
+    ADD COLUMN IF NOT EXISTS token_contract TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS decimals       INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS min_deposit    NUMERIC NOT NULL DEFAULT 0 CHECK (min_deposit >= 0),
+    ADD COLUMN IF NOT EXISTS deposit_fee    NUMERIC NOT NULL DEFAULT 0 CHECK (deposit_fee >= 0);
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS conversion;
+
+-- Only Naira wallets existed before conversions.
+DELETE FROM wallet WHERE asset_id <> '0f0a0c3c-9a0a-4ec4-9be0-3ddea69327b3';
+
+DROP INDEX IF EXISTS wallet_user_id_asset_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS wallet_user_id_key ON wallet (user_id);
+
+ALTER TABLE wallet
+    DROP COLUMN IF EXISTS asset_id;
@@ -0,0 +1,33 @@
+-- Wallets are kept per asset so users can hold crypto next to Naira. Every
+-- existing wallet is the user's Naira wallet.
+ALTER TABLE wallet
+    ADD COLUMN IF NOT EXISTS asset_id UUID REFERENCES asset (id);
+
+UPDATE wallet
+SET asset_id = '0f0a0c3c-9a0a-4ec4-9be0-3ddea69327b3'
+WHERE asset_id IS NULL;
+
+ALTER TABLE wallet
+    ALTER COLUMN asset_id SET NOT NULL;
+
+DROP INDEX IF EXISTS wallet_user_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS wallet_user_id_asset_id_key ON wallet (user_id, asset_id);
+
+CREATE TABLE IF NOT EXISTS conversion (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    transaction_id UUID NOT NULL REFERENCES transaction (id),
+    user_id        UUID NOT NULL REFERENCES "user" (id),
+    from_asset_id  UUID NOT NULL REFERENCES asset (id),
+    to_asset_id    UUID NOT NULL REFERENCES asset (id),
+    from_amount    NUMERIC NOT NULL CHECK (from_amount > 0),
+    fee            NUMERIC NOT NULL DEFAULT 0 CHECK (fee >= 0),
+    to_amount      NUMERIC NOT NULL CHECK (to_amount > 0),
+    rate           NUMERIC NOT NULL,
+    from_rate_id   UUID NOT NULL REFERENCES rate (id),
+    to_rate_id     UUID NOT NULL REFERENCES rate (id),
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS conversion_transaction_id_key ON conversion (transaction_id);
+CREATE INDEX IF NOT EXISTS conversion_user_id_created_at_idx ON conversion (user_id, created_at DESC);
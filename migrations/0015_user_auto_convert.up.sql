@@ -0,0 +1,2 @@
+ALTER TABLE "user"
+    ADD COLUMN IF NOT EXISTS auto_convert BOOLEAN NOT NULL DEFAULT TRUE;
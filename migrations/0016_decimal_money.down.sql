@@ -0,0 +1,11 @@
+ALTER TABLE withdrawal
+    ALTER COLUMN fee TYPE INTEGER USING round(fee)::integer;
+
+ALTER TABLE transaction
+    ALTER COLUMN amount_usd TYPE DOUBLE PRECISION USING amount_usd::double precision;
+
+ALTER TABLE quote
+    ALTER COLUMN rate TYPE DOUBLE PRECISION USING rate::double precision;
+
+ALTER TABLE rate
+    ALTER COLUMN rate TYPE DOUBLE PRECISION USING rate::double precision;
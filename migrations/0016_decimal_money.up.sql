@@ -0,0 +1,13 @@
+-- Rates and USD amounts were stored as floating point. NUMERIC keeps them
+-- exact like every other amount.
+ALTER TABLE rate
+    ALTER COLUMN rate TYPE NUMERIC USING rate::numeric;
+
+ALTER TABLE quote
+    ALTER COLUMN rate TYPE NUMERIC USING rate::numeric;
+
+ALTER TABLE transaction
+    ALTER COLUMN amount_usd TYPE NUMERIC USING amount_usd::numeric;
+
+ALTER TABLE withdrawal
+    ALTER COLUMN fee TYPE NUMERIC USING fee::numeric;
@@ -0,0 +1,15 @@
+ALTER TABLE asset
+    ADD COLUMN IF NOT EXISTS deposit_fee NUMERIC NOT NULL DEFAULT 0 CHECK (deposit_fee >= 0);
+
+UPDATE asset
+SET deposit_fee = fee_schedule.flat
+FROM fee_schedule
+WHERE fee_schedule.asset_id = asset.id
+  AND fee_schedule.operation = 'deposit'
+  AND fee_schedule.kind = 'flat'
+  AND fee_schedule.tier = ''
+  AND fee_schedule.superseded_at IS NULL;
+
+DROP TABLE IF EXISTS transaction_fee;
+DROP TABLE IF EXISTS fee_band;
+DROP TABLE IF EXISTS fee_schedule;
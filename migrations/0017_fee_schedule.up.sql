@@ -0,0 +1,66 @@
+-- A fee schedule prices one operation for an asset and tier. A NULL asset or
+-- an empty tier matches any. Schedules are never edited: a change is saved as
+-- the next version and the active one is superseded, so the fees recorded on
+-- past transactions keep pointing at the schedule that applied.
+CREATE TABLE IF NOT EXISTS fee_schedule (
+    id            UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    operation     TEXT NOT NULL CHECK (operation IN ('deposit', 'sell', 'withdrawal', 'convert')),
+    asset_id      UUID REFERENCES asset (id),
+    tier          TEXT NOT NULL DEFAULT '',
+    version       INTEGER NOT NULL CHECK (version > 0),
+    kind          TEXT NOT NULL CHECK (kind IN ('flat', 'percentage', 'tiered')),
+    flat          NUMERIC NOT NULL DEFAULT 0 CHECK (flat >= 0),
+    rate_bps      INTEGER NOT NULL DEFAULT 0 CHECK (rate_bps >= 0 AND rate_bps < 10000),
+    min_fee       NUMERIC NOT NULL DEFAULT 0 CHECK (min_fee >= 0),
+    max_fee       NUMERIC NOT NULL DEFAULT 0 CHECK (max_fee >= 0),
+    created_by    TEXT NOT NULL DEFAULT '',
+    superseded_at TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS fee_schedule_active_key
+    ON fee_schedule (operation, COALESCE(asset_id, '00000000-0000-0000-0000-000000000000'), tier)
+    WHERE superseded_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS fee_schedule_version_key
+    ON fee_schedule (operation, COALESCE(asset_id, '00000000-0000-0000-0000-000000000000'), tier, version);
+
+-- The bands of a tiered schedule. The first band whose up_to covers the
+-- amount applies; an up_to of 0 has no upper bound.
+CREATE TABLE IF NOT EXISTS fee_band (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    fee_schedule_id UUID NOT NULL REFERENCES fee_schedule (id) ON DELETE CASCADE,
+    up_to           NUMERIC NOT NULL DEFAULT 0 CHECK (up_to >= 0),
+    flat            NUMERIC NOT NULL DEFAULT 0 CHECK (flat >= 0),
+    rate_bps        INTEGER NOT NULL DEFAULT 0 CHECK (rate_bps >= 0 AND rate_bps < 10000)
+);
+
+CREATE INDEX IF NOT EXISTS fee_band_fee_schedule_id_idx ON fee_band (fee_schedule_id);
+
+-- Every fee charged, in the asset it was charged in.
+CREATE TABLE IF NOT EXISTS transaction_fee (
+    id              UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    transaction_id  UUID NOT NULL REFERENCES transaction (id),
+    fee_schedule_id UUID NOT NULL REFERENCES fee_schedule (id),
+    operation       TEXT NOT NULL,
+    asset_id        UUID NOT NULL REFERENCES asset (id),
+    amount          NUMERIC NOT NULL CHECK (amount > 0),
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS transaction_fee_transaction_id_idx ON transaction_fee (transaction_id);
+
+-- The fees charged so far become the first version of their schedules.
+INSERT INTO fee_schedule (operation, version, kind, flat, created_by)
+VALUES ('withdrawal', 1, 'flat', 100, 'migration');
+
+INSERT INTO fee_schedule (operation, version, kind, rate_bps, created_by)
+VALUES ('convert', 1, 'percentage', 50, 'migration');
+
+INSERT INTO fee_schedule (operation, asset_id, version, kind, flat, created_by)
+SELECT 'deposit', id, 1, 'flat', deposit_fee, 'migration'
+FROM asset
+WHERE deposit_fee > 0;
+
+ALTER TABLE asset
+    DROP COLUMN IF EXISTS deposit_fee;
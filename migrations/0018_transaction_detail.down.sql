@@ -0,0 +1,4 @@
+ALTER TABLE network
+    DROP COLUMN IF EXISTS explorer_url;
+
+DROP TABLE IF EXISTS transaction_event;
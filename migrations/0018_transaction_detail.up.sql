@@ -0,0 +1,24 @@
+-- Every status a transaction moves through, for the timeline in its detail
+-- view.
+CREATE TABLE IF NOT EXISTS transaction_event (
+    id             UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    transaction_id UUID NOT NULL REFERENCES transaction (id) ON DELETE CASCADE,
+    status         TEXT NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS transaction_event_transaction_id_idx ON transaction_event (transaction_id, created_at);
+
+-- Earlier transactions only know their current status.
+INSERT INTO transaction_event (transaction_id, status, created_at)
+SELECT id, status, updated_at
+FROM transaction;
+
+-- A block explorer link for a transaction hash, with {hash} where the hash
+-- goes.
+ALTER TABLE network
+    ADD COLUMN IF NOT EXISTS explorer_url TEXT NOT NULL DEFAULT '';
+
+UPDATE network SET explorer_url = 'https://etherscan.io/tx/{hash}' WHERE code = 'ETH' AND explorer_url = '';
+UPDATE network SET explorer_url = 'https://tronscan.org/#/transaction/{hash}' WHERE code = 'TRON' AND explorer_url = '';
+UPDATE network SET explorer_url = 'https://bscscan.com/tx/{hash}' WHERE code = 'BNB' AND explorer_url = '';
@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type BeneficiaryRepository struct {
+	DB *gorm.DB
+}
+
+func NewBeneficiaryRepository(db *gorm.DB) *BeneficiaryRepository {
+	return &BeneficiaryRepository{
+		DB: db,
+	}
+}
+
+func (r *BeneficiaryRepository) CreateBeneficiary(beneficiary *database.Beneficiary) error {
+	return r.DB.Create(beneficiary).Error
+}
+
+func (r *BeneficiaryRepository) ListByUser(userID uuid.UUID) ([]database.Beneficiary, error) {
+	var beneficiaries []database.Beneficiary
+	err := r.DB.Where("user_id = ?", userID).Order("created_at ASC").Find(&beneficiaries).Error
+	return beneficiaries, err
+}
+
+func (r *BeneficiaryRepository) CountByUser(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.DB.Model(&database.Beneficiary{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// FindByID returns the user's beneficiary with the given id, or nil when the
+// user has no such beneficiary.
+func (r *BeneficiaryRepository) FindByID(userID, id uuid.UUID) (*database.Beneficiary, error) {
+	return r.findOne(r.DB.Where("user_id = ? AND id = ?", userID, id))
+}
+
+// FindByAccount returns the user's beneficiary for a bank account, or nil
+// when the account is not saved.
+func (r *BeneficiaryRepository) FindByAccount(userID uuid.UUID, bankCode, accountNumber string) (*database.Beneficiary, error) {
+	return r.findOne(r.DB.Where("user_id = ? AND bank_code = ? AND account_number = ?", userID, bankCode, accountNumber))
+}
+
+// DeleteBeneficiary removes the user's beneficiary and reports whether there
+// was one to remove.
+func (r *BeneficiaryRepository) DeleteBeneficiary(userID, id uuid.UUID) (bool, error) {
+	result := r.DB.Where("user_id = ? AND id = ?", userID, id).Delete(&database.Beneficiary{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *BeneficiaryRepository) findOne(query *gorm.DB) (*database.Beneficiary, error) {
+	var beneficiary database.Beneficiary
+	err := query.First(&beneficiary).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &beneficiary, nil
+}
@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ConversionRepository struct {
+	DB *gorm.DB
+}
+
+func NewConversionRepository(db *gorm.DB) *ConversionRepository {
+	return &ConversionRepository{
+		DB: db,
+	}
+}
+
+// RecordConversion moves a conversion's amount out of the user's source
+// wallet and pays the converted amount into their target wallet in one
+// journal. The source wallet is locked while its balance is checked, so
+// parallel conversions and withdrawals cannot spend the same funds.
+func (r *ConversionRepository) RecordConversion(transaction *database.Transaction, conversion *database.Conversion) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var source database.Wallet
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND asset_id = ?", conversion.UserID, conversion.FromAssetID).
+			First(&source).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInsufficientBalance
+			}
+			return fmt.Errorf("error locking wallet: %v", err)
+		}
+		if source.Balance.LessThan(conversion.FromAmount) {
+			return ErrInsufficientBalance
+		}
+
+		if err := tx.Create(transaction).Error; err != nil {
+			return fmt.Errorf("error creating transaction: %v", err)
+		}
+		conversion.TransactionID = transaction.ID
+		if err := tx.Create(conversion).Error; err != nil {
+			return fmt.Errorf("error creating conversion: %v", err)
+		}
+		if err := recordFees(tx, transaction.ID, transaction.Fees); err != nil {
+			return err
+		}
+		if err := recordStatus(tx, transaction.ID, transaction.Status); err != nil {
+			return err
+		}
+
+		target, err := findOrCreateWallet(tx, conversion.UserID, conversion.ToAssetID)
+		if err != nil {
+			return err
+		}
+
+		entries := []database.LedgerEntry{
+			debit(common.LedgerAccountUserWallet, source.ID, conversion.FromAssetID, conversion.FromAmount),
+			credit(common.LedgerAccountConversion, uuid.Nil, conversion.FromAssetID, conversion.FromAmount.Sub(conversion.Fee)),
+			debit(common.LedgerAccountConversion, uuid.Nil, conversion.ToAssetID, conversion.ToAmount),
+			credit(common.LedgerAccountUserWallet, target.ID, conversion.ToAssetID, conversion.ToAmount),
+		}
+		if conversion.Fee.IsPositive() {
+			entries = append(entries, credit(common.LedgerAccountFees, uuid.Nil, conversion.FromAssetID, conversion.Fee))
+		}
+		return postJournal(tx, transaction.ID, entries...)
+	})
+}
+
+func (r *ConversionRepository) GetConversionByTransactionID(transactionID uuid.UUID) (*database.Conversion, error) {
+	var conversion database.Conversion
+	if err := r.DB.Where("transaction_id = ?", transactionID).First(&conversion).Error; err != nil {
+		return nil, err
+	}
+	return &conversion, nil
+}
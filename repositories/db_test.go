@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"os"
+	"testing"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/ShowBaba/kagewallet/migrations"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// testDB connects to TEST_DATABASE_URL and migrates it. Tests that need
+// Postgres are skipped when it is not set. Every test makes its own users, so
+// the database can be shared and is never cleaned.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		NamingStrategy:         schema.NamingStrategy{SingularTable: true},
+		SkipDefaultTransaction: true,
+		Logger:                 logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("error connecting to test database: %v", err)
+	}
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatalf("error migrating test database: %v", err)
+	}
+	return db
+}
+
+// fundedUser creates a user with a Naira wallet opened at balance.
+func fundedUser(t *testing.T, db *gorm.DB, balance decimal.Decimal) (*database.User, *database.Wallet) {
+	t.Helper()
+	user := &database.User{}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("error creating user: %v", err)
+	}
+	wallet := &database.Wallet{UserID: user.ID, AssetID: nairaAssetID}
+	if err := db.Create(wallet).Error; err != nil {
+		t.Fatalf("error creating wallet: %v", err)
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return postJournal(tx, uuid.Nil,
+			debit(common.LedgerAccountOpeningBalance, uuid.Nil, nairaAssetID, balance),
+			credit(common.LedgerAccountUserWallet, wallet.ID, nairaAssetID, balance),
+		)
+	})
+	if err != nil {
+		t.Fatalf("error funding wallet: %v", err)
+	}
+	return user, wallet
+}
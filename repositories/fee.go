@@ -0,0 +1,181 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type FeeRepository struct {
+	DB *gorm.DB
+}
+
+func NewFeeRepository(db *gorm.DB) *FeeRepository {
+	return &FeeRepository{
+		DB: db,
+	}
+}
+
+// FindActiveSchedule returns the active schedule that most closely matches
+// the operation, asset and tier: one for the asset and tier, then the asset,
+// then the tier, then the operation's default. It returns nil when the
+// operation has no fee.
+func (r *FeeRepository) FindActiveSchedule(operation string, assetID uuid.UUID, tier string) (*database.FeeSchedule, error) {
+	var schedule database.FeeSchedule
+	err := r.DB.Where("operation = ? AND superseded_at IS NULL", operation).
+		Where("asset_id = ? OR asset_id IS NULL", assetID).
+		Where("tier = ? OR tier = ''", tier).
+		Order("asset_id IS NULL, tier = ''").
+		First(&schedule).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching fee schedule: %v", err)
+	}
+	if err := loadBands(r.DB, &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// ListSchedules returns the active schedules, or every version when history
+// is set, optionally for one operation.
+func (r *FeeRepository) ListSchedules(operation string, history bool) ([]database.FeeSchedule, error) {
+	query := r.DB.Model(&database.FeeSchedule{})
+	if operation != "" {
+		query = query.Where("operation = ?", operation)
+	}
+	if !history {
+		query = query.Where("superseded_at IS NULL")
+	}
+
+	var schedules []database.FeeSchedule
+	if err := query.Order("operation, asset_id NULLS FIRST, tier, version DESC").Find(&schedules).Error; err != nil {
+		return nil, fmt.Errorf("error listing fee schedules: %v", err)
+	}
+	for i := range schedules {
+		if err := loadBands(r.DB, &schedules[i]); err != nil {
+			return nil, err
+		}
+	}
+	return schedules, nil
+}
+
+func (r *FeeRepository) GetSchedule(id uuid.UUID) (*database.FeeSchedule, error) {
+	var schedule database.FeeSchedule
+	if err := r.DB.Where("id = ?", id).First(&schedule).Error; err != nil {
+		return nil, err
+	}
+	if err := loadBands(r.DB, &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// CreateScheduleVersion saves the schedule as the next version for its
+// operation, asset and tier, superseding the active version in the same
+// transaction. The key is locked first so two admins cannot both create the
+// same version.
+func (r *FeeRepository) CreateScheduleVersion(schedule *database.FeeSchedule) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", scheduleKey(schedule)).Error; err != nil {
+			return fmt.Errorf("error locking fee schedule: %v", err)
+		}
+
+		var version int
+		err := scheduleKeyQuery(tx, schedule).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&version).Error
+		if err != nil {
+			return fmt.Errorf("error fetching fee schedule version: %v", err)
+		}
+
+		err = scheduleKeyQuery(tx, schedule).
+			Where("superseded_at IS NULL").
+			Updates(map[string]interface{}{"superseded_at": time.Now(), "updated_at": time.Now()}).Error
+		if err != nil {
+			return fmt.Errorf("error superseding fee schedule: %v", err)
+		}
+
+		schedule.Version = version + 1
+		schedule.SupersededAt = nil
+		if err := tx.Create(schedule).Error; err != nil {
+			return fmt.Errorf("error creating fee schedule: %v", err)
+		}
+		for i := range schedule.Bands {
+			schedule.Bands[i].FeeScheduleID = schedule.ID
+			if err := tx.Create(&schedule.Bands[i]).Error; err != nil {
+				return fmt.Errorf("error creating fee band: %v", err)
+			}
+		}
+		return nil
+	})
+}
+
+// RetireSchedule supersedes an active schedule without a replacement, so the
+// next most general schedule applies. It reports whether the schedule was
+// still active.
+func (r *FeeRepository) RetireSchedule(id uuid.UUID) (bool, error) {
+	result := r.DB.Model(&database.FeeSchedule{}).
+		Where("id = ? AND superseded_at IS NULL", id).
+		Updates(map[string]interface{}{"superseded_at": time.Now(), "updated_at": time.Now()})
+	if result.Error != nil {
+		return false, fmt.Errorf("error retiring fee schedule: %v", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *FeeRepository) GetTransactionFees(transactionID uuid.UUID) ([]database.TransactionFee, error) {
+	var fees []database.TransactionFee
+	if err := r.DB.Where("transaction_id = ?", transactionID).Order("created_at").Find(&fees).Error; err != nil {
+		return nil, fmt.Errorf("error fetching transaction fees: %v", err)
+	}
+	return fees, nil
+}
+
+func loadBands(db *gorm.DB, schedule *database.FeeSchedule) error {
+	err := db.Where("fee_schedule_id = ?", schedule.ID).
+		Order("up_to = 0, up_to").
+		Find(&schedule.Bands).Error
+	if err != nil {
+		return fmt.Errorf("error fetching fee bands: %v", err)
+	}
+	return nil
+}
+
+func scheduleKey(schedule *database.FeeSchedule) string {
+	assetID := uuid.Nil
+	if schedule.AssetID != nil {
+		assetID = *schedule.AssetID
+	}
+	return fmt.Sprintf("fee_schedule:%s:%s:%s", schedule.Operation, assetID, schedule.Tier)
+}
+
+func scheduleKeyQuery(tx *gorm.DB, schedule *database.FeeSchedule) *gorm.DB {
+	query := tx.Model(&database.FeeSchedule{}).
+		Where("operation = ? AND tier = ?", schedule.Operation, schedule.Tier)
+	if schedule.AssetID == nil {
+		return query.Where("asset_id IS NULL")
+	}
+	return query.Where("asset_id = ?", *schedule.AssetID)
+}
+
+// recordFees saves the fees charged on a transaction inside the caller's
+// database transaction. Zero fees are not recorded.
+func recordFees(tx *gorm.DB, transactionID uuid.UUID, fees []database.TransactionFee) error {
+	for i := range fees {
+		if !fees[i].Amount.IsPositive() {
+			continue
+		}
+		fees[i].TransactionID = transactionID
+		if err := tx.Create(&fees[i]).Error; err != nil {
+			return fmt.Errorf("error recording fee: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnbalancedJournal = errors.New("journal debits and credits do not balance")
+	// ErrSubKoboAmount rejects Naira entries finer than a kobo, which no
+	// bank transfer can settle.
+	ErrSubKoboAmount = errors.New("naira ledger amount is not whole kobo")
+)
+
+var nairaAssetID = uuid.MustParse(common.NairaAssetID)
+
+// signedAmountSQL is positive for debits and negative for credits, so every
+// journal must sum to zero per asset.
+const signedAmountSQL = "CASE WHEN side = 'debit' THEN amount ELSE -amount END"
+
+// walletBalanceSQL projects a wallet balance from its user_wallet entries.
+// User wallets are liabilities, so credits increase the balance.
+const walletBalanceSQL = `(SELECT COALESCE(SUM(CASE WHEN side = 'credit' THEN amount ELSE -amount END), 0)
+	FROM ledger_entry WHERE ledger_entry.wallet_id = wallet.id AND ledger_entry.account = 'user_wallet')`
+
+type LedgerRepository struct {
+	DB *gorm.DB
+}
+
+func NewLedgerRepository(db *gorm.DB) *LedgerRepository {
+	return &LedgerRepository{
+		DB: db,
+	}
+}
+
+func debit(account string, walletID, assetID uuid.UUID, amount decimal.Decimal) database.LedgerEntry {
+	return database.LedgerEntry{Account: account, WalletID: walletID, AssetID: assetID, Side: common.LedgerSideDebit, Amount: amount}
+}
+
+func credit(account string, walletID, assetID uuid.UUID, amount decimal.Decimal) database.LedgerEntry {
+	return database.LedgerEntry{Account: account, WalletID: walletID, AssetID: assetID, Side: common.LedgerSideCredit, Amount: amount}
+}
+
+// postJournal writes a balanced set of entries for a transaction and refreshes
+// the balance projection of every user wallet it touches. It must be called
+// inside a database transaction.
+func postJournal(tx *gorm.DB, transactionID uuid.UUID, entries ...database.LedgerEntry) error {
+	if len(entries) < 2 {
+		return errors.New("journal must have at least two entries")
+	}
+
+	var (
+		journalID = uuid.New()
+		totals    = make(map[uuid.UUID]decimal.Decimal)
+		wallets   = make(map[uuid.UUID]struct{})
+	)
+	for i := range entries {
+		entry := &entries[i]
+		if !entry.Amount.IsPositive() {
+			return fmt.Errorf("ledger entry amount must be positive, got %s", entry.Amount)
+		}
+		if entry.AssetID == nairaAssetID && !entry.Amount.Equal(entry.Amount.Truncate(common.NairaPlaces)) {
+			return fmt.Errorf("%w: %s", ErrSubKoboAmount, entry.Amount)
+		}
+		switch entry.Side {
+		case common.LedgerSideDebit:
+			totals[entry.AssetID] = totals[entry.AssetID].Add(entry.Amount)
+		case common.LedgerSideCredit:
+			totals[entry.AssetID] = totals[entry.AssetID].Sub(entry.Amount)
+		default:
+			return fmt.Errorf("invalid ledger entry side: %s", entry.Side)
+		}
+		entry.JournalID = journalID
+		entry.TransactionID = transactionID
+		if entry.Account == common.LedgerAccountUserWallet {
+			wallets[entry.WalletID] = struct{}{}
+		}
+	}
+
+	for assetID, total := range totals {
+		if !total.IsZero() {
+			return fmt.Errorf("%w: asset %s is off by %s", ErrUnbalancedJournal, assetID, total)
+		}
+	}
+
+	if err := tx.Create(&entries).Error; err != nil {
+		return fmt.Errorf("error creating ledger entries: %v", err)
+	}
+
+	for walletID := range wallets {
+		if err := projectWalletBalance(tx, walletID); err != nil {
+			return fmt.Errorf("error projecting wallet balance: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func projectWalletBalance(tx *gorm.DB, walletID uuid.UUID) error {
+	return tx.Model(&database.Wallet{}).
+		Where("id = ?", walletID).
+		Updates(map[string]interface{}{
+			"balance":    gorm.Expr(walletBalanceSQL),
+			"updated_at": time.Now(),
+		}).Error
+}
+
+func (r *LedgerRepository) GetEntriesByWallet(walletID uuid.UUID) ([]database.LedgerEntry, error) {
+	var entries []database.LedgerEntry
+	err := r.DB.Where("wallet_id = ?", walletID).Order("created_at ASC").Find(&entries).Error
+	return entries, err
+}
+
+func (r *LedgerRepository) GetEntriesByTransaction(transactionID uuid.UUID) ([]database.LedgerEntry, error) {
+	var entries []database.LedgerEntry
+	err := r.DB.Where("transaction_id = ?", transactionID).Order("created_at ASC").Find(&entries).Error
+	return entries, err
+}
+
+// OpenWalletBalances posts an opening journal for every wallet that has a
+// balance but no ledger history, so balances written before the ledger
+// existed survive a rebuild.
+func (r *LedgerRepository) OpenWalletBalances() (int, error) {
+	var wallets []database.Wallet
+	err := r.DB.
+		Where("balance <> 0").
+		Where("NOT EXISTS (SELECT 1 FROM ledger_entry WHERE ledger_entry.wallet_id = wallet.id)").
+		Find(&wallets).Error
+	if err != nil {
+		return 0, err
+	}
+
+	for _, wallet := range wallets {
+		assetID := wallet.AssetID
+		// Naira balances written as floats can hold fractions of a kobo;
+		// they open at the whole kobo below.
+		if assetID == nairaAssetID {
+			wallet.Balance = wallet.Balance.RoundDown(common.NairaPlaces)
+		}
+		if wallet.Balance.IsZero() {
+			if err := projectWalletBalance(r.DB, wallet.ID); err != nil {
+				return 0, fmt.Errorf("error opening wallet %s: %v", wallet.ID, err)
+			}
+			continue
+		}
+		entries := []database.LedgerEntry{
+			debit(common.LedgerAccountOpeningBalance, uuid.Nil, assetID, wallet.Balance),
+			credit(common.LedgerAccountUserWallet, wallet.ID, assetID, wallet.Balance),
+		}
+		if wallet.Balance.IsNegative() {
+			entries = []database.LedgerEntry{
+				credit(common.LedgerAccountOpeningBalance, uuid.Nil, assetID, wallet.Balance.Neg()),
+				debit(common.LedgerAccountUserWallet, wallet.ID, assetID, wallet.Balance.Neg()),
+			}
+		}
+		err := r.DB.Transaction(func(tx *gorm.DB) error {
+			return postJournal(tx, uuid.Nil, entries...)
+		})
+		if err != nil {
+			return 0, fmt.Errorf("error opening wallet %s: %v", wallet.ID, err)
+		}
+	}
+
+	return len(wallets), nil
+}
+
+func (r *LedgerRepository) RebuildWalletBalances() (int64, error) {
+	result := r.DB.Model(&database.Wallet{}).
+		Where("1 = 1").
+		Updates(map[string]interface{}{
+			"balance":    gorm.Expr(walletBalanceSQL),
+			"updated_at": time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
+
+func (r *LedgerRepository) GetUnbalancedJournals() ([]database.UnbalancedJournal, error) {
+	var journals []database.UnbalancedJournal
+	err := r.DB.Model(&database.LedgerEntry{}).
+		Select("journal_id, asset_id, SUM(" + signedAmountSQL + ") AS total").
+		Group("journal_id, asset_id").
+		Having("SUM(" + signedAmountSQL + ") <> 0").
+		Scan(&journals).Error
+	return journals, err
+}
+
+func (r *LedgerRepository) GetWalletsOutOfSync() ([]database.WalletLedgerDrift, error) {
+	var drifts []database.WalletLedgerDrift
+	err := r.DB.Table("wallet").
+		Select("wallet.id AS wallet_id, wallet.user_id, wallet.balance, " + walletBalanceSQL + " AS ledger_balance").
+		Where("wallet.balance <> " + walletBalanceSQL).
+		Scan(&drifts).Error
+	return drifts, err
+}
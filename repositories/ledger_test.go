@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// The journal is checked before anything is written, so these cases need no
+// database.
+func TestPostJournalRejectsInvalidEntries(t *testing.T) {
+	var (
+		walletID = uuid.New()
+		btc      = uuid.New()
+	)
+	tests := []struct {
+		name    string
+		entries []database.LedgerEntry
+		want    error
+	}{
+		{
+			name: "sub-kobo naira debit",
+			entries: []database.LedgerEntry{
+				debit(common.LedgerAccountBlockradarFloat, uuid.Nil, nairaAssetID, decimal.RequireFromString("100.005")),
+				credit(common.LedgerAccountUserWallet, walletID, nairaAssetID, decimal.RequireFromString("100.005")),
+			},
+			want: ErrSubKoboAmount,
+		},
+		{
+			name: "sub-kobo naira fee",
+			entries: []database.LedgerEntry{
+				debit(common.LedgerAccountBlockradarFloat, uuid.Nil, nairaAssetID, decimal.RequireFromString("100")),
+				credit(common.LedgerAccountUserWallet, walletID, nairaAssetID, decimal.RequireFromString("99.999")),
+				credit(common.LedgerAccountFees, uuid.Nil, nairaAssetID, decimal.RequireFromString("0.001")),
+			},
+			want: ErrSubKoboAmount,
+		},
+		{
+			name: "crypto may be finer than a kobo but must balance",
+			entries: []database.LedgerEntry{
+				debit(common.LedgerAccountBlockradarFloat, uuid.Nil, btc, decimal.RequireFromString("0.12345678")),
+				credit(common.LedgerAccountUserWallet, walletID, btc, decimal.RequireFromString("0.12345677")),
+			},
+			want: ErrUnbalancedJournal,
+		},
+		{
+			name: "whole kobo that does not balance",
+			entries: []database.LedgerEntry{
+				debit(common.LedgerAccountUserWallet, walletID, nairaAssetID, decimal.RequireFromString("50.01")),
+				credit(common.LedgerAccountWithdrawalHold, walletID, nairaAssetID, decimal.RequireFromString("50")),
+			},
+			want: ErrUnbalancedJournal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := postJournal(nil, uuid.New(), tt.entries...)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("postJournal() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
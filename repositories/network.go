@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type NetworkRepository struct {
+	DB *gorm.DB
+}
+
+func NewNetworkRepository(db *gorm.DB) *NetworkRepository {
+	return &NetworkRepository{
+		DB: db,
+	}
+}
+
+func (r *NetworkRepository) CreateNetwork(network *database.Network) error {
+	return r.DB.Create(network).Error
+}
+
+func (r *NetworkRepository) UpdateNetwork(networkID uuid.UUID, updates map[string]interface{}) error {
+	updates["updated_at"] = time.Now()
+	return r.DB.Model(&database.Network{}).Where("id = ?", networkID).Updates(updates).Error
+}
+
+func (r *NetworkRepository) FindByID(id uuid.UUID) (*database.Network, error) {
+	var network database.Network
+	if err := r.DB.First(&network, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &network, nil
+}
+
+func (r *NetworkRepository) ListNetworks() ([]database.Network, error) {
+	var networks []database.Network
+	err := r.DB.Order("code ASC").Find(&networks).Error
+	return networks, err
+}
+
+func (r *NetworkRepository) ListActiveByProvider(provider string) ([]database.Network, error) {
+	var networks []database.Network
+	err := r.DB.Where("provider = ? AND is_active = ?", provider, true).Order("code ASC").Find(&networks).Error
+	return networks, err
+}
+
+// FindByAssetID returns the network an asset is deposited on.
+func (r *NetworkRepository) FindByAssetID(assetID uuid.UUID) (*database.Network, error) {
+	var network database.Network
+	err := r.DB.Joins("JOIN asset ON asset.network_id = network.id").
+		Where("asset.id = ?", assetID).
+		First(&network).Error
+	if err != nil {
+		return nil, err
+	}
+	return &network, nil
+}
@@ -0,0 +1,62 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type QuoteRepository struct {
+	DB *gorm.DB
+}
+
+func NewQuoteRepository(db *gorm.DB) *QuoteRepository {
+	return &QuoteRepository{
+		DB: db,
+	}
+}
+
+func (r *QuoteRepository) CreateQuote(quote *database.Quote) error {
+	return r.DB.Create(quote).Error
+}
+
+func (r *QuoteRepository) GetQuoteByID(id uuid.UUID) (*database.Quote, error) {
+	var quote database.Quote
+	err := r.DB.Where("id = ?", id).First(&quote).Error
+	if err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+// GetLatestQuoteForDeposit returns the newest quote issued for an address
+// before the deposit was seen, whether or not it has expired since.
+func (r *QuoteRepository) GetLatestQuoteForDeposit(address string, assetID uuid.UUID, at time.Time) (*database.Quote, error) {
+	var quote database.Quote
+	err := r.DB.
+		Where("address = ? AND asset_id = ? AND created_at <= ?", address, assetID, at).
+		Order("created_at DESC").
+		First(&quote).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &quote, nil
+}
+
+// ExpireActiveQuotes retires earlier quotes for an address so only the newest
+// one is shown as active.
+func (r *QuoteRepository) ExpireActiveQuotes(address string, assetID uuid.UUID) error {
+	return r.DB.Model(&database.Quote{}).
+		Where("address = ? AND asset_id = ? AND status = ?", address, assetID, common.QuoteStatusActive).
+		Updates(map[string]interface{}{
+			"status":     common.QuoteStatusExpired,
+			"updated_at": time.Now(),
+		}).Error
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/ShowBaba/kagewallet/database"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -18,9 +19,11 @@ func NewRateRepository(db *gorm.DB) *RateRepository {
 	}
 }
 
-func (r *RateRepository) AddNewRate(rate float64, source string) error {
+func (r *RateRepository) AddNewRate(assetID uuid.UUID, side string, rate decimal.Decimal, source string) error {
 	newRate := database.Rate{
 		ID:        uuid.New(),
+		AssetID:   assetID,
+		Side:      side,
 		Rate:      rate,
 		Source:    source,
 		CreatedAt: time.Now(),
@@ -29,39 +32,57 @@ func (r *RateRepository) AddNewRate(rate float64, source string) error {
 	return r.DB.Create(&newRate).Error
 }
 
-func (r *RateRepository) GetLatestRate() (*database.Rate, error) {
+func (r *RateRepository) GetLatestRate(assetID uuid.UUID, side string) (*database.Rate, error) {
 	var rate database.Rate
-	err := r.DB.Order("created_at DESC").First(&rate).Error
+	err := r.DB.Where("asset_id = ? AND side = ?", assetID, side).Order("created_at DESC").First(&rate).Error
 	if err != nil {
 		return nil, err
 	}
 	return &rate, nil
 }
 
-// func (r *RateRepository) GetLatestRatesForActiveAssets() ([]struct {
-// 	Symbol   string
-// 	Name     string
-// 	Rate     float64
-// 	Standard string
-// }, error) {
-// 	var results []struct {
-// 		Symbol   string
-// 		Name     string
-// 		Rate     float64
-// 		Standard string
-// 	}
-//
-// 	err := r.DB.Raw(`
-// 		SELECT a.symbol, a.name, a.standard, r.rate
-// 		FROM asset a
-// 		JOIN (
-// 			SELECT asset_id, MAX(created_at) AS max_date
-// 			FROM rate
-// 			GROUP BY asset_id
-// 		) latest ON a.id = latest.asset_id
-// 		JOIN rate r ON latest.asset_id = r.asset_id AND latest.max_date = r.created_at
-// 		WHERE a.is_active = true
-// 	`).Scan(&results).Error
-//
-// 	return results, err
-// }
+func (r *RateRepository) GetRateByID(id uuid.UUID) (*database.Rate, error) {
+	var rate database.Rate
+	err := r.DB.Where("id = ?", id).First(&rate).Error
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+func (r *RateRepository) GetLatestRatesForActiveAssets(side string) ([]database.AssetRate, error) {
+	var results []database.AssetRate
+
+	err := r.DB.Raw(`
+		SELECT a.id AS asset_id, a.symbol, a.name, a.standard, r.id AS rate_id, r.side, r.rate, r.source, r.created_at
+		FROM asset a
+		JOIN (
+			SELECT DISTINCT ON (asset_id) *
+			FROM rate
+			WHERE side = ?
+			ORDER BY asset_id, created_at DESC
+		) r ON r.asset_id = a.id
+		WHERE a.is_active = true
+		ORDER BY a.symbol, a.standard
+	`, side).Scan(&results).Error
+
+	return results, err
+}
+
+func (r *RateRepository) GetLatestRatesBySource(source, side string) ([]database.AssetRate, error) {
+	var results []database.AssetRate
+
+	err := r.DB.Raw(`
+		SELECT a.id AS asset_id, a.symbol, a.name, a.standard, r.id AS rate_id, r.side, r.rate, r.source, r.created_at
+		FROM asset a
+		JOIN (
+			SELECT DISTINCT ON (asset_id) *
+			FROM rate
+			WHERE side = ? AND source = ?
+			ORDER BY asset_id, created_at DESC
+		) r ON r.asset_id = a.id
+		WHERE a.is_active = true
+	`, side, source).Scan(&results).Error
+
+	return results, err
+}
@@ -0,0 +1,34 @@
+package repositories
+
+import (
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SecurityEventRepository struct {
+	DB *gorm.DB
+}
+
+func NewSecurityEventRepository(db *gorm.DB) *SecurityEventRepository {
+	return &SecurityEventRepository{
+		DB: db,
+	}
+}
+
+func (r *SecurityEventRepository) CreateEvent(event *database.SecurityEvent) error {
+	return r.DB.Create(event).Error
+}
+
+func (r *SecurityEventRepository) ListEvents(userID uuid.UUID, eventType string, limit, offset int) ([]database.SecurityEvent, error) {
+	var events []database.SecurityEvent
+	query := r.DB.Order("created_at DESC").Limit(limit).Offset(offset)
+	if userID != uuid.Nil {
+		query = query.Where("user_id = ?", userID)
+	}
+	if eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	err := query.Find(&events).Error
+	return events, err
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 
 	"github.com/ShowBaba/kagewallet/database"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -59,9 +61,35 @@ func (r *TransactionRepository) GetTransactionByID(transactionID string) (*datab
 }
 
 func (r *TransactionRepository) UpdateTransactionStatus(transactionID, status string) error {
-	return r.DB.Model(&database.Transaction{}).
-		Where("id = ?", transactionID).
-		Update("status", status).Error
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&database.Transaction{}).
+			Where("id = ?", transactionID).
+			Update("status", status).Error; err != nil {
+			return err
+		}
+		return recordStatus(tx, uuid.MustParse(transactionID), status)
+	})
+}
+
+// GetTransactionEvents returns the statuses a transaction moved through,
+// oldest first.
+func (r *TransactionRepository) GetTransactionEvents(transactionID uuid.UUID) ([]database.TransactionEvent, error) {
+	var events []database.TransactionEvent
+	err := r.DB.Where("transaction_id = ?", transactionID).Order("created_at").Find(&events).Error
+	if err != nil {
+		return nil, fmt.Errorf("error fetching transaction events: %v", err)
+	}
+	return events, nil
+}
+
+// recordStatus adds a status to a transaction's timeline inside the caller's
+// database transaction. Every path that creates a transaction or changes its
+// status calls it.
+func recordStatus(tx *gorm.DB, transactionID uuid.UUID, status string) error {
+	if err := tx.Create(&database.TransactionEvent{TransactionID: transactionID, Status: status}).Error; err != nil {
+		return fmt.Errorf("error recording transaction status: %v", err)
+	}
+	return nil
 }
 
 func (r *TransactionRepository) DeleteTransaction(transactionID string) error {
@@ -109,11 +137,11 @@ func (r *TransactionRepository) GetTotalTransactionCountByUser(userID string) (i
 	return count, err
 }
 
-func (r *TransactionRepository) GetTotalAmountByUser(userID string) (float64, error) {
-	var totalAmount float64
+func (r *TransactionRepository) GetTotalAmountByUser(userID string) (decimal.Decimal, error) {
+	var totalAmount decimal.Decimal
 	err := r.DB.Model(&database.Transaction{}).
 		Where("user_id = ?", userID).
-		Select("SUM(amount)").
+		Select("COALESCE(SUM(amount), 0)").
 		Scan(&totalAmount).Error
 	return totalAmount, err
 }
@@ -49,6 +49,41 @@ func (r *UserRepository) UpdatePassword(userID string, hashedPassword string) er
 	}).Error
 }
 
+// ResetPassword replaces the password hash and records when it happened so
+// withdrawals can be held back for a while afterwards.
+func (r *UserRepository) ResetPassword(userID string, hashedPassword string, at time.Time) error {
+	return r.DB.Model(&database.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"password_hash":     hashedPassword,
+		"password_reset_at": at,
+		"updated_at":        time.Now(),
+	}).Error
+}
+
+// Freeze marks an account frozen. It reports false when the account was
+// already frozen.
+func (r *UserRepository) Freeze(userID uuid.UUID, reason, by string, at time.Time) (bool, error) {
+	result := r.DB.Model(&database.User{}).Where("id = ? AND is_frozen = ?", userID, false).Updates(map[string]interface{}{
+		"is_frozen":     true,
+		"frozen_at":     at,
+		"frozen_reason": reason,
+		"frozen_by":     by,
+		"updated_at":    time.Now(),
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Unfreeze lifts a freeze. It reports false when the account was not frozen.
+func (r *UserRepository) Unfreeze(userID uuid.UUID) (bool, error) {
+	result := r.DB.Model(&database.User{}).Where("id = ? AND is_frozen = ?", userID, true).Updates(map[string]interface{}{
+		"is_frozen":     false,
+		"frozen_at":     nil,
+		"frozen_reason": "",
+		"frozen_by":     "",
+		"updated_at":    time.Now(),
+	})
+	return result.RowsAffected > 0, result.Error
+}
+
 func (r *UserRepository) HasSetPassword(userID uuid.UUID) (bool, error) {
 	var user database.User
 
@@ -3,11 +3,14 @@ package repositories
 import (
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/ShowBaba/kagewallet/common"
 	"github.com/ShowBaba/kagewallet/database"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type WalletRepository struct {
@@ -24,6 +27,30 @@ func (r *WalletRepository) CreateWallet(wallet *database.Wallet) error {
 	return r.DB.Create(wallet).Error
 }
 
+// findOrCreateWallet returns the user's wallet for an asset, opening an empty
+// one the first time the user receives that asset. It must be called inside a
+// database transaction.
+func findOrCreateWallet(tx *gorm.DB, userID, assetID uuid.UUID) (*database.Wallet, error) {
+	var wallet database.Wallet
+	err := tx.Where("user_id = ? AND asset_id = ?", userID, assetID).First(&wallet).Error
+	if err == nil {
+		return &wallet, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("error checking wallet existence: %v", err)
+	}
+
+	wallet = database.Wallet{
+		UserID:  userID,
+		AssetID: assetID,
+		Balance: decimal.Zero,
+	}
+	if err := tx.Create(&wallet).Error; err != nil {
+		return nil, fmt.Errorf("error creating wallet: %v", err)
+	}
+	return &wallet, nil
+}
+
 func (r *WalletRepository) GetWalletByUserAndAsset(userID, assetID uuid.UUID) (*database.Wallet, error) {
 	var wallet database.Wallet
 	err := r.DB.Where("user_id = ? AND asset_id = ?", userID, assetID).First(&wallet).Error
@@ -33,18 +60,6 @@ func (r *WalletRepository) GetWalletByUserAndAsset(userID, assetID uuid.UUID) (*
 	return &wallet, nil
 }
 
-func (r *WalletRepository) UpdateWalletBalance(userID uuid.UUID, amount decimal.Decimal) error {
-	return r.DB.Model(&database.Wallet{}).
-		Where("user_id = ?", userID).
-		Update("balance", gorm.Expr("balance + ?", amount)).Error
-}
-
-func (r *WalletRepository) DeductWalletBalance(userID, assetID uuid.UUID, amount float64) error {
-	return r.DB.Model(&database.Wallet{}).
-		Where("user_id = ? AND asset_id = ? AND balance >= ?", userID, assetID, amount).
-		Update("balance", gorm.Expr("balance - ?", amount)).Error
-}
-
 func (r *WalletRepository) GetWalletsByUser(userID uuid.UUID) (*database.WalletWithDetails, error) {
 	var wallet database.WalletWithDetails
 	err := r.DB.
@@ -52,7 +67,7 @@ func (r *WalletRepository) GetWalletsByUser(userID uuid.UUID) (*database.WalletW
 		Select("wallet.*, telegram.username as user_name, \"user\".email as user_email").
 		Joins("JOIN \"user\" ON \"user\".id = wallet.user_id").
 		Joins("JOIN telegram ON telegram.user_id = \"user\".id").
-		Where("wallet.user_id = ?", userID).
+		Where("wallet.user_id = ? AND wallet.asset_id = ?", userID, common.NairaAssetID).
 		Scan(&wallet).Error
 	if err != nil {
 		return nil, err
@@ -60,8 +75,17 @@ func (r *WalletRepository) GetWalletsByUser(userID uuid.UUID) (*database.WalletW
 	return &wallet, nil
 }
 
-func (r *WalletRepository) DeleteWallet(userID, assetID uuid.UUID) error {
-	return r.DB.Where("user_id = ? AND asset_id = ?", userID, assetID).Delete(&database.Wallet{}).Error
+// GetAssetBalances lists the user's wallets that hold a balance, Naira first.
+func (r *WalletRepository) GetAssetBalances(userID uuid.UUID) ([]database.AssetBalance, error) {
+	var balances []database.AssetBalance
+	err := r.DB.
+		Table("wallet").
+		Select("wallet.id AS wallet_id, wallet.asset_id, asset.symbol, asset.name, asset.standard, asset.decimals, wallet.balance").
+		Joins("JOIN asset ON asset.id = wallet.asset_id").
+		Where("wallet.user_id = ? AND wallet.balance > 0", userID).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "wallet.asset_id = ? DESC, asset.symbol, asset.standard", Vars: []interface{}{common.NairaAssetID}, WithoutParentheses: true}}).
+		Scan(&balances).Error
+	return balances, err
 }
 
 func (r *WalletRepository) GetWalletByID(walletID uuid.UUID) (*database.Wallet, error) {
@@ -73,37 +97,82 @@ func (r *WalletRepository) GetWalletByID(walletID uuid.UUID) (*database.Wallet,
 	return &wallet, nil
 }
 
-func (r *WalletRepository) HandleTransactionAndUpdateBalance(userID string, transaction *database.Transaction, amount float64) error {
-	return r.DB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Create(transaction).Error; err != nil {
-			return fmt.Errorf("error creating transaction: %v", err)
+// RecordDeposit creates or advances a deposit transaction keyed by its chain
+// hash and credits the user's wallet for creditAssetID the first time it
+// reaches completed, with any fees going to the fees account and recorded
+// from transaction.Fees. The amount and fee are in creditAssetID: Naira when
+// the deposit is sold, the deposited asset when it is kept. Completed and
+// below_minimum deposits are final. An advisory lock on the hash serialises
+// the webhook and the reconciler.
+func (r *WalletRepository) RecordDeposit(transaction *database.Transaction, creditAssetID uuid.UUID, amount, fee decimal.Decimal) (string, error) {
+	var outcome string
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", transaction.Hash).Error; err != nil {
+			return fmt.Errorf("error locking deposit hash: %v", err)
 		}
 
-		var wallet database.Wallet
-		err := tx.Model(&database.Wallet{}).
-			Where("user_id = ?", userID).
-			First(&wallet).Error
-
-		if err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				newWallet := &database.Wallet{
-					UserID:  uuid.MustParse(userID),
-					Balance: decimal.NewFromFloat(amount),
-				}
-				if createErr := tx.Create(newWallet).Error; createErr != nil {
-					return fmt.Errorf("error creating wallet: %v", createErr)
-				}
-			} else {
-				return fmt.Errorf("error checking wallet existence: %v", err)
+		var existing database.Transaction
+		err := tx.Where("hash = ?", transaction.Hash).First(&existing).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := tx.Create(transaction).Error; err != nil {
+				return fmt.Errorf("error creating transaction: %v", err)
+			}
+			if err := recordStatus(tx, transaction.ID, transaction.Status); err != nil {
+				return err
+			}
+			outcome = common.DepositOutcomeCreated
+		case err != nil:
+			return fmt.Errorf("error checking existing transaction: %v", err)
+		case existing.Status == "completed" || existing.Status == common.DepositStatusBelowMinimum ||
+			(existing.Status == transaction.Status && existing.Confirmations >= transaction.Confirmations):
+			transaction.ID = existing.ID
+			outcome = common.DepositOutcomeSkipped
+			return nil
+		default:
+			updates := map[string]interface{}{
+				"status":        transaction.Status,
+				"confirmations": transaction.Confirmations,
+				"updated_at":    time.Now(),
 			}
-		} else {
-			if updateErr := tx.Model(&database.Wallet{}).
-				Where("user_id = ?", userID).
-				Update("balance", gorm.Expr("balance + ?", amount)).Error; updateErr != nil {
-				return fmt.Errorf("error updating wallet balance: %v", updateErr)
+			if transaction.Status == "completed" {
+				updates["rate_id"] = transaction.RateID
 			}
+			if err := tx.Model(&database.Transaction{}).Where("id = ?", existing.ID).Updates(updates).Error; err != nil {
+				return fmt.Errorf("error updating transaction status: %v", err)
+			}
+			if existing.Status != transaction.Status {
+				if err := recordStatus(tx, existing.ID, transaction.Status); err != nil {
+					return err
+				}
+			}
+			transaction.ID = existing.ID
+			outcome = common.DepositOutcomeUpdated
+		}
+
+		if transaction.Status != "completed" || !amount.IsPositive() {
+			return nil
+		}
+
+		if err := recordFees(tx, transaction.ID, transaction.Fees); err != nil {
+			return err
+		}
+
+		wallet, err := findOrCreateWallet(tx, transaction.UserID, creditAssetID)
+		if err != nil {
+			return err
 		}
 
-		return nil
+		var (
+			entries = []database.LedgerEntry{
+				debit(common.LedgerAccountBlockradarFloat, uuid.Nil, creditAssetID, amount.Add(fee)),
+				credit(common.LedgerAccountUserWallet, wallet.ID, creditAssetID, amount),
+			}
+		)
+		if fee.IsPositive() {
+			entries = append(entries, credit(common.LedgerAccountFees, uuid.Nil, creditAssetID, fee))
+		}
+		return postJournal(tx, transaction.ID, entries...)
 	})
+	return outcome, err
 }
@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrWebhookEventBusy = errors.New("webhook event is being processed")
+
+type WebhookEventRepository struct {
+	DB *gorm.DB
+}
+
+func NewWebhookEventRepository(db *gorm.DB) *WebhookEventRepository {
+	return &WebhookEventRepository{
+		DB: db,
+	}
+}
+
+// RecordEvent stores a raw webhook once per provider, event id and event type.
+// It reports false when the event was already in the inbox.
+func (r *WebhookEventRepository) RecordEvent(event *database.WebhookEvent) (bool, error) {
+	result := r.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "provider"}, {Name: "event_id"}, {Name: "event_type"}},
+		DoNothing: true,
+	}).Create(event)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *WebhookEventRepository) GetEventByID(id uuid.UUID) (*database.WebhookEvent, error) {
+	var event database.WebhookEvent
+	err := r.DB.Where("id = ?", id).First(&event).Error
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *WebhookEventRepository) ListEvents(provider, status string, limit, offset int) ([]database.WebhookEvent, error) {
+	var events []database.WebhookEvent
+	query := r.DB.Order("created_at DESC").Limit(limit).Offset(offset)
+	if provider != "" {
+		query = query.Where("provider = ?", provider)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Find(&events).Error
+	return events, err
+}
+
+// ClaimEvents marks up to limit due events as processing and returns them.
+// SKIP LOCKED lets several workers claim from the inbox without overlap, and
+// events stuck in processing longer than staleAfter are picked up again.
+func (r *WebhookEventRepository) ClaimEvents(limit int, staleAfter time.Duration) ([]database.WebhookEvent, error) {
+	var events []database.WebhookEvent
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("(status IN ? AND next_attempt_at <= ?) OR (status = ? AND updated_at < ?)",
+				[]string{common.WebhookEventStatusReceived, common.WebhookEventStatusFailed}, now,
+				common.WebhookEventStatusProcessing, now.Add(-staleAfter)).
+			Order("created_at ASC").
+			Limit(limit).
+			Find(&events).Error
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(events))
+		for i := range events {
+			ids[i] = events[i].ID
+			events[i].Attempts++
+		}
+		return tx.Model(&database.WebhookEvent{}).
+			Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"status":     common.WebhookEventStatusProcessing,
+				"attempts":   gorm.Expr("attempts + 1"),
+				"updated_at": now,
+			}).Error
+	})
+	return events, err
+}
+
+func (r *WebhookEventRepository) MarkProcessed(id uuid.UUID) error {
+	now := time.Now()
+	return r.DB.Model(&database.WebhookEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":       common.WebhookEventStatusProcessed,
+			"last_error":   "",
+			"processed_at": now,
+			"updated_at":   now,
+		}).Error
+}
+
+func (r *WebhookEventRepository) MarkFailed(id uuid.UUID, status, lastError string, nextAttemptAt time.Time) error {
+	return r.DB.Model(&database.WebhookEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":          status,
+			"last_error":      lastError,
+			"next_attempt_at": nextAttemptAt,
+			"updated_at":      time.Now(),
+		}).Error
+}
+
+// ReplayEvent queues an event for another run through the worker. Handlers
+// are idempotent, so replaying a processed event has no further effect.
+func (r *WebhookEventRepository) ReplayEvent(id uuid.UUID) error {
+	result := r.DB.Model(&database.WebhookEvent{}).
+		Where("id = ? AND status <> ?", id, common.WebhookEventStatusProcessing).
+		Updates(map[string]interface{}{
+			"status":          common.WebhookEventStatusReceived,
+			"next_attempt_at": time.Now(),
+			"updated_at":      time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrWebhookEventBusy
+	}
+	return nil
+}
@@ -2,13 +2,19 @@ package repositories
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
+	"github.com/ShowBaba/kagewallet/common"
 	"github.com/ShowBaba/kagewallet/database"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var ErrInsufficientBalance = errors.New("insufficient wallet balance")
+
 type WithdrawalRepository struct {
 	DB *gorm.DB
 }
@@ -36,7 +42,7 @@ func (r *WithdrawalRepository) GetWithdrawalByID(id uuid.UUID) (*database.Withdr
 
 func (r *WithdrawalRepository) GetWithdrawalByTransactionID(id uuid.UUID) (*database.Withdrawal, error) {
 	var withdrawal database.Withdrawal
-	err := r.DB.Where("id = ?", id).First(&withdrawal).Error
+	err := r.DB.Where("transaction_id = ?", id).First(&withdrawal).Error
 	if err != nil {
 		return nil, err
 	}
@@ -60,32 +66,307 @@ func (r *WithdrawalRepository) UpdateWithdrawalStatus(withdrawalID uuid.UUID, st
 	return result.Error
 }
 
-func (r *WithdrawalRepository) GetPendingWithdrawals() ([]database.Withdrawal, error) {
+// GetPendingWithdrawals returns withdrawals that have been pending since
+// before olderThan. It goes by updated_at so an approved withdrawal counts
+// from its approval rather than from when it was requested.
+func (r *WithdrawalRepository) GetPendingWithdrawals(olderThan time.Time) ([]database.Withdrawal, error) {
 	var withdrawals []database.Withdrawal
-	err := r.DB.Where("status = ?", "pending").
-		Order("created_at ASC").
+	err := r.DB.Where("status = ? AND updated_at < ?", "pending", olderThan).
+		Order("updated_at ASC").
 		Find(&withdrawals).Error
 	return withdrawals, err
 }
 
-func (r *WithdrawalRepository) DeleteWithdrawal(id uuid.UUID) error {
-	result := r.DB.Where("id = ?", id).Delete(&database.Withdrawal{})
-	if result.RowsAffected == 0 {
-		return errors.New("withdrawal not found")
+func (r *WithdrawalRepository) ListWithdrawalsByStatus(status string, limit, offset int) ([]database.Withdrawal, error) {
+	var withdrawals []database.Withdrawal
+	query := r.DB.Order("created_at ASC").Limit(limit).Offset(offset)
+	if status != "" {
+		query = query.Where("status = ?", status)
 	}
-	return result.Error
+	err := query.Find(&withdrawals).Error
+	return withdrawals, err
 }
 
-func (r *WithdrawalRepository) CountUserWithdrawals(userID uuid.UUID) (int64, error) {
-	var count int64
-	err := r.DB.Model(&database.Withdrawal{}).
-		Where("user_id = ?", userID).
-		Count(&count).Error
-	return count, err
+func (r *WithdrawalRepository) CreateReview(review *database.WithdrawalReview) error {
+	return r.DB.Create(review).Error
+}
+
+func (r *WithdrawalRepository) ListReviews(withdrawalID uuid.UUID) ([]database.WithdrawalReview, error) {
+	var reviews []database.WithdrawalReview
+	err := r.DB.Where("withdrawal_id = ?", withdrawalID).Order("created_at ASC").Find(&reviews).Error
+	return reviews, err
 }
 
-func (r *WithdrawalRepository) CreateTransactionAndWithdrawal(wallet *database.WalletWithDetails, transaction *database.Transaction, withdrawal *database.Withdrawal) error {
+// ReleaseForTransfer moves a withdrawal awaiting approval back to pending so
+// it can be sent. It reports false when another admin already decided it.
+func (r *WithdrawalRepository) ReleaseForTransfer(withdrawalID uuid.UUID) (bool, error) {
+	released := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&database.Withdrawal{}).
+			Where("id = ? AND status = ?", withdrawalID, common.WithdrawalStatusAwaitingApproval).
+			Updates(map[string]interface{}{"status": "pending", "updated_at": time.Now()})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		released = true
+
+		var withdrawal database.Withdrawal
+		if err := tx.Where("id = ?", withdrawalID).First(&withdrawal).Error; err != nil {
+			return fmt.Errorf("error fetching withdrawal: %v", err)
+		}
+		return recordStatus(tx, withdrawal.TransactionID, common.TransactionEventApproved)
+	})
+	return released, err
+}
+
+// AssignPayoutProvider records the provider a withdrawal is about to be sent
+// through, on the withdrawal and as its transaction's source.
+func (r *WithdrawalRepository) AssignPayoutProvider(withdrawalID, transactionID uuid.UUID, provider string) error {
 	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&database.Withdrawal{}).
+			Where("id = ?", withdrawalID).
+			Updates(map[string]interface{}{"provider": provider, "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&database.Transaction{}).
+			Where("id = ?", transactionID).
+			Updates(map[string]interface{}{"source": provider, "updated_at": time.Now()}).Error
+	})
+}
+
+// payoutFloatAccount is the ledger account of the provider that paid a
+// withdrawal. Withdrawals from before providers were recorded went through
+// Monnify.
+func payoutFloatAccount(provider string) string {
+	if provider == common.PayoutProviderPaystack {
+		return common.LedgerAccountPaystackFloat
+	}
+	return common.LedgerAccountMonnifyFloat
+}
+
+// RejectWithdrawal fails a withdrawal awaiting approval and releases its hold
+// back to the wallet. It reports false when another admin already decided it.
+func (r *WithdrawalRepository) RejectWithdrawal(withdrawalID uuid.UUID) (bool, error) {
+	settled := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&database.Withdrawal{}).
+			Where("id = ? AND status = ?", withdrawalID, common.WithdrawalStatusAwaitingApproval).
+			Updates(map[string]interface{}{"status": "failed", "updated_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		settled = true
+
+		var withdrawal database.Withdrawal
+		if err := tx.Where("id = ?", withdrawalID).First(&withdrawal).Error; err != nil {
+			return fmt.Errorf("error fetching withdrawal: %v", err)
+		}
+		if err := tx.Model(&database.Transaction{}).
+			Where("id = ?", withdrawal.TransactionID).
+			Updates(map[string]interface{}{"status": "failed", "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+		if err := recordStatus(tx, withdrawal.TransactionID, common.TransactionEventRejected); err != nil {
+			return err
+		}
+
+		var wallet database.Wallet
+		if err := tx.Where("user_id = ? AND asset_id = ?", withdrawal.UserID, common.NairaAssetID).First(&wallet).Error; err != nil {
+			return fmt.Errorf("error fetching wallet: %v", err)
+		}
+		held, holdWalletID, err := heldAmount(tx, withdrawal.TransactionID)
+		if err != nil {
+			return err
+		}
+		if !held.IsPositive() {
+			return nil
+		}
+		assetID := uuid.MustParse(common.NairaAssetID)
+		return postJournal(tx, withdrawal.TransactionID,
+			debit(common.LedgerAccountWithdrawalHold, holdWalletID, assetID, held),
+			credit(common.LedgerAccountUserWallet, wallet.ID, assetID, held),
+		)
+	})
+	return settled, err
+}
+
+// CompleteWithdrawal moves a pending withdrawal and its transaction to
+// completed and turns its hold into the final debit. It reports false when
+// another path already settled it.
+func (r *WithdrawalRepository) CompleteWithdrawal(transactionID uuid.UUID) (bool, error) {
+	settled := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&database.Transaction{}).
+			Where("id = ? AND status = ?", transactionID, "pending").
+			Updates(map[string]interface{}{"status": "completed", "updated_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		settled = true
+
+		var withdrawal database.Withdrawal
+		if err := tx.Where("transaction_id = ?", transactionID).First(&withdrawal).Error; err != nil {
+			return fmt.Errorf("error fetching withdrawal: %v", err)
+		}
+		if err := tx.Model(&database.Withdrawal{}).
+			Where("id = ?", withdrawal.ID).
+			Updates(map[string]interface{}{"status": "completed", "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+		if err := recordStatus(tx, transactionID, "completed"); err != nil {
+			return err
+		}
+
+		held, holdWalletID, err := heldAmount(tx, transactionID)
+		if err != nil {
+			return err
+		}
+		// Withdrawals made before holds existed were debited up front.
+		if !held.IsPositive() {
+			return nil
+		}
+
+		var (
+			assetID = uuid.MustParse(common.NairaAssetID)
+			fee     = withdrawal.Fee
+			entries = []database.LedgerEntry{
+				debit(common.LedgerAccountWithdrawalHold, holdWalletID, assetID, held),
+				credit(payoutFloatAccount(withdrawal.Provider), uuid.Nil, assetID, withdrawal.Amount),
+			}
+		)
+		if fee.IsPositive() {
+			entries = append(entries, credit(common.LedgerAccountFees, uuid.Nil, assetID, fee))
+		}
+		return postJournal(tx, transactionID, entries...)
+	})
+	return settled, err
+}
+
+// FailWithdrawal marks a withdrawal failed and gives the amount and fee back
+// to the user's wallet in the same database transaction. A pending withdrawal
+// has its hold released; a completed one that the provider reversed is refunded
+// from the float. The conditional status update guarantees this happens at
+// most once, whichever of the webhook or the reconciler gets there first.
+func (r *WithdrawalRepository) FailWithdrawal(transactionID uuid.UUID, fromStatuses ...string) (bool, error) {
+	settled := false
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&database.Transaction{}).
+			Where("id = ? AND status IN ?", transactionID, fromStatuses).
+			Updates(map[string]interface{}{"status": "failed", "updated_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		settled = true
+		if err := recordStatus(tx, transactionID, "failed"); err != nil {
+			return err
+		}
+
+		var withdrawal database.Withdrawal
+		if err := tx.Where("transaction_id = ?", transactionID).First(&withdrawal).Error; err != nil {
+			return fmt.Errorf("error fetching withdrawal: %v", err)
+		}
+		if err := tx.Model(&database.Withdrawal{}).
+			Where("id = ?", withdrawal.ID).
+			Updates(map[string]interface{}{"status": "failed", "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+
+		var wallet database.Wallet
+		if err := tx.Where("user_id = ? AND asset_id = ?", withdrawal.UserID, common.NairaAssetID).First(&wallet).Error; err != nil {
+			return fmt.Errorf("error fetching wallet: %v", err)
+		}
+
+		held, holdWalletID, err := heldAmount(tx, transactionID)
+		if err != nil {
+			return err
+		}
+
+		assetID := uuid.MustParse(common.NairaAssetID)
+		if held.IsPositive() {
+			return postJournal(tx, transactionID,
+				debit(common.LedgerAccountWithdrawalHold, holdWalletID, assetID, held),
+				credit(common.LedgerAccountUserWallet, wallet.ID, assetID, held),
+			)
+		}
+
+		var (
+			fee     = withdrawal.Fee
+			entries = []database.LedgerEntry{
+				debit(payoutFloatAccount(withdrawal.Provider), uuid.Nil, assetID, withdrawal.Amount),
+				credit(common.LedgerAccountUserWallet, wallet.ID, assetID, withdrawal.Amount.Add(fee)),
+			}
+		)
+		if fee.IsPositive() {
+			entries = append(entries, debit(common.LedgerAccountFees, uuid.Nil, assetID, fee))
+		}
+		return postJournal(tx, transactionID, entries...)
+	})
+	return settled, err
+}
+
+// heldAmount returns what is still reserved against a transaction and the
+// wallet it is reserved from.
+func heldAmount(tx *gorm.DB, transactionID uuid.UUID) (decimal.Decimal, uuid.UUID, error) {
+	var row struct {
+		WalletID uuid.UUID
+		Total    decimal.Decimal
+	}
+	err := tx.Model(&database.LedgerEntry{}).
+		Select("wallet_id, COALESCE(SUM(CASE WHEN side = 'credit' THEN amount ELSE -amount END), 0) AS total").
+		Where("transaction_id = ? AND account = ?", transactionID, common.LedgerAccountWithdrawalHold).
+		Group("wallet_id").
+		Scan(&row).Error
+	if err != nil {
+		return decimal.Zero, uuid.Nil, fmt.Errorf("error fetching withdrawal hold: %v", err)
+	}
+	return row.Total, row.WalletID, nil
+}
+
+// HoldFundsForWithdrawal locks the user's wallet row, checks the spendable
+// balance covers the amount and fee, and moves them into a hold alongside the
+// pending transaction and withdrawal. Concurrent withdrawals queue on the row
+// lock, so together they can never reserve more than the wallet holds or slip
+// past the user's limits. A withdrawal over a limit is still held but saved as
+// awaiting approval, with the limit it broke as its review reason. One that
+// arrives with a review reason already set is held for approval as it is.
+func (r *WithdrawalRepository) HoldFundsForWithdrawal(transaction *database.Transaction, withdrawal *database.Withdrawal, limit *database.WithdrawalLimit) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		var wallet database.Wallet
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND asset_id = ?", withdrawal.UserID, common.NairaAssetID).
+			First(&wallet).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInsufficientBalance
+			}
+			return fmt.Errorf("error locking wallet: %v", err)
+		}
+
+		total := withdrawal.Amount.Add(withdrawal.Fee)
+		if wallet.Balance.LessThan(total) {
+			return ErrInsufficientBalance
+		}
+
+		if withdrawal.ReviewReason == "" {
+			reason, err := reviewReason(tx, withdrawal.UserID, total, limit)
+			if err != nil {
+				return err
+			}
+			if reason != "" {
+				withdrawal.Status = common.WithdrawalStatusAwaitingApproval
+				withdrawal.ReviewReason = reason
+			}
+		}
+
 		transaction.ID = uuid.New()
 		transaction.CreatedAt = time.Now()
 		transaction.UpdatedAt = time.Now()
@@ -102,22 +383,123 @@ func (r *WithdrawalRepository) CreateTransactionAndWithdrawal(wallet *database.W
 		if err := tx.Create(withdrawal).Error; err != nil {
 			return err
 		}
+		if err := recordFees(tx, transaction.ID, transaction.Fees); err != nil {
+			return err
+		}
+		if err := recordStatus(tx, transaction.ID, withdrawal.Status); err != nil {
+			return err
+		}
 
-		wallet.UpdatedAt = time.Now()
-		result := tx.Model(&database.Wallet{}).
-			Where("id = ?", wallet.ID).
-			Update("balance", wallet.Balance)
+		assetID := uuid.MustParse(common.NairaAssetID)
+		return postJournal(tx, transaction.ID,
+			debit(common.LedgerAccountUserWallet, wallet.ID, assetID, total),
+			credit(common.LedgerAccountWithdrawalHold, wallet.ID, assetID, total),
+		)
+	})
+}
 
-		if result.Error != nil {
-			return result.Error
+// reviewReason returns the first of the limits a withdrawal of total would
+// break, or an empty string when it stays within all of them. Daily and weekly
+// limits cover a rolling 24 hours and 7 days.
+func reviewReason(tx *gorm.DB, userID uuid.UUID, total decimal.Decimal, limit *database.WithdrawalLimit) (string, error) {
+	if limit == nil {
+		return "", nil
+	}
+	if limit.PerTransaction.IsPositive() && total.GreaterThan(limit.PerTransaction) {
+		return common.WithdrawalReviewPerTransaction, nil
+	}
+
+	now := time.Now()
+	windows := []struct {
+		since  time.Time
+		limit  decimal.Decimal
+		reason string
+	}{
+		{now.Add(-24 * time.Hour), limit.Daily, common.WithdrawalReviewDaily},
+		{now.Add(-7 * 24 * time.Hour), limit.Weekly, common.WithdrawalReviewWeekly},
+	}
+	for _, window := range windows {
+		if !window.limit.IsPositive() {
+			continue
+		}
+		usage, err := withdrawalUsage(tx, userID, window.since)
+		if err != nil {
+			return "", err
+		}
+		if usage.Total.Add(total).GreaterThan(window.limit) {
+			return window.reason, nil
 		}
+	}
 
-		if result.RowsAffected == 0 {
-			return errors.New("wallet update failed: no rows affected")
+	if limit.HourlyCount > 0 {
+		usage, err := withdrawalUsage(tx, userID, now.Add(-time.Hour))
+		if err != nil {
+			return "", err
 		}
+		if usage.Count >= int64(limit.HourlyCount) {
+			return common.WithdrawalReviewHourlyCount, nil
+		}
+	}
+	return "", nil
+}
 
-		return nil
-	})
+// GetWithdrawalUsage sums the withdrawals a user made since the given time.
+// Failed withdrawals do not count; pending ones and those awaiting approval
+// do, since their funds are held.
+func (r *WithdrawalRepository) GetWithdrawalUsage(userID uuid.UUID, since time.Time) (database.WithdrawalUsage, error) {
+	return withdrawalUsage(r.DB, userID, since)
+}
+
+func withdrawalUsage(tx *gorm.DB, userID uuid.UUID, since time.Time) (database.WithdrawalUsage, error) {
+	var usage database.WithdrawalUsage
+	err := tx.Model(&database.Withdrawal{}).
+		Select("COALESCE(SUM(amount + fee), 0) AS total, COUNT(*) AS count").
+		Where("user_id = ? AND status IN ? AND created_at >= ?", userID,
+			[]string{"pending", "completed", common.WithdrawalStatusAwaitingApproval}, since).
+		Scan(&usage).Error
+	if err != nil {
+		return usage, fmt.Errorf("error fetching withdrawal usage: %v", err)
+	}
+	return usage, nil
+}
+
+// GetWithdrawalLimit returns the limits of a tier, or nil when the tier has
+// none configured.
+func (r *WithdrawalRepository) GetWithdrawalLimit(tier string) (*database.WithdrawalLimit, error) {
+	var limit database.WithdrawalLimit
+	err := r.DB.Where("tier = ?", tier).First(&limit).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+// GetLastCompletedWithdrawal returns the user's most recent paid-out
+// withdrawal, or nil when there is none, so its bank account can be offered
+// again.
+func (r *WithdrawalRepository) GetLastCompletedWithdrawal(userID uuid.UUID) (*database.Withdrawal, error) {
+	var withdrawal database.Withdrawal
+	err := r.DB.Where("user_id = ? AND status = ?", userID, "completed").
+		Order("created_at DESC").
+		First(&withdrawal).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &withdrawal, nil
+}
+
+func (r *WithdrawalRepository) HasCompletedWithdrawalTo(userID uuid.UUID, bankCode, accountNumber string) (bool, error) {
+	var count int64
+	err := r.DB.Model(&database.Withdrawal{}).
+		Where("user_id = ? AND bank_code = ? AND account_number = ? AND status = ?", userID, bankCode, accountNumber, "completed").
+		Count(&count).Error
+	return count > 0, err
 }
 
 func (r *WithdrawalRepository) GetWithdrawalsByAccountNumber(accountNumber string) ([]database.Withdrawal, error) {
@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// TestHoldFundsForWithdrawalConcurrently races more withdrawals than a wallet
+// can pay for and checks the row lock lets exactly the affordable ones
+// through, leaving the wallet at zero rather than below it.
+func TestHoldFundsForWithdrawalConcurrently(t *testing.T) {
+	db := testDB(t)
+	var (
+		repo       = NewWithdrawalRepository(db)
+		balance    = decimal.NewFromInt(1000)
+		amount     = decimal.NewFromInt(90)
+		fee        = decimal.NewFromInt(10)
+		affordable = 10
+		attempts   = 25
+	)
+	user, wallet := fundedUser(t, db, balance)
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+		refused   int
+		failures  []error
+	)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := repo.HoldFundsForWithdrawal(
+				&database.Transaction{
+					UserID:    user.ID,
+					AssetID:   nairaAssetID,
+					Type:      "withdrawal",
+					Amount:    amount,
+					Status:    "pending",
+					Reference: uuid.NewString(),
+				},
+				&database.Withdrawal{UserID: user.ID, Status: "pending", Amount: amount, Fee: fee},
+				nil,
+			)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				succeeded++
+			case errors.Is(err, ErrInsufficientBalance):
+				refused++
+			default:
+				failures = append(failures, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(failures) > 0 {
+		t.Fatalf("unexpected errors: %v", failures)
+	}
+	if succeeded != affordable || refused != attempts-affordable {
+		t.Fatalf("%d held and %d refused, want %d and %d", succeeded, refused, affordable, attempts-affordable)
+	}
+
+	var got database.Wallet
+	if err := db.Where("id = ?", wallet.ID).First(&got).Error; err != nil {
+		t.Fatalf("error fetching wallet: %v", err)
+	}
+	if !got.Balance.IsZero() {
+		t.Errorf("wallet balance = %s, want 0", got.Balance)
+	}
+
+	var held decimal.Decimal
+	err := db.Model(&database.LedgerEntry{}).
+		Select("COALESCE(SUM(CASE WHEN side = 'credit' THEN amount ELSE -amount END), 0)").
+		Where("wallet_id = ? AND account = ?", wallet.ID, common.LedgerAccountWithdrawalHold).
+		Scan(&held).Error
+	if err != nil {
+		t.Fatalf("error summing holds: %v", err)
+	}
+	if !held.Equal(balance) {
+		t.Errorf("held %s, want the whole balance of %s", held, balance)
+	}
+
+	var count int64
+	if err := db.Model(&database.Withdrawal{}).Where("user_id = ?", user.ID).Count(&count).Error; err != nil {
+		t.Fatalf("error counting withdrawals: %v", err)
+	}
+	if count != int64(affordable) {
+		t.Errorf("%d withdrawals saved, want %d", count, affordable)
+	}
+}
@@ -11,11 +11,18 @@ import (
 
 func RegisterAdminRoutes(router *mux.Router, db *gorm.DB) {
 	var (
-		rateRepo       = repositories.NewRateRepository(db)
-		assetRepo      = repositories.NewAssetRepository(db)
-		monnifyService = services.NewMonnifyService()
-		adminService   = services.NewAdminService(rateRepo, assetRepo, monnifyService)
-		adminHandler   = handlers.NewAdminHandler(adminService)
+		rateRepo          = repositories.NewRateRepository(db)
+		assetRepo         = repositories.NewAssetRepository(db)
+		userRepo          = repositories.NewUserRepository(db)
+		withdrawalRepo    = repositories.NewWithdrawalRepository(db)
+		walletRepo        = repositories.NewWalletRepository(db)
+		transactionRepo   = repositories.NewTransactionRepository(db)
+		monnifyService    = services.NewMonnifyService()
+		feeService        = services.NewFeeService(repositories.NewFeeRepository(db))
+		accountService    = services.NewAccountService(userRepo, repositories.NewSecurityEventRepository(db))
+		withdrawalService = services.NewWithdrawalService(mustPayoutRouter(monnifyService), withdrawalRepo, walletRepo, transactionRepo, userRepo, repositories.NewBeneficiaryRepository(db), feeService, accountService)
+		adminService      = services.NewAdminService(rateRepo, assetRepo, monnifyService, repositories.NewWebhookEventRepository(db), accountService, withdrawalService, services.NewCustodyServiceFromEnv(repositories.NewNetworkRepository(db)), feeService)
+		adminHandler      = handlers.NewAdminHandler(adminService)
 	)
 	apiRouter := router.PathPrefix("/api/admin").Subrouter()
 	apiRouter.HandleFunc("/create_asset", helpers.ValidateAdminToken(adminHandler.CreateAsset())).Methods("POST")
@@ -23,4 +30,21 @@ func RegisterAdminRoutes(router *mux.Router, db *gorm.DB) {
 	apiRouter.HandleFunc("/create_rate", helpers.ValidateAdminToken(adminHandler.CreateRate())).Methods("POST")
 	apiRouter.HandleFunc("/validate_monnify_otp", helpers.ValidateAdminToken(adminHandler.ValidateMonnifyTransferOTP())).Methods("POST")
 	apiRouter.HandleFunc("/get_assets", helpers.ValidateAdminToken(adminHandler.GetAssets())).Methods("GET")
+	apiRouter.HandleFunc("/networks", helpers.ValidateAdminToken(adminHandler.ListNetworks())).Methods("GET")
+	apiRouter.HandleFunc("/networks", helpers.ValidateAdminToken(adminHandler.CreateNetwork())).Methods("POST")
+	apiRouter.HandleFunc("/networks/{id}", helpers.ValidateAdminToken(adminHandler.UpdateNetwork())).Methods("PATCH")
+	apiRouter.HandleFunc("/networks/{id}/balances", helpers.ValidateAdminToken(adminHandler.GetNetworkBalances())).Methods("GET")
+	apiRouter.HandleFunc("/webhook_events", helpers.ValidateAdminToken(adminHandler.ListWebhookEvents())).Methods("GET")
+	apiRouter.HandleFunc("/webhook_events/{id}", helpers.ValidateAdminToken(adminHandler.GetWebhookEvent())).Methods("GET")
+	apiRouter.HandleFunc("/webhook_events/{id}/replay", helpers.ValidateAdminToken(adminHandler.ReplayWebhookEvent())).Methods("POST")
+	apiRouter.HandleFunc("/users/{id}/freeze", helpers.ValidateAdminToken(adminHandler.FreezeUser())).Methods("POST")
+	apiRouter.HandleFunc("/users/{id}/unfreeze", helpers.ValidateAdminToken(adminHandler.UnfreezeUser())).Methods("POST")
+	apiRouter.HandleFunc("/security_events", helpers.ValidateAdminToken(adminHandler.ListSecurityEvents())).Methods("GET")
+	apiRouter.HandleFunc("/withdrawals", helpers.ValidateAdminToken(adminHandler.ListWithdrawals())).Methods("GET")
+	apiRouter.HandleFunc("/withdrawals/{id}", helpers.ValidateAdminToken(adminHandler.GetWithdrawal())).Methods("GET")
+	apiRouter.HandleFunc("/withdrawals/{id}/approve", helpers.ValidateAdminToken(adminHandler.ApproveWithdrawal())).Methods("POST")
+	apiRouter.HandleFunc("/withdrawals/{id}/reject", helpers.ValidateAdminToken(adminHandler.RejectWithdrawal())).Methods("POST")
+	apiRouter.HandleFunc("/fee_schedules", helpers.ValidateAdminToken(adminHandler.ListFeeSchedules())).Methods("GET")
+	apiRouter.HandleFunc("/fee_schedules", helpers.ValidateAdminToken(adminHandler.CreateFeeSchedule())).Methods("POST")
+	apiRouter.HandleFunc("/fee_schedules/{id}", helpers.ValidateAdminToken(adminHandler.RetireFeeSchedule())).Methods("DELETE")
 }
@@ -1,26 +1,47 @@
 package routes
 
 import (
+	"github.com/ShowBaba/kagewallet/common"
 	"github.com/ShowBaba/kagewallet/handlers"
+	log "github.com/ShowBaba/kagewallet/logging"
 	"github.com/ShowBaba/kagewallet/repositories"
 	"github.com/ShowBaba/kagewallet/services"
 	"github.com/gorilla/mux"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 func RegisterWebhookRoutes(router *mux.Router, db *gorm.DB) {
 	var (
-		addressRepo     = repositories.NewAddressRepository(db)
-		transactionRepo = repositories.NewTransactionRepository(db)
-		walletRepo      = repositories.NewWalletRepository(db)
-		rateRepo        = repositories.NewRateRepository(db)
-		assetRepo       = repositories.NewAssetRepository(db)
-		rateService     = services.NewRateService(rateRepo)
-		withdrawalRepo  = repositories.NewWithdrawalRepository(db)
-		webhookService  = services.NewWebhookService(addressRepo, transactionRepo, walletRepo, assetRepo, withdrawalRepo, rateService)
-		webhookHandler  = handlers.NewWebhookHandler(webhookService)
-		apiRouter       = router.PathPrefix("/api/webhook").Subrouter()
+		addressRepo       = repositories.NewAddressRepository(db)
+		userRepo          = repositories.NewUserRepository(db)
+		transactionRepo   = repositories.NewTransactionRepository(db)
+		walletRepo        = repositories.NewWalletRepository(db)
+		rateRepo          = repositories.NewRateRepository(db)
+		assetRepo         = repositories.NewAssetRepository(db)
+		rateService       = services.NewRateService(rateRepo, repositories.NewQuoteRepository(db))
+		withdrawalRepo    = repositories.NewWithdrawalRepository(db)
+		payouts           = mustPayoutRouter(services.NewMonnifyService())
+		feeService        = services.NewFeeService(repositories.NewFeeRepository(db))
+		accountService    = services.NewAccountService(userRepo, repositories.NewSecurityEventRepository(db))
+		withdrawalService = services.NewWithdrawalService(payouts, withdrawalRepo, walletRepo, transactionRepo, userRepo, repositories.NewBeneficiaryRepository(db), feeService, accountService)
+		custodyService    = services.NewCustodyServiceFromEnv(repositories.NewNetworkRepository(db))
+		webhookService    = services.NewWebhookService(addressRepo, userRepo, transactionRepo, walletRepo, assetRepo, withdrawalRepo, rateService, withdrawalService, custodyService, feeService)
+		inboxService      = services.NewWebhookInboxService(repositories.NewWebhookEventRepository(db), webhookService)
+		webhookHandler    = handlers.NewWebhookHandler(inboxService, payouts, custodyService)
+		apiRouter         = router.PathPrefix("/api/webhook").Subrouter()
 	)
-	apiRouter.HandleFunc("/blockradar", webhookHandler.BlockradarWebhook()).Methods("POST")
-	apiRouter.HandleFunc("/monnify", webhookHandler.MonnifyWebhook()).Methods("POST")
+	apiRouter.HandleFunc("/blockradar", webhookHandler.CustodyWebhook(common.WebhookProviderBlockradar)).Methods("POST")
+	apiRouter.HandleFunc("/monnify", webhookHandler.PayoutWebhook(common.PayoutProviderMonnify)).Methods("POST")
+	apiRouter.HandleFunc("/paystack", webhookHandler.PayoutWebhook(common.PayoutProviderPaystack)).Methods("POST")
+}
+
+// mustPayoutRouter builds the payout router from the environment, stopping
+// the server on a configuration mistake as main does for the jobs.
+func mustPayoutRouter(monnifyService *services.MonnifyService) *services.PayoutRouter {
+	payouts, err := services.NewPayoutRouterFromEnv(monnifyService)
+	if err != nil {
+		log.Fatal("error configuring payout providers", zap.Error(err))
+	}
+	return payouts
 }
@@ -2,51 +2,122 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/ShowBaba/kagewallet/common"
 	"github.com/ShowBaba/kagewallet/database"
 	"github.com/ShowBaba/kagewallet/repositories"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
 type AdminService struct {
-	RateRepo       *repositories.RateRepository
-	AssetRepo      *repositories.AssetRepository
-	MonnifyService *MonnifyService
+	RateRepo          *repositories.RateRepository
+	AssetRepo         *repositories.AssetRepository
+	MonnifyService    *MonnifyService
+	WebhookEventRepo  *repositories.WebhookEventRepository
+	AccountService    *AccountService
+	WithdrawalService *WithdrawalService
+	CustodyService    *CustodyService
+	FeeService        *FeeService
 }
 
 func NewAdminService(rateRepo *repositories.RateRepository,
-	assetRepo *repositories.AssetRepository, monnifyService *MonnifyService) *AdminService {
+	assetRepo *repositories.AssetRepository, monnifyService *MonnifyService,
+	webhookEventRepo *repositories.WebhookEventRepository, accountService *AccountService,
+	withdrawalService *WithdrawalService, custodyService *CustodyService, feeService *FeeService) *AdminService {
 	return &AdminService{
 		rateRepo,
 		assetRepo,
 		monnifyService,
+		webhookEventRepo,
+		accountService,
+		withdrawalService,
+		custodyService,
+		feeService,
 	}
 }
 
 func (s *AdminService) CreateAsset(input common.CreateAssetInput) error {
 	asset := database.Asset{
-		ID:           uuid.New(),
-		Name:         input.Name,
-		Symbol:       input.Symbol,
-		Standard:     input.Standard,
-		LogoURL:      input.LogoURL,
-		IsActive:     input.IsActive,
-		Instructions: input.Instructions,
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
+		ID:            uuid.New(),
+		Name:          input.Name,
+		Symbol:        input.Symbol,
+		Standard:      input.Standard,
+		LogoURL:       input.LogoURL,
+		IsActive:      input.IsActive,
+		Instructions:  input.Instructions,
+		TokenContract: input.TokenContract,
+		Decimals:      input.Decimals,
+		MinDeposit:    input.MinDeposit,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+	if input.NetworkID != "" {
+		networkID, err := uuid.Parse(input.NetworkID)
+		if err != nil {
+			return fmt.Errorf("invalid network id: %v", err)
+		}
+		if _, err := s.CustodyService.NetworkRepo.FindByID(networkID); err != nil {
+			return fmt.Errorf("error fetching network: %w", err)
+		}
+		asset.NetworkID = &networkID
 	}
 	return s.AssetRepo.AddNewAsset(asset)
 }
 
+// CreateNetwork adds a chain wallet. Its provider must be registered, but the
+// env vars it names may be set later; until then the network is skipped.
+func (s *AdminService) CreateNetwork(input common.CreateNetworkInput) (*database.Network, error) {
+	if _, err := s.CustodyService.Provider(input.Provider); err != nil {
+		return nil, err
+	}
+	network := database.Network{
+		Code:                  input.Code,
+		Name:                  input.Name,
+		ChainID:               input.ChainID,
+		Provider:              input.Provider,
+		TokenStandard:         input.TokenStandard,
+		WalletIDEnv:           input.WalletIDEnv,
+		APIKeyEnv:             input.APIKeyEnv,
+		RequiredConfirmations: input.RequiredConfirmations,
+		IsActive:              input.IsActive,
+		ExplorerURL:           input.ExplorerURL,
+	}
+	if err := s.CustodyService.NetworkRepo.CreateNetwork(&network); err != nil {
+		return nil, err
+	}
+	return &network, nil
+}
+
+func (s *AdminService) UpdateNetwork(networkID uuid.UUID, updates map[string]interface{}) error {
+	if provider, ok := updates["provider"].(string); ok {
+		if _, err := s.CustodyService.Provider(provider); err != nil {
+			return err
+		}
+	}
+	return s.CustodyService.NetworkRepo.UpdateNetwork(networkID, updates)
+}
+
+func (s *AdminService) ListNetworks() ([]database.Network, error) {
+	return s.CustodyService.ListNetworks()
+}
+
+func (s *AdminService) GetNetworkBalances(networkID uuid.UUID) ([]CustodyBalance, error) {
+	return s.CustodyService.GetBalances(networkID)
+}
+
 func (s *AdminService) UpdateAsset(assetID uuid.UUID, updates map[string]interface{}) error {
 	return s.AssetRepo.UpdateAsset(assetID, updates)
 }
 
-func (s *AdminService) CreateRate(rate float64, source string) error {
-	return s.RateRepo.AddNewRate(rate, source)
+func (s *AdminService) CreateRate(assetID uuid.UUID, side string, rate decimal.Decimal, source string) error {
+	if _, err := s.AssetRepo.FindAssetByID(assetID.String()); err != nil {
+		return fmt.Errorf("error fetching asset: %w", err)
+	}
+	return s.RateRepo.AddNewRate(assetID, side, rate, source)
 }
 
 func (s *AdminService) AssetExists(name, symbol, standard string) (bool, error) {
@@ -79,3 +150,113 @@ func (s *AdminService) GetAssets(active bool) ([]database.Asset, error) {
 func (s *AdminService) ValidateMonnifyTransferOTP(reference, otp string) error {
 	return s.MonnifyService.ValidateTransferOTP(reference, otp)
 }
+
+func (s *AdminService) ListWebhookEvents(provider, status string, limit, offset int) ([]database.WebhookEvent, error) {
+	return s.WebhookEventRepo.ListEvents(provider, status, limit, offset)
+}
+
+func (s *AdminService) GetWebhookEvent(id uuid.UUID) (*database.WebhookEvent, error) {
+	return s.WebhookEventRepo.GetEventByID(id)
+}
+
+func (s *AdminService) ReplayWebhookEvent(id uuid.UUID) (*database.WebhookEvent, error) {
+	if _, err := s.WebhookEventRepo.GetEventByID(id); err != nil {
+		return nil, err
+	}
+	if err := s.WebhookEventRepo.ReplayEvent(id); err != nil {
+		return nil, err
+	}
+	return s.WebhookEventRepo.GetEventByID(id)
+}
+
+func (s *AdminService) FreezeUser(userID uuid.UUID, reason string) (*database.User, error) {
+	if _, err := s.AccountService.UserRepo.FindOneByID(userID.String()); err != nil {
+		return nil, err
+	}
+	if err := s.AccountService.Freeze(userID, reason, common.FrozenByAdmin); err != nil {
+		return nil, err
+	}
+	return s.AccountService.UserRepo.FindOneByID(userID.String())
+}
+
+func (s *AdminService) UnfreezeUser(userID uuid.UUID, reason string) (*database.User, error) {
+	if _, err := s.AccountService.UserRepo.FindOneByID(userID.String()); err != nil {
+		return nil, err
+	}
+	if err := s.AccountService.Unfreeze(userID, reason, common.FrozenByAdmin); err != nil {
+		return nil, err
+	}
+	return s.AccountService.UserRepo.FindOneByID(userID.String())
+}
+
+func (s *AdminService) ListSecurityEvents(userID uuid.UUID, eventType string, limit, offset int) ([]database.SecurityEvent, error) {
+	return s.AccountService.ListSecurityEvents(userID, eventType, limit, offset)
+}
+
+func (s *AdminService) ListWithdrawals(status string, limit, offset int) ([]database.Withdrawal, error) {
+	return s.WithdrawalService.ListWithdrawals(status, limit, offset)
+}
+
+func (s *AdminService) GetWithdrawal(id uuid.UUID) (*WithdrawalWithReviews, error) {
+	return s.WithdrawalService.GetWithdrawalWithReviews(id)
+}
+
+func (s *AdminService) ApproveWithdrawal(id uuid.UUID, admin, reason string) (*WithdrawalWithReviews, int, error) {
+	_, missing, err := s.WithdrawalService.ApproveWithdrawal(id, admin, reason)
+	if err != nil {
+		return nil, 0, err
+	}
+	withdrawal, err := s.WithdrawalService.GetWithdrawalWithReviews(id)
+	return withdrawal, missing, err
+}
+
+func (s *AdminService) RejectWithdrawal(id uuid.UUID, admin, reason string) (*WithdrawalWithReviews, error) {
+	if _, err := s.WithdrawalService.RejectWithdrawal(id, admin, reason); err != nil {
+		return nil, err
+	}
+	return s.WithdrawalService.GetWithdrawalWithReviews(id)
+}
+
+func (s *AdminService) ListFeeSchedules(operation string, history bool) ([]database.FeeSchedule, error) {
+	return s.FeeService.ListSchedules(operation, history)
+}
+
+// CreateFeeSchedule saves a schedule as the next version for its operation,
+// asset and tier, replacing the one in force.
+func (s *AdminService) CreateFeeSchedule(input common.FeeScheduleInput, admin string) (*database.FeeSchedule, error) {
+	schedule := database.FeeSchedule{
+		Operation: input.Operation,
+		Tier:      input.Tier,
+		Kind:      input.Kind,
+		Flat:      input.Flat,
+		RateBPS:   input.RateBPS,
+		MinFee:    input.MinFee,
+		MaxFee:    input.MaxFee,
+		CreatedBy: admin,
+	}
+	if input.AssetID != "" {
+		assetID, err := uuid.Parse(input.AssetID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid asset id", ErrInvalidFeeSchedule)
+		}
+		if _, err := s.AssetRepo.FindAssetByID(assetID.String()); err != nil {
+			return nil, fmt.Errorf("error fetching asset: %w", err)
+		}
+		schedule.AssetID = &assetID
+	}
+	for _, band := range input.Bands {
+		schedule.Bands = append(schedule.Bands, database.FeeBand{
+			UpTo:    band.UpTo,
+			Flat:    band.Flat,
+			RateBPS: band.RateBPS,
+		})
+	}
+	if err := s.FeeService.CreateSchedule(&schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (s *AdminService) RetireFeeSchedule(id uuid.UUID) (bool, error) {
+	return s.FeeService.RetireSchedule(id)
+}
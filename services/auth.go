@@ -1,23 +1,59 @@
 package services
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"math/big"
 	"strings"
+	"time"
 
 	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
 	"github.com/ShowBaba/kagewallet/helpers"
+	log "github.com/ShowBaba/kagewallet/logging"
 	"github.com/ShowBaba/kagewallet/repositories"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultOneTimeCodeTTL        = 10 * time.Minute
+	codeRequestWindow            = time.Hour
+	maxCodeRequests              = 3
+	maxCodeAttempts              = 5
+	defaultPasswordResetCooldown = 24 * time.Hour
+)
+
+var (
+	ErrNoEmailOnFile       = errors.New("no email on file")
+	ErrTooManyCodeRequests = errors.New("too many one-time code requests")
+	ErrTooManyCodeAttempts = errors.New("too many one-time code attempts")
+	ErrCodeExpired         = errors.New("one-time code expired")
+	ErrInvalidCode         = errors.New("invalid one-time code")
+	ErrResetNotVerified    = errors.New("password reset not verified")
 )
 
 type AuthService struct {
 	UserRepo *repositories.UserRepository
+	Mailer   Mailer
 }
 
-func NewAuthService(userRepo *repositories.UserRepository) *AuthService {
-	return &AuthService{UserRepo: userRepo}
+func NewAuthService(userRepo *repositories.UserRepository, mailer Mailer) *AuthService {
+	return &AuthService{UserRepo: userRepo, Mailer: mailer}
 }
 
 func (a *AuthService) SetPassword(input common.SetPasswordInput) error {
+	user, err := a.UserRepo.FindOneByID(input.UserID)
+	if err != nil {
+		return fmt.Errorf("error fetching user: %v", err)
+	}
+	if user.IsFrozen {
+		return ErrAccountFrozen
+	}
 	hashedPassword, err := helpers.HashPassword(strings.TrimSpace(input.Password))
 	if err != nil {
 		return err
@@ -33,3 +69,171 @@ func (a *AuthService) ConfirmPassword(userID string, inputPassword string) (bool
 	isValid := helpers.CheckPasswordHash(strings.TrimSpace(inputPassword), user.PasswordHash)
 	return isValid, nil
 }
+
+// RequestPasswordReset emails a one-time code for resetting the password and
+// returns the address it went to.
+func (a *AuthService) RequestPasswordReset(userID string) (string, error) {
+	return a.sendCode(userID, common.OTPPurposePasswordReset, "Your KageWallet password reset code",
+		"Your KageWallet password reset code is %s.\n\n"+
+			"It expires in %s. If you did not ask to reset your password, ignore this email and your password will stay the same.")
+}
+
+// VerifyPasswordResetCode checks a code sent by RequestPasswordReset. A correct
+// code unlocks ResetPassword for the rest of the code's lifetime.
+func (a *AuthService) VerifyPasswordResetCode(userID, code string) error {
+	if err := a.verifyCode(userID, common.OTPPurposePasswordReset, code); err != nil {
+		return err
+	}
+	if err := database.SetRedisKey(fmt.Sprintf(common.RedisPasswordResetVerifiedKey, userID), "true", OneTimeCodeTTL()); err != nil {
+		return fmt.Errorf("error storing reset verification: %v", err)
+	}
+	return nil
+}
+
+// RequestUnlockCode emails the one-time code a user needs, on top of their
+// password, to unlock an account they locked themselves.
+func (a *AuthService) RequestUnlockCode(userID string) (string, error) {
+	return a.sendCode(userID, common.OTPPurposeUnlock, "Your KageWallet unlock code",
+		"Your KageWallet account unlock code is %s.\n\n"+
+			"It expires in %s. If you did not ask to unlock your account, do not share this code and contact support.")
+}
+
+func (a *AuthService) VerifyUnlockCode(userID, code string) error {
+	return a.verifyCode(userID, common.OTPPurposeUnlock, code)
+}
+
+// sendCode emails a fresh one-time code for purpose to the user's stored
+// address. Only the hash of the code is kept, for OTP_TTL, and a user can ask
+// for at most three codes per purpose an hour. bodyFormat receives the code
+// and its lifetime.
+func (a *AuthService) sendCode(userID, purpose, subject, bodyFormat string) (string, error) {
+	user, err := a.UserRepo.FindOneByID(userID)
+	if err != nil {
+		return "", fmt.Errorf("error fetching user: %v", err)
+	}
+	if user.Email == "" {
+		return "", ErrNoEmailOnFile
+	}
+
+	requests, err := database.Incr(fmt.Sprintf(common.RedisOTPRequestsKey, purpose, userID), codeRequestWindow)
+	if err != nil {
+		return "", fmt.Errorf("error counting code requests: %v", err)
+	}
+	if requests > maxCodeRequests {
+		return "", ErrTooManyCodeRequests
+	}
+
+	code, err := generateCode()
+	if err != nil {
+		return "", fmt.Errorf("error generating code: %v", err)
+	}
+
+	ttl := OneTimeCodeTTL()
+	if err := database.SetRedisKey(fmt.Sprintf(common.RedisOTPCodeKey, purpose, userID), hashCode(code), ttl); err != nil {
+		return "", fmt.Errorf("error storing code: %v", err)
+	}
+	_ = database.DeleteRedisKey(fmt.Sprintf(common.RedisOTPAttemptsKey, purpose, userID))
+	if purpose == common.OTPPurposePasswordReset {
+		_ = database.DeleteRedisKey(fmt.Sprintf(common.RedisPasswordResetVerifiedKey, userID))
+	}
+
+	if err := a.Mailer.Send(user.Email, subject, fmt.Sprintf(bodyFormat, code, ttl.Round(time.Minute))); err != nil {
+		return "", err
+	}
+	return user.Email, nil
+}
+
+// verifyCode checks a code sent by sendCode. Each code allows five guesses;
+// after that the user has to request a new one. A code can only be used once.
+func (a *AuthService) verifyCode(userID, purpose, code string) error {
+	codeKey := fmt.Sprintf(common.RedisOTPCodeKey, purpose, userID)
+	attemptsKey := fmt.Sprintf(common.RedisOTPAttemptsKey, purpose, userID)
+
+	stored, err := database.GetRedisKey(codeKey)
+	if errors.Is(err, redis.Nil) {
+		return ErrCodeExpired
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching code: %v", err)
+	}
+
+	attempts, err := database.Incr(attemptsKey, OneTimeCodeTTL())
+	if err != nil {
+		return fmt.Errorf("error counting code attempts: %v", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(hashCode(strings.TrimSpace(code)))) != 1 {
+		if attempts >= maxCodeAttempts {
+			_ = database.DeleteRedisKey(codeKey)
+			_ = database.DeleteRedisKey(attemptsKey)
+			return ErrTooManyCodeAttempts
+		}
+		return ErrInvalidCode
+	}
+
+	_ = database.DeleteRedisKey(codeKey)
+	_ = database.DeleteRedisKey(attemptsKey)
+	return nil
+}
+
+// ResetPassword replaces the password hash of a user who verified a reset
+// code and records the time, which pauses withdrawals for
+// PASSWORD_RESET_WITHDRAWAL_COOLDOWN.
+func (a *AuthService) ResetPassword(userID, password string) error {
+	verifiedKey := fmt.Sprintf(common.RedisPasswordResetVerifiedKey, userID)
+	verified, err := database.GetRedisKey(verifiedKey)
+	if errors.Is(err, redis.Nil) || (err == nil && verified != "true") {
+		return ErrResetNotVerified
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching reset verification: %v", err)
+	}
+
+	user, err := a.UserRepo.FindOneByID(userID)
+	if err != nil {
+		return fmt.Errorf("error fetching user: %v", err)
+	}
+	if user.IsFrozen {
+		return ErrAccountFrozen
+	}
+
+	hashedPassword, err := helpers.HashPassword(strings.TrimSpace(password))
+	if err != nil {
+		return err
+	}
+	if err := a.UserRepo.ResetPassword(userID, hashedPassword, time.Now()); err != nil {
+		return fmt.Errorf("error resetting password: %v", err)
+	}
+	_ = database.DeleteRedisKey(verifiedKey)
+
+	if user.Email != "" {
+		body := fmt.Sprintf("Your KageWallet password was reset on %s. Withdrawals are paused for %s as a precaution.\n\n"+
+			"If this was not you, send /lock_account to the bot and contact support immediately.",
+			time.Now().Format("02 Jan 2006, 03:04 PM"), PasswordResetCooldown())
+		if err := a.Mailer.Send(user.Email, "Your KageWallet password was reset", body); err != nil {
+			log.Error("error sending password reset notice", zap.Error(err))
+		}
+	}
+	return nil
+}
+
+func PasswordResetCooldown() time.Duration {
+	return helpers.DurationFromEnv("PASSWORD_RESET_WITHDRAWAL_COOLDOWN", defaultPasswordResetCooldown)
+}
+
+func OneTimeCodeTTL() time.Duration {
+	return helpers.DurationFromEnv("OTP_TTL", defaultOneTimeCodeTTL)
+}
+
+func generateCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+func hashCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
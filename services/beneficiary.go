@@ -0,0 +1,141 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/ShowBaba/kagewallet/helpers"
+	"github.com/ShowBaba/kagewallet/repositories"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultBeneficiaryCoolingOff = 24 * time.Hour
+	MaxBeneficiaries             = 10
+	MaxBeneficiaryNicknameLength = 30
+)
+
+var (
+	ErrBeneficiaryExists      = errors.New("beneficiary already saved")
+	ErrBeneficiaryNotFound    = errors.New("beneficiary not found")
+	ErrBeneficiaryLimit       = errors.New("too many beneficiaries")
+	ErrBeneficiaryCoolingOff  = errors.New("beneficiary is still cooling off")
+	ErrInvalidBeneficiaryName = errors.New("invalid beneficiary nickname")
+)
+
+// BeneficiaryService manages the bank accounts users save for withdrawals.
+// Account names are resolved through the payout providers when an account is
+// added, so paying a beneficiary does not need another lookup.
+type BeneficiaryService struct {
+	BeneficiaryRepo   *repositories.BeneficiaryRepository
+	WithdrawalService *WithdrawalService
+}
+
+func NewBeneficiaryService(beneficiaryRepo *repositories.BeneficiaryRepository, withdrawalService *WithdrawalService) *BeneficiaryService {
+	return &BeneficiaryService{
+		beneficiaryRepo,
+		withdrawalService,
+	}
+}
+
+// AddBeneficiary resolves and saves a bank account. The account can be paid
+// once BENEFICIARY_COOLING_OFF has passed, unless the user has already been
+// paid to it.
+func (s *BeneficiaryService) AddBeneficiary(userID uuid.UUID, bankCode, accountNumber, nickname string) (*database.Beneficiary, error) {
+	nickname = strings.TrimSpace(nickname)
+	if nickname == "" || len([]rune(nickname)) > MaxBeneficiaryNicknameLength {
+		return nil, ErrInvalidBeneficiaryName
+	}
+
+	existing, err := s.BeneficiaryRepo.FindByAccount(userID, bankCode, accountNumber)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching beneficiary: %v", err)
+	}
+	if existing != nil {
+		return nil, ErrBeneficiaryExists
+	}
+	count, err := s.BeneficiaryRepo.CountByUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error counting beneficiaries: %v", err)
+	}
+	if count >= MaxBeneficiaries {
+		return nil, ErrBeneficiaryLimit
+	}
+
+	bank, err := s.WithdrawalService.GetBankByCode(bankCode)
+	if err != nil {
+		return nil, err
+	}
+	account, err := s.WithdrawalService.ValidateBankAccount(accountNumber, bankCode)
+	if err != nil {
+		return nil, err
+	}
+
+	activeFrom := time.Now().Add(BeneficiaryCoolingOff())
+	paidBefore, err := s.WithdrawalService.WithdrawalRepo.HasCompletedWithdrawalTo(userID, bankCode, accountNumber)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching withdrawals: %v", err)
+	}
+	if paidBefore {
+		activeFrom = time.Now()
+	}
+
+	beneficiary := &database.Beneficiary{
+		UserID:        userID,
+		BankCode:      bankCode,
+		BankName:      bank.Name,
+		AccountNumber: accountNumber,
+		AccountName:   account.AccountName,
+		Nickname:      nickname,
+		ActiveFrom:    activeFrom,
+	}
+	if err := s.BeneficiaryRepo.CreateBeneficiary(beneficiary); err != nil {
+		return nil, fmt.Errorf("error saving beneficiary: %v", err)
+	}
+	return beneficiary, nil
+}
+
+func (s *BeneficiaryService) ListBeneficiaries(userID uuid.UUID) ([]database.Beneficiary, error) {
+	return s.BeneficiaryRepo.ListByUser(userID)
+}
+
+func (s *BeneficiaryService) CountBeneficiaries(userID uuid.UUID) (int64, error) {
+	return s.BeneficiaryRepo.CountByUser(userID)
+}
+
+// GetPayableBeneficiary returns a beneficiary that can be paid now, or
+// ErrBeneficiaryCoolingOff with the beneficiary when it cannot be paid yet.
+func (s *BeneficiaryService) GetPayableBeneficiary(userID, id uuid.UUID) (*database.Beneficiary, error) {
+	beneficiary, err := s.BeneficiaryRepo.FindByID(userID, id)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching beneficiary: %v", err)
+	}
+	if beneficiary == nil {
+		return nil, ErrBeneficiaryNotFound
+	}
+	if time.Now().Before(beneficiary.ActiveFrom) {
+		return beneficiary, ErrBeneficiaryCoolingOff
+	}
+	return beneficiary, nil
+}
+
+func (s *BeneficiaryService) RemoveBeneficiary(userID, id uuid.UUID) (*database.Beneficiary, error) {
+	beneficiary, err := s.BeneficiaryRepo.FindByID(userID, id)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching beneficiary: %v", err)
+	}
+	if beneficiary == nil {
+		return nil, ErrBeneficiaryNotFound
+	}
+	if _, err := s.BeneficiaryRepo.DeleteBeneficiary(userID, id); err != nil {
+		return nil, fmt.Errorf("error removing beneficiary: %v", err)
+	}
+	return beneficiary, nil
+}
+
+func BeneficiaryCoolingOff() time.Duration {
+	return helpers.DurationFromEnv("BENEFICIARY_COOLING_OFF", defaultBeneficiaryCoolingOff)
+}
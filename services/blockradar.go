@@ -0,0 +1,307 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/ShowBaba/kagewallet/helpers"
+	"github.com/shopspring/decimal"
+)
+
+// BlockradarService is the Blockradar custody provider. Each network is one
+// Blockradar wallet with its own API key.
+type BlockradarService struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+func NewBlockradarService() *BlockradarService {
+	return NewBlockradarServiceWithConfig(
+		helpers.StringFromEnv("BLOCKRADAR_BASE_URL", "https://api.blockradar.co"),
+		&http.Client{Timeout: 30 * time.Second},
+	)
+}
+
+// NewBlockradarServiceWithConfig builds a client against any Blockradar
+// compatible base URL, such as a local fake server.
+func NewBlockradarServiceWithConfig(baseURL string, httpClient *http.Client) *BlockradarService {
+	return &BlockradarService{
+		BaseURL:    baseURL,
+		HTTPClient: httpClient,
+	}
+}
+
+func (b *BlockradarService) Name() string { return common.WebhookProviderBlockradar }
+
+func (b *BlockradarService) do(network *database.Network, method, path string, payload interface{}, response interface{}) error {
+	apiKey := networkAPIKey(network)
+	if apiKey == "" || networkWalletID(network) == "" {
+		return fmt.Errorf("network %s is not configured", network.Code)
+	}
+
+	var body io.Reader
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(raw)
+	}
+	req, err := http.NewRequest(method, b.BaseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", apiKey)
+
+	resp, err := b.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send HTTP request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, string(respBody))
+	}
+	if err := json.Unmarshal(respBody, response); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+	return nil
+}
+
+func (b *BlockradarService) GenerateAddress(network *database.Network, label string, metadata map[string]string) (string, error) {
+	var response CreateBlockradarAddressResponse
+	err := b.do(network, "POST", fmt.Sprintf("/v1/wallets/%s/addresses", networkWalletID(network)), map[string]interface{}{
+		"name":                  label,
+		"disableAutoSweep":      false,
+		"enableGaslessWithdraw": true,
+		"showPrivateKey":        false,
+		"metadata":              metadata,
+	}, &response)
+	if err != nil {
+		return "", err
+	}
+	if response.Data.Address == "" {
+		return "", fmt.Errorf("no address in response: %s", response.Message)
+	}
+	return response.Data.Address, nil
+}
+
+func (b *BlockradarService) VerifyWebhook(network *database.Network, body []byte, header http.Header) bool {
+	return helpers.ValidHMACSHA512(body, networkAPIKey(network), header.Get("x-blockradar-signature"))
+}
+
+func (b *BlockradarService) ParseWebhook(body []byte) (*CustodyEvent, error) {
+	var payload common.BlockradarEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("error unmarshalling blockradar event: %v", err)
+	}
+
+	metadata := make(map[string]string)
+	raw, err := json.Marshal(payload.Data.Address.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %v", err)
+	}
+	if err := json.Unmarshal(raw, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webhook metadata: %v", err)
+	}
+
+	return &CustodyEvent{
+		ID:       payload.Data.ID,
+		Type:     payload.Event,
+		Address:  payload.Data.Address.Address,
+		ChainID:  payload.Data.ChainID,
+		Metadata: metadata,
+		Deposit: common.Deposit{
+			Reference:        payload.Data.Reference,
+			Hash:             payload.Data.Hash,
+			RecipientAddress: payload.Data.RecipientAddress,
+			Amount:           payload.Data.Amount,
+			AmountPaid:       payload.Data.AmountPaid,
+			Currency:         payload.Data.Currency,
+			Status:           payload.Data.Status,
+			Type:             payload.Data.Type,
+			Confirmations:    payload.Data.Confirmations,
+			CreatedAt:        payload.Data.CreatedAt,
+		},
+	}, nil
+}
+
+func (b *BlockradarService) ListTransactions(network *database.Network, page int) ([]common.Deposit, bool, error) {
+	var response FetchTransactionResponse
+	if err := b.do(network, "GET", fmt.Sprintf("/v1/wallets/%s/transactions?page=%d", networkWalletID(network), page), nil, &response); err != nil {
+		return nil, false, err
+	}
+
+	deposits := make([]common.Deposit, 0, len(response.Data))
+	for _, transaction := range response.Data {
+		createdAt, _ := time.Parse(time.RFC3339, transaction.CreatedAt)
+		deposits = append(deposits, common.Deposit{
+			Reference:        transaction.Reference,
+			Hash:             transaction.Hash,
+			RecipientAddress: transaction.RecipientAddress,
+			Amount:           transaction.Amount,
+			AmountPaid:       transaction.AmountPaid,
+			Currency:         transaction.Currency,
+			Status:           transaction.Status,
+			Type:             transaction.Type,
+			Confirmations:    transaction.Confirmations,
+			CreatedAt:        createdAt,
+		})
+	}
+	more := len(response.Data) > 0 && (response.Meta.TotalPages == 0 || int64(page) < response.Meta.TotalPages)
+	return deposits, more, nil
+}
+
+func (b *BlockradarService) GetBalances(network *database.Network) ([]CustodyBalance, error) {
+	var response BlockradarBalancesResponse
+	if err := b.do(network, "GET", fmt.Sprintf("/v1/wallets/%s/balances", networkWalletID(network)), nil, &response); err != nil {
+		return nil, err
+	}
+	balances := make([]CustodyBalance, 0, len(response.Data))
+	for _, item := range response.Data {
+		balance, err := decimal.NewFromString(item.Balance)
+		if err != nil {
+			return nil, fmt.Errorf("invalid balance for %s: %v", item.Asset.Asset.Symbol, err)
+		}
+		balances = append(balances, CustodyBalance{
+			Symbol:  item.Asset.Asset.Symbol,
+			Name:    item.Asset.Asset.Name,
+			Balance: balance,
+		})
+	}
+	return balances, nil
+}
+
+type BlockradarBalancesResponse struct {
+	Message    string `json:"message"`
+	StatusCode int    `json:"statusCode"`
+	Data       []struct {
+		Balance          string `json:"balance"`
+		ConvertedBalance string `json:"convertedBalance"`
+		Asset            struct {
+			Asset struct {
+				Symbol string `json:"symbol"`
+				Name   string `json:"name"`
+			} `json:"asset"`
+		} `json:"asset"`
+	} `json:"data"`
+}
+
+type CreateBlockradarAddressResponse struct {
+	Message    string `json:"message"`
+	StatusCode int    `json:"statusCode"`
+	Data       struct {
+		Address        string      `json:"address"`
+		Name           string      `json:"name"`
+		Type           string      `json:"type"`
+		DerivationPath string      `json:"derivationPath"`
+		Metadata       interface{} `json:"metadata"`
+		Configurations struct {
+			AML struct {
+				Provider string `json:"provider"`
+				Status   string `json:"status"`
+				Message  string `json:"message"`
+			} `json:"aml"`
+			ShowPrivateKey        bool `json:"showPrivateKey"`
+			DisableAutoSweep      bool `json:"disableAutoSweep"`
+			EnableGaslessWithdraw bool `json:"enableGaslessWithdraw"`
+		} `json:"configurations"`
+		Network    string `json:"network"`
+		Blockchain struct {
+			ID              string `json:"id"`
+			Name            string `json:"name"`
+			Symbol          string `json:"symbol"`
+			Slug            string `json:"slug"`
+			DerivationPath  string `json:"derivationPath"`
+			IsEvmCompatible bool   `json:"isEvmCompatible"`
+			IsActive        bool   `json:"isActive"`
+			TokenStandard   string `json:"tokenStandard"`
+			CreatedAt       string `json:"createdAt"`
+			UpdatedAt       string `json:"updatedAt"`
+			LogoURL         string `json:"logoUrl"`
+		} `json:"blockchain"`
+		ID        string `json:"id"`
+		IsActive  bool   `json:"isActive"`
+		CreatedAt string `json:"createdAt"`
+		UpdatedAt string `json:"updatedAt"`
+	} `json:"data"`
+}
+
+type FetchTransactionResponse struct {
+	Message    string                  `json:"message"`
+	StatusCode int                     `json:"statusCode"`
+	Data       []BlockradarTransaction `json:"data"`
+	Analytics  struct {
+		DepositsSumIn24Hours       int64
+		WithdrawsSumIn24Hours      int64
+		TransactionsSumIn24Hours   int64
+		DepositsCountIn24Hours     int64
+		WithdrawsCountIn24Hours    int64
+		TransactionsCountIn24Hours int64
+		TotalDepositsCount         int64
+		TotalWithdrawsCount        int64
+		TotalTransactionsCount     int64
+	} `json:"analytics"`
+
+	Meta struct {
+		TotalItems   int64
+		ItemCount    int64
+		ItemsPerPage int64
+		TotalPages   int64
+		CurrentPage  int64
+	} `json:"meta"`
+}
+
+type BlockradarTransaction struct {
+	ID               string  `json:"id"`
+	Reference        string  `json:"reference"`
+	SenderAddress    string  `json:"senderAddress"`
+	RecipientAddress string  `json:"recipientAddress"`
+	Amount           string  `json:"amount"`
+	AmountPaid       string  `json:"amountPaid"`
+	Fee              *string `json:"fee"`
+	Currency         string  `json:"currency"`
+	BlockNumber      int     `json:"blockNumber"`
+	BlockHash        string  `json:"blockHash"`
+	Hash             string  `json:"hash"`
+	Confirmations    int     `json:"confirmations"`
+	Confirmed        bool    `json:"confirmed"`
+	GasPrice         string  `json:"gasPrice"`
+	GasUsed          string  `json:"gasUsed"`
+	GasFee           string  `json:"gasFee"`
+	Status           string  `json:"status"`
+	Type             string  `json:"type"`
+	Note             *string `json:"note"`
+	AmlScreening     struct {
+		Provider string `json:"provider"`
+		Status   string `json:"status"`
+		Message  string `json:"message"`
+	} `json:"amlScreening"`
+	AssetSwept                 bool   `json:"assetSwept"`
+	AssetSweptAt               string `json:"assetSweptAt"`
+	AssetSweptGasFee           string `json:"assetSweptGasFee"`
+	AssetSweptHash             string `json:"assetSweptHash"`
+	AssetSweptSenderAddress    string `json:"assetSweptSenderAddress"`
+	AssetSweptRecipientAddress string `json:"assetSweptRecipientAddress"`
+	AssetSweptAmount           string `json:"assetSweptAmount"`
+	Reason                     string `json:"reason"`
+	Network                    string `json:"network"`
+	ChainID                    int    `json:"chainId"`
+	Metadata                   interface{}
+	CreatedAt                  string `json:"createdAt"`
+	UpdatedAt                  string `json:"updatedAt"`
+	Beneficiary                *string
+	PaymentLink                *string
+	Customer                   *string
+}
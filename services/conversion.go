@@ -0,0 +1,233 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/ShowBaba/kagewallet/helpers"
+	log "github.com/ShowBaba/kagewallet/logging"
+	"github.com/ShowBaba/kagewallet/repositories"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// defaultAssetDecimals is used for assets whose decimals are not set.
+const defaultAssetDecimals = 8
+
+var (
+	ErrConversionSameAsset   = errors.New("cannot convert an asset to itself")
+	ErrConversionUnavailable = errors.New("conversion is not available for this pair")
+	ErrConversionTooSmall    = errors.New("amount is too small to convert")
+	ErrConversionRateChanged = errors.New("rates or fees changed since the preview")
+)
+
+// ConversionQuote prices a conversion from the latest rates and the user's
+// convert fee schedule. Amount and Fee are in the source asset, Receive is in
+// the target asset and Rate is how much of the target one unit of the source
+// buys.
+type ConversionQuote struct {
+	FromAsset  *database.Asset
+	ToAsset    *database.Asset
+	Amount     decimal.Decimal
+	Fee        Fee
+	Rate       decimal.Decimal
+	Receive    decimal.Decimal
+	FromRateID uuid.UUID
+	ToRateID   uuid.UUID
+}
+
+// Preview identifies the quote for a later Convert call.
+func (q *ConversionQuote) Preview() ConversionPreview {
+	return ConversionPreview{
+		FromAssetID: q.FromAsset.ID,
+		ToAssetID:   q.ToAsset.ID,
+		Amount:      q.Amount,
+		Fee:         q.Fee.Amount,
+		FromRateID:  q.FromRateID,
+		ToRateID:    q.ToRateID,
+	}
+}
+
+// ConversionPreview is what the user saw before confirming a conversion: the
+// pair, the amount, the fee and the rates it was priced at.
+type ConversionPreview struct {
+	FromAssetID uuid.UUID
+	ToAssetID   uuid.UUID
+	Amount      decimal.Decimal
+	Fee         decimal.Decimal
+	FromRateID  uuid.UUID
+	ToRateID    uuid.UUID
+}
+
+type ConversionService struct {
+	RateService    *RateService
+	AssetRepo      *repositories.AssetRepository
+	WalletRepo     *repositories.WalletRepository
+	ConversionRepo *repositories.ConversionRepository
+	FeeService     *FeeService
+}
+
+func NewConversionService(rateService *RateService, assetRepo *repositories.AssetRepository,
+	walletRepo *repositories.WalletRepository, conversionRepo *repositories.ConversionRepository,
+	feeService *FeeService) *ConversionService {
+	return &ConversionService{
+		rateService,
+		assetRepo,
+		walletRepo,
+		conversionRepo,
+		feeService,
+	}
+}
+
+// GetConvertibleBalances lists the crypto the user holds and can convert.
+func (c *ConversionService) GetConvertibleBalances(userID uuid.UUID) ([]database.AssetBalance, error) {
+	balances, err := c.WalletRepo.GetAssetBalances(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching balances: %v", err)
+	}
+	var convertible []database.AssetBalance
+	for _, balance := range balances {
+		if balance.AssetID.String() != common.NairaAssetID {
+			convertible = append(convertible, balance)
+		}
+	}
+	return convertible, nil
+}
+
+// GetTargetAssets lists the active crypto assets the source can be converted
+// to, which are those we have a buy rate for.
+func (c *ConversionService) GetTargetAssets(fromAssetID uuid.UUID) ([]database.AssetRate, error) {
+	rates, err := c.RateService.GetActiveAssetRates(common.RateSideBuy)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching rates: %v", err)
+	}
+	var targets []database.AssetRate
+	for _, rate := range rates {
+		if rate.AssetID != fromAssetID && rate.AssetID.String() != common.NairaAssetID {
+			targets = append(targets, rate)
+		}
+	}
+	return targets, nil
+}
+
+// Quote prices converting amount of one asset into another through Naira: the
+// source is valued at its sell rate and the target bought at its buy rate.
+// The fee for the user's tier is taken from the source first, and the amount
+// received is rounded down to the target's decimals.
+func (c *ConversionService) Quote(user *database.User, fromAssetID, toAssetID uuid.UUID, amount decimal.Decimal) (*ConversionQuote, error) {
+	if fromAssetID == toAssetID {
+		return nil, ErrConversionSameAsset
+	}
+	if !amount.IsPositive() {
+		return nil, ErrConversionTooSmall
+	}
+
+	fromAsset, err := c.AssetRepo.FindAssetByID(fromAssetID.String())
+	if err != nil {
+		return nil, fmt.Errorf("error fetching asset: %w", err)
+	}
+	toAsset, err := c.AssetRepo.FindAssetByID(toAssetID.String())
+	if err != nil {
+		return nil, fmt.Errorf("error fetching asset: %w", err)
+	}
+	if !fromAsset.IsActive || !toAsset.IsActive ||
+		fromAssetID.String() == common.NairaAssetID || toAssetID.String() == common.NairaAssetID {
+		return nil, ErrConversionUnavailable
+	}
+	if halted, _ := c.RateService.IsSellHalted(fromAssetID.String()); halted {
+		return nil, ErrConversionUnavailable
+	}
+
+	sellRate, err := c.RateService.GetCurrentRate(fromAssetID, common.RateSideSell)
+	if err != nil {
+		return nil, fmt.Errorf("%w: no sell rate for %s", ErrConversionUnavailable, fromAsset.Symbol)
+	}
+	buyRate, err := c.RateService.GetCurrentRate(toAssetID, common.RateSideBuy)
+	if err != nil {
+		return nil, fmt.Errorf("%w: no buy rate for %s", ErrConversionUnavailable, toAsset.Symbol)
+	}
+	sell := sellRate.Rate
+	buy := buyRate.Rate
+	if !sell.IsPositive() || !buy.IsPositive() {
+		return nil, ErrConversionUnavailable
+	}
+
+	fee, err := c.FeeService.Quote(common.FeeOperationConvert, fromAssetID, user.Tier, amount, assetDecimals(fromAsset))
+	if err != nil {
+		return nil, err
+	}
+	receive := amount.Sub(fee.Amount).Mul(sell).Div(buy).Truncate(assetDecimals(toAsset))
+	if !receive.IsPositive() {
+		return nil, ErrConversionTooSmall
+	}
+
+	return &ConversionQuote{
+		FromAsset:  fromAsset,
+		ToAsset:    toAsset,
+		Amount:     amount,
+		Fee:        fee,
+		Rate:       sell.Div(buy),
+		Receive:    receive,
+		FromRateID: sellRate.ID,
+		ToRateID:   buyRate.ID,
+	}, nil
+}
+
+// Convert carries out a conversion the user previewed. It is priced again and
+// refused if either rate or the fee changed since the preview, so the user
+// always gets what they confirmed.
+func (c *ConversionService) Convert(user *database.User, preview ConversionPreview) (*database.Transaction, *ConversionQuote, error) {
+	quote, err := c.Quote(user, preview.FromAssetID, preview.ToAssetID, preview.Amount)
+	if err != nil {
+		return nil, nil, err
+	}
+	if quote.FromRateID != preview.FromRateID || quote.ToRateID != preview.ToRateID ||
+		!quote.Fee.Amount.Equal(preview.Fee) {
+		return nil, nil, ErrConversionRateChanged
+	}
+
+	userID := user.ID
+	transaction := database.Transaction{
+		UserID:    userID,
+		AssetID:   quote.FromAsset.ID,
+		Type:      common.TransactionTypeConvert,
+		Amount:    quote.Amount,
+		Status:    "completed",
+		Reference: helpers.GenerateTransactionReference(),
+		RateID:    quote.FromRateID,
+		Fees:      FeeRecords(quote.Fee),
+	}
+	conversion := database.Conversion{
+		UserID:      userID,
+		FromAssetID: quote.FromAsset.ID,
+		ToAssetID:   quote.ToAsset.ID,
+		FromAmount:  quote.Amount,
+		Fee:         quote.Fee.Amount,
+		ToAmount:    quote.Receive,
+		Rate:        quote.Rate,
+		FromRateID:  quote.FromRateID,
+		ToRateID:    quote.ToRateID,
+	}
+	if err := c.ConversionRepo.RecordConversion(&transaction, &conversion); err != nil {
+		return nil, nil, err
+	}
+
+	log.Info("conversion completed",
+		zap.String("user_id", userID.String()),
+		zap.String("reference", transaction.Reference),
+		zap.String("from", quote.FromAsset.Symbol),
+		zap.String("to", quote.ToAsset.Symbol),
+		zap.String("amount", quote.Amount.String()),
+		zap.String("received", quote.Receive.String()))
+	return &transaction, quote, nil
+}
+
+func assetDecimals(asset *database.Asset) int32 {
+	if asset.Decimals > 0 {
+		return int32(asset.Decimals)
+	}
+	return defaultAssetDecimals
+}
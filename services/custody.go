@@ -0,0 +1,215 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/ShowBaba/kagewallet/repositories"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// CustodyProvider holds the chain wallets users deposit into. Every call is
+// made for one network, whose row says which wallet and key to use.
+type CustodyProvider interface {
+	Name() string
+	// GenerateAddress creates a deposit address that reports metadata back
+	// with every deposit webhook.
+	GenerateAddress(network *database.Network, label string, metadata map[string]string) (string, error)
+	VerifyWebhook(network *database.Network, body []byte, header http.Header) bool
+	ParseWebhook(body []byte) (*CustodyEvent, error)
+	// ListTransactions returns one page of the wallet's transactions, newest
+	// first, and whether there are more pages.
+	ListTransactions(network *database.Network, page int) ([]common.Deposit, bool, error)
+	GetBalances(network *database.Network) ([]CustodyBalance, error)
+}
+
+// CustodyEvent is a deposit webhook. Metadata is what was attached to the
+// address when it was generated, Address is the address the provider says
+// the event belongs to and ChainID the chain it says it happened on.
+type CustodyEvent struct {
+	ID       string
+	Type     string
+	Address  string
+	ChainID  int64
+	Metadata map[string]string
+	Deposit  common.Deposit
+}
+
+type CustodyBalance struct {
+	Symbol  string          `json:"symbol"`
+	Name    string          `json:"name"`
+	Balance decimal.Decimal `json:"balance"`
+}
+
+var ErrAssetNotSupported = errors.New("asset not supported currently")
+
+// CustodyService maps assets to their network and the network to its custody
+// provider, so adding a chain is a matter of a network row and env vars.
+type CustodyService struct {
+	NetworkRepo *repositories.NetworkRepository
+	Providers   map[string]CustodyProvider
+}
+
+func NewCustodyService(networkRepo *repositories.NetworkRepository, providers ...CustodyProvider) *CustodyService {
+	service := &CustodyService{
+		networkRepo,
+		map[string]CustodyProvider{},
+	}
+	for _, provider := range providers {
+		service.Providers[provider.Name()] = provider
+	}
+	return service
+}
+
+// NewCustodyServiceFromEnv registers every supported custody provider.
+func NewCustodyServiceFromEnv(networkRepo *repositories.NetworkRepository) *CustodyService {
+	return NewCustodyService(networkRepo, NewBlockradarService())
+}
+
+func (c *CustodyService) Provider(name string) (CustodyProvider, error) {
+	provider, ok := c.Providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown custody provider %q", name)
+	}
+	return provider, nil
+}
+
+// NetworkForAsset returns the active network an asset is deposited on and its
+// provider.
+func (c *CustodyService) NetworkForAsset(asset *database.Asset) (*database.Network, CustodyProvider, error) {
+	if asset.NetworkID == nil {
+		return nil, nil, fmt.Errorf("%w: %s has no network", ErrAssetNotSupported, asset.Symbol)
+	}
+	network, err := c.NetworkRepo.FindByID(*asset.NetworkID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error fetching network: %v", err)
+	}
+	if !network.IsActive {
+		return nil, nil, fmt.Errorf("%w: network %s is disabled", ErrAssetNotSupported, network.Code)
+	}
+	provider, err := c.Provider(network.Provider)
+	if err != nil {
+		return nil, nil, err
+	}
+	return network, provider, nil
+}
+
+// GenerateAddress creates a deposit address for the user on the asset's
+// network.
+func (c *CustodyService) GenerateAddress(userID string, asset *database.Asset) (string, error) {
+	network, provider, err := c.NetworkForAsset(asset)
+	if err != nil {
+		return "", err
+	}
+	address, err := provider.GenerateAddress(network, fmt.Sprintf(`Kage:%s wallet`, network.Code), map[string]string{
+		"user_id":  userID,
+		"asset_id": asset.ID.String(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error generating %s address: %v", network.Code, err)
+	}
+	return address, nil
+}
+
+// VerifyWebhook checks a webhook against the key of the one network it says
+// it is for, so a key for one network cannot vouch for a deposit on another.
+// The network is taken from the asset_id attached to the address, or from
+// the chain id when the address carries none. Only the signature makes the
+// payload trustworthy; until then it is used to pick the key and nothing else.
+func (c *CustodyService) VerifyWebhook(providerName string, body []byte, header http.Header) (bool, error) {
+	provider, err := c.Provider(providerName)
+	if err != nil {
+		return false, err
+	}
+	event, err := provider.ParseWebhook(body)
+	if err != nil {
+		return false, nil
+	}
+	network, err := c.webhookNetwork(providerName, event)
+	if err != nil || network == nil {
+		return false, err
+	}
+	if !network.IsActive || network.Provider != providerName {
+		return false, nil
+	}
+	return provider.VerifyWebhook(network, body, header), nil
+}
+
+// webhookNetwork returns the network a webhook claims to be for, or nil when
+// it names none or one that does not exist.
+func (c *CustodyService) webhookNetwork(providerName string, event *CustodyEvent) (*database.Network, error) {
+	if assetID, err := uuid.Parse(event.Metadata["asset_id"]); err == nil {
+		network, err := c.NetworkRepo.FindByAssetID(assetID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error fetching network: %v", err)
+		}
+		return network, nil
+	}
+	if event.ChainID == 0 {
+		return nil, nil
+	}
+	networks, err := c.NetworkRepo.ListActiveByProvider(providerName)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching networks: %v", err)
+	}
+	var found *database.Network
+	for i := range networks {
+		if networks[i].ChainID != event.ChainID {
+			continue
+		}
+		// Two networks on one chain cannot be told apart.
+		if found != nil {
+			return nil, nil
+		}
+		found = &networks[i]
+	}
+	return found, nil
+}
+
+// ActiveNetworks returns every active network whose wallet is configured.
+func (c *CustodyService) ActiveNetworks() ([]database.Network, error) {
+	networks, err := c.NetworkRepo.ListNetworks()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching networks: %v", err)
+	}
+	var active []database.Network
+	for _, network := range networks {
+		if network.IsActive && networkWalletID(&network) != "" && networkAPIKey(&network) != "" {
+			active = append(active, network)
+		}
+	}
+	return active, nil
+}
+
+func (c *CustodyService) ListNetworks() ([]database.Network, error) {
+	return c.NetworkRepo.ListNetworks()
+}
+
+func (c *CustodyService) GetBalances(networkID uuid.UUID) ([]CustodyBalance, error) {
+	network, err := c.NetworkRepo.FindByID(networkID)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := c.Provider(network.Provider)
+	if err != nil {
+		return nil, err
+	}
+	return provider.GetBalances(network)
+}
+
+func networkWalletID(network *database.Network) string {
+	return os.Getenv(network.WalletIDEnv)
+}
+
+func networkAPIKey(network *database.Network) string {
+	return os.Getenv(network.APIKeyEnv)
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const testBankCode = "058"
+
+// fakeMonnify serves the parts of the Monnify API the payout code uses.
+// transfer and status answer transfers and status lookups; they default to
+// accepting every transfer and reporting it successful.
+type fakeMonnify struct {
+	*httptest.Server
+	transfer  http.HandlerFunc
+	status    func(reference string) (int, string)
+	transfers atomic.Int32
+	lookups   atomic.Int32
+}
+
+func newFakeMonnify(t *testing.T) *fakeMonnify {
+	f := &fakeMonnify{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"requestSuccessful": true, "responseBody": {"accessToken": "token", "expiresIn": 3600}}`)
+	})
+	mux.HandleFunc("/api/v1/banks", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"requestSuccessful": true, "responseBody": [{"name": "Test Bank", "code": %q}]}`, testBankCode)
+	})
+	mux.HandleFunc("/api/v2/disbursements/single", func(w http.ResponseWriter, r *http.Request) {
+		f.transfers.Add(1)
+		if f.transfer != nil {
+			f.transfer(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"requestSuccessful": true, "responseBody": {"status": "SUCCESS"}}`)
+	})
+	mux.HandleFunc("/api/v2/disbursements/single/summary", func(w http.ResponseWriter, r *http.Request) {
+		f.lookups.Add(1)
+		code, status := http.StatusOK, "SUCCESS"
+		if f.status != nil {
+			code, status = f.status(r.URL.Query().Get("reference"))
+		}
+		w.WriteHeader(code)
+		fmt.Fprintf(w, `{"requestSuccessful": true, "responseBody": {"reference": %q, "status": %q}}`, r.URL.Query().Get("reference"), status)
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeMonnify) client(timeout time.Duration) *MonnifyService {
+	return NewMonnifyServiceWithConfig(MonnifyConfig{BaseURL: f.URL, APIKey: "key", SecretKey: "secret"}, &http.Client{Timeout: timeout})
+}
+
+// fakePaystack serves the parts of the Paystack API the payout code uses.
+type fakePaystack struct {
+	*httptest.Server
+	transfer  http.HandlerFunc
+	transfers atomic.Int32
+	bodies    []string
+	mu        sync.Mutex
+}
+
+func newFakePaystack(t *testing.T) *fakePaystack {
+	f := &fakePaystack{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bank", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"status": true, "data": [{"name": "Test Bank", "code": %q, "active": true}], "meta": {}}`, testBankCode)
+	})
+	mux.HandleFunc("/bank/resolve", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"status": true, "data": {"account_number": %q, "account_name": "ADA OBI"}}`, r.URL.Query().Get("account_number"))
+	})
+	mux.HandleFunc("/transferrecipient", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"status": true, "data": {"recipient_code": "RCP_test"}}`)
+	})
+	mux.HandleFunc("/transfer", func(w http.ResponseWriter, r *http.Request) {
+		f.transfers.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		f.mu.Lock()
+		f.bodies = append(f.bodies, string(body))
+		f.mu.Unlock()
+		if f.transfer != nil {
+			f.transfer(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"status": true, "data": {"transfer_code": "TRF_test", "status": "pending"}}`)
+	})
+	mux.HandleFunc("/transfer/verify/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status": true, "data": {"status": "success"}}`)
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakePaystack) client(timeout time.Duration) *PaystackService {
+	return NewPaystackServiceWithConfig(PaystackConfig{BaseURL: f.URL, SecretKey: "secret"}, &http.Client{Timeout: timeout})
+}
+
+// hang holds a request until the test ends, past any client timeout, so the
+// client cannot tell whether the transfer went through.
+func hang(t *testing.T) http.HandlerFunc {
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}
+}
+
+// resetPayoutState clears the breaker and bank cache, which are shared by
+// every router in the process.
+func resetPayoutState(t *testing.T) {
+	reset := func() {
+		payoutBreaker = &circuitBreaker{failures: map[string]int{}, openUntil: map[string]time.Time{}}
+		bankCacheMu.Lock()
+		bankCache = map[string]cachedBanks{}
+		bankCacheMu.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// fakeRedis serves HGET from hashes over the Redis protocol and points
+// database.RedisClient at it for the rest of the test. Every other command
+// is refused.
+func fakeRedis(t *testing.T, hashes map[string]map[string]string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error starting fake redis: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveRedis(conn, hashes)
+		}
+	}()
+
+	previous := database.RedisClient
+	database.RedisClient = redis.NewClient(&redis.Options{Addr: listener.Addr().String(), Protocol: 2, DisableIndentity: true})
+	t.Cleanup(func() {
+		database.RedisClient.Close()
+		database.RedisClient = previous
+		listener.Close()
+	})
+}
+
+func serveRedis(conn net.Conn, hashes map[string]map[string]string) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readRedisCommand(r)
+		if err != nil {
+			return
+		}
+		if len(args) != 3 || !strings.EqualFold(args[0], "HGET") {
+			fmt.Fprintf(conn, "-ERR unknown command '%s'\r\n", args[0])
+			continue
+		}
+		value, ok := hashes[args[1]][args[2]]
+		if !ok {
+			fmt.Fprint(conn, "$-1\r\n")
+			continue
+		}
+		fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value), value)
+	}
+}
+
+// readRedisCommand reads one command, sent as an array of bulk strings.
+func readRedisCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("unexpected redis command %q", line)
+	}
+	args := make([]string, count)
+	for i := range args {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+// fakeFeeRepo prices every asset and tier of an operation with one schedule.
+type fakeFeeRepo map[string]*database.FeeSchedule
+
+func (f fakeFeeRepo) FindActiveSchedule(operation string, assetID uuid.UUID, tier string) (*database.FeeSchedule, error) {
+	return f[operation], nil
+}
+
+func (f fakeFeeRepo) ListSchedules(operation string, history bool) ([]database.FeeSchedule, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (f fakeFeeRepo) CreateScheduleVersion(schedule *database.FeeSchedule) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (f fakeFeeRepo) RetireSchedule(id uuid.UUID) (bool, error) {
+	return false, fmt.Errorf("not implemented")
+}
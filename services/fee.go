@@ -0,0 +1,240 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var ErrInvalidFeeSchedule = errors.New("invalid fee schedule")
+
+// FeeScheduleRepository is where fee schedules are kept, normally a
+// repositories.FeeRepository.
+type FeeScheduleRepository interface {
+	FindActiveSchedule(operation string, assetID uuid.UUID, tier string) (*database.FeeSchedule, error)
+	ListSchedules(operation string, history bool) ([]database.FeeSchedule, error)
+	CreateScheduleVersion(schedule *database.FeeSchedule) error
+	RetireSchedule(id uuid.UUID) (bool, error)
+}
+
+type FeeService struct {
+	FeeRepo FeeScheduleRepository
+}
+
+func NewFeeService(feeRepo FeeScheduleRepository) *FeeService {
+	return &FeeService{
+		feeRepo,
+	}
+}
+
+// Fee is what an operation charges and the schedule it was priced under.
+// Amount is in AssetID, the asset the operation charges, and is zero when no
+// schedule applies.
+type Fee struct {
+	Operation string
+	AssetID   uuid.UUID
+	Amount    decimal.Decimal
+	Schedule  *database.FeeSchedule
+	// RateBPS is the percentage that applied, from the schedule or its band.
+	RateBPS int64
+	// Clamped is "minimum" or "maximum" when the fee was raised or lowered
+	// to one of the schedule's caps.
+	Clamped string
+}
+
+// Label describes how the fee was worked out for the bot's breakdowns: the
+// percentage charged, the cap it was held to, or empty for a flat fee.
+func (f Fee) Label() string {
+	switch {
+	case f.Clamped != "":
+		return f.Clamped
+	case f.RateBPS > 0:
+		return decimal.New(f.RateBPS, -2).String() + "%"
+	}
+	return ""
+}
+
+// Record is the fee as saved against the transaction that charged it.
+func (f Fee) Record() database.TransactionFee {
+	fee := database.TransactionFee{
+		Operation: f.Operation,
+		AssetID:   f.AssetID,
+		Amount:    f.Amount,
+	}
+	if f.Schedule != nil {
+		fee.FeeScheduleID = f.Schedule.ID
+	}
+	return fee
+}
+
+// FeeRecords lists the fees that were charged, skipping zero ones.
+func FeeRecords(fees ...Fee) []database.TransactionFee {
+	var records []database.TransactionFee
+	for _, fee := range fees {
+		if fee.Amount.IsPositive() && fee.Schedule != nil {
+			records = append(records, fee.Record())
+		}
+	}
+	return records
+}
+
+// FeeAssetID is the asset an operation charges its fee in: Naira for sells
+// and withdrawals, and the asset itself for deposits and conversions.
+func FeeAssetID(operation string, assetID uuid.UUID) uuid.UUID {
+	switch operation {
+	case common.FeeOperationSell, common.FeeOperationWithdrawal:
+		return uuid.MustParse(common.NairaAssetID)
+	}
+	return assetID
+}
+
+// Quote prices an operation on amount of an asset for a user of the given
+// tier, rounding the fee up to places. Amount is in the asset the operation
+// charges, see FeeAssetID.
+func (f *FeeService) Quote(operation string, assetID uuid.UUID, tier string, amount decimal.Decimal, places int32) (Fee, error) {
+	fee := Fee{
+		Operation: operation,
+		AssetID:   FeeAssetID(operation, assetID),
+		Amount:    decimal.Zero,
+	}
+	schedule, err := f.FeeRepo.FindActiveSchedule(operation, assetID, tier)
+	if err != nil {
+		return fee, err
+	}
+	if schedule == nil {
+		return fee, nil
+	}
+	fee.Schedule = schedule
+	fee.Amount, fee.RateBPS, fee.Clamped = ComputeFee(schedule, amount)
+	fee.Amount = fee.Amount.RoundUp(places)
+	return fee, nil
+}
+
+// QuoteNaira prices an operation charged in Naira.
+func (f *FeeService) QuoteNaira(operation string, assetID uuid.UUID, tier string, amount decimal.Decimal) (Fee, error) {
+	return f.Quote(operation, assetID, tier, amount, common.NairaPlaces)
+}
+
+// ComputeFee applies a schedule to an amount, returning the unrounded fee,
+// the percentage that applied and which cap, if any, it was held to. A
+// tiered schedule charges the first band whose limit covers the amount.
+func ComputeFee(schedule *database.FeeSchedule, amount decimal.Decimal) (decimal.Decimal, int64, string) {
+	flat, bps := schedule.Flat, schedule.RateBPS
+	if schedule.Kind == common.FeeKindTiered {
+		flat, bps = decimal.Zero, 0
+		for _, band := range sortedBands(schedule.Bands) {
+			if band.UpTo.IsZero() || amount.LessThanOrEqual(band.UpTo) {
+				flat, bps = band.Flat, band.RateBPS
+				break
+			}
+		}
+	}
+
+	fee := flat.Add(amount.Mul(decimal.NewFromInt(bps)).Div(decimal.NewFromInt(10000)))
+	if fee.LessThan(schedule.MinFee) {
+		return schedule.MinFee, bps, "minimum"
+	}
+	if schedule.MaxFee.IsPositive() && fee.GreaterThan(schedule.MaxFee) {
+		return schedule.MaxFee, bps, "maximum"
+	}
+	return fee, bps, ""
+}
+
+// ValidateSchedule checks a schedule before it is saved. Flat schedules have
+// no percentage, percentage schedules have one, and tiered schedules price
+// everything through bands, the last of which must have no upper bound.
+func ValidateSchedule(schedule *database.FeeSchedule) error {
+	switch schedule.Operation {
+	case common.FeeOperationDeposit, common.FeeOperationSell, common.FeeOperationConvert:
+	case common.FeeOperationWithdrawal:
+		if schedule.AssetID != nil && schedule.AssetID.String() != common.NairaAssetID {
+			return fmt.Errorf("%w: withdrawals are only in Naira", ErrInvalidFeeSchedule)
+		}
+	default:
+		return fmt.Errorf("%w: unknown operation %q", ErrInvalidFeeSchedule, schedule.Operation)
+	}
+
+	if schedule.Flat.IsNegative() || schedule.MinFee.IsNegative() || schedule.MaxFee.IsNegative() {
+		return fmt.Errorf("%w: amounts cannot be negative", ErrInvalidFeeSchedule)
+	}
+	if schedule.MaxFee.IsPositive() && schedule.MaxFee.LessThan(schedule.MinFee) {
+		return fmt.Errorf("%w: max_fee is below min_fee", ErrInvalidFeeSchedule)
+	}
+	if schedule.RateBPS < 0 || schedule.RateBPS >= 10000 {
+		return fmt.Errorf("%w: rate_bps must be from 0 to 9999", ErrInvalidFeeSchedule)
+	}
+
+	switch schedule.Kind {
+	case common.FeeKindFlat:
+		if schedule.RateBPS != 0 || len(schedule.Bands) > 0 {
+			return fmt.Errorf("%w: a flat fee has no rate_bps or bands", ErrInvalidFeeSchedule)
+		}
+	case common.FeeKindPercentage:
+		if schedule.RateBPS == 0 || len(schedule.Bands) > 0 {
+			return fmt.Errorf("%w: a percentage fee needs rate_bps and has no bands", ErrInvalidFeeSchedule)
+		}
+	case common.FeeKindTiered:
+		if !schedule.Flat.IsZero() || schedule.RateBPS != 0 || len(schedule.Bands) == 0 {
+			return fmt.Errorf("%w: a tiered fee is priced by its bands only", ErrInvalidFeeSchedule)
+		}
+		bands := sortedBands(schedule.Bands)
+		for i, band := range bands {
+			if band.UpTo.IsNegative() || band.Flat.IsNegative() || band.RateBPS < 0 || band.RateBPS >= 10000 {
+				return fmt.Errorf("%w: band %d is out of range", ErrInvalidFeeSchedule, i+1)
+			}
+			if band.UpTo.IsZero() != (i == len(bands)-1) {
+				return fmt.Errorf("%w: exactly one band, the last, has no up_to", ErrInvalidFeeSchedule)
+			}
+			if i > 0 && band.UpTo.Equal(bands[i-1].UpTo) {
+				return fmt.Errorf("%w: bands share an up_to", ErrInvalidFeeSchedule)
+			}
+		}
+		schedule.Bands = bands
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidFeeSchedule, schedule.Kind)
+	}
+	return nil
+}
+
+// ActiveSchedule returns the schedule a user of the tier is charged under for
+// an operation on the asset, or nil when the operation is free.
+func (f *FeeService) ActiveSchedule(operation string, assetID uuid.UUID, tier string) (*database.FeeSchedule, error) {
+	return f.FeeRepo.FindActiveSchedule(operation, assetID, tier)
+}
+
+func (f *FeeService) ListSchedules(operation string, history bool) ([]database.FeeSchedule, error) {
+	return f.FeeRepo.ListSchedules(operation, history)
+}
+
+// CreateSchedule validates a schedule and saves it as the next version for
+// its operation, asset and tier.
+func (f *FeeService) CreateSchedule(schedule *database.FeeSchedule) error {
+	if err := ValidateSchedule(schedule); err != nil {
+		return err
+	}
+	return f.FeeRepo.CreateScheduleVersion(schedule)
+}
+
+// RetireSchedule stops charging an active schedule, leaving the next most
+// general one to apply.
+func (f *FeeService) RetireSchedule(id uuid.UUID) (bool, error) {
+	return f.FeeRepo.RetireSchedule(id)
+}
+
+// sortedBands orders bands by their upper bound, with the unbounded band
+// last.
+func sortedBands(bands []database.FeeBand) []database.FeeBand {
+	sorted := append([]database.FeeBand(nil), bands...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].UpTo.IsZero() != sorted[j].UpTo.IsZero() {
+			return sorted[j].UpTo.IsZero()
+		}
+		return sorted[i].UpTo.LessThan(sorted[j].UpTo)
+	})
+	return sorted
+}
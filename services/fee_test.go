@@ -0,0 +1,148 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func dec(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+func band(upTo, flat string, bps int64) database.FeeBand {
+	return database.FeeBand{UpTo: dec(upTo), Flat: dec(flat), RateBPS: bps}
+}
+
+func tieredSchedule(bands ...database.FeeBand) *database.FeeSchedule {
+	return &database.FeeSchedule{Operation: common.FeeOperationWithdrawal, Kind: common.FeeKindTiered, Bands: bands}
+}
+
+func TestComputeFee(t *testing.T) {
+	var (
+		flat       = &database.FeeSchedule{Kind: common.FeeKindFlat, Flat: dec("100")}
+		percentage = &database.FeeSchedule{Kind: common.FeeKindPercentage, RateBPS: 150}
+		capped     = &database.FeeSchedule{Kind: common.FeeKindPercentage, RateBPS: 150, MinFee: dec("50"), MaxFee: dec("2000")}
+		flatPlus   = &database.FeeSchedule{Kind: common.FeeKindPercentage, Flat: dec("10"), RateBPS: 100}
+		// Bands are given out of order; the unbounded one still comes last.
+		tiered = tieredSchedule(
+			band("0", "0", 50),
+			band("5000", "10", 0),
+			band("50000", "0", 100),
+		)
+	)
+	tests := []struct {
+		name        string
+		schedule    *database.FeeSchedule
+		amount      string
+		want        string
+		wantRounded string
+		wantBPS     int64
+		wantClamped string
+	}{
+		{"flat", flat, "5000", "100", "100", 0, ""},
+		{"flat on a small amount", flat, "1", "100", "100", 0, ""},
+		{"percentage", percentage, "10000", "150", "150", 150, ""},
+		{"percentage rounds up to kobo", percentage, "1234.57", "18.51855", "18.52", 150, ""},
+		{"fraction of a kobo rounds up", &database.FeeSchedule{Kind: common.FeeKindPercentage, RateBPS: 1}, "10.01", "0.001001", "0.01", 1, ""},
+		{"flat plus percentage", flatPlus, "1000", "20", "20", 100, ""},
+		{"below minimum", capped, "1000", "50", "50", 150, "minimum"},
+		{"at minimum", capped, "3333.34", "50.0001", "50.01", 150, ""},
+		{"above maximum", capped, "1000000", "2000", "2000", 150, "maximum"},
+		{"at maximum", capped, "133333.33", "1999.99995", "2000", 150, ""},
+		{"no maximum", percentage, "10000000", "150000", "150000", 150, ""},
+		{"first band", tiered, "100", "10", "10", 0, ""},
+		{"first band upper bound", tiered, "5000", "10", "10", 0, ""},
+		{"just past first band", tiered, "5000.01", "50.0001", "50.01", 100, ""},
+		{"second band upper bound", tiered, "50000", "500", "500", 100, ""},
+		{"just past second band", tiered, "50000.01", "250.00005", "250.01", 50, ""},
+		{"unbounded band", tiered, "1000000", "5000", "5000", 50, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee, bps, clamped := ComputeFee(tt.schedule, dec(tt.amount))
+			if !fee.Equal(dec(tt.want)) {
+				t.Errorf("fee = %s, want %s", fee, tt.want)
+			}
+			if rounded := fee.RoundUp(common.NairaPlaces); !rounded.Equal(dec(tt.wantRounded)) {
+				t.Errorf("rounded fee = %s, want %s", rounded, tt.wantRounded)
+			}
+			if bps != tt.wantBPS {
+				t.Errorf("rate = %d bps, want %d", bps, tt.wantBPS)
+			}
+			if clamped != tt.wantClamped {
+				t.Errorf("clamped = %q, want %q", clamped, tt.wantClamped)
+			}
+		})
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	naira := uuid.MustParse(common.NairaAssetID)
+	other := uuid.New()
+	tests := []struct {
+		name     string
+		schedule *database.FeeSchedule
+		valid    bool
+	}{
+		{"flat", &database.FeeSchedule{Operation: common.FeeOperationSell, Kind: common.FeeKindFlat, Flat: dec("100")}, true},
+		{"percentage with caps", &database.FeeSchedule{Operation: common.FeeOperationDeposit, Kind: common.FeeKindPercentage, RateBPS: 150, MinFee: dec("1"), MaxFee: dec("10")}, true},
+		{"naira withdrawal", &database.FeeSchedule{Operation: common.FeeOperationWithdrawal, AssetID: &naira, Kind: common.FeeKindFlat, Flat: dec("50")}, true},
+		{"tiered", tieredSchedule(band("5000", "10", 0), band("50000", "0", 100), band("0", "0", 50)), true},
+		{"tiered out of order", tieredSchedule(band("0", "0", 50), band("50000", "0", 100), band("5000", "10", 0)), true},
+		{"one unbounded band", tieredSchedule(band("0", "0", 50)), true},
+
+		{"unknown operation", &database.FeeSchedule{Operation: "swap", Kind: common.FeeKindFlat}, false},
+		{"withdrawal in another asset", &database.FeeSchedule{Operation: common.FeeOperationWithdrawal, AssetID: &other, Kind: common.FeeKindFlat}, false},
+		{"unknown kind", &database.FeeSchedule{Operation: common.FeeOperationSell, Kind: "stepped"}, false},
+		{"negative flat", &database.FeeSchedule{Operation: common.FeeOperationSell, Kind: common.FeeKindFlat, Flat: dec("-1")}, false},
+		{"negative minimum", &database.FeeSchedule{Operation: common.FeeOperationSell, Kind: common.FeeKindPercentage, RateBPS: 100, MinFee: dec("-1")}, false},
+		{"negative maximum", &database.FeeSchedule{Operation: common.FeeOperationSell, Kind: common.FeeKindPercentage, RateBPS: 100, MaxFee: dec("-1")}, false},
+		{"maximum below minimum", &database.FeeSchedule{Operation: common.FeeOperationSell, Kind: common.FeeKindPercentage, RateBPS: 100, MinFee: dec("10"), MaxFee: dec("5")}, false},
+		{"negative rate", &database.FeeSchedule{Operation: common.FeeOperationSell, Kind: common.FeeKindPercentage, RateBPS: -1}, false},
+		{"rate of 100%", &database.FeeSchedule{Operation: common.FeeOperationSell, Kind: common.FeeKindPercentage, RateBPS: 10000}, false},
+		{"flat with a rate", &database.FeeSchedule{Operation: common.FeeOperationSell, Kind: common.FeeKindFlat, Flat: dec("1"), RateBPS: 100}, false},
+		{"flat with bands", &database.FeeSchedule{Operation: common.FeeOperationSell, Kind: common.FeeKindFlat, Bands: []database.FeeBand{band("0", "1", 0)}}, false},
+		{"percentage without a rate", &database.FeeSchedule{Operation: common.FeeOperationSell, Kind: common.FeeKindPercentage}, false},
+		{"percentage with bands", &database.FeeSchedule{Operation: common.FeeOperationSell, Kind: common.FeeKindPercentage, RateBPS: 100, Bands: []database.FeeBand{band("0", "1", 0)}}, false},
+		{"tiered without bands", tieredSchedule(), false},
+		{"tiered with a flat fee", &database.FeeSchedule{Operation: common.FeeOperationSell, Kind: common.FeeKindTiered, Flat: dec("1"), Bands: []database.FeeBand{band("0", "0", 50)}}, false},
+		{"tiered with a rate", &database.FeeSchedule{Operation: common.FeeOperationSell, Kind: common.FeeKindTiered, RateBPS: 50, Bands: []database.FeeBand{band("0", "0", 50)}}, false},
+		// Amounts above the last bound would be priced by no band.
+		{"gap above the last band", tieredSchedule(band("5000", "10", 0), band("50000", "0", 100)), false},
+		{"two unbounded bands", tieredSchedule(band("5000", "10", 0), band("0", "0", 100), band("0", "0", 50)), false},
+		{"overlapping bands", tieredSchedule(band("5000", "10", 0), band("5000", "0", 100), band("0", "0", 50)), false},
+		{"negative band bound", tieredSchedule(band("-5000", "10", 0), band("0", "0", 50)), false},
+		{"negative band flat", tieredSchedule(band("5000", "-10", 0), band("0", "0", 50)), false},
+		{"negative band rate", tieredSchedule(band("5000", "0", -1), band("0", "0", 50)), false},
+		{"band rate of 100%", tieredSchedule(band("5000", "0", 10000), band("0", "0", 50)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSchedule(tt.schedule)
+			if tt.valid && err != nil {
+				t.Fatalf("ValidateSchedule() = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidFeeSchedule) {
+				t.Fatalf("ValidateSchedule() = %v, want %v", err, ErrInvalidFeeSchedule)
+			}
+		})
+	}
+}
+
+func TestValidateScheduleSortsBands(t *testing.T) {
+	schedule := tieredSchedule(band("0", "0", 50), band("50000", "0", 100), band("5000", "10", 0))
+	if err := ValidateSchedule(schedule); err != nil {
+		t.Fatalf("ValidateSchedule() = %v", err)
+	}
+	want := []string{"5000", "50000", "0"}
+	for i, band := range schedule.Bands {
+		if !band.UpTo.Equal(dec(want[i])) {
+			t.Errorf("band %d up_to = %s, want %s", i+1, band.UpTo, want[i])
+		}
+	}
+}
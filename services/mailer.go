@@ -0,0 +1,113 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/ShowBaba/kagewallet/logging"
+	"go.uber.org/zap"
+)
+
+// Mailer delivers plain text email. MAILER picks the implementation: "smtp"
+// sends through SMTP_HOST, while "log" writes messages to MAILER_LOG_PATH so
+// flows that send email can be exercised locally. The log mailer is only
+// allowed with ENV=dev, and MAILER must be set, so a deployment without a
+// real mailer fails to start instead of writing one-time codes to disk.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+func NewMailerFromEnv() (Mailer, error) {
+	switch kind := strings.TrimSpace(os.Getenv("MAILER")); kind {
+	case "":
+		return nil, fmt.Errorf("MAILER is not set")
+	case "log":
+		if os.Getenv("ENV") != "dev" {
+			return nil, fmt.Errorf("MAILER=log is only allowed with ENV=dev")
+		}
+		return &LogMailer{Path: os.Getenv("MAILER_LOG_PATH")}, nil
+	case "smtp":
+		mailer := &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     os.Getenv("SMTP_PORT"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		}
+		if mailer.Port == "" {
+			mailer.Port = "587"
+		}
+		if mailer.Host == "" || mailer.From == "" {
+			return nil, fmt.Errorf("SMTP_HOST and SMTP_FROM are required when MAILER=smtp")
+		}
+		return mailer, nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", kind)
+	}
+}
+
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	message := strings.Join([]string{
+		"From: " + m.From,
+		"To: " + to,
+		"Subject: " + subject,
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	if err := smtp.SendMail(net.JoinHostPort(m.Host, m.Port), auth, m.From, []string{to}, []byte(message)); err != nil {
+		return fmt.Errorf("error sending email: %v", err)
+	}
+	return nil
+}
+
+// LogMailer appends messages to Path for local development. Without a path
+// only the recipient and subject are logged; the body can hold one-time
+// codes and is never written to the application log.
+type LogMailer struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (m *LogMailer) Send(to, subject, body string) error {
+	if m.Path == "" {
+		log.Info("email not delivered, MAILER_LOG_PATH is not set", zap.String("to", to), zap.String("subject", subject))
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening mail log: %v", err)
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "%s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC3339), to, subject, body)
+	if err != nil {
+		return fmt.Errorf("error writing mail log: %v", err)
+	}
+	return nil
+}
@@ -6,55 +6,66 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"math"
 	"net/http"
+	neturl "net/url"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
-	"github.com/ShowBaba/kagewallet/helpers"
 	log "github.com/ShowBaba/kagewallet/logging"
 	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 
 	"github.com/ShowBaba/kagewallet/common"
 	"github.com/ShowBaba/kagewallet/database"
+	"github.com/ShowBaba/kagewallet/helpers"
 )
 
+type MonnifyConfig struct {
+	BaseURL             string
+	APIKey              string
+	SecretKey           string
+	SourceAccountNumber string
+}
+
 type MonnifyService struct {
+	MonnifyConfig
+	HTTPClient *http.Client
 }
 
 func NewMonnifyService() *MonnifyService {
-	initialize()
-	return &MonnifyService{}
+	return NewMonnifyServiceWithConfig(initialize(), &http.Client{Timeout: 60 * time.Second})
 }
 
-var (
-	baseURL             string
-	apiKey              string
-	secretKey           string
-	sourceAccountNumber string
-	banks               []Bank
-	HTTPClient          *http.Client
-)
+// NewMonnifyServiceWithConfig builds a client against any Monnify compatible
+// base URL, which lets the reconciler run against a local fake server.
+func NewMonnifyServiceWithConfig(config MonnifyConfig, httpClient *http.Client) *MonnifyService {
+	return &MonnifyService{
+		MonnifyConfig: config,
+		HTTPClient:    httpClient,
+	}
+}
+
+func (m *MonnifyService) Name() string { return common.PayoutProviderMonnify }
 
 func (m *MonnifyService) getAuthToken() (string, error) {
-	token, err := database.GetRedisKey(common.RedisMonnifyToken)
-	if err == nil && token != "" {
-		return token, nil
+	if database.RedisClient != nil {
+		token, err := database.GetRedisKey(common.RedisMonnifyToken)
+		if err == nil && token != "" {
+			return token, nil
+		}
 	}
-	authString := fmt.Sprintf("%s:%s", apiKey, secretKey)
+	authString := fmt.Sprintf("%s:%s", m.APIKey, m.SecretKey)
 	encodedAuth := base64.StdEncoding.EncodeToString([]byte(authString))
 
-	req, err := http.NewRequest("POST", fmt.Sprintf(`%s/api/v1/auth/login`, baseURL), bytes.NewBuffer([]byte("{}")))
+	req, err := http.NewRequest("POST", fmt.Sprintf(`%s/api/v1/auth/login`, m.BaseURL), bytes.NewBuffer([]byte("{}")))
 	if err != nil {
 		return "", err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Basic "+encodedAuth)
-	resp, err := HTTPClient.Do(req)
+	resp, err := m.HTTPClient.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -67,85 +78,56 @@ func (m *MonnifyService) getAuthToken() (string, error) {
 	if err := json.NewDecoder(resp.Body).Decode(&authResponse); err != nil {
 		return "", err
 	}
+	if database.RedisClient == nil {
+		return authResponse.ResponseBody.AccessToken, nil
+	}
 	err = database.SetRedisKey(common.RedisMonnifyToken, authResponse.ResponseBody.AccessToken, 50*time.Minute)
 	return authResponse.ResponseBody.AccessToken, err
 }
 
-func (m *MonnifyService) GetBanks(page, limit int) (paginatedBanks []Bank, totalPages int, err error) {
-	if len(banks) == 0 {
-		if err := loadBanks(); err != nil {
-			return nil, 0, fmt.Errorf("failed to load banks: %w", err)
-		}
-	}
-	if page < 1 || limit < 1 {
-		return nil, 0, fmt.Errorf("invalid page or limit")
-	}
-	totalBanks := len(banks)
-	totalPages = int(math.Ceil(float64(totalBanks) / float64(limit)))
-
-	if page > totalPages {
-		return nil, totalPages, fmt.Errorf("page out of range")
-	}
-	start := (page - 1) * limit
-	end := start + limit
-	if start > totalBanks {
-		return nil, totalPages, fmt.Errorf("no banks on this page")
+// ListBanks fetches Monnify's bank list, falling back to the copy shipped in
+// assets/monnify_banks.json when the API cannot be reached.
+func (m *MonnifyService) ListBanks() ([]Bank, error) {
+	list, err := m.fetchBanks()
+	if err == nil {
+		return list, nil
 	}
-	if end > totalBanks {
-		end = totalBanks
-	}
-	paginatedBanks = banks[start:end]
-	return paginatedBanks, totalPages, nil
+	log.Warn("error fetching monnify banks, using the bundled list", zap.Error(err))
+	return loadBanks()
 }
 
-func (m *MonnifyService) GetBankByCode(code string) (Bank, error) {
-	if len(banks) == 0 {
-		if err := loadBanks(); err != nil {
-			return Bank{}, fmt.Errorf("failed to load banks: %w", err)
-		}
+func (m *MonnifyService) fetchBanks() ([]Bank, error) {
+	token, err := m.getAuthToken()
+	if err != nil {
+		return nil, err
 	}
-	for _, bank := range banks {
-		if bank.Code == code {
-			return bank, nil
-		}
+	req, err := http.NewRequest("GET", fmt.Sprintf(`%s/api/v1/banks`, m.BaseURL), nil)
+	if err != nil {
+		return nil, err
 	}
-	return Bank{}, fmt.Errorf("bank with code %s not found", code)
-}
-
-func (m *MonnifyService) SearchBank(query string, page int, limit int) ([]Bank, int, error) {
-	if len(banks) == 0 {
-		if err := loadBanks(); err != nil {
-			return nil, 0, fmt.Errorf("failed to load banks: %w", err)
-		}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := m.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
 	}
-	var filteredBanks []Bank
-	query = strings.ToLower(query)
-
-	for _, bank := range banks {
-		if strings.Contains(strings.ToLower(bank.Name), query) {
-			filteredBanks = append(filteredBanks, bank)
-		}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch banks: %s", resp.Status)
 	}
-	totalBanks := len(filteredBanks)
-	totalPages := int(math.Ceil(float64(totalBanks) / float64(limit)))
 
-	if page > totalPages {
-		return nil, totalPages, fmt.Errorf("page out of range")
-	}
-	start := (page - 1) * limit
-	end := start + limit
-	if start > totalBanks {
-		return nil, totalPages, fmt.Errorf("no banks on this page")
+	var response BankListResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
 	}
-	if end > totalBanks {
-		end = totalBanks
+	if !response.RequestSuccessful {
+		return nil, fmt.Errorf("failed to fetch banks: %s", response.ResponseMessage)
 	}
-	paginatedBanks := filteredBanks[start:end]
-	return paginatedBanks, totalPages, nil
+	return response.ResponseBody, nil
 }
 
 func (m *MonnifyService) ValidateBankAccount(accountNumber, bankCode string) (*AccountDetails, error) {
-	url := fmt.Sprintf(`%s/api/v1/disbursements/account/validate?accountNumber=%v&bankCode=%v`, baseURL, accountNumber, bankCode)
+	url := fmt.Sprintf(`%s/api/v1/disbursements/account/validate?accountNumber=%v&bankCode=%v`, m.BaseURL, accountNumber, bankCode)
 	token, err := m.getAuthToken()
 	if err != nil {
 		return nil, err
@@ -156,7 +138,7 @@ func (m *MonnifyService) ValidateBankAccount(accountNumber, bankCode string) (*A
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+token)
-	resp, err := HTTPClient.Do(req)
+	resp, err := m.HTTPClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -176,16 +158,16 @@ func (m *MonnifyService) ValidateBankAccount(accountNumber, bankCode string) (*A
 	}, nil
 }
 
-func (m *MonnifyService) InitiateTransfer(amount decimal.Decimal, bankCode, bankAccountNumber string) (string, interface{}, error) {
-	url := fmt.Sprintf(`%s/api/v2/disbursements/single`, baseURL)
+// InitiateTransfer sends a single disbursement under a reference the caller
+// has already recorded, so a transfer whose response is lost can still be
+// looked up.
+func (m *MonnifyService) InitiateTransfer(reference string, amount decimal.Decimal, bankCode, bankAccountNumber string) error {
+	url := fmt.Sprintf(`%s/api/v2/disbursements/single`, m.BaseURL)
 	token, err := m.getAuthToken()
 	if err != nil {
-		return "", nil, err
+		return fmt.Errorf("%w: %v", ErrTransferRejected, err)
 	}
 
-	fmt.Println("token; ", token)
-
-	reference := helpers.GenerateTransactionReference()
 	var jsonStr = []byte(fmt.Sprintf(`
 	{
 		"amount": %v,
@@ -195,44 +177,47 @@ func (m *MonnifyService) InitiateTransfer(amount decimal.Decimal, bankCode, bank
 		"destinationAccountNumber": "%s",
 		"currency": "NGN",
 		"sourceAccountNumber": "%s"
-	})`, amount, reference, bankCode, bankAccountNumber, sourceAccountNumber))
+	})`, amount, reference, bankCode, bankAccountNumber, m.SourceAccountNumber))
 
-	fmt.Println("jsonStr; ", string(jsonStr))
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonStr))
 	if err != nil {
-		return "", nil, err
+		return fmt.Errorf("%w: %v", ErrTransferRejected, err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+token)
 
-	resp, err := HTTPClient.Do(req)
+	resp, err := m.HTTPClient.Do(req)
 	if err != nil {
-		return "", nil, err
+		if requestNotSent(err) {
+			return fmt.Errorf("%w: %v", ErrPayoutUnavailable, err)
+		}
+		return err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", nil, err
+		return err
 	}
 
-	// fmt.Println(string(body))
-	//
-	// fmt.Println("body; ", resp.Body)
+	if resp.StatusCode >= http.StatusBadRequest && resp.StatusCode < http.StatusInternalServerError {
+		return fmt.Errorf("%w: %s: %s", ErrTransferRejected, resp.Status, string(body))
+	}
 	if resp.StatusCode != http.StatusOK {
-		return "", nil, fmt.Errorf("failed to validate bank account: %s", resp.Status)
+		return fmt.Errorf("failed to initiate transfer: %s", resp.Status)
 	}
 
 	var response InitiateTransferResponse
 	if err := json.Unmarshal(body, &response); err != nil {
-		return "", nil, fmt.Errorf("error unmarshalling JSON: %s", err)
+		return fmt.Errorf("error unmarshalling JSON: %s", err)
 	}
-	return reference, response, nil
+	log.Info("monnify initiate transfer response", zap.String("reference", reference), zap.Any("response", response))
+	return nil
 }
 
 func (m *MonnifyService) ValidateTransferOTP(reference, otp string) error {
-	url := fmt.Sprintf(`%s/api/v2/disbursements/single/validate-otp`, baseURL)
+	url := fmt.Sprintf(`%s/api/v2/disbursements/single/validate-otp`, m.BaseURL)
 	token, err := m.getAuthToken()
 	if err != nil {
 		return err
@@ -253,7 +238,7 @@ func (m *MonnifyService) ValidateTransferOTP(reference, otp string) error {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+token)
 
-	resp, err := HTTPClient.Do(req)
+	resp, err := m.HTTPClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -276,20 +261,85 @@ func (m *MonnifyService) ValidateTransferOTP(reference, otp string) error {
 	return nil
 }
 
-func loadBanks() error {
-	// TODO: move bank codes data to redis for ease of update
-	log.Info("bank codes loaded")
+func (m *MonnifyService) GetTransferStatus(reference string) (string, error) {
+	url := fmt.Sprintf(`%s/api/v2/disbursements/single/summary?reference=%s`, m.BaseURL, neturl.QueryEscape(reference))
+	token, err := m.getAuthToken()
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := m.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", ErrTransferNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch transfer status: %s", resp.Status)
+	}
+
+	var response TransferStatusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+	if !response.RequestSuccessful {
+		return "", fmt.Errorf("failed to fetch transfer status: %s", response.ResponseMessage)
+	}
+	return monnifyPayoutStatus(response.ResponseBody.Status), nil
+}
+
+func monnifyPayoutStatus(status string) string {
+	switch status {
+	case "SUCCESS":
+		return common.PayoutStatusSuccess
+	case "FAILED", "EXPIRED":
+		return common.PayoutStatusFailed
+	case "REVERSED":
+		return common.PayoutStatusReversed
+	default:
+		return common.PayoutStatusPending
+	}
+}
+
+func (m *MonnifyService) VerifyWebhook(body []byte, header http.Header) bool {
+	return helpers.ValidHMACSHA512(body, m.SecretKey, header.Get("monnify-signature"))
+}
+
+func (m *MonnifyService) ParseWebhook(body []byte) (*PayoutEvent, error) {
+	var payload common.MonnifyEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("error unmarshalling monnify event: %v", err)
+	}
+	event := &PayoutEvent{Reference: payload.EventData.Reference, Type: payload.EventType}
+	switch payload.EventType {
+	case "SUCCESSFUL_DISBURSEMENT":
+		event.Status = common.PayoutStatusSuccess
+	case "FAILED_DISBURSEMENT":
+		event.Status = common.PayoutStatusFailed
+	case "REVERSED_DISBURSEMENT":
+		event.Status = common.PayoutStatusReversed
+	}
+	return event, nil
+}
+
+func loadBanks() ([]Bank, error) {
 	basePath, _ := filepath.Abs("assets")
 	data, err := os.ReadFile(fmt.Sprintf("%s/%s", basePath, "/monnify_banks.json"))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	var banksData []Bank
 	if err := json.Unmarshal(data, &banksData); err != nil {
-		return err
+		return nil, err
 	}
-	banks = banksData
-	return nil
+	return banksData, nil
 }
 
 type AccountDetails struct {
@@ -306,6 +356,12 @@ type Bank struct {
 	TransferUssdTemplate string `json:"transferUssdTemplate"`
 }
 
+type BankListResponse struct {
+	RequestSuccessful bool   `json:"requestSuccessful"`
+	ResponseMessage   string `json:"responseMessage"`
+	ResponseBody      []Bank `json:"responseBody"`
+}
+
 type AuthResponse struct {
 	RequestSuccessful bool   `json:"requestSuccessful"`
 	ResponseMessage   string `json:"responseMessage"`
@@ -332,32 +388,46 @@ type InitiateTransferResponse struct {
 	ResponseMessage   string `json:"responseMessage"`
 	ResponseCode      string `json:"responseCode"`
 	ResponseBody      struct {
-		Amount                   float64   `json:"amount"`
-		Reference                string    `json:"reference"`
-		Status                   string    `json:"status"`
-		DateCreated              time.Time `json:"dateCreated"`
-		TotalFee                 float64   `json:"totalFee"`
-		DestinationAccountName   string    `json:"destinationAccountName"`
-		DestinationBankName      string    `json:"destinationBankName"`
-		DestinationAccountNumber string    `json:"destinationAccountNumber"`
-		DestinationBankCode      string    `json:"destinationBankCode"`
+		Amount                   decimal.Decimal `json:"amount"`
+		Reference                string          `json:"reference"`
+		Status                   string          `json:"status"`
+		DateCreated              time.Time       `json:"dateCreated"`
+		TotalFee                 decimal.Decimal `json:"totalFee"`
+		DestinationAccountName   string          `json:"destinationAccountName"`
+		DestinationBankName      string          `json:"destinationBankName"`
+		DestinationAccountNumber string          `json:"destinationAccountNumber"`
+		DestinationBankCode      string          `json:"destinationBankCode"`
 	} `json:"responseBody"`
 }
 
-func initialize() {
-	HTTPClient = &http.Client{}
-	err := loadBanks()
-	if err != nil {
-		log.Error("error loading bank codes", zap.Error(err))
-	}
-	baseURL = "https://api.monnify.com"
-	apiKey = os.Getenv("MONNIFY_API_KEY_LIVE")
-	secretKey = os.Getenv("MONNIFY_SECRET_KEY_LIVE")
-	sourceAccountNumber = os.Getenv("MONNIFY_SOURCE_ACCOUNT_NUMBER_LIVE")
+type TransferStatusResponse struct {
+	RequestSuccessful bool   `json:"requestSuccessful"`
+	ResponseMessage   string `json:"responseMessage"`
+	ResponseCode      string `json:"responseCode"`
+	ResponseBody      struct {
+		Amount      decimal.Decimal `json:"amount"`
+		Reference   string          `json:"reference"`
+		Narration   string          `json:"narration"`
+		Currency    string          `json:"currency"`
+		Fee         decimal.Decimal `json:"fee"`
+		Status      string          `json:"status"`
+		DateCreated string          `json:"dateCreated"`
+	} `json:"responseBody"`
+}
+
+func initialize() MonnifyConfig {
 	if os.Getenv("ENV") == "dev" {
-		baseURL = "https://sandbox.monnify.com"
-		apiKey = os.Getenv("MONNIFY_API_KEY_TEST")
-		secretKey = os.Getenv("MONNIFY_SECRET_KEY_TEST")
-		sourceAccountNumber = os.Getenv("MONNIFY_SOURCE_ACCOUNT_NUMBER_TEST")
+		return MonnifyConfig{
+			BaseURL:             helpers.StringFromEnv("MONNIFY_BASE_URL", "https://sandbox.monnify.com"),
+			APIKey:              os.Getenv("MONNIFY_API_KEY_TEST"),
+			SecretKey:           os.Getenv("MONNIFY_SECRET_KEY_TEST"),
+			SourceAccountNumber: os.Getenv("MONNIFY_SOURCE_ACCOUNT_NUMBER_TEST"),
+		}
+	}
+	return MonnifyConfig{
+		BaseURL:             "https://api.monnify.com",
+		APIKey:              os.Getenv("MONNIFY_API_KEY_LIVE"),
+		SecretKey:           os.Getenv("MONNIFY_SECRET_KEY_LIVE"),
+		SourceAccountNumber: os.Getenv("MONNIFY_SOURCE_ACCOUNT_NUMBER_LIVE"),
 	}
 }
@@ -0,0 +1,420 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/helpers"
+	log "github.com/ShowBaba/kagewallet/logging"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// PayoutProvider is a bank disbursement API. Transfer statuses and webhook
+// outcomes are reported as one of the common.PayoutStatus values so the
+// withdrawal code does not need to know which provider paid.
+type PayoutProvider interface {
+	Name() string
+	ListBanks() ([]Bank, error)
+	ValidateBankAccount(accountNumber, bankCode string) (*AccountDetails, error)
+	// InitiateTransfer sends a single transfer under a reference the caller
+	// has already recorded. ErrTransferRejected and ErrPayoutUnavailable mean
+	// the provider definitely did not accept it; any other error leaves the
+	// outcome unknown.
+	InitiateTransfer(reference string, amount decimal.Decimal, bankCode, accountNumber string) error
+	GetTransferStatus(reference string) (string, error)
+	VerifyWebhook(body []byte, header http.Header) bool
+	ParseWebhook(body []byte) (*PayoutEvent, error)
+}
+
+// PayoutEvent is a provider webhook about a transfer. Status is empty for
+// events that do not settle a transfer.
+type PayoutEvent struct {
+	Reference string
+	Type      string
+	Status    string
+}
+
+var (
+	ErrTransferRejected  = errors.New("transfer rejected by payout provider")
+	ErrTransferNotFound  = errors.New("transfer not found on payout provider")
+	ErrPayoutUnavailable = errors.New("payout provider unavailable")
+)
+
+// requestNotSent reports whether an HTTP client error happened before the
+// request reached the provider, in which case nothing can have been paid.
+func requestNotSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// PayoutRouter picks the provider for each transfer. Providers are tried in
+// priority order, a bank can be pinned to a provider, a provider can be kept
+// away from large amounts, and a provider that keeps failing is skipped until
+// RetryAfter has passed.
+type PayoutRouter struct {
+	Providers        []PayoutProvider
+	BankRoutes       map[string]string
+	MaxAmounts       map[string]decimal.Decimal
+	FailureThreshold int
+	RetryAfter       time.Duration
+	BanksTTL         time.Duration
+	known            map[string]PayoutProvider
+}
+
+func NewPayoutRouter(providers []PayoutProvider, known []PayoutProvider, bankRoutes map[string]string,
+	maxAmounts map[string]decimal.Decimal, failureThreshold int, retryAfter, banksTTL time.Duration) *PayoutRouter {
+	router := &PayoutRouter{
+		providers,
+		bankRoutes,
+		maxAmounts,
+		failureThreshold,
+		retryAfter,
+		banksTTL,
+		map[string]PayoutProvider{},
+	}
+	for _, provider := range known {
+		router.known[provider.Name()] = provider
+	}
+	for _, provider := range providers {
+		router.known[provider.Name()] = provider
+	}
+	return router
+}
+
+// NewPayoutRouterFromEnv routes transfers over the providers listed in
+// PAYOUT_PROVIDERS (Monnify alone by default). Every provider can still be
+// looked up by name, so webhooks and reconciliation keep working for
+// transfers sent before a provider was taken out of the list.
+func NewPayoutRouterFromEnv(monnifyService *MonnifyService) (*PayoutRouter, error) {
+	known := []PayoutProvider{monnifyService, NewPaystackService()}
+
+	names := os.Getenv("PAYOUT_PROVIDERS")
+	if strings.TrimSpace(names) == "" {
+		names = common.PayoutProviderMonnify
+	}
+	var providers []PayoutProvider
+	for _, name := range strings.Split(names, ",") {
+		provider := findPayoutProvider(known, strings.TrimSpace(name))
+		if provider == nil {
+			return nil, fmt.Errorf("unknown payout provider %q", name)
+		}
+		providers = append(providers, provider)
+	}
+
+	bankRoutes := parseKeyValueList(os.Getenv("PAYOUT_BANK_ROUTES"))
+	for bankCode, name := range bankRoutes {
+		if findPayoutProvider(providers, name) == nil {
+			return nil, fmt.Errorf("bank %s is routed to %q, which is not in PAYOUT_PROVIDERS", bankCode, name)
+		}
+	}
+	maxAmounts := map[string]decimal.Decimal{}
+	for name, value := range parseKeyValueList(os.Getenv("PAYOUT_MAX_AMOUNTS")) {
+		amount, err := decimal.NewFromString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PAYOUT_MAX_AMOUNTS value for %s: %v", name, err)
+		}
+		maxAmounts[name] = amount
+	}
+
+	return NewPayoutRouter(providers, known, bankRoutes, maxAmounts,
+		helpers.IntFromEnv("PAYOUT_FAILURE_THRESHOLD", 3),
+		helpers.DurationFromEnv("PAYOUT_RETRY_AFTER", time.Minute),
+		helpers.DurationFromEnv("PAYOUT_BANKS_TTL", 24*time.Hour),
+	), nil
+}
+
+func findPayoutProvider(providers []PayoutProvider, name string) PayoutProvider {
+	for _, provider := range providers {
+		if provider.Name() == name {
+			return provider
+		}
+	}
+	return nil
+}
+
+// Provider returns a provider by name, whether or not it takes new transfers.
+func (p *PayoutRouter) Provider(name string) (PayoutProvider, error) {
+	provider, ok := p.known[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown payout provider %q", name)
+	}
+	return provider, nil
+}
+
+// Banks returns the bank list of the first provider that can serve one.
+// Users pick from this list and a bank code is then matched against the list
+// of whichever provider ends up paying.
+func (p *PayoutRouter) Banks() ([]Bank, error) {
+	var lastErr error
+	for _, provider := range p.Providers {
+		list, err := p.providerBanks(provider)
+		if err == nil && len(list) > 0 {
+			return list, nil
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no banks available")
+	}
+	return nil, lastErr
+}
+
+func (p *PayoutRouter) GetBanks(page, limit int) ([]Bank, int, error) {
+	list, err := p.Banks()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load banks: %w", err)
+	}
+	return paginateBanks(list, page, limit)
+}
+
+func (p *PayoutRouter) SearchBank(query string, page, limit int) ([]Bank, int, error) {
+	list, err := p.Banks()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load banks: %w", err)
+	}
+	var filteredBanks []Bank
+	query = strings.ToLower(query)
+	for _, bank := range list {
+		if strings.Contains(strings.ToLower(bank.Name), query) {
+			filteredBanks = append(filteredBanks, bank)
+		}
+	}
+	return paginateBanks(filteredBanks, page, limit)
+}
+
+func (p *PayoutRouter) GetBankByCode(code string) (Bank, error) {
+	list, err := p.Banks()
+	if err != nil {
+		return Bank{}, fmt.Errorf("failed to load banks: %w", err)
+	}
+	for _, bank := range list {
+		if bank.Code == code {
+			return bank, nil
+		}
+	}
+	return Bank{}, fmt.Errorf("bank with code %s not found", code)
+}
+
+func paginateBanks(list []Bank, page, limit int) ([]Bank, int, error) {
+	if page < 1 || limit < 1 {
+		return nil, 0, fmt.Errorf("invalid page or limit")
+	}
+	totalBanks := len(list)
+	totalPages := int(math.Ceil(float64(totalBanks) / float64(limit)))
+
+	if page > totalPages {
+		return nil, totalPages, fmt.Errorf("page out of range")
+	}
+	start := (page - 1) * limit
+	end := start + limit
+	if start > totalBanks {
+		return nil, totalPages, fmt.Errorf("no banks on this page")
+	}
+	if end > totalBanks {
+		end = totalBanks
+	}
+	return list[start:end], totalPages, nil
+}
+
+// ValidateBankAccount resolves the account name through the first provider
+// that answers. A lookup moves no money, so any failure can be retried on the
+// next provider.
+func (p *PayoutRouter) ValidateBankAccount(accountNumber, bankCode string) (*AccountDetails, error) {
+	candidates := p.candidates(bankCode, decimal.Zero)
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no payout provider available for bank %s", bankCode)
+	}
+	var lastErr error
+	for _, provider := range candidates {
+		account, err := provider.ValidateBankAccount(accountNumber, bankCode)
+		if err == nil {
+			payoutBreaker.success(provider.Name())
+			return account, nil
+		}
+		if requestNotSent(err) || errors.Is(err, ErrPayoutUnavailable) {
+			payoutBreaker.failure(provider.Name(), p.FailureThreshold, p.RetryAfter)
+		}
+		log.Warn("account lookup failed", zap.String("provider", provider.Name()), zap.Error(err))
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// Transfer sends a transfer through the first provider that accepts it.
+// assign is called with each provider's name before it is tried, so the
+// withdrawal always records who may hold the money. The next provider is only
+// tried when the current one certainly did not accept the transfer; when the
+// outcome is unknown Transfer stops and returns the error with the provider's
+// name, leaving the reconciler to find out what happened.
+func (p *PayoutRouter) Transfer(reference string, amount decimal.Decimal, bankCode, accountNumber string,
+	assign func(provider string) error) (string, error) {
+	if !amount.Equal(amount.Truncate(common.NairaPlaces)) {
+		return "", fmt.Errorf("%w: %s is not a whole number of kobo", ErrTransferRejected, amount)
+	}
+	candidates := p.candidates(bankCode, amount)
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("%w: no payout provider available for bank %s", ErrTransferRejected, bankCode)
+	}
+
+	var lastErr error
+	for _, provider := range candidates {
+		name := provider.Name()
+		if err := assign(name); err != nil {
+			return "", err
+		}
+		err := provider.InitiateTransfer(reference, amount, bankCode, accountNumber)
+		if err == nil {
+			payoutBreaker.success(name)
+			return name, nil
+		}
+		if !errors.Is(err, ErrTransferRejected) && !errors.Is(err, ErrPayoutUnavailable) {
+			payoutBreaker.failure(name, p.FailureThreshold, p.RetryAfter)
+			return name, err
+		}
+		if errors.Is(err, ErrPayoutUnavailable) {
+			payoutBreaker.failure(name, p.FailureThreshold, p.RetryAfter)
+		}
+		log.Warn("payout provider did not take the transfer, trying the next one",
+			zap.String("provider", name), zap.String("reference", reference), zap.Error(err))
+		lastErr = err
+	}
+	if !errors.Is(lastErr, ErrTransferRejected) {
+		lastErr = fmt.Errorf("%w: %v", ErrTransferRejected, lastErr)
+	}
+	return "", lastErr
+}
+
+// candidates lists the providers that may pay the given bank and amount, the
+// bank's pinned provider first.
+func (p *PayoutRouter) candidates(bankCode string, amount decimal.Decimal) []PayoutProvider {
+	ordered := make([]PayoutProvider, 0, len(p.Providers))
+	if preferred := findPayoutProvider(p.Providers, p.BankRoutes[bankCode]); preferred != nil {
+		ordered = append(ordered, preferred)
+	}
+	for _, provider := range p.Providers {
+		if provider.Name() != p.BankRoutes[bankCode] {
+			ordered = append(ordered, provider)
+		}
+	}
+
+	var candidates []PayoutProvider
+	for _, provider := range ordered {
+		name := provider.Name()
+		if !payoutBreaker.available(name) {
+			continue
+		}
+		if max, ok := p.MaxAmounts[name]; ok && max.IsPositive() && amount.GreaterThan(max) {
+			continue
+		}
+		if !p.supportsBank(provider, bankCode) {
+			continue
+		}
+		candidates = append(candidates, provider)
+	}
+	return candidates
+}
+
+// supportsBank reports whether the bank is in the provider's list. A provider
+// whose list cannot be loaded is assumed to support every bank.
+func (p *PayoutRouter) supportsBank(provider PayoutProvider, bankCode string) bool {
+	list, err := p.providerBanks(provider)
+	if err != nil || len(list) == 0 {
+		return true
+	}
+	for _, bank := range list {
+		if bank.Code == bankCode {
+			return true
+		}
+	}
+	return false
+}
+
+type cachedBanks struct {
+	banks     []Bank
+	fetchedAt time.Time
+}
+
+var (
+	bankCacheMu sync.Mutex
+	bankCache   = map[string]cachedBanks{}
+)
+
+// providerBanks returns a provider's bank list, fetching it at most once per
+// BanksTTL. A stale list is kept when a refresh fails.
+func (p *PayoutRouter) providerBanks(provider PayoutProvider) ([]Bank, error) {
+	bankCacheMu.Lock()
+	cached, ok := bankCache[provider.Name()]
+	bankCacheMu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < p.BanksTTL {
+		return cached.banks, nil
+	}
+
+	list, err := provider.ListBanks()
+	if err != nil {
+		if ok {
+			log.Warn("error refreshing bank list, keeping the old one", zap.String("provider", provider.Name()), zap.Error(err))
+			return cached.banks, nil
+		}
+		return nil, err
+	}
+	bankCacheMu.Lock()
+	bankCache[provider.Name()] = cachedBanks{list, time.Now()}
+	bankCacheMu.Unlock()
+	return list, nil
+}
+
+// GetTransferStatus asks the provider that holds a transfer for its status.
+func (p *PayoutRouter) GetTransferStatus(providerName, reference string) (string, error) {
+	provider, err := p.Provider(providerName)
+	if err != nil {
+		return "", err
+	}
+	return provider.GetTransferStatus(reference)
+}
+
+// circuitBreaker stops routing to a provider after FailureThreshold failures
+// in a row. Once RetryAfter has passed the provider gets another transfer; a
+// success closes the breaker and another failure opens it again. It is shared
+// by every router in the process.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  map[string]int
+	openUntil map[string]time.Time
+}
+
+var payoutBreaker = &circuitBreaker{
+	failures:  map[string]int{},
+	openUntil: map[string]time.Time{},
+}
+
+func (c *circuitBreaker) available(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().After(c.openUntil[name])
+}
+
+func (c *circuitBreaker) success(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.failures, name)
+	delete(c.openUntil, name)
+}
+
+func (c *circuitBreaker) failure(name string, threshold int, retryAfter time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures[name]++
+	if threshold > 0 && c.failures[name] >= threshold {
+		c.openUntil[name] = time.Now().Add(retryAfter)
+		log.Warn("payout provider marked down", zap.String("provider", name), zap.Int("failures", c.failures[name]))
+	}
+}
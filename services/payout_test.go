@@ -0,0 +1,279 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/shopspring/decimal"
+)
+
+const testTimeout = 200 * time.Millisecond
+
+func testRouter(providers ...PayoutProvider) *PayoutRouter {
+	return NewPayoutRouter(providers, nil, nil, nil, 3, time.Minute, time.Hour)
+}
+
+// transfer sends one transfer and returns the providers it was assigned to
+// along with the router's result.
+func transfer(router *PayoutRouter, amount string) ([]string, string, error) {
+	var assigned []string
+	provider, err := router.Transfer("ref-"+amount, decimal.RequireFromString(amount), testBankCode, "0123456789", func(provider string) error {
+		assigned = append(assigned, provider)
+		return nil
+	})
+	return assigned, provider, err
+}
+
+func TestTransferFailsOverWhenProviderDidNotTakeIt(t *testing.T) {
+	// A server that was closed refuses connections, so the request is never
+	// sent.
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name     string
+		paystack func(t *testing.T, f *fakePaystack) *PaystackService
+	}{
+		{
+			name: "rejected",
+			paystack: func(t *testing.T, f *fakePaystack) *PaystackService {
+				f.transfer = func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusBadRequest)
+					fmt.Fprint(w, `{"status": false, "message": "Insufficient balance"}`)
+				}
+				return f.client(testTimeout)
+			},
+		},
+		{
+			name: "unavailable",
+			paystack: func(t *testing.T, f *fakePaystack) *PaystackService {
+				return NewPaystackServiceWithConfig(PaystackConfig{BaseURL: closed.URL}, &http.Client{Timeout: testTimeout})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetPayoutState(t)
+			var (
+				paystack = newFakePaystack(t)
+				monnify  = newFakeMonnify(t)
+				router   = testRouter(tt.paystack(t, paystack), monnify.client(testTimeout))
+			)
+
+			assigned, provider, err := transfer(router, "5000")
+			if err != nil {
+				t.Fatalf("Transfer() error = %v", err)
+			}
+			if provider != common.PayoutProviderMonnify {
+				t.Errorf("paid through %q, want monnify", provider)
+			}
+			if want := []string{common.PayoutProviderPaystack, common.PayoutProviderMonnify}; !reflect.DeepEqual(assigned, want) {
+				t.Errorf("assigned %v, want %v", assigned, want)
+			}
+			if got := monnify.transfers.Load(); got != 1 {
+				t.Errorf("monnify received %d transfers, want 1", got)
+			}
+		})
+	}
+}
+
+func TestTransferDoesNotFailOverWhenOutcomeIsUnknown(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(t *testing.T) http.HandlerFunc
+	}{
+		{"timeout", hang},
+		{"server error", func(t *testing.T) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetPayoutState(t)
+			var (
+				paystack = newFakePaystack(t)
+				monnify  = newFakeMonnify(t)
+				router   = testRouter(paystack.client(testTimeout), monnify.client(testTimeout))
+			)
+			paystack.transfer = tt.handler(t)
+
+			assigned, provider, err := transfer(router, "5000")
+			if err == nil {
+				t.Fatal("Transfer() succeeded, want the unknown outcome reported")
+			}
+			if errors.Is(err, ErrTransferRejected) || errors.Is(err, ErrPayoutUnavailable) {
+				t.Errorf("Transfer() error = %v, want an unknown outcome", err)
+			}
+			if provider != common.PayoutProviderPaystack {
+				t.Errorf("Transfer() provider = %q, want paystack so the reconciler asks it", provider)
+			}
+			if want := []string{common.PayoutProviderPaystack}; !reflect.DeepEqual(assigned, want) {
+				t.Errorf("assigned %v, want %v", assigned, want)
+			}
+			if got := monnify.transfers.Load(); got != 0 {
+				t.Errorf("monnify received %d transfers, want none", got)
+			}
+		})
+	}
+}
+
+func TestTransferRejectedEverywhere(t *testing.T) {
+	resetPayoutState(t)
+	var (
+		paystack = newFakePaystack(t)
+		monnify  = newFakeMonnify(t)
+		router   = testRouter(paystack.client(testTimeout), monnify.client(testTimeout))
+		reject   = func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusUnprocessableEntity) }
+	)
+	paystack.transfer, monnify.transfer = reject, reject
+
+	_, provider, err := transfer(router, "5000")
+	if !errors.Is(err, ErrTransferRejected) {
+		t.Fatalf("Transfer() error = %v, want ErrTransferRejected", err)
+	}
+	if provider != "" {
+		t.Errorf("Transfer() provider = %q, want none", provider)
+	}
+}
+
+func TestTransferRefusesFractionsOfAKobo(t *testing.T) {
+	resetPayoutState(t)
+	var (
+		paystack = newFakePaystack(t)
+		router   = testRouter(paystack.client(testTimeout))
+	)
+
+	assigned, _, err := transfer(router, "100.005")
+	if !errors.Is(err, ErrTransferRejected) {
+		t.Fatalf("Transfer() error = %v, want ErrTransferRejected", err)
+	}
+	if len(assigned) > 0 || paystack.transfers.Load() > 0 {
+		t.Errorf("a provider was tried for an amount finer than a kobo")
+	}
+
+	if _, _, err := transfer(router, "1234.56"); err != nil {
+		t.Fatalf("Transfer() error = %v", err)
+	}
+	if len(paystack.bodies) != 1 || !strings.Contains(paystack.bodies[0], `"amount":123456`) {
+		t.Errorf("paystack was sent %v, want 123456 kobo", paystack.bodies)
+	}
+}
+
+func TestPaystackKobo(t *testing.T) {
+	tests := []struct {
+		amount  string
+		want    int64
+		wantErr bool
+	}{
+		{"1500", 150000, false},
+		{"1234.56", 123456, false},
+		{"0.01", 1, false},
+		{"1234.565", 0, true},
+		{"0.001", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := paystackKobo(decimal.RequireFromString(tt.amount))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("paystackKobo(%s) error = %v, wantErr %v", tt.amount, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("paystackKobo(%s) = %d, want %d", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	resetPayoutState(t)
+	var (
+		paystack = newFakePaystack(t)
+		monnify  = newFakeMonnify(t)
+		router   = testRouter(monnify.client(testTimeout), paystack.client(testTimeout))
+		down     = func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) }
+	)
+	router.FailureThreshold = 2
+	router.RetryAfter = 100 * time.Millisecond
+	monnify.transfer = down
+
+	// Failures below the threshold keep the provider in use.
+	for i := 0; i < 2; i++ {
+		if _, provider, err := transfer(router, "100"); err == nil || provider != common.PayoutProviderMonnify {
+			t.Fatalf("transfer %d: provider %q, error %v, want a monnify failure", i+1, provider, err)
+		}
+	}
+
+	// Open: monnify is skipped without being called.
+	if _, provider, err := transfer(router, "100"); err != nil || provider != common.PayoutProviderPaystack {
+		t.Fatalf("with the breaker open: provider %q, error %v, want paystack", provider, err)
+	}
+	if got := monnify.transfers.Load(); got != 2 {
+		t.Fatalf("monnify received %d transfers, want 2", got)
+	}
+
+	// Half-open: after RetryAfter monnify gets one transfer, and failing it
+	// opens the breaker again straight away.
+	time.Sleep(router.RetryAfter + 20*time.Millisecond)
+	if _, provider, _ := transfer(router, "100"); provider != common.PayoutProviderMonnify {
+		t.Fatalf("half-open: provider %q, want monnify to be tried", provider)
+	}
+	if _, provider, _ := transfer(router, "100"); provider != common.PayoutProviderPaystack {
+		t.Fatalf("after a half-open failure: provider %q, want paystack", provider)
+	}
+	if got := monnify.transfers.Load(); got != 3 {
+		t.Fatalf("monnify received %d transfers, want 3", got)
+	}
+
+	// A success while half-open closes the breaker.
+	time.Sleep(router.RetryAfter + 20*time.Millisecond)
+	monnify.transfer = nil
+	for i := 0; i < 2; i++ {
+		if _, provider, err := transfer(router, "100"); err != nil || provider != common.PayoutProviderMonnify {
+			t.Fatalf("closed transfer %d: provider %q, error %v, want monnify", i+1, provider, err)
+		}
+	}
+}
+
+func TestPaystackGetTransferStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+		wantErr error
+	}{
+		{"success", statusHandler(http.StatusOK, "success"), common.PayoutStatusSuccess, nil},
+		{"failed", statusHandler(http.StatusOK, "failed"), common.PayoutStatusFailed, nil},
+		{"reversed", statusHandler(http.StatusOK, "reversed"), common.PayoutStatusReversed, nil},
+		{"pending", statusHandler(http.StatusOK, "otp"), common.PayoutStatusPending, nil},
+		{"not found", statusHandler(http.StatusNotFound, ""), "", ErrTransferNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+			paystack := NewPaystackServiceWithConfig(PaystackConfig{BaseURL: server.URL}, &http.Client{Timeout: testTimeout})
+
+			got, err := paystack.GetTransferStatus("ref")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetTransferStatus() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetTransferStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func statusHandler(code int, status string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+		fmt.Fprintf(w, `{"status": true, "data": {"status": %q}}`, status)
+	}
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"time"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/helpers"
+	log "github.com/ShowBaba/kagewallet/logging"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+type PaystackConfig struct {
+	BaseURL   string
+	SecretKey string
+}
+
+// PaystackService pays out through Paystack Transfers. Paystack pays a
+// transfer recipient rather than an account, so a recipient is created (or
+// found again, Paystack deduplicates them) for every transfer.
+type PaystackService struct {
+	PaystackConfig
+	HTTPClient *http.Client
+}
+
+func NewPaystackService() *PaystackService {
+	secretKey := os.Getenv("PAYSTACK_SECRET_KEY_LIVE")
+	if os.Getenv("ENV") == "dev" {
+		secretKey = os.Getenv("PAYSTACK_SECRET_KEY_TEST")
+	}
+	return NewPaystackServiceWithConfig(PaystackConfig{
+		BaseURL:   helpers.StringFromEnv("PAYSTACK_BASE_URL", "https://api.paystack.co"),
+		SecretKey: secretKey,
+	}, &http.Client{Timeout: 60 * time.Second})
+}
+
+// NewPaystackServiceWithConfig builds a client against any Paystack
+// compatible base URL, such as a local fake server.
+func NewPaystackServiceWithConfig(config PaystackConfig, httpClient *http.Client) *PaystackService {
+	return &PaystackService{
+		PaystackConfig: config,
+		HTTPClient:     httpClient,
+	}
+}
+
+func (p *PaystackService) Name() string { return common.PayoutProviderPaystack }
+
+// do sends a request and decodes a Paystack envelope into response. It
+// returns the HTTP status so callers can tell rejections from outages.
+func (p *PaystackService) do(method, path string, payload interface{}, response interface{}) (int, error) {
+	var body io.Reader
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewBuffer(raw)
+	}
+	req, err := http.NewRequest(method, p.BaseURL+path, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.SecretKey)
+
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		if requestNotSent(err) {
+			return 0, fmt.Errorf("%w: %v", ErrPayoutUnavailable, err)
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return resp.StatusCode, fmt.Errorf("paystack returned %s: %s", resp.Status, string(raw))
+	}
+	if err := json.Unmarshal(raw, response); err != nil {
+		return resp.StatusCode, fmt.Errorf("error unmarshalling JSON: %s", err)
+	}
+	return resp.StatusCode, nil
+}
+
+func (p *PaystackService) ListBanks() ([]Bank, error) {
+	var (
+		list []Bank
+		next string
+	)
+	for {
+		path := "/bank?country=nigeria&currency=NGN&perPage=100&use_cursor=true"
+		if next != "" {
+			path += "&next=" + neturl.QueryEscape(next)
+		}
+		var response PaystackBankListResponse
+		if _, err := p.do("GET", path, nil, &response); err != nil {
+			return nil, fmt.Errorf("failed to fetch banks: %w", err)
+		}
+		for _, bank := range response.Data {
+			if bank.Active {
+				list = append(list, Bank{Name: bank.Name, Code: bank.Code})
+			}
+		}
+		if response.Meta.Next == "" {
+			return list, nil
+		}
+		next = response.Meta.Next
+	}
+}
+
+func (p *PaystackService) ValidateBankAccount(accountNumber, bankCode string) (*AccountDetails, error) {
+	path := fmt.Sprintf("/bank/resolve?account_number=%s&bank_code=%s", neturl.QueryEscape(accountNumber), neturl.QueryEscape(bankCode))
+	var response PaystackResolveResponse
+	if _, err := p.do("GET", path, nil, &response); err != nil {
+		return nil, fmt.Errorf("failed to validate bank account: %w", err)
+	}
+	return &AccountDetails{
+		AccountNumber: response.Data.AccountNumber,
+		AccountName:   response.Data.AccountName,
+		BankCode:      bankCode,
+	}, nil
+}
+
+// paystackKobo converts a Naira amount into the kobo Paystack takes. An amount
+// finer than a kobo is refused rather than rounded, so no more is ever sent
+// than was held.
+func paystackKobo(amount decimal.Decimal) (int64, error) {
+	kobo := amount.Shift(common.NairaPlaces)
+	if !kobo.IsInteger() {
+		return 0, fmt.Errorf("%w: %s is not a whole number of kobo", ErrTransferRejected, amount)
+	}
+	return kobo.Truncate(0).IntPart(), nil
+}
+
+func (p *PaystackService) InitiateTransfer(reference string, amount decimal.Decimal, bankCode, accountNumber string) error {
+	kobo, err := paystackKobo(amount)
+	if err != nil {
+		return err
+	}
+	account, err := p.ValidateBankAccount(accountNumber, bankCode)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrTransferRejected, err)
+	}
+
+	var recipient PaystackRecipientResponse
+	if _, err := p.do("POST", "/transferrecipient", map[string]string{
+		"type":           "nuban",
+		"name":           account.AccountName,
+		"account_number": accountNumber,
+		"bank_code":      bankCode,
+		"currency":       "NGN",
+	}, &recipient); err != nil {
+		return fmt.Errorf("%w: %v", ErrTransferRejected, err)
+	}
+
+	var response PaystackTransferResponse
+	status, err := p.do("POST", "/transfer", map[string]interface{}{
+		"source":    "balance",
+		"amount":    kobo,
+		"recipient": recipient.Data.RecipientCode,
+		"reference": reference,
+		"reason":    "trf",
+		"currency":  "NGN",
+	}, &response)
+	if err != nil {
+		if status >= http.StatusBadRequest && status < http.StatusInternalServerError {
+			return fmt.Errorf("%w: %v", ErrTransferRejected, err)
+		}
+		return err
+	}
+	log.Info("paystack initiate transfer response",
+		zap.String("reference", reference),
+		zap.String("transfer_code", response.Data.TransferCode),
+		zap.String("status", response.Data.Status))
+	return nil
+}
+
+func (p *PaystackService) GetTransferStatus(reference string) (string, error) {
+	var response PaystackTransferResponse
+	status, err := p.do("GET", "/transfer/verify/"+neturl.PathEscape(reference), nil, &response)
+	if status == http.StatusNotFound {
+		return "", ErrTransferNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch transfer status: %w", err)
+	}
+	return paystackPayoutStatus(response.Data.Status), nil
+}
+
+func paystackPayoutStatus(status string) string {
+	switch status {
+	case "success":
+		return common.PayoutStatusSuccess
+	case "failed", "abandoned", "rejected", "blocked":
+		return common.PayoutStatusFailed
+	case "reversed":
+		return common.PayoutStatusReversed
+	default:
+		return common.PayoutStatusPending
+	}
+}
+
+func (p *PaystackService) VerifyWebhook(body []byte, header http.Header) bool {
+	return helpers.ValidHMACSHA512(body, p.SecretKey, header.Get("x-paystack-signature"))
+}
+
+func (p *PaystackService) ParseWebhook(body []byte) (*PayoutEvent, error) {
+	var payload common.PaystackEvent
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("error unmarshalling paystack event: %v", err)
+	}
+	event := &PayoutEvent{Reference: payload.Data.Reference, Type: payload.Event}
+	switch payload.Event {
+	case "transfer.success":
+		event.Status = common.PayoutStatusSuccess
+	case "transfer.failed":
+		event.Status = common.PayoutStatusFailed
+	case "transfer.reversed":
+		event.Status = common.PayoutStatusReversed
+	}
+	return event, nil
+}
+
+type PaystackBankListResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    []struct {
+		Name   string `json:"name"`
+		Code   string `json:"code"`
+		Active bool   `json:"active"`
+	} `json:"data"`
+	Meta struct {
+		Next string `json:"next"`
+	} `json:"meta"`
+}
+
+type PaystackResolveResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		AccountNumber string `json:"account_number"`
+		AccountName   string `json:"account_name"`
+	} `json:"data"`
+}
+
+type PaystackRecipientResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		RecipientCode string `json:"recipient_code"`
+	} `json:"data"`
+}
+
+type PaystackTransferResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		Reference    string `json:"reference"`
+		TransferCode string `json:"transfer_code"`
+		Status       string `json:"status"`
+		Amount       int64  `json:"amount"`
+	} `json:"data"`
+}
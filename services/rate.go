@@ -1,18 +1,115 @@
 package services
 
 import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ShowBaba/kagewallet/common"
 	"github.com/ShowBaba/kagewallet/database"
+	"github.com/ShowBaba/kagewallet/helpers"
 	"github.com/ShowBaba/kagewallet/repositories"
+	"github.com/google/uuid"
 )
 
+const defaultQuoteTTL = 30 * time.Minute
+
 type RateService struct {
-	RateRepo *repositories.RateRepository
+	RateRepo  *repositories.RateRepository
+	QuoteRepo *repositories.QuoteRepository
+}
+
+func NewRateService(rateRepo *repositories.RateRepository, quoteRepo *repositories.QuoteRepository) *RateService {
+	return &RateService{RateRepo: rateRepo, QuoteRepo: quoteRepo}
+}
+
+func (r *RateService) GetCurrentRate(assetID uuid.UUID, side string) (*database.Rate, error) {
+	return r.RateRepo.GetLatestRate(assetID, side)
+}
+
+func (r *RateService) GetActiveAssetRates(side string) ([]database.AssetRate, error) {
+	return r.RateRepo.GetLatestRatesForActiveAssets(side)
+}
+
+// IsSellHalted reports whether the rate aggregator paused sells of an asset
+// because none of its providers had a fresh quote.
+func (r *RateService) IsSellHalted(assetID string) (bool, string) {
+	if database.RedisClient == nil {
+		return false, ""
+	}
+	reason, err := database.HGet(common.RedisSellHaltedKey, assetID)
+	if err != nil || reason == "" {
+		return false, ""
+	}
+	return true, reason
 }
 
-func NewRateService(rateRepo *repositories.RateRepository) *RateService {
-	return &RateService{RateRepo: rateRepo}
+// IssueQuote locks the current sell rate of an asset for deposits to address
+// for QUOTE_TTL. Any earlier quote for the address is retired.
+func (r *RateService) IssueQuote(userID, assetID uuid.UUID, address string) (*database.Quote, error) {
+	rate, err := r.GetCurrentRate(assetID, common.RateSideSell)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching rate: %v", err)
+	}
+
+	if err := r.QuoteRepo.ExpireActiveQuotes(address, assetID); err != nil {
+		return nil, fmt.Errorf("error expiring previous quotes: %v", err)
+	}
+
+	quote := &database.Quote{
+		UserID:    userID,
+		AssetID:   assetID,
+		Address:   address,
+		RateID:    rate.ID,
+		Rate:      rate.Rate,
+		Status:    common.QuoteStatusActive,
+		ExpiresAt: time.Now().Add(helpers.DurationFromEnv("QUOTE_TTL", defaultQuoteTTL)),
+	}
+	if err := r.QuoteRepo.CreateQuote(quote); err != nil {
+		return nil, fmt.Errorf("error creating quote: %v", err)
+	}
+	return quote, nil
+}
+
+func (r *RateService) GetQuote(id uuid.UUID) (*database.Quote, error) {
+	return r.QuoteRepo.GetQuoteByID(id)
+}
+
+// GetDepositRate picks the sell rate for a deposit seen at the given time. A
+// deposit inside a quote's window gets the quoted rate. Once the quote has
+// expired QUOTE_EXPIRY_POLICY decides: "current" pays the live rate, while
+// "lower_of" (the default) pays the lower of the live and quoted rates.
+func (r *RateService) GetDepositRate(assetID uuid.UUID, address string, at time.Time) (*database.Rate, error) {
+	current, err := r.GetCurrentRate(assetID, common.RateSideSell)
+	if err != nil {
+		return nil, err
+	}
+
+	quote, err := r.QuoteRepo.GetLatestQuoteForDeposit(address, assetID, at)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching quote: %v", err)
+	}
+	if quote == nil {
+		return current, nil
+	}
+
+	quoted, err := r.RateRepo.GetRateByID(quote.RateID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching quoted rate: %v", err)
+	}
+	if !at.After(quote.ExpiresAt) {
+		return quoted, nil
+	}
+
+	if QuoteExpiryPolicy() == common.QuoteExpiryPolicyLowerOf && quoted.Rate.LessThan(current.Rate) {
+		return quoted, nil
+	}
+	return current, nil
 }
 
-func (r *RateService) GetCurrentRate() (*database.Rate, error) {
-	return r.RateRepo.GetLatestRate()
+func QuoteExpiryPolicy() string {
+	if os.Getenv("QUOTE_EXPIRY_POLICY") == common.QuoteExpiryPolicyCurrent {
+		return common.QuoteExpiryPolicyCurrent
+	}
+	return common.QuoteExpiryPolicyLowerOf
 }
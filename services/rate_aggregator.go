@@ -0,0 +1,198 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/ShowBaba/kagewallet/helpers"
+	log "github.com/ShowBaba/kagewallet/logging"
+	"github.com/ShowBaba/kagewallet/repositories"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+const (
+	RateStrategyMedian   = "median"
+	RateStrategyPriority = "priority"
+)
+
+type RateAggregator struct {
+	Providers []RateProvider
+	Strategy  string
+	SpreadBps int64
+	RateRepo  *repositories.RateRepository
+	AssetRepo *repositories.AssetRepository
+}
+
+type RateRefreshResult struct {
+	AssetID string
+	Key     string
+	Mid     decimal.Decimal
+	Source  string
+	Halted  bool
+}
+
+func NewRateAggregator(providers []RateProvider, strategy string, spreadBps int64,
+	rateRepo *repositories.RateRepository, assetRepo *repositories.AssetRepository) *RateAggregator {
+	return &RateAggregator{
+		providers,
+		strategy,
+		spreadBps,
+		rateRepo,
+		assetRepo,
+	}
+}
+
+// NewRateAggregatorFromEnv wires the providers listed in RATE_PROVIDERS, in
+// priority order. It returns nil when automatic refresh is not configured.
+func NewRateAggregatorFromEnv(rateRepo *repositories.RateRepository, assetRepo *repositories.AssetRepository) (*RateAggregator, error) {
+	names := os.Getenv("RATE_PROVIDERS")
+	if strings.TrimSpace(names) == "" {
+		return nil, nil
+	}
+
+	var providers []RateProvider
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case "admin":
+			providers = append(providers, NewAdminRateProvider(rateRepo, helpers.DurationFromEnv("RATE_ADMIN_MAX_AGE", 24*time.Hour)))
+		case "http":
+			if os.Getenv("RATE_HTTP_URL") == "" {
+				return nil, fmt.Errorf("RATE_HTTP_URL is required for the http rate provider")
+			}
+			providers = append(providers, NewHTTPTickerRateProvider(
+				os.Getenv("RATE_HTTP_URL"),
+				parseKeyValueList(os.Getenv("RATE_HTTP_FIELDS")),
+				parseKeyValueList(os.Getenv("RATE_HTTP_HEADERS")),
+				helpers.DurationFromEnv("RATE_HTTP_MAX_AGE", 15*time.Minute),
+			))
+		case "file":
+			if os.Getenv("RATE_FILE_PATH") == "" {
+				return nil, fmt.Errorf("RATE_FILE_PATH is required for the file rate provider")
+			}
+			providers = append(providers, NewStaticFileRateProvider(os.Getenv("RATE_FILE_PATH"), helpers.DurationFromEnv("RATE_FILE_MAX_AGE", 24*time.Hour)))
+		default:
+			return nil, fmt.Errorf("unknown rate provider %q", name)
+		}
+	}
+
+	strategy := os.Getenv("RATE_STRATEGY")
+	if strategy == "" {
+		strategy = RateStrategyMedian
+	}
+	if strategy != RateStrategyMedian && strategy != RateStrategyPriority {
+		return nil, fmt.Errorf("unknown rate strategy %q", strategy)
+	}
+
+	spreadBps, _ := strconv.ParseInt(os.Getenv("RATE_SPREAD_BPS"), 10, 64)
+
+	return NewRateAggregator(providers, strategy, spreadBps, rateRepo, assetRepo), nil
+}
+
+func parseKeyValueList(value string) map[string]string {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		result[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	return result
+}
+
+type providerQuote struct {
+	provider string
+	quote    RateQuote
+}
+
+// Refresh polls every provider, combines the fresh quotes per active asset and
+// writes a buy and sell rate with the spread applied. Assets left without a
+// fresh quote have sells halted until a provider recovers.
+func (r *RateAggregator) Refresh() ([]RateRefreshResult, error) {
+	assets, err := r.AssetRepo.GetActiveAssets()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching active assets: %v", err)
+	}
+
+	quotes := make(map[string][]providerQuote)
+	for _, provider := range r.Providers {
+		providerQuotes, err := provider.FetchRates()
+		if err != nil {
+			log.Error("error fetching rates from provider", zap.String("provider", provider.Name()), zap.Error(err))
+			continue
+		}
+		for _, quote := range providerQuotes {
+			if !quote.Rate.IsPositive() || time.Since(quote.FetchedAt) > provider.MaxAge() {
+				continue
+			}
+			quotes[quote.Key] = append(quotes[quote.Key], providerQuote{provider.Name(), quote})
+		}
+	}
+
+	results := make([]RateRefreshResult, 0, len(assets))
+	for _, asset := range assets {
+		if asset.ID.String() == common.NairaAssetID {
+			continue
+		}
+		key := rateQuoteKey(asset.Symbol, asset.Standard)
+		candidates := quotes[key]
+		if len(candidates) == 0 {
+			candidates = quotes[rateQuoteKey(asset.Symbol, "")]
+		}
+
+		result := RateRefreshResult{AssetID: asset.ID.String(), Key: key}
+		if len(candidates) == 0 {
+			result.Halted = true
+			if err := database.HSet(common.RedisSellHaltedKey, asset.ID.String(), "no fresh rate from any provider"); err != nil {
+				log.Error("error halting sells", zap.String("asset_id", asset.ID.String()), zap.Error(err))
+			}
+			log.Warn("sells halted, every rate provider is stale", zap.String("asset", key))
+			results = append(results, result)
+			continue
+		}
+
+		result.Mid, result.Source = r.combine(candidates)
+		spread := result.Mid.Mul(decimal.NewFromInt(r.SpreadBps)).Div(decimal.NewFromInt(20000))
+		if err := r.RateRepo.AddNewRate(asset.ID, common.RateSideSell, result.Mid.Sub(spread), result.Source); err != nil {
+			return results, fmt.Errorf("error writing sell rate for %s: %v", key, err)
+		}
+		if err := r.RateRepo.AddNewRate(asset.ID, common.RateSideBuy, result.Mid.Add(spread), result.Source); err != nil {
+			return results, fmt.Errorf("error writing buy rate for %s: %v", key, err)
+		}
+		if err := database.HDel(common.RedisSellHaltedKey, asset.ID.String()); err != nil {
+			log.Error("error resuming sells", zap.String("asset_id", asset.ID.String()), zap.Error(err))
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (r *RateAggregator) combine(candidates []providerQuote) (decimal.Decimal, string) {
+	if r.Strategy == RateStrategyPriority {
+		return candidates[0].quote.Rate, fmt.Sprintf("%s(%s)", RateStrategyPriority, candidates[0].provider)
+	}
+
+	var (
+		rates = make([]decimal.Decimal, len(candidates))
+		names = make([]string, len(candidates))
+	)
+	for i, candidate := range candidates {
+		rates[i] = candidate.quote.Rate
+		names[i] = candidate.provider
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i].LessThan(rates[j]) })
+
+	mid := rates[len(rates)/2]
+	if len(rates)%2 == 0 {
+		mid = rates[len(rates)/2-1].Add(rates[len(rates)/2]).Div(decimal.NewFromInt(2))
+	}
+	return mid, fmt.Sprintf("%s(%s)", RateStrategyMedian, strings.Join(names, ","))
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/repositories"
+	"github.com/shopspring/decimal"
+)
+
+// RateQuote is a mid-market Naira price for one unit of an asset. Key is
+// either a bare symbol ("USDT") or a symbol and standard ("USDT:TRC20").
+type RateQuote struct {
+	Key       string
+	Rate      decimal.Decimal
+	FetchedAt time.Time
+}
+
+type RateProvider interface {
+	Name() string
+	MaxAge() time.Duration
+	FetchRates() ([]RateQuote, error)
+}
+
+func rateQuoteKey(symbol, standard string) string {
+	if standard == "" {
+		return strings.ToUpper(symbol)
+	}
+	return fmt.Sprintf("%s:%s", strings.ToUpper(symbol), strings.ToUpper(standard))
+}
+
+// AdminRateProvider feeds the rates admins post through /api/admin/create_rate
+// back into the aggregator.
+type AdminRateProvider struct {
+	RateRepo *repositories.RateRepository
+	maxAge   time.Duration
+}
+
+func NewAdminRateProvider(rateRepo *repositories.RateRepository, maxAge time.Duration) *AdminRateProvider {
+	return &AdminRateProvider{rateRepo, maxAge}
+}
+
+func (a *AdminRateProvider) Name() string { return "admin" }
+
+func (a *AdminRateProvider) MaxAge() time.Duration { return a.maxAge }
+
+func (a *AdminRateProvider) FetchRates() ([]RateQuote, error) {
+	rates, err := a.RateRepo.GetLatestRatesBySource(common.RateSourceAdmin, common.RateSideSell)
+	if err != nil {
+		return nil, err
+	}
+	quotes := make([]RateQuote, 0, len(rates))
+	for _, rate := range rates {
+		quotes = append(quotes, RateQuote{
+			Key:       rateQuoteKey(rate.Symbol, rate.Standard),
+			Rate:      rate.Rate,
+			FetchedAt: rate.CreatedAt,
+		})
+	}
+	return quotes, nil
+}
+
+// HTTPTickerRateProvider reads rates from any JSON ticker endpoint. Fields maps
+// a quote key to a dotted path in the response, e.g. "USDT" -> "data.usdt.ngn".
+type HTTPTickerRateProvider struct {
+	URL        string
+	Fields     map[string]string
+	Headers    map[string]string
+	HTTPClient *http.Client
+	maxAge     time.Duration
+}
+
+func NewHTTPTickerRateProvider(url string, fields, headers map[string]string, maxAge time.Duration) *HTTPTickerRateProvider {
+	return &HTTPTickerRateProvider{
+		URL:        url,
+		Fields:     fields,
+		Headers:    headers,
+		HTTPClient: &http.Client{Timeout: 15 * time.Second},
+		maxAge:     maxAge,
+	}
+}
+
+func (h *HTTPTickerRateProvider) Name() string { return "http" }
+
+func (h *HTTPTickerRateProvider) MaxAge() time.Duration { return h.maxAge }
+
+func (h *HTTPTickerRateProvider) FetchRates() ([]RateQuote, error) {
+	req, err := http.NewRequest("GET", h.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for key, value := range h.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := h.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ticker returned %s", resp.Status)
+	}
+
+	var payload interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("error unmarshalling ticker response: %v", err)
+	}
+
+	var (
+		now    = time.Now()
+		quotes = make([]RateQuote, 0, len(h.Fields))
+	)
+	for key, path := range h.Fields {
+		rate, err := lookupJSONNumber(payload, path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s from ticker: %v", path, err)
+		}
+		quotes = append(quotes, RateQuote{Key: strings.ToUpper(key), Rate: rate, FetchedAt: now})
+	}
+	return quotes, nil
+}
+
+// lookupJSONNumber reads the number at path from a payload decoded with
+// UseNumber, so the rate never passes through float64.
+func lookupJSONNumber(payload interface{}, path string) (decimal.Decimal, error) {
+	current := payload
+	for _, part := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return decimal.Zero, fmt.Errorf("%s is not an object", part)
+		}
+		current, ok = object[part]
+		if !ok {
+			return decimal.Zero, fmt.Errorf("%s not found", part)
+		}
+	}
+	switch value := current.(type) {
+	case json.Number:
+		return decimal.NewFromString(value.String())
+	case string:
+		return decimal.NewFromString(value)
+	default:
+		return decimal.Zero, fmt.Errorf("unexpected value type %T", current)
+	}
+}
+
+// StaticFileRateProvider reads a JSON object of quote keys to rates from disk.
+// Quotes are as fresh as the file's modification time.
+type StaticFileRateProvider struct {
+	Path   string
+	maxAge time.Duration
+}
+
+func NewStaticFileRateProvider(path string, maxAge time.Duration) *StaticFileRateProvider {
+	return &StaticFileRateProvider{path, maxAge}
+}
+
+func (s *StaticFileRateProvider) Name() string { return "file" }
+
+func (s *StaticFileRateProvider) MaxAge() time.Duration { return s.maxAge }
+
+func (s *StaticFileRateProvider) FetchRates() ([]RateQuote, error) {
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates map[string]decimal.Decimal
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("error unmarshalling rate file: %v", err)
+	}
+
+	quotes := make([]RateQuote, 0, len(rates))
+	for key, rate := range rates {
+		quotes = append(quotes, RateQuote{Key: strings.ToUpper(key), Rate: rate, FetchedAt: info.ModTime()})
+	}
+	return quotes, nil
+}
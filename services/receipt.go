@@ -0,0 +1,144 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Receipt is a transaction receipt laid out as labelled rows.
+type Receipt struct {
+	Title  string
+	Rows   []ReceiptRow
+	Footer string
+}
+
+type ReceiptRow struct {
+	Label string
+	Value string
+}
+
+const (
+	receiptPageWidth  = 595
+	receiptPageHeight = 842
+	receiptMargin     = 50
+	receiptValueX     = 200
+	receiptLineHeight = 18
+	// receiptWrapAt keeps long values such as hashes and links inside the
+	// page at the 10pt size values are set in.
+	receiptWrapAt = 60
+)
+
+// RenderReceiptPDF draws a receipt on a single A4 page. It uses the PDF
+// standard Helvetica fonts so no font files are embedded; characters they
+// cannot show are replaced, and the Naira sign is written as NGN.
+func RenderReceiptPDF(receipt Receipt) []byte {
+	var content bytes.Buffer
+	y := receiptPageHeight - receiptMargin - 10
+
+	fmt.Fprintf(&content, "BT /F2 18 Tf %d %d Td (%s) Tj ET\n", receiptMargin, y, pdfText(receipt.Title))
+	y -= 14
+	fmt.Fprintf(&content, "0.8 G %d %d m %d %d l S 0 G\n", receiptMargin, y, receiptPageWidth-receiptMargin, y)
+	y -= 24
+
+	for _, row := range receipt.Rows {
+		lines := wrapText(row.Value, receiptWrapAt)
+		fmt.Fprintf(&content, "BT /F2 10 Tf %d %d Td (%s) Tj ET\n", receiptMargin, y, pdfText(row.Label))
+		for _, line := range lines {
+			fmt.Fprintf(&content, "BT /F1 10 Tf %d %d Td (%s) Tj ET\n", receiptValueX, y, pdfText(line))
+			y -= receiptLineHeight
+		}
+		if y < receiptMargin+40 {
+			break
+		}
+	}
+
+	if receipt.Footer != "" {
+		fmt.Fprintf(&content, "0.8 G %d %d m %d %d l S 0 G\n", receiptMargin, receiptMargin+24, receiptPageWidth-receiptMargin, receiptMargin+24)
+		fmt.Fprintf(&content, "BT /F1 8 Tf 0.4 g %d %d Td (%s) Tj ET\n", receiptMargin, receiptMargin+10, pdfText(receipt.Footer))
+	}
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] "+
+			"/Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> /Contents 6 0 R >>", receiptPageWidth, receiptPageHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+	}
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = pdf.Len()
+		fmt.Fprintf(&pdf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := pdf.Len()
+	fmt.Fprintf(&pdf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&pdf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&pdf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return pdf.Bytes()
+}
+
+// pdfText escapes a string for a PDF literal in WinAnsi encoding.
+func pdfText(text string) string {
+	text = strings.ReplaceAll(text, "₦", "NGN ")
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '•':
+			b.WriteString(`\225`)
+		case r == '–':
+			b.WriteString(`\226`)
+		case r == '—':
+			b.WriteString(`\227`)
+		case r >= 32 && r < 127:
+			b.WriteRune(r)
+		case r >= 160 && r <= 255:
+			fmt.Fprintf(&b, `\%03o`, r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// wrapText splits text into lines of at most width characters, breaking at
+// spaces where it can and inside long words such as hashes where it cannot.
+func wrapText(text string, width int) []string {
+	var (
+		lines []string
+		line  string
+	)
+	for _, word := range strings.Fields(text) {
+		for len([]rune(word)) > width {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			runes := []rune(word)
+			lines = append(lines, string(runes[:width]))
+			word = string(runes[width:])
+		}
+		switch {
+		case line == "":
+			line = word
+		case len([]rune(line))+1+len([]rune(word)) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func testReceipt() Receipt {
+	return Receipt{
+		Title: "Withdrawal (Receipt)",
+		Rows: []ReceiptRow{
+			{Label: "Amount", Value: "₦10,000.00"},
+			{Label: "Note", Value: `paid (in full) to C:\payouts`},
+			{Label: "Hash", Value: "0x8f1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d3e4f"},
+		},
+		Footer: "KageWallet – thank you",
+	}
+}
+
+func TestRenderReceiptPDFStructure(t *testing.T) {
+	pdf := RenderReceiptPDF(testReceipt())
+
+	if !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+		t.Fatalf("missing %%PDF- header: %q", pdf[:min(len(pdf), 16)])
+	}
+	if !bytes.HasSuffix(bytes.TrimRight(pdf, "\r\n"), []byte("%%EOF")) {
+		t.Fatalf("missing %%%%EOF trailer: %q", pdf[max(0, len(pdf)-16):])
+	}
+
+	// startxref gives the offset of the xref table.
+	match := regexp.MustCompile(`startxref\n(\d+)\n%%EOF`).FindSubmatch(pdf)
+	if match == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(match[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d points at %q, not the xref table", xref, pdf[xref:min(len(pdf), xref+10)])
+	}
+
+	// Every in-use entry must point at the start of its object.
+	header := regexp.MustCompile(`^xref\n0 (\d+)\n0000000000 65535 f \n`).FindSubmatch(pdf[xref:])
+	if header == nil {
+		t.Fatalf("malformed xref table: %q", pdf[xref:min(len(pdf), xref+40)])
+	}
+	size, _ := strconv.Atoi(string(header[1]))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n \n`).FindAllSubmatch(pdf[xref:], -1)
+	if len(entries) != size-1 {
+		t.Fatalf("%d xref entries, want %d", len(entries), size-1)
+	}
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		want := fmt.Sprintf("%d 0 obj\n", i+1)
+		if offset >= len(pdf) || !bytes.HasPrefix(pdf[offset:], []byte(want)) {
+			t.Errorf("object %d: xref offset %d does not point at %q", i+1, offset, want)
+		}
+	}
+	if !bytes.Contains(pdf, []byte(fmt.Sprintf("/Size %d /Root 1 0 R", size))) {
+		t.Errorf("trailer does not declare /Size %d", size)
+	}
+
+	// The content stream's declared length must match its bytes.
+	stream := regexp.MustCompile(`(?s)<< /Length (\d+) >>\nstream\n(.*?)endstream`).FindSubmatch(pdf)
+	if stream == nil {
+		t.Fatal("missing content stream")
+	}
+	if length, _ := strconv.Atoi(string(stream[1])); length != len(stream[2]) {
+		t.Errorf("stream /Length %d, but it holds %d bytes", length, len(stream[2]))
+	}
+}
+
+func TestRenderReceiptPDFEscapesText(t *testing.T) {
+	pdf := RenderReceiptPDF(testReceipt())
+	for _, want := range []string{
+		`(Withdrawal \(Receipt\)) Tj`,
+		`(paid \(in full\) to C:\\payouts) Tj`,
+		`(NGN 10,000.00) Tj`,
+		`(KageWallet \226 thank you) Tj`,
+	} {
+		if !bytes.Contains(pdf, []byte(want)) {
+			t.Errorf("content stream is missing %s", want)
+		}
+	}
+}
+
+func TestPDFText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"(a)", `\(a\)`},
+		{`C:\path`, `C:\\path`},
+		{`\(`, `\\\(`},
+		{"₦500", "NGN 500"},
+		{"café", `caf\351`},
+		{"• – —", `\225 \226 \227`},
+		{"tab\there", "tab?here"},
+		{"🚀", "?"},
+	}
+	for _, tt := range tests {
+		if got := pdfText(tt.in); got != tt.want {
+			t.Errorf("pdfText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -1,22 +1,75 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ShowBaba/kagewallet/common"
 	"github.com/ShowBaba/kagewallet/database"
 	"github.com/ShowBaba/kagewallet/repositories"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
 )
 
+var ErrTransactionNotFound = errors.New("transaction not found")
+
 type TransactionService struct {
 	UserRepo        *repositories.UserRepository
 	TransactionRepo *repositories.TransactionRepository
+	AssetRepo       *repositories.AssetRepository
+	NetworkRepo     *repositories.NetworkRepository
+	RateRepo        *repositories.RateRepository
+	WithdrawalRepo  *repositories.WithdrawalRepository
+	ConversionRepo  *repositories.ConversionRepository
+	FeeRepo         *repositories.FeeRepository
 }
 
-func NewTransactionService(userRepo *repositories.UserRepository, transactionRepo *repositories.TransactionRepository) *TransactionService {
+func NewTransactionService(userRepo *repositories.UserRepository, transactionRepo *repositories.TransactionRepository,
+	assetRepo *repositories.AssetRepository, networkRepo *repositories.NetworkRepository,
+	rateRepo *repositories.RateRepository, withdrawalRepo *repositories.WithdrawalRepository,
+	conversionRepo *repositories.ConversionRepository, feeRepo *repositories.FeeRepository) *TransactionService {
 	return &TransactionService{
 		userRepo,
 		transactionRepo,
+		assetRepo,
+		networkRepo,
+		rateRepo,
+		withdrawalRepo,
+		conversionRepo,
+		feeRepo,
 	}
 }
 
+// TransactionDetail is everything shown about one transaction: the asset and
+// network it moved on, the rate and fees it was priced with, where a
+// withdrawal was paid, what a conversion bought and its status timeline.
+type TransactionDetail struct {
+	Transaction *database.Transaction
+	Asset       *database.Asset
+	Network     *database.Network
+	// ExplorerURL links the hash on a block explorer. It is empty for
+	// transactions that did not happen on chain.
+	ExplorerURL string
+	// Rate is the Naira rate of the asset the transaction was priced at, or
+	// zero when it has none.
+	Rate       decimal.Decimal
+	Fees       []DetailFee
+	Withdrawal *database.Withdrawal
+	Conversion *database.Conversion
+	ToAsset    *database.Asset
+	Events     []database.TransactionEvent
+}
+
+// DetailFee is a fee charged on a transaction in the asset it was charged in.
+type DetailFee struct {
+	Operation string
+	AssetID   uuid.UUID
+	Amount    decimal.Decimal
+	Symbol    string
+}
+
 func (t *TransactionService) FetchUserTransactions(userID string, limit, offset int) ([]database.TransactionWithAsset, error) {
 	return t.TransactionRepo.GetTransactionsByUser(userID, limit, offset)
 
@@ -25,3 +78,107 @@ func (t *TransactionService) FetchUserTransactions(userID string, limit, offset
 func (t *TransactionService) FetchUserTransactionCount(userID string) (int, error) {
 	return t.TransactionRepo.GetUserTransactionCount(userID)
 }
+
+// GetTransactionDetail loads one of the user's transactions with everything
+// its detail view and receipt show. Another user's transaction is reported
+// as not found.
+func (t *TransactionService) GetTransactionDetail(userID, transactionID uuid.UUID) (*TransactionDetail, error) {
+	transaction, err := t.TransactionRepo.GetTransactionByID(transactionID.String())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTransactionNotFound
+		}
+		return nil, fmt.Errorf("error fetching transaction: %v", err)
+	}
+	if transaction.UserID != userID {
+		return nil, ErrTransactionNotFound
+	}
+
+	detail := &TransactionDetail{Transaction: transaction}
+	if detail.Asset, err = t.AssetRepo.FindAssetByID(transaction.AssetID.String()); err != nil {
+		return nil, fmt.Errorf("error fetching asset: %v", err)
+	}
+	if detail.Asset.NetworkID != nil {
+		if detail.Network, err = t.NetworkRepo.FindByID(*detail.Asset.NetworkID); err != nil {
+			return nil, fmt.Errorf("error fetching network: %v", err)
+		}
+	}
+	if transaction.RateID != uuid.Nil {
+		rate, err := t.RateRepo.GetRateByID(transaction.RateID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("error fetching rate: %v", err)
+		}
+		if rate != nil {
+			detail.Rate = rate.Rate
+		}
+	}
+
+	switch transaction.Type {
+	case "withdrawal":
+		if detail.Withdrawal, err = t.WithdrawalRepo.GetWithdrawalByTransactionID(transaction.ID); err != nil {
+			return nil, fmt.Errorf("error fetching withdrawal: %v", err)
+		}
+	case common.TransactionTypeConvert:
+		if detail.Conversion, err = t.ConversionRepo.GetConversionByTransactionID(transaction.ID); err != nil {
+			return nil, fmt.Errorf("error fetching conversion: %v", err)
+		}
+		if detail.ToAsset, err = t.AssetRepo.FindAssetByID(detail.Conversion.ToAssetID.String()); err != nil {
+			return nil, fmt.Errorf("error fetching asset: %v", err)
+		}
+	default:
+		if detail.Network != nil {
+			detail.ExplorerURL = ExplorerURL(detail.Network, transaction.Hash)
+		}
+	}
+
+	if detail.Fees, err = t.detailFees(detail); err != nil {
+		return nil, err
+	}
+	if detail.Events, err = t.TransactionRepo.GetTransactionEvents(transaction.ID); err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
+
+// detailFees lists the fees recorded on a transaction. Withdrawals and
+// conversions from before fees were recorded fall back to the fee saved on
+// them.
+func (t *TransactionService) detailFees(detail *TransactionDetail) ([]DetailFee, error) {
+	recorded, err := t.FeeRepo.GetTransactionFees(detail.Transaction.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var fees []DetailFee
+	for _, fee := range recorded {
+		symbol := detail.Asset.Symbol
+		if fee.AssetID != detail.Asset.ID {
+			asset, err := t.AssetRepo.FindAssetByID(fee.AssetID.String())
+			if err != nil {
+				return nil, fmt.Errorf("error fetching asset: %v", err)
+			}
+			symbol = asset.Symbol
+		}
+		fees = append(fees, DetailFee{Operation: fee.Operation, AssetID: fee.AssetID, Amount: fee.Amount, Symbol: symbol})
+	}
+	if len(fees) > 0 {
+		return fees, nil
+	}
+
+	switch {
+	case detail.Withdrawal != nil && detail.Withdrawal.Fee.IsPositive():
+		fees = append(fees, DetailFee{Operation: common.FeeOperationWithdrawal, AssetID: detail.Asset.ID, Amount: detail.Withdrawal.Fee, Symbol: detail.Asset.Symbol})
+	case detail.Conversion != nil && detail.Conversion.Fee.IsPositive():
+		fees = append(fees, DetailFee{Operation: common.FeeOperationConvert, AssetID: detail.Asset.ID, Amount: detail.Conversion.Fee, Symbol: detail.Asset.Symbol})
+	}
+	return fees, nil
+}
+
+// ExplorerURL fills a transaction hash into the network's explorer link. It
+// is empty when the network has no explorer or there is no hash.
+func ExplorerURL(network *database.Network, hash string) string {
+	if network.ExplorerURL == "" || hash == "" {
+		return ""
+	}
+	return strings.ReplaceAll(network.ExplorerURL, "{hash}", hash)
+}
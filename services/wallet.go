@@ -1,24 +1,38 @@
 package services
 
 import (
+	"fmt"
+
+	"github.com/ShowBaba/kagewallet/common"
 	"github.com/ShowBaba/kagewallet/database"
 	"github.com/ShowBaba/kagewallet/repositories"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 type WalletService struct {
-	WalletRepo *repositories.WalletRepository
-	AssetRepo  *repositories.AssetRepository
+	WalletRepo  *repositories.WalletRepository
+	AssetRepo   *repositories.AssetRepository
+	RateService *RateService
 }
 
 func NewWalletService(walletRepo *repositories.WalletRepository,
-	assetRepo *repositories.AssetRepository) *WalletService {
+	assetRepo *repositories.AssetRepository, rateService *RateService) *WalletService {
 	return &WalletService{
 		walletRepo,
 		assetRepo,
+		rateService,
 	}
 }
 
+// WalletBalance is a balance with what it is worth in Naira at the current
+// sell rate. Priced is false for crypto without a sell rate.
+type WalletBalance struct {
+	database.AssetBalance
+	NairaValue decimal.Decimal
+	Priced     bool
+}
+
 func (w *WalletService) GetUserWalletsData(userId string) (*database.WalletWithDetails, error) {
 	wallet, err := w.WalletRepo.GetWalletsByUser(uuid.MustParse(userId))
 	if err != nil {
@@ -26,3 +40,32 @@ func (w *WalletService) GetUserWalletsData(userId string) (*database.WalletWithD
 	}
 	return wallet, nil
 }
+
+// GetBalances lists every asset the user holds, Naira first, valued in
+// Naira.
+func (w *WalletService) GetBalances(userID uuid.UUID) ([]WalletBalance, error) {
+	balances, err := w.WalletRepo.GetAssetBalances(userID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching balances: %v", err)
+	}
+	rates, err := w.RateService.GetActiveAssetRates(common.RateSideSell)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching rates: %v", err)
+	}
+	sellRates := make(map[uuid.UUID]decimal.Decimal, len(rates))
+	for _, rate := range rates {
+		sellRates[rate.AssetID] = rate.Rate
+	}
+
+	var values []WalletBalance
+	for _, balance := range balances {
+		value := WalletBalance{AssetBalance: balance}
+		if balance.AssetID.String() == common.NairaAssetID {
+			value.NairaValue, value.Priced = balance.Balance, true
+		} else if rate, ok := sellRates[balance.AssetID]; ok {
+			value.NairaValue, value.Priced = balance.Balance.Mul(rate).Round(2), true
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 
@@ -21,221 +20,325 @@ import (
 )
 
 type WebhookService struct {
-	AddressRepo     *repositories.AddressRepository
-	TransactionRepo *repositories.TransactionRepository
-	WalletRepo      *repositories.WalletRepository
-	AssetRepo       *repositories.AssetRepository
-	WithdrawalRepo  *repositories.WithdrawalRepository
-	RateService     *RateService
+	AddressRepo       *repositories.AddressRepository
+	UserRepo          *repositories.UserRepository
+	TransactionRepo   *repositories.TransactionRepository
+	WalletRepo        *repositories.WalletRepository
+	AssetRepo         *repositories.AssetRepository
+	WithdrawalRepo    *repositories.WithdrawalRepository
+	RateService       *RateService
+	WithdrawalService *WithdrawalService
+	CustodyService    *CustodyService
+	FeeService        *FeeService
 }
 
 func NewWebhookService(addressRepo *repositories.AddressRepository,
+	userRepo *repositories.UserRepository,
 	transactionRepo *repositories.TransactionRepository,
 	walletRepo *repositories.WalletRepository,
 	assetRepo *repositories.AssetRepository,
 	withdrawalRepo *repositories.WithdrawalRepository,
-	rateService *RateService) *WebhookService {
+	rateService *RateService,
+	withdrawalService *WithdrawalService,
+	custodyService *CustodyService,
+	feeService *FeeService) *WebhookService {
 	return &WebhookService{
 		addressRepo,
+		userRepo,
 		transactionRepo,
 		walletRepo,
 		assetRepo,
 		withdrawalRepo,
 		rateService,
+		withdrawalService,
+		custodyService,
+		feeService,
 	}
 }
 
-func (w *WebhookService) BlockradarWebhook(payload common.BlockradarEvent) error {
-	userData := make(map[string]string)
-	m, err := json.Marshal(payload.Data.Address.Metadata)
-	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %v", err)
+// CustodyWebhook credits a verified deposit event to the user and asset the
+// address was generated for.
+func (w *WebhookService) CustodyWebhook(event *CustodyEvent) error {
+	userID := event.Metadata["user_id"]
+	assetID := event.Metadata["asset_id"]
+	if userID == "" || assetID == "" {
+		return fmt.Errorf("missing user_id or asset_id in webhook metadata")
 	}
 
-	err = json.Unmarshal(m, &userData)
-	if err != nil {
-		return fmt.Errorf("failed to unmarshal webhook metadata: %v", err)
+	if event.Address != event.Deposit.RecipientAddress {
+		log.Error("address mismatch in webhook")
+		return nil
 	}
 
-	userID := userData["user_id"]
-	assetID := userData["asset_id"]
-	if userID == "" || assetID == "" {
-		return fmt.Errorf("missing user_id or asset_id in webhook metadata")
-	}
+	_, err := w.ProcessDeposit(userID, assetID, event.Deposit)
+	return err
+}
 
-	addressData, err := w.AddressRepo.GetAddressByUserAndAsset(userID, assetID, payload.Data.Address.Address)
+// ProcessDeposit records a deposit to one of our addresses and credits the
+// user once it is successful. It is shared by the custody webhook and the
+// deposit reconciler, so it must stay safe to call repeatedly for one hash.
+func (w *WebhookService) ProcessDeposit(userID, assetID string, deposit common.Deposit) (string, error) {
+	addressData, err := w.AddressRepo.GetAddressByUserAndAsset(userID, assetID, deposit.RecipientAddress)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil
+			return common.DepositOutcomeSkipped, nil
 		}
-		return fmt.Errorf("error fetching address data: %v", err)
+		return "", fmt.Errorf("error fetching address data: %v", err)
 	}
 
-	if addressData == nil || addressData.Address != payload.Data.RecipientAddress {
-		log.Error("address mismatch or data not found in webhook")
-		return nil
+	if addressData == nil || addressData.Address != deposit.RecipientAddress {
+		log.Error("address mismatch or data not found for deposit", zap.String("hash", deposit.Hash))
+		return common.DepositOutcomeSkipped, nil
 	}
 
-	existingTransactions, err := w.TransactionRepo.GetTransactionByColumn("hash", payload.Data.Hash)
-	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		return fmt.Errorf("error checking existing transaction: %v", err)
+	assetData, err := w.AssetRepo.FindAssetByID(assetID)
+	if err != nil {
+		return "", fmt.Errorf("error fetching asset: %v", err)
 	}
 
-	var status string
-	switch payload.Data.Status {
-	case "SUCCESS":
-		status = "completed"
-	default:
-		status = "failed"
+	coinAmount, err := decimal.NewFromString(deposit.Amount)
+	if err != nil {
+		return "", fmt.Errorf("invalid amount in deposit: %v", err)
 	}
 
-	coinAmount, err := strconv.ParseFloat(payload.Data.Amount, 64)
+	// The user's preference and tier are read each time the deposit is
+	// seen, and the ones in place when it completes are the ones applied.
+	user, err := w.UserRepo.FindOneByID(userID)
 	if err != nil {
-		return fmt.Errorf("invalid amount in webhook: %v", err)
+		return "", fmt.Errorf("error fetching user: %v", err)
 	}
 
-	rate, err := w.RateService.GetCurrentRate()
+	seenAt := deposit.CreatedAt
+	if seenAt.IsZero() {
+		seenAt = time.Now()
+	}
+	rate, err := w.RateService.GetDepositRate(uuid.MustParse(assetID), deposit.RecipientAddress, seenAt)
 	if err != nil {
 		log.Error("error fetching rate", zap.Error(err))
-		return fmt.Errorf("error processing webhook: %v", err)
+		return "", fmt.Errorf("error processing deposit: %v", err)
 	}
 
-	amount := coinAmount * rate.Rate
-
-	hash := payload.Data.Hash
-
-	// TODO: if the transaction fail, alert admin
-	if len(existingTransactions) > 0 {
-		if existingTransactions[0].Status != payload.Data.Status {
-			err := w.TransactionRepo.UpdateTransactionStatus(existingTransactions[0].ID.String(), status)
-			if err != nil {
-				return fmt.Errorf("error updating transaction status: %v", err)
-			}
-		}
-	} else {
-
-		var amountUSD float64
-		fmt.Println("payload.Data.Currency; ", payload.Data.Currency)
-		if payload.Data.Currency == "USD" {
-			amountUSD, err = strconv.ParseFloat(payload.Data.AmountPaid, 64)
-			if err != nil {
-				log.Error("error converting amount", zap.Error(err))
-				return fmt.Errorf("error processing webhook: %v", err)
-			}
+	// The deposit fee is taken in the asset. A sold deposit also pays the
+	// sell fee, in Naira, on what the rest is sold for.
+	depositFee, err := w.FeeService.Quote(common.FeeOperationDeposit, assetData.ID, user.Tier, coinAmount, assetDecimals(assetData))
+	if err != nil {
+		return "", fmt.Errorf("error pricing deposit fee: %v", err)
+	}
+	var (
+		nairaRate     = rate.Rate
+		sellFee       Fee
+		creditAssetID = assetData.ID
+		creditAmount  = coinAmount.Sub(depositFee.Amount)
+		creditFee     = depositFee.Amount
+	)
+	sell, sellHalted := w.sellsDeposit(user, assetID, deposit.Hash)
+	if sell {
+		sellFee, creditAmount, creditFee, err = w.sellDeposit(assetData.ID, user.Tier, coinAmount, creditAmount, nairaRate)
+		if err != nil {
+			return "", err
 		}
-		err = w.WalletRepo.HandleTransactionAndUpdateBalance(userID, &database.Transaction{
-			ID:              uuid.New(),
-			UserID:          uuid.MustParse(userID),
-			AssetID:         uuid.MustParse(assetID),
-			Type:            strings.ToLower(payload.Data.Type),
-			Amount:          decimal.NewFromFloat(coinAmount),
-			Status:          status,
-			Reference:       helpers.GenerateTransactionReference(),
-			SourceReference: payload.Data.Reference,
-			Hash:            hash,
-			RateID:          rate.ID,
-			Confirmations:   int64(payload.Data.Confirmations),
-			AmountUSD:       amountUSD,
-			Source:          "Blockradar",
-		}, amount)
+		creditAssetID = uuid.MustParse(common.NairaAssetID)
+	}
 
+	status, err := w.depositStatus(deposit, assetData, coinAmount, creditAmount)
+	if err != nil {
+		return "", err
+	}
+
+	amountUSD := decimal.Zero
+	if deposit.Currency == "USD" {
+		amountUSD, err = decimal.NewFromString(deposit.AmountPaid)
 		if err != nil {
-			return fmt.Errorf("error handling transaction and updating wallet balance: %v", err)
+			log.Error("error converting amount", zap.Error(err))
+			return "", fmt.Errorf("error processing deposit: %v", err)
 		}
-
-		log.Info("transaction processed successfully", zap.String("transaction_id", payload.Data.Reference))
 	}
 
-	assetData, err := w.AssetRepo.FindAssetByID(assetID)
+	// TODO: if the transaction fail, alert admin
+	outcome, err := w.WalletRepo.RecordDeposit(&database.Transaction{
+		ID:              uuid.New(),
+		UserID:          uuid.MustParse(userID),
+		AssetID:         uuid.MustParse(assetID),
+		Type:            strings.ToLower(deposit.Type),
+		Amount:          coinAmount,
+		Status:          status,
+		Reference:       helpers.GenerateTransactionReference(),
+		SourceReference: deposit.Reference,
+		Hash:            deposit.Hash,
+		RateID:          rate.ID,
+		Confirmations:   int64(deposit.Confirmations),
+		AmountUSD:       amountUSD,
+		Source:          "Blockradar",
+		Fees:            FeeRecords(depositFee, sellFee),
+	}, creditAssetID, creditAmount, creditFee)
 	if err != nil {
-		return fmt.Errorf("error fetching asset: %v", err)
+		return "", fmt.Errorf("error recording deposit: %v", err)
+	}
+
+	log.Info("deposit processed", zap.String("hash", deposit.Hash), zap.String("outcome", outcome))
+
+	if outcome == common.DepositOutcomeSkipped {
+		return outcome, nil
 	}
 
-	if status == "completed" {
-		message := fmt.Sprintf(
-			"🎉 Trade Successful! 🎉\n\n"+
-				"Your trade of *%v %v* has been processed successfully. ✅\n\n"+
-				"💰 Your balance has been updated.\n\n"+
-				"🔍 Use /balance to check your updated balances. Happy trading! 🚀",
+	var message string
+	switch status {
+	case "completed":
+		if sell {
+			message = fmt.Sprintf(
+				"🎉 Trade Successful! 🎉\n\n"+
+					"Your trade of *%v %v* has been processed successfully. ✅\n\n"+
+					"💰 Your balance has been updated.\n\n"+
+					"🔍 Use /balance to check your updated balances. Happy trading! 🚀",
+				coinAmount,
+				assetData.Symbol,
+			)
+		} else {
+			message = fmt.Sprintf(
+				"🎉 Deposit Received! 🎉\n\n"+
+					"*%v %v* has been added to your %v wallet. ✅\n\n"+
+					"🔍 Use /balance to check your balances, or /convert to swap it for another crypto. 🚀",
+				creditAmount,
+				assetData.Symbol,
+				assetData.Symbol,
+			)
+		}
+		if sellHalted {
+			message += fmt.Sprintf("\n\n_Selling %v is paused right now, so it was kept as crypto instead of being converted to Naira._", assetData.Symbol)
+		}
+		if depositFee.Amount.IsPositive() {
+			message += fmt.Sprintf("\n\n_A deposit fee of %v %v was deducted._", depositFee.Amount, assetData.Symbol)
+		}
+		if sellFee.Amount.IsPositive() {
+			message += fmt.Sprintf("\n\n_A sell fee of ₦%s was deducted._", helpers.CommaFixed(sellFee.Amount, 2))
+		}
+	case common.DepositStatusBelowMinimum:
+		message = fmt.Sprintf(
+			"⚠️ Deposit Not Credited\n\n"+
+				"We received *%v %v*, which is below the minimum deposit of *%v %v* "+
+				"or does not cover its fees. It has not been added to your balance.\n\n"+
+				"Please contact support with your transaction hash:\n`%s`",
 			coinAmount,
 			assetData.Symbol,
+			assetData.MinDeposit,
+			assetData.Symbol,
+			deposit.Hash,
 		)
+	default:
+		return outcome, nil
+	}
 
-		if err = sendNotification(message, userID, hash, "telegram"); err != nil {
-			log.Error("failed to publish notification: %v", zap.Error(err))
-		}
+	if err = sendNotification(message, userID, deposit.Hash, "telegram"); err != nil {
+		log.Error("failed to publish notification: %v", zap.Error(err))
 	}
 
-	return nil
+	return outcome, nil
 }
 
-func (w *WebhookService) MonnifyWebhook(payload common.MonnifyEvent) error {
+// sellsDeposit reports whether a deposit is sold for Naira. A user who
+// auto-converts keeps the deposit as crypto while sells of the asset are
+// halted, since the only rate to sell at is stale; the second result reports
+// that this happened.
+func (w *WebhookService) sellsDeposit(user *database.User, assetID, hash string) (bool, bool) {
+	if !user.AutoConvert {
+		return false, false
+	}
+	if halted, reason := w.RateService.IsSellHalted(assetID); halted {
+		log.Warn("sells halted, keeping deposit as crypto", zap.String("hash", hash), zap.String("reason", reason))
+		return false, true
+	}
+	return true, false
+}
 
-	fmt.Println("payload; ", payload)
-	var (
-		message, userId, hash string
-	)
-	switch payload.EventType {
-	case "SUCCESSFUL_DISBURSEMENT":
-		transaction, err := w.TransactionRepo.GetTransactionBySourceReference(payload.EventData.Reference)
-		if err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return nil
-			}
-			return err
-		}
-		err = w.TransactionRepo.UpdateTransactionStatus(transaction.ID.String(), "completed")
-		if err != nil {
-			return fmt.Errorf("error updating transaction status: %v", err)
-		}
-		message = fmt.Sprintf(
-			"🎉 Success! Your withdrawal of *₦%v* has been processed. 🚀\n\n"+
-				"💰 Expect to receive your funds shortly. If you have any concerns, feel free to reach out to our support team. 🛠️",
-			transaction.Amount,
-		)
+// sellDeposit prices selling what is left of a deposit after its deposit
+// fee. It returns the sell fee, the Naira to credit and the Naira fees; the
+// credit and fees add up to the whole deposit's value in kobo.
+func (w *WebhookService) sellDeposit(assetID uuid.UUID, tier string, coinAmount, netAmount, rate decimal.Decimal) (Fee, decimal.Decimal, decimal.Decimal, error) {
+	gross, proceeds := saleProceeds(coinAmount, netAmount, rate)
+	sellFee, err := w.FeeService.QuoteNaira(common.FeeOperationSell, assetID, tier, proceeds)
+	if err != nil {
+		return sellFee, decimal.Zero, decimal.Zero, fmt.Errorf("error pricing sell fee: %v", err)
+	}
+	return sellFee, proceeds.Sub(sellFee.Amount), gross.Sub(proceeds).Add(sellFee.Amount), nil
+}
 
-		userId = transaction.UserID.String()
-		hash = transaction.Hash
-	case "FAILED_DISBURSEMENT", "REVERSED_DISBURSEMENT":
-		// TODO: notify admin
-		transaction, err := w.TransactionRepo.GetTransactionBySourceReference(payload.EventData.TransactionReference)
-		if err != nil {
-			return err
-		}
-		err = w.TransactionRepo.UpdateTransactionStatus(transaction.ID.String(), "failed")
-		if err != nil {
-			return fmt.Errorf("error updating transaction status: %v", err)
-		}
-		message = fmt.Sprintf(
-			"⚠️ Oops! Your withdrawal of *₦%v* could not be processed. 😞\n\n"+
-				"🔄 You can try again later or reach out to our support team for assistance. We're here to help! 🛠️",
-			transaction.Amount,
-		)
+// saleProceeds values a deposit and what is left of it after the deposit fee
+// in Naira, each rounded down to whole kobo. Their difference is the deposit
+// fee in Naira, so no fraction of a kobo is lost between the two.
+func saleProceeds(coinAmount, netAmount, rate decimal.Decimal) (decimal.Decimal, decimal.Decimal) {
+	return coinAmount.Mul(rate).RoundDown(common.NairaPlaces), netAmount.Mul(rate).RoundDown(common.NairaPlaces)
+}
 
-		userId = transaction.UserID.String()
-		hash = transaction.Hash
-		withdrawalData, err := w.WithdrawalRepo.GetWithdrawalByTransactionID(transaction.ID)
+// depositStatus maps the provider's status onto ours. A successful deposit
+// below the asset's minimum, or that would credit nothing after fees, is
+// never credited, and one without the confirmations its network requires
+// waits as confirming until the reconciler sees it again.
+func (w *WebhookService) depositStatus(deposit common.Deposit, asset *database.Asset, amount, credit decimal.Decimal) (string, error) {
+	switch deposit.Status {
+	case "SUCCESS":
+	case "PENDING":
+		return "pending", nil
+	default:
+		return "failed", nil
+	}
+
+	if amount.LessThan(asset.MinDeposit) || !credit.IsPositive() {
+		return common.DepositStatusBelowMinimum, nil
+	}
+
+	if asset.NetworkID != nil {
+		network, err := w.CustodyService.NetworkRepo.FindByID(*asset.NetworkID)
 		if err != nil {
-			return err
+			return "", fmt.Errorf("error fetching network: %v", err)
 		}
-		originalAmount := withdrawalData.Amount.Add(decimal.NewFromInt(int64(withdrawalData.Fee)))
-		if err := w.WalletRepo.UpdateWalletBalance(transaction.UserID, originalAmount); err != nil {
-			return err
+		if int64(deposit.Confirmations) < network.RequiredConfirmations {
+			return common.DepositStatusConfirming, nil
 		}
+	}
+	return "completed", nil
+}
 
+// PayoutWebhook settles a withdrawal from a provider's transfer webhook. Only
+// the provider the withdrawal was last sent through can settle it: one that
+// turned the transfer down before a failover has no say in its outcome.
+func (w *WebhookService) PayoutWebhook(provider string, event *PayoutEvent) error {
+	switch event.Status {
+	case common.PayoutStatusSuccess, common.PayoutStatusFailed, common.PayoutStatusReversed:
 	default:
-		return fmt.Errorf("unknown event type: %s", payload.EventType)
+		log.Info("ignoring payout webhook", zap.String("provider", provider), zap.String("event_type", event.Type))
+		return nil
 	}
 
-	if err := sendNotification(message, userId, hash, "telegram"); err != nil {
-		log.Error("failed to publish notification: %v", zap.Error(err))
+	transaction, err := w.TransactionRepo.GetTransactionBySourceReference(event.Reference)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	withdrawal, err := w.WithdrawalRepo.GetWithdrawalByTransactionID(transaction.ID)
+	if err != nil {
+		return fmt.Errorf("error fetching withdrawal: %v", err)
+	}
+	if withdrawal.Provider != provider {
+		log.Warn("ignoring payout webhook from a provider that does not hold the withdrawal",
+			zap.String("provider", provider),
+			zap.String("withdrawal_provider", withdrawal.Provider),
+			zap.String("reference", event.Reference))
+		return nil
 	}
 
-	return nil
+	if event.Status == common.PayoutStatusSuccess {
+		return w.WithdrawalService.CompleteWithdrawal(transaction)
+	}
+	return w.WithdrawalService.FailWithdrawal(transaction, event.Status == common.PayoutStatusReversed)
 }
 
 func sendNotification(message, userID, hash, channel string) error {
 	fmt.Println("sending notification:", channel)
+	if database.RedisClient == nil {
+		return errors.New("redis is not configured")
+	}
 
 	notificationJSON, err := database.HGet(common.RedisNotificationKey, hash)
 	if err != nil && !errors.Is(err, redis.Nil) {
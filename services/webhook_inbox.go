@@ -0,0 +1,128 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	log "github.com/ShowBaba/kagewallet/logging"
+	"github.com/ShowBaba/kagewallet/repositories"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultWebhookMaxAttempts = 10
+	webhookStaleAfter         = 5 * time.Minute
+	webhookMaxBackoff         = time.Hour
+)
+
+// WebhookInboxService persists raw provider webhooks before anything acts on
+// them. A worker drains the inbox through WebhookService, whose deposit and
+// withdrawal paths are idempotent, so a retried or replayed event never
+// applies its effects twice.
+type WebhookInboxService struct {
+	EventRepo      *repositories.WebhookEventRepository
+	WebhookService *WebhookService
+	MaxAttempts    int
+}
+
+func NewWebhookInboxService(eventRepo *repositories.WebhookEventRepository, webhookService *WebhookService) *WebhookInboxService {
+	maxAttempts, err := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS"))
+	if err != nil || maxAttempts <= 0 {
+		maxAttempts = defaultWebhookMaxAttempts
+	}
+	return &WebhookInboxService{
+		eventRepo,
+		webhookService,
+		maxAttempts,
+	}
+}
+
+// Receive stores an event. eventID falls back to a hash of the body when the
+// provider does not send one. It reports false for a duplicate delivery.
+func (s *WebhookInboxService) Receive(provider, eventID, eventType string, body []byte) (bool, error) {
+	if eventID == "" {
+		sum := sha256.Sum256(body)
+		eventID = hex.EncodeToString(sum[:])
+	}
+	created, err := s.EventRepo.RecordEvent(&database.WebhookEvent{
+		Provider:      provider,
+		EventID:       eventID,
+		EventType:     eventType,
+		Payload:       string(body),
+		Status:        common.WebhookEventStatusReceived,
+		NextAttemptAt: time.Now(),
+	})
+	if err != nil {
+		return false, fmt.Errorf("error recording webhook event: %v", err)
+	}
+	if !created {
+		log.Info("duplicate webhook event", zap.String("provider", provider), zap.String("event_id", eventID), zap.String("event_type", eventType))
+	}
+	return created, nil
+}
+
+// ProcessPending claims a batch of due events and runs them. Failures are
+// retried with exponential backoff until MaxAttempts, after which the event is
+// parked as dead for an admin to inspect and replay.
+func (s *WebhookInboxService) ProcessPending(limit int) (processed, failed int) {
+	events, err := s.EventRepo.ClaimEvents(limit, webhookStaleAfter)
+	if err != nil {
+		log.Error("error claiming webhook events", zap.Error(err))
+		return 0, 0
+	}
+
+	for _, event := range events {
+		if err := s.process(event); err != nil {
+			failed++
+			status := common.WebhookEventStatusFailed
+			if event.Attempts >= s.MaxAttempts {
+				status = common.WebhookEventStatusDead
+			}
+			backoff := time.Duration(math.Min(float64(webhookMaxBackoff), float64(30*time.Second)*math.Pow(2, float64(event.Attempts-1))))
+			log.Error("error processing webhook event",
+				zap.String("event_id", event.ID.String()),
+				zap.String("provider", event.Provider),
+				zap.Int("attempts", event.Attempts),
+				zap.String("status", status),
+				zap.Error(err),
+			)
+			if err := s.EventRepo.MarkFailed(event.ID, status, err.Error(), time.Now().Add(backoff)); err != nil {
+				log.Error("error marking webhook event failed", zap.String("event_id", event.ID.String()), zap.Error(err))
+			}
+			continue
+		}
+
+		processed++
+		if err := s.EventRepo.MarkProcessed(event.ID); err != nil {
+			log.Error("error marking webhook event processed", zap.String("event_id", event.ID.String()), zap.Error(err))
+		}
+	}
+	return processed, failed
+}
+
+func (s *WebhookInboxService) process(event database.WebhookEvent) error {
+	if custody, err := s.WebhookService.CustodyService.Provider(event.Provider); err == nil {
+		payload, err := custody.ParseWebhook([]byte(event.Payload))
+		if err != nil {
+			return err
+		}
+		return s.WebhookService.CustodyWebhook(payload)
+	}
+
+	provider, err := s.WebhookService.WithdrawalService.Payouts.Provider(event.Provider)
+	if err != nil {
+		return fmt.Errorf("unknown webhook provider: %s", event.Provider)
+	}
+	payload, err := provider.ParseWebhook([]byte(event.Payload))
+	if err != nil {
+		return err
+	}
+	return s.WebhookService.PayoutWebhook(event.Provider, payload)
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func isKobo(amount decimal.Decimal) bool {
+	return amount.Equal(amount.Truncate(common.NairaPlaces))
+}
+
+func TestSaleProceeds(t *testing.T) {
+	tests := []struct {
+		name         string
+		coin, net    string
+		rate         string
+		wantGross    string
+		wantProceeds string
+	}{
+		{"whole amounts", "1", "1", "1500", "1500", "1500"},
+		{"drops fractions of a kobo", "0.12345678", "0.12345678", "1587.33", "195.96", "195.96"},
+		{"deposit fee comes out of the gross", "0.5", "0.49", "1234.567", "617.28", "604.93"},
+		{"tiny deposit is worth nothing", "0.000001", "0.000001", "1500", "0", "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gross, proceeds := saleProceeds(decimal.RequireFromString(tt.coin), decimal.RequireFromString(tt.net), decimal.RequireFromString(tt.rate))
+			if !gross.Equal(decimal.RequireFromString(tt.wantGross)) {
+				t.Errorf("gross = %s, want %s", gross, tt.wantGross)
+			}
+			if !proceeds.Equal(decimal.RequireFromString(tt.wantProceeds)) {
+				t.Errorf("proceeds = %s, want %s", proceeds, tt.wantProceeds)
+			}
+		})
+	}
+}
+
+// TestKoboAmountsSumExactly follows random deposits through the deposit fee,
+// the sale, the sell fee and a withdrawal with its fee, and checks every Naira
+// amount is whole kobo and that the parts always add back up to the whole.
+func TestSellsDepositWhileSellsAreHalted(t *testing.T) {
+	const (
+		halted = "11111111-1111-1111-1111-111111111111"
+		open   = "22222222-2222-2222-2222-222222222222"
+	)
+	fakeRedis(t, map[string]map[string]string{
+		common.RedisSellHaltedKey: {halted: "rate feed stale"},
+	})
+	webhook := &WebhookService{RateService: &RateService{}}
+
+	tests := []struct {
+		name        string
+		autoConvert bool
+		assetID     string
+		wantSell    bool
+		wantHalted  bool
+	}{
+		{"auto-convert", true, open, true, false},
+		{"auto-convert while halted", true, halted, false, true},
+		{"kept as crypto", false, open, false, false},
+		{"kept as crypto while halted", false, halted, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &database.User{AutoConvert: tt.autoConvert}
+			sell, sellHalted := webhook.sellsDeposit(user, tt.assetID, "0xhash")
+			if sell != tt.wantSell || sellHalted != tt.wantHalted {
+				t.Errorf("sellsDeposit() = %v, %v, want %v, %v", sell, sellHalted, tt.wantSell, tt.wantHalted)
+			}
+		})
+	}
+}
+
+// TestKoboAmountsSumExactly sells random deposits and withdraws part of each
+// credit, checking that every Naira amount is whole kobo and that what is
+// credited, charged and sent adds up to the deposits' value.
+func TestKoboAmountsSumExactly(t *testing.T) {
+	var (
+		rng  = rand.New(rand.NewSource(1))
+		fees = &FeeService{FeeRepo: fakeFeeRepo{
+			common.FeeOperationDeposit: {Kind: common.FeeKindPercentage, RateBPS: 50},
+			common.FeeOperationSell: {
+				Kind:   common.FeeKindTiered,
+				MinFee: decimal.NewFromInt(10),
+				Bands: []database.FeeBand{
+					{UpTo: decimal.NewFromInt(100000), RateBPS: 100},
+					{Flat: decimal.RequireFromString("25.5"), RateBPS: 33},
+				},
+			},
+			common.FeeOperationWithdrawal: {Kind: common.FeeKindPercentage, Flat: decimal.RequireFromString("10.05"), RateBPS: 15, MaxFee: decimal.NewFromInt(2000)},
+		}}
+		webhook    = &WebhookService{FeeService: fees}
+		withdrawal = &WithdrawalService{FeeService: fees}
+		user       = &database.User{}
+		assetID    = uuid.New()
+
+		gross, credited, charged, sent decimal.Decimal
+	)
+
+	for i := 0; i < 5000; i++ {
+		var (
+			coin = decimal.New(rng.Int63n(1_000_000_000)+1, -8)
+			rate = decimal.New(rng.Int63n(200_000_000)+1, -int32(rng.Intn(5)))
+		)
+		depositFee, err := fees.Quote(common.FeeOperationDeposit, assetID, user.Tier, coin, 8)
+		if err != nil {
+			t.Fatalf("Quote() error = %v", err)
+		}
+		_, credit, sellFees, err := webhook.sellDeposit(assetID, user.Tier, coin, coin.Sub(depositFee.Amount), rate)
+		if err != nil {
+			t.Fatalf("sellDeposit() error = %v", err)
+		}
+		if !isKobo(credit) || !isKobo(sellFees) {
+			t.Fatalf("selling %s at %s credits %s and charges %s, not whole kobo", coin, rate, credit, sellFees)
+		}
+		gross = gross.Add(coin.Mul(rate).RoundDown(common.NairaPlaces))
+		charged = charged.Add(sellFees)
+		credited = credited.Add(credit)
+		if !credit.IsPositive() {
+			continue
+		}
+
+		// Withdraw part of the credit, as a user typing an amount would.
+		amount := decimal.New(rng.Int63n(credit.Shift(common.NairaPlaces).IntPart())+1, -common.NairaPlaces)
+		withdrawalFee, err := withdrawal.WithdrawalFee(user, amount)
+		if err != nil {
+			t.Fatalf("WithdrawalFee() error = %v", err)
+		}
+		if !isKobo(withdrawalFee.Amount) {
+			t.Fatalf("withdrawing %s charges %s, not whole kobo", amount, withdrawalFee.Amount)
+		}
+		if withdrawalFee.Amount.GreaterThanOrEqual(amount) {
+			continue
+		}
+		credited = credited.Sub(amount)
+		charged = charged.Add(withdrawalFee.Amount)
+		sent = sent.Add(amount.Sub(withdrawalFee.Amount))
+	}
+
+	if total := credited.Add(charged).Add(sent); !total.Equal(gross) {
+		t.Errorf("credited %s, charged %s and sent %s add up to %s, want %s", credited, charged, sent, total, gross)
+	}
+}
@@ -8,64 +8,160 @@ import (
 	"github.com/ShowBaba/kagewallet/common"
 	"github.com/ShowBaba/kagewallet/database"
 	"github.com/ShowBaba/kagewallet/helpers"
+	log "github.com/ShowBaba/kagewallet/logging"
 	"github.com/ShowBaba/kagewallet/repositories"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
 )
 
 type WithdrawalService struct {
-	MonnifyService *MonnifyService
-	WithdrawalRepo *repositories.WithdrawalRepository
-	WalletRepo     *repositories.WalletRepository
+	Payouts         *PayoutRouter
+	WithdrawalRepo  *repositories.WithdrawalRepository
+	WalletRepo      *repositories.WalletRepository
+	TransactionRepo *repositories.TransactionRepository
+	UserRepo        *repositories.UserRepository
+	BeneficiaryRepo *repositories.BeneficiaryRepository
+	FeeService      *FeeService
+	AccountService  *AccountService
 }
 
-func NewWithdrawalService(monnifyService *MonnifyService, withdrawalRepo *repositories.WithdrawalRepository,
-	walletRepo *repositories.WalletRepository) *WithdrawalService {
-	return &WithdrawalService{monnifyService,
+var (
+	ErrWithdrawalCooldown = errors.New("withdrawals are paused after a password reset")
+	ErrBalanceBelowFee    = errors.New("balance does not cover the withdrawal fee")
+	ErrBalanceChanged     = errors.New("balance changed since it was confirmed")
+)
+
+func NewWithdrawalService(payouts *PayoutRouter, withdrawalRepo *repositories.WithdrawalRepository,
+	walletRepo *repositories.WalletRepository, transactionRepo *repositories.TransactionRepository,
+	userRepo *repositories.UserRepository, beneficiaryRepo *repositories.BeneficiaryRepository,
+	feeService *FeeService, accountService *AccountService) *WithdrawalService {
+	return &WithdrawalService{payouts,
 		withdrawalRepo,
-		walletRepo}
+		walletRepo,
+		transactionRepo,
+		userRepo,
+		beneficiaryRepo,
+		feeService,
+		accountService}
+}
+
+// WithdrawalCooldownUntil reports whether a recent password reset still keeps
+// the user from withdrawing, and until when.
+func WithdrawalCooldownUntil(user *database.User) (time.Time, bool) {
+	if user.PasswordResetAt == nil {
+		return time.Time{}, false
+	}
+	until := user.PasswordResetAt.Add(PasswordResetCooldown())
+	return until, time.Now().Before(until)
 }
 
 func (w *WithdrawalService) GetBanks(page, limit int) (paginatedBanks []Bank, totalPages int, err error) {
-	return w.MonnifyService.GetBanks(page, limit)
+	return w.Payouts.GetBanks(page, limit)
 }
 
 func (w *WithdrawalService) GetBankByCode(code string) (bank Bank, err error) {
-	return w.MonnifyService.GetBankByCode(code)
+	return w.Payouts.GetBankByCode(code)
 }
 
 func (w *WithdrawalService) SearchBank(query string, page int, limit int) ([]Bank, int, error) {
-	return w.MonnifyService.SearchBank(query, page, limit)
+	return w.Payouts.SearchBank(query, page, limit)
 }
 
 func (w *WithdrawalService) ValidateBankAccount(accountNumber, bankCode string) (*AccountDetails, error) {
-	return w.MonnifyService.ValidateBankAccount(accountNumber, bankCode)
+	return w.Payouts.ValidateBankAccount(accountNumber, bankCode)
 }
 
-func (w *WithdrawalService) InitiateTransfer(accountNumber, bankCode, userId string, amount float64) error {
-	amountDec := decimal.NewFromFloat(amount)
-	withdrawalFeeDec := decimal.NewFromFloat(common.WithdrawalFee)
-	finalAmount := amountDec.Sub(withdrawalFeeDec)
+// WithdrawalFee prices withdrawing amount, fee included, for the user's tier.
+func (w *WithdrawalService) WithdrawalFee(user *database.User, amount decimal.Decimal) (Fee, error) {
+	return w.FeeService.QuoteNaira(common.FeeOperationWithdrawal, uuid.MustParse(common.NairaAssetID), user.Tier, amount)
+}
+
+// WithdrawableBalance returns the user's Naira balance, the fee for
+// withdrawing all of it and whether it is large enough to pay that fee and
+// still send something.
+func (w *WithdrawalService) WithdrawableBalance(userId string) (decimal.Decimal, Fee, bool, error) {
 	wallet, err := w.WalletRepo.GetWalletsByUser(uuid.MustParse(userId))
 	if err != nil {
-		return err
+		return decimal.Zero, Fee{}, false, fmt.Errorf("error fetching wallet: %v", err)
 	}
-	if wallet.Balance < amount {
-		return errors.New("insufficient wallet balance")
+	user, err := w.UserRepo.FindOneByID(userId)
+	if err != nil {
+		return decimal.Zero, Fee{}, false, fmt.Errorf("error fetching user: %v", err)
+	}
+	fee, err := w.WithdrawalFee(user, wallet.Balance)
+	if err != nil {
+		return decimal.Zero, Fee{}, false, err
 	}
-	wallet.Balance -= amount
-	wallet.UpdatedAt = time.Now()
+	return wallet.Balance, fee, wallet.Balance.GreaterThan(fee.Amount), nil
+}
 
-	sourceRef, response, err := w.MonnifyService.InitiateTransfer(finalAmount, bankCode, accountNumber)
+// WithdrawAll sends the user's whole Naira balance, less the withdrawal fee,
+// to the given account. confirmed is the balance the user was shown and
+// agreed to; if anything was credited or spent since, nothing is sent and
+// ErrBalanceChanged is returned so they can be asked again.
+func (w *WithdrawalService) WithdrawAll(accountNumber, bankCode, userId string, confirmed decimal.Decimal) (*database.Withdrawal, error) {
+	balance, _, ok, err := w.WithdrawableBalance(userId)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrBalanceBelowFee
+	}
+	if !balance.Equal(confirmed) {
+		return nil, ErrBalanceChanged
 	}
-	ref := helpers.GenerateTransactionReference()
-	hash, err := helpers.GenerateRandomHash(ref)
+	return w.InitiateTransfer(accountNumber, bankCode, userId, balance)
+}
+
+// InitiateTransfer reserves the amount and fee in the user's wallet before
+// any payout provider is called. The fee is priced on the amount from the
+// user's active withdrawal schedule and taken out of it. A withdrawal over
+// one of the user's limits, or flagged by a risk rule, is held without a
+// transfer and returned with the awaiting_approval status.
+func (w *WithdrawalService) InitiateTransfer(accountNumber, bankCode, userId string, amount decimal.Decimal) (*database.Withdrawal, error) {
+	user, err := w.UserRepo.FindOneByID(userId)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("error fetching user: %v", err)
+	}
+	withdrawalFee, err := w.WithdrawalFee(user, amount)
+	if err != nil {
+		return nil, err
+	}
+	finalAmount := amount.Sub(withdrawalFee.Amount)
+	if !finalAmount.IsPositive() {
+		return nil, errors.New("withdrawal amount must be greater than the fee")
+	}
+	if err := w.AccountService.Guard(user, "withdraw"); err != nil {
+		return nil, err
+	}
+	if until, paused := WithdrawalCooldownUntil(user); paused {
+		return nil, fmt.Errorf("%w until %s", ErrWithdrawalCooldown, until.Format(time.RFC3339))
+	}
+	// A saved beneficiary cannot be paid during its cooling-off period, even
+	// when its account number is typed in rather than picked.
+	beneficiary, err := w.BeneficiaryRepo.FindByAccount(user.ID, bankCode, accountNumber)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching beneficiary: %v", err)
+	}
+	if beneficiary != nil && time.Now().Before(beneficiary.ActiveFrom) {
+		return nil, fmt.Errorf("%w until %s", ErrBeneficiaryCoolingOff, beneficiary.ActiveFrom.Format(time.RFC3339))
+	}
+	limit, err := w.getWithdrawalLimit(user)
+	if err != nil {
+		return nil, err
+	}
+
+	bank, err := w.GetBankByCode(bankCode)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceRef := helpers.GenerateTransactionReference()
+	hash, err := helpers.GenerateRandomHash(sourceRef)
+	if err != nil {
+		return nil, err
 	}
-	fmt.Println("monnify initiate withdrawal response; ", response)
 	transaction := database.Transaction{
 		UserID:          uuid.MustParse(userId),
 		AssetID:         uuid.MustParse(common.NairaAssetID),
@@ -77,13 +173,8 @@ func (w *WithdrawalService) InitiateTransfer(accountNumber, bankCode, userId str
 		Hash:            hash,
 		RateID:          uuid.Nil,
 		Confirmations:   0,
-		AmountUSD:       0,
-		Source:          "Monnify",
-	}
-
-	bank, err := w.GetBankByCode(bankCode)
-	if err != nil {
-		return err
+		AmountUSD:       decimal.Zero,
+		Fees:            FeeRecords(withdrawalFee),
 	}
 	withdrawal := database.Withdrawal{
 		AccountNumber: accountNumber,
@@ -92,7 +183,137 @@ func (w *WithdrawalService) InitiateTransfer(accountNumber, bankCode, userId str
 		UserID:        uuid.MustParse(userId),
 		Status:        "pending",
 		Amount:        finalAmount,
-		Fee:           common.WithdrawalFee,
+		Fee:           withdrawalFee.Amount,
+	}
+	reason, err := w.riskReason(user.ID, amount, bankCode, accountNumber)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		withdrawal.Status = common.WithdrawalStatusAwaitingApproval
+		withdrawal.ReviewReason = reason
+	}
+	if err := w.WithdrawalRepo.HoldFundsForWithdrawal(&transaction, &withdrawal, limit); err != nil {
+		return nil, err
+	}
+	if withdrawal.Status == common.WithdrawalStatusAwaitingApproval {
+		log.Warn("withdrawal held for approval",
+			zap.String("withdrawal_id", withdrawal.ID.String()),
+			zap.String("user_id", userId),
+			zap.String("reason", withdrawal.ReviewReason))
+		return &withdrawal, nil
+	}
+
+	if err := w.sendTransfer(transaction.ID, sourceRef, &withdrawal); err != nil {
+		return nil, err
+	}
+	return &withdrawal, nil
+}
+
+// sendTransfer pays out a held, pending withdrawal through the payout router,
+// which records on the withdrawal each provider it tries. The hold is released
+// straight away if no provider accepts the transfer; any other failure leaves
+// the withdrawal pending for the reconciler, which can look the transfer up by
+// its pre-generated reference at the recorded provider.
+func (w *WithdrawalService) sendTransfer(transactionID uuid.UUID, sourceRef string, withdrawal *database.Withdrawal) error {
+	provider, err := w.Payouts.Transfer(sourceRef, withdrawal.Amount, withdrawal.BankCode, withdrawal.AccountNumber, func(provider string) error {
+		withdrawal.Provider = provider
+		return w.WithdrawalRepo.AssignPayoutProvider(withdrawal.ID, transactionID, provider)
+	})
+	if err != nil {
+		if errors.Is(err, ErrTransferRejected) {
+			if _, releaseErr := w.WithdrawalRepo.FailWithdrawal(transactionID, "pending"); releaseErr != nil {
+				log.Error("failed to release withdrawal hold", zap.String("transaction_id", transactionID.String()), zap.Error(releaseErr))
+			}
+			return err
+		}
+		log.Error("withdrawal outcome unknown, leaving it for the reconciler",
+			zap.String("reference", sourceRef), zap.String("provider", provider), zap.Error(err))
+		return nil
+	}
+	log.Info("withdrawal sent", zap.String("reference", sourceRef), zap.String("provider", provider))
+	return nil
+}
+
+func (w *WithdrawalService) CompleteWithdrawal(transaction *database.Transaction) error {
+	settled, err := w.WithdrawalRepo.CompleteWithdrawal(transaction.ID)
+	if err != nil {
+		return fmt.Errorf("error completing withdrawal: %v", err)
+	}
+	if !settled {
+		return nil
+	}
+
+	message := fmt.Sprintf(
+		"🎉 Success! Your withdrawal of *₦%v* has been processed. 🚀\n\n"+
+			"💰 Expect to receive your funds shortly. If you have any concerns, feel free to reach out to our support team. 🛠️",
+		transaction.Amount,
+	)
+	if err := sendNotification(message, transaction.UserID.String(), transaction.Hash, "telegram"); err != nil {
+		log.Error("failed to publish notification: %v", zap.Error(err))
+	}
+	return nil
+}
+
+// FailWithdrawal refunds a withdrawal that the provider failed or reversed. A
+// reversal can arrive after we already marked the transfer completed.
+func (w *WithdrawalService) FailWithdrawal(transaction *database.Transaction, reversed bool) error {
+	fromStatuses := []string{"pending"}
+	if reversed {
+		fromStatuses = append(fromStatuses, "completed")
+	}
+	settled, err := w.WithdrawalRepo.FailWithdrawal(transaction.ID, fromStatuses...)
+	if err != nil {
+		return fmt.Errorf("error failing withdrawal: %v", err)
+	}
+	if !settled {
+		return nil
+	}
+
+	// TODO: notify admin
+	message := fmt.Sprintf(
+		"⚠️ Oops! Your withdrawal of *₦%v* could not be processed. 😞\n\n"+
+			"💰 The amount has been returned to your wallet.\n\n"+
+			"🔄 You can try again later or reach out to our support team for assistance. We're here to help! 🛠️",
+		transaction.Amount,
+	)
+	if err := sendNotification(message, transaction.UserID.String(), transaction.Hash, "telegram"); err != nil {
+		log.Error("failed to publish notification: %v", zap.Error(err))
+	}
+	return nil
+}
+
+// ReconcileWithdrawal asks the provider holding a pending withdrawal for its
+// final state and settles it. It returns the status it saw.
+func (w *WithdrawalService) ReconcileWithdrawal(withdrawal database.Withdrawal) (string, error) {
+	transaction, err := w.TransactionRepo.GetTransactionByID(withdrawal.TransactionID.String())
+	if err != nil {
+		return "", fmt.Errorf("error fetching transaction: %v", err)
+	}
+	if transaction.SourceReference == "" {
+		return "", errors.New("withdrawal has no source reference")
+	}
+	// A provider is recorded before it is called, so without one the
+	// transfer was never sent.
+	if withdrawal.Provider == "" {
+		return "NOT_SENT", w.FailWithdrawal(transaction, false)
+	}
+
+	status, err := w.Payouts.GetTransferStatus(withdrawal.Provider, transaction.SourceReference)
+	if errors.Is(err, ErrTransferNotFound) {
+		// The transfer never reached the provider, so the hold can go back.
+		return "NOT_FOUND", w.FailWithdrawal(transaction, false)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	switch status {
+	case common.PayoutStatusSuccess:
+		return status, w.CompleteWithdrawal(transaction)
+	case common.PayoutStatusFailed, common.PayoutStatusReversed:
+		return status, w.FailWithdrawal(transaction, status == common.PayoutStatusReversed)
+	default:
+		return status, nil
 	}
-	return w.WithdrawalRepo.CreateTransactionAndWithdrawal(wallet, &transaction, &withdrawal)
 }
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/ShowBaba/kagewallet/helpers"
+	log "github.com/ShowBaba/kagewallet/logging"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrWithdrawalNotAwaitingApproval = errors.New("withdrawal is not awaiting approval")
+	ErrWithdrawalAlreadyReviewed     = errors.New("withdrawal already reviewed by this admin")
+)
+
+// WithdrawalWithReviews is a withdrawal together with the admin decisions
+// recorded on it.
+type WithdrawalWithReviews struct {
+	database.Withdrawal
+	Reviews []database.WithdrawalReview `json:"reviews"`
+}
+
+// WithdrawalApprovalsRequired is how many different admins have to approve a
+// held withdrawal before it is sent. A single rejection is always enough to
+// refund it.
+func WithdrawalApprovalsRequired() int {
+	return helpers.IntFromEnv("WITHDRAWAL_APPROVALS_REQUIRED", 1)
+}
+
+// riskReason applies the rules that hold a withdrawal for approval whatever
+// the user's limits: a gross amount of at least WITHDRAWAL_APPROVAL_THRESHOLD,
+// or at least WITHDRAWAL_NEW_PAYEE_REVIEW_AMOUNT to an account the user has
+// never been paid to. Both rules are off when unset.
+func (w *WithdrawalService) riskReason(userID uuid.UUID, total decimal.Decimal, bankCode, accountNumber string) (string, error) {
+	if threshold := decimalFromEnv("WITHDRAWAL_APPROVAL_THRESHOLD"); threshold.IsPositive() && total.GreaterThanOrEqual(threshold) {
+		return common.WithdrawalReviewThreshold, nil
+	}
+	if newPayee := decimalFromEnv("WITHDRAWAL_NEW_PAYEE_REVIEW_AMOUNT"); newPayee.IsPositive() && total.GreaterThanOrEqual(newPayee) {
+		paidBefore, err := w.WithdrawalRepo.HasCompletedWithdrawalTo(userID, bankCode, accountNumber)
+		if err != nil {
+			return "", fmt.Errorf("error fetching withdrawals: %v", err)
+		}
+		if !paidBefore {
+			return common.WithdrawalReviewNewPayee, nil
+		}
+	}
+	return "", nil
+}
+
+func (w *WithdrawalService) ListWithdrawals(status string, limit, offset int) ([]database.Withdrawal, error) {
+	return w.WithdrawalRepo.ListWithdrawalsByStatus(status, limit, offset)
+}
+
+func (w *WithdrawalService) GetWithdrawalWithReviews(withdrawalID uuid.UUID) (*WithdrawalWithReviews, error) {
+	withdrawal, err := w.WithdrawalRepo.GetWithdrawalByID(withdrawalID)
+	if err != nil {
+		return nil, err
+	}
+	reviews, err := w.WithdrawalRepo.ListReviews(withdrawalID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching reviews: %v", err)
+	}
+	return &WithdrawalWithReviews{Withdrawal: *withdrawal, Reviews: reviews}, nil
+}
+
+// ApproveWithdrawal records an admin's approval of a held withdrawal. Once
+// WITHDRAWAL_APPROVALS_REQUIRED admins have approved it, the withdrawal goes
+// back to pending and is sent to a payout provider. It returns the withdrawal
+// and how many approvals are still missing.
+func (w *WithdrawalService) ApproveWithdrawal(withdrawalID uuid.UUID, admin, reason string) (*database.Withdrawal, int, error) {
+	withdrawal, err := w.reviewWithdrawal(withdrawalID, admin, common.WithdrawalDecisionApprove, reason)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	reviews, err := w.WithdrawalRepo.ListReviews(withdrawalID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error fetching reviews: %v", err)
+	}
+	approvals := 0
+	for _, review := range reviews {
+		if review.Decision == common.WithdrawalDecisionApprove {
+			approvals++
+		}
+	}
+	if missing := WithdrawalApprovalsRequired() - approvals; missing > 0 {
+		return withdrawal, missing, nil
+	}
+
+	released, err := w.WithdrawalRepo.ReleaseForTransfer(withdrawalID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error releasing withdrawal: %v", err)
+	}
+	if !released {
+		return nil, 0, ErrWithdrawalNotAwaitingApproval
+	}
+	withdrawal.Status = "pending"
+
+	transaction, err := w.TransactionRepo.GetTransactionByID(withdrawal.TransactionID.String())
+	if err != nil {
+		return nil, 0, fmt.Errorf("error fetching transaction: %v", err)
+	}
+	if err := w.sendTransfer(transaction.ID, transaction.SourceReference, withdrawal); err != nil {
+		withdrawal.Status = "failed"
+		w.notifyReviewedWithdrawal(transaction, "failed")
+		return withdrawal, 0, fmt.Errorf("error sending approved withdrawal: %w", err)
+	}
+	w.notifyReviewedWithdrawal(transaction, common.WithdrawalDecisionApprove)
+	return withdrawal, 0, nil
+}
+
+// RejectWithdrawal records an admin's rejection of a held withdrawal, fails it
+// and gives the amount and fee back to the user's wallet.
+func (w *WithdrawalService) RejectWithdrawal(withdrawalID uuid.UUID, admin, reason string) (*database.Withdrawal, error) {
+	withdrawal, err := w.reviewWithdrawal(withdrawalID, admin, common.WithdrawalDecisionReject, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	rejected, err := w.WithdrawalRepo.RejectWithdrawal(withdrawalID)
+	if err != nil {
+		return nil, fmt.Errorf("error rejecting withdrawal: %v", err)
+	}
+	if !rejected {
+		return nil, ErrWithdrawalNotAwaitingApproval
+	}
+	withdrawal.Status = "failed"
+
+	transaction, err := w.TransactionRepo.GetTransactionByID(withdrawal.TransactionID.String())
+	if err != nil {
+		log.Error("error fetching transaction of rejected withdrawal", zap.String("withdrawal_id", withdrawalID.String()), zap.Error(err))
+		return withdrawal, nil
+	}
+	w.notifyReviewedWithdrawal(transaction, common.WithdrawalDecisionReject)
+	return withdrawal, nil
+}
+
+// reviewWithdrawal checks a withdrawal is awaiting approval and records the
+// admin's decision on it.
+func (w *WithdrawalService) reviewWithdrawal(withdrawalID uuid.UUID, admin, decision, reason string) (*database.Withdrawal, error) {
+	withdrawal, err := w.WithdrawalRepo.GetWithdrawalByID(withdrawalID)
+	if err != nil {
+		return nil, err
+	}
+	if withdrawal.Status != common.WithdrawalStatusAwaitingApproval {
+		return nil, ErrWithdrawalNotAwaitingApproval
+	}
+
+	reviews, err := w.WithdrawalRepo.ListReviews(withdrawalID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching reviews: %v", err)
+	}
+	for _, review := range reviews {
+		if review.Admin == admin {
+			return nil, ErrWithdrawalAlreadyReviewed
+		}
+	}
+
+	err = w.WithdrawalRepo.CreateReview(&database.WithdrawalReview{
+		WithdrawalID: withdrawalID,
+		Admin:        admin,
+		Decision:     decision,
+		Reason:       strings.TrimSpace(reason),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error recording review: %v", err)
+	}
+	log.Info("withdrawal reviewed",
+		zap.String("withdrawal_id", withdrawalID.String()),
+		zap.String("admin", admin),
+		zap.String("decision", decision))
+	return withdrawal, nil
+}
+
+// notifyReviewedWithdrawal tells the user a held withdrawal was approved,
+// rejected, or approved but then refused by every payout provider ("failed").
+func (w *WithdrawalService) notifyReviewedWithdrawal(transaction *database.Transaction, outcome string) {
+	var message string
+	switch outcome {
+	case common.WithdrawalDecisionApprove:
+		message = fmt.Sprintf(
+			"✅ Your withdrawal of *₦%v* has been approved and is on its way. 🚀\n\n"+
+				"📩 We'll notify you once the transaction is processed.",
+			transaction.Amount,
+		)
+	case common.WithdrawalDecisionReject:
+		message = fmt.Sprintf(
+			"⚠️ Your withdrawal of *₦%v* was not approved.\n\n"+
+				"💰 The amount and fee have been returned to your wallet.\n\n"+
+				"🔄 Please reach out to our support team if you have any questions. 🛠️",
+			transaction.Amount,
+		)
+	default:
+		message = fmt.Sprintf(
+			"⚠️ Oops! Your withdrawal of *₦%v* was approved but could not be processed. 😞\n\n"+
+				"💰 The amount has been returned to your wallet.\n\n"+
+				"🔄 You can try again later or reach out to our support team for assistance. 🛠️",
+			transaction.Amount,
+		)
+	}
+	// The hash of a transaction keys its delivery status, so review notices
+	// get their own key and do not hold back the completion notice.
+	if err := sendNotification(message, transaction.UserID.String(), transaction.Hash+":"+outcome, "telegram"); err != nil {
+		log.Error("failed to publish notification: %v", zap.Error(err))
+	}
+}
+
+func decimalFromEnv(key string) decimal.Decimal {
+	value, err := decimal.NewFromString(strings.TrimSpace(os.Getenv(key)))
+	if err != nil {
+		return decimal.Zero
+	}
+	return value
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/shopspring/decimal"
+)
+
+// WithdrawalLimitStatus is a user's withdrawal limits next to what they have
+// used of them.
+type WithdrawalLimitStatus struct {
+	Tier   string
+	Limit  database.WithdrawalLimit
+	Daily  database.WithdrawalUsage
+	Weekly database.WithdrawalUsage
+	Hourly database.WithdrawalUsage
+}
+
+// DailyRemaining returns what the user can still withdraw in the current
+// 24 hours without approval. ok is false when there is no daily limit.
+func (s *WithdrawalLimitStatus) DailyRemaining() (decimal.Decimal, bool) {
+	return remainingLimit(s.Limit.Daily, s.Daily.Total)
+}
+
+func (s *WithdrawalLimitStatus) WeeklyRemaining() (decimal.Decimal, bool) {
+	return remainingLimit(s.Limit.Weekly, s.Weekly.Total)
+}
+
+func (s *WithdrawalLimitStatus) HourlyRemaining() (int64, bool) {
+	if s.Limit.HourlyCount <= 0 {
+		return 0, false
+	}
+	remaining := int64(s.Limit.HourlyCount) - s.Hourly.Count
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, true
+}
+
+func remainingLimit(limit, used decimal.Decimal) (decimal.Decimal, bool) {
+	if !limit.IsPositive() {
+		return decimal.Zero, false
+	}
+	remaining := limit.Sub(used)
+	if remaining.IsNegative() {
+		return decimal.Zero, true
+	}
+	return remaining, true
+}
+
+// UserTier returns the user's tier, treating users created before tiers
+// existed as basic.
+func UserTier(user *database.User) string {
+	if user.Tier == "" {
+		return common.UserTierBasic
+	}
+	return user.Tier
+}
+
+// getWithdrawalLimit returns the limits of the user's tier. A tier without a
+// withdrawal_limit row is a configuration error, so withdrawals stop rather
+// than go out unchecked.
+func (w *WithdrawalService) getWithdrawalLimit(user *database.User) (*database.WithdrawalLimit, error) {
+	tier := UserTier(user)
+	limit, err := w.WithdrawalRepo.GetWithdrawalLimit(tier)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching withdrawal limit: %v", err)
+	}
+	if limit == nil {
+		return nil, fmt.Errorf("no withdrawal limits configured for tier %q", tier)
+	}
+	return limit, nil
+}
+
+func (w *WithdrawalService) GetWithdrawalLimitStatus(user *database.User) (*WithdrawalLimitStatus, error) {
+	limit, err := w.getWithdrawalLimit(user)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &WithdrawalLimitStatus{Tier: UserTier(user), Limit: *limit}
+	now := time.Now()
+	if status.Daily, err = w.WithdrawalRepo.GetWithdrawalUsage(user.ID, now.Add(-24*time.Hour)); err != nil {
+		return nil, err
+	}
+	if status.Weekly, err = w.WithdrawalRepo.GetWithdrawalUsage(user.ID, now.Add(-7*24*time.Hour)); err != nil {
+		return nil, err
+	}
+	if status.Hourly, err = w.WithdrawalRepo.GetWithdrawalUsage(user.ID, now.Add(-time.Hour)); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/ShowBaba/kagewallet/common"
+	"github.com/ShowBaba/kagewallet/database"
+	"github.com/ShowBaba/kagewallet/migrations"
+	"github.com/ShowBaba/kagewallet/repositories"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// testDB connects to TEST_DATABASE_URL and migrates it. Tests that need
+// Postgres are skipped when it is not set.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		NamingStrategy:         schema.NamingStrategy{SingularTable: true},
+		SkipDefaultTransaction: true,
+		Logger:                 logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("error connecting to test database: %v", err)
+	}
+	if _, err := migrations.Up(db); err != nil {
+		t.Fatalf("error migrating test database: %v", err)
+	}
+	return db
+}
+
+// fakeStatus answers status lookups with one Monnify status, or 404 when it
+// is empty.
+func fakeStatus(status string) func(string) (int, string) {
+	return func(string) (int, string) {
+		if status == "" {
+			return http.StatusNotFound, ""
+		}
+		return http.StatusOK, status
+	}
+}
+
+func TestMonnifyGetTransferStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		monnify string
+		want    string
+		wantErr error
+	}{
+		{"success", "SUCCESS", common.PayoutStatusSuccess, nil},
+		{"failed", "FAILED", common.PayoutStatusFailed, nil},
+		{"expired", "EXPIRED", common.PayoutStatusFailed, nil},
+		{"reversed", "REVERSED", common.PayoutStatusReversed, nil},
+		{"pending", "PENDING", common.PayoutStatusPending, nil},
+		{"in progress", "IN_PROGRESS", common.PayoutStatusPending, nil},
+		{"not found", "", "", ErrTransferNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeMonnify(t)
+			fake.status = fakeStatus(tt.monnify)
+			router := testRouter(fake.client(testTimeout))
+
+			got, err := router.GetTransferStatus(common.PayoutProviderMonnify, "ref-1")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("status = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestReconcileWithdrawal settles a held withdrawal from each status Monnify
+// can report. Reconciling runs twice, as a later job run or a late webhook
+// would, and the hold must be settled or released exactly once.
+func TestReconcileWithdrawal(t *testing.T) {
+	db := testDB(t)
+	var (
+		balance = decimal.NewFromInt(1000)
+		amount  = decimal.NewFromInt(490)
+		fee     = decimal.NewFromInt(10)
+	)
+	afterHold := balance.Sub(amount).Sub(fee)
+	tests := []struct {
+		name        string
+		monnify     string
+		wantStatus  string
+		wantTxn     string
+		wantBalance decimal.Decimal
+		// wantEntries counts the hold's two entries plus the settlement's.
+		wantEntries int
+	}{
+		{"success", "SUCCESS", common.PayoutStatusSuccess, "completed", afterHold, 5},
+		{"failed", "FAILED", common.PayoutStatusFailed, "failed", balance, 4},
+		{"expired", "EXPIRED", common.PayoutStatusFailed, "failed", balance, 4},
+		{"reversed", "REVERSED", common.PayoutStatusReversed, "failed", balance, 4},
+		{"pending", "PENDING", common.PayoutStatusPending, "pending", afterHold, 2},
+		{"not found", "", "NOT_FOUND", "failed", balance, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeMonnify(t)
+			fake.status = fakeStatus(tt.monnify)
+			var (
+				withdrawalRepo  = repositories.NewWithdrawalRepository(db)
+				transactionRepo = repositories.NewTransactionRepository(db)
+				ledgerRepo      = repositories.NewLedgerRepository(db)
+				service         = NewWithdrawalService(testRouter(fake.client(testTimeout)), withdrawalRepo,
+					repositories.NewWalletRepository(db), transactionRepo, repositories.NewUserRepository(db), nil, nil, nil)
+			)
+
+			user := &database.User{}
+			if err := db.Create(user).Error; err != nil {
+				t.Fatalf("error creating user: %v", err)
+			}
+			wallet := &database.Wallet{UserID: user.ID, AssetID: uuid.MustParse(common.NairaAssetID), Balance: balance}
+			if err := db.Create(wallet).Error; err != nil {
+				t.Fatalf("error creating wallet: %v", err)
+			}
+			if _, err := ledgerRepo.OpenWalletBalances(); err != nil {
+				t.Fatalf("error funding wallet: %v", err)
+			}
+
+			transaction := &database.Transaction{
+				UserID:          user.ID,
+				AssetID:         wallet.AssetID,
+				Type:            "withdrawal",
+				Amount:          amount,
+				Status:          "pending",
+				Reference:       uuid.NewString(),
+				SourceReference: uuid.NewString(),
+			}
+			withdrawal := &database.Withdrawal{UserID: user.ID, Status: "pending", Amount: amount, Fee: fee, BankCode: testBankCode}
+			if err := withdrawalRepo.HoldFundsForWithdrawal(transaction, withdrawal, nil); err != nil {
+				t.Fatalf("error holding funds: %v", err)
+			}
+			if err := withdrawalRepo.AssignPayoutProvider(withdrawal.ID, transaction.ID, common.PayoutProviderMonnify); err != nil {
+				t.Fatalf("error assigning provider: %v", err)
+			}
+			withdrawal.Provider = common.PayoutProviderMonnify
+
+			for i := 0; i < 2; i++ {
+				status, err := service.ReconcileWithdrawal(*withdrawal)
+				if err != nil {
+					t.Fatalf("reconcile %d: %v", i+1, err)
+				}
+				if status != tt.wantStatus {
+					t.Errorf("reconcile %d: status = %q, want %q", i+1, status, tt.wantStatus)
+				}
+			}
+			if got := fake.lookups.Load(); got != 2 {
+				t.Errorf("status looked up %d times, want 2", got)
+			}
+
+			got, err := transactionRepo.GetTransactionByID(transaction.ID.String())
+			if err != nil {
+				t.Fatalf("error fetching transaction: %v", err)
+			}
+			if got.Status != tt.wantTxn {
+				t.Errorf("transaction status = %q, want %q", got.Status, tt.wantTxn)
+			}
+			var gotWallet database.Wallet
+			if err := db.Where("id = ?", wallet.ID).First(&gotWallet).Error; err != nil {
+				t.Fatalf("error fetching wallet: %v", err)
+			}
+			if !gotWallet.Balance.Equal(tt.wantBalance) {
+				t.Errorf("wallet balance = %s, want %s", gotWallet.Balance, tt.wantBalance)
+			}
+			entries, err := ledgerRepo.GetEntriesByTransaction(transaction.ID)
+			if err != nil {
+				t.Fatalf("error fetching ledger entries: %v", err)
+			}
+			if len(entries) != tt.wantEntries {
+				t.Errorf("%d ledger entries, want %d", len(entries), tt.wantEntries)
+			}
+		})
+	}
+}
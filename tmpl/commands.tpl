@@ -10,15 +10,19 @@
 
 <b>Wallet and Transaction Management:</b>
 - /sell: Sell cryptocurrency.
-- /balance: View wallets balances.
+- /balance: View your balance in every asset and its Naira value.
 - /withdraw: Request a withdrawal
 - /withdraw_all: Withdraw all funds to your bank account.
+- /beneficiaries: Save or remove the bank accounts you withdraw to.
+- /limits: See how much you can still withdraw.
 - /convert: Convert a specified amount from one cryptocurrency to another.
-- /transactions: View your transaction history, including deposits and withdrawals.
+- /auto_convert: Choose whether deposits are sold for Naira or kept as crypto.
+- /transactions: View your transaction history and open any transaction for its details and receipt.
 
 <b>Account Security:</b>
 - /lock_account: Temporarily lock your account for security reasons.
 - /unlock_account: Unlock your account by verifying your password.
 
+<b>Use the /cancel command to stop the operation in progress</b>
 <b>Use the /refresh command to refresh the chat anytime</b>
 <b>Use the /commands command to get lists of available commands</b>